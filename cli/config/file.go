@@ -4,6 +4,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 
 	"github.com/kirsle/configdir"
 	"golang.org/x/xerrors"
@@ -11,8 +13,24 @@ import (
 
 const (
 	FlagName = "global-config"
+
+	// DefaultProfile is the name of the profile whose files are stored in
+	// the root of the configuration directory, i.e. the one used when no
+	// profile is selected.
+	DefaultProfile = "default"
 )
 
+var profileNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// ValidateProfileName returns an error if name can't be used as the name of a
+// profile, e.g. because it would escape the profiles directory.
+func ValidateProfileName(name string) error {
+	if !profileNameRegex.MatchString(name) {
+		return xerrors.Errorf("invalid profile name %q: must start with a letter or digit and only contain letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
 // Root represents the configuration directory.
 type Root string
 
@@ -30,6 +48,50 @@ func (r Root) Session() File {
 	return File(filepath.Join(string(r), "session"))
 }
 
+// Profile returns the configuration directory of the named profile, so that
+// users can stay logged in to several deployments at once.  The
+// DefaultProfile, or an empty name, is the root itself.
+func (r Root) Profile(name string) Root {
+	r.mustNotEmpty()
+	if name == "" || name == DefaultProfile {
+		return r
+	}
+	return Root(filepath.Join(string(r), "profiles", name))
+}
+
+// Profiles returns the names of the profiles that have a URL configured,
+// sorted by name.
+func (r Root) Profiles() ([]string, error) {
+	r.mustNotEmpty()
+	var names []string
+	_, err := os.Stat(string(r.URL()))
+	if err == nil {
+		names = append(names, DefaultProfile)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(string(r), "profiles"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || ValidateProfileName(entry.Name()) != nil || entry.Name() == DefaultProfile {
+			continue
+		}
+		_, err := os.Stat(string(r.Profile(entry.Name()).URL()))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
 // ReplicaID is a unique identifier for the Coder server.
 func (r Root) ReplicaID() File {
 	r.mustNotEmpty()
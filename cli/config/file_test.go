@@ -36,3 +36,33 @@ func TestFile(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestProfiles(t *testing.T) {
+	t.Parallel()
+
+	root := config.Root(t.TempDir())
+	require.Equal(t, root, root.Profile(config.DefaultProfile))
+
+	err := root.URL().Write("https://dev.coder.com")
+	require.NoError(t, err)
+	err = root.Profile("prod").URL().Write("https://prod.coder.com")
+	require.NoError(t, err)
+	// Profiles that were never logged in to aren't listed.
+	err = root.Profile("empty").Session().Write("token")
+	require.NoError(t, err)
+
+	prodURL, err := root.Profile("prod").URL().Read()
+	require.NoError(t, err)
+	require.Equal(t, "https://prod.coder.com", prodURL)
+	defaultURL, err := root.URL().Read()
+	require.NoError(t, err)
+	require.Equal(t, "https://dev.coder.com", defaultURL)
+
+	profiles, err := root.Profiles()
+	require.NoError(t, err)
+	require.Equal(t, []string{config.DefaultProfile, "prod"}, profiles)
+
+	require.NoError(t, config.ValidateProfileName("prod-2"))
+	require.Error(t, config.ValidateProfileName("../prod"))
+	require.Error(t, config.ValidateProfileName(""))
+}
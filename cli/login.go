@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -43,9 +44,11 @@ func (r *RootCmd) login() *clibase.Cmd {
 	var (
 		email              string
 		username           string
+		fullName           string
 		password           string
 		trial              bool
 		useTokenForSession bool
+		force              bool
 	)
 	cmd := &clibase.Cmd{
 		Use:        "login <url>",
@@ -127,6 +130,18 @@ func (r *RootCmd) login() *clibase.Cmd {
 					if err != nil {
 						return xerrors.Errorf("pick username prompt: %w", err)
 					}
+
+					if fullName == "" {
+						fullName, err = cliui.Prompt(inv, cliui.PromptOptions{
+							Text: "What's your " + cliui.DefaultStyles.Field.Render("full name") + "? (optional)",
+						})
+						if errors.Is(err, cliui.Canceled) {
+							return nil
+						}
+						if err != nil {
+							return xerrors.Errorf("pick full name prompt: %w", err)
+						}
+					}
 				}
 
 				if email == "" {
@@ -187,6 +202,7 @@ func (r *RootCmd) login() *clibase.Cmd {
 				_, err = client.CreateFirstUser(ctx, codersdk.CreateFirstUserRequest{
 					Email:    email,
 					Username: username,
+					Name:     fullName,
 					Password: password,
 					Trial:    trial,
 				})
@@ -202,7 +218,7 @@ func (r *RootCmd) login() *clibase.Cmd {
 				}
 
 				sessionToken := resp.SessionToken
-				config := r.createConfig()
+				config := r.sessionConfig()
 				err = config.Session().Write(sessionToken)
 				if err != nil {
 					return xerrors.Errorf("write session token: %w", err)
@@ -221,7 +237,29 @@ func (r *RootCmd) login() *clibase.Cmd {
 			}
 
 			sessionToken, _ := inv.ParsedFlags().GetString(varToken)
+			if sessionToken != "" && !inv.ParsedFlags().Changed(varToken) {
+				// The token was read from the environment, e.g. in CI.
+				// Verify it before doing anything with it, so that a bad
+				// token fails the same way it does at the prompt.
+				client.SetSessionToken(sessionToken)
+				_, err := client.User(ctx, codersdk.Me)
+				if err != nil {
+					return xerrors.New("That's not a valid token!")
+				}
+			}
+			if sessionToken == "" && !force {
+				// Don't make the user paste a token if they're already
+				// logged in to this deployment.
+				username, ok := r.existingSession(ctx, client, serverURL)
+				if ok {
+					_, _ = fmt.Fprintf(inv.Stdout, Caret+"Already logged in as %s! Use --force to log in again.\n", cliui.DefaultStyles.Keyword.Render(username))
+					return nil
+				}
+			}
 			if sessionToken == "" {
+				if !isTTY(inv) {
+					return xerrors.Errorf("a session token is required to login in non-interactive mode. set %s or use --%s", envSessionToken, varToken)
+				}
 				authURL := *serverURL
 				// Don't use filepath.Join, we don't want to use the os separator
 				// for a url.
@@ -272,7 +310,7 @@ func (r *RootCmd) login() *clibase.Cmd {
 				return xerrors.Errorf("get user: %w", err)
 			}
 
-			config := r.createConfig()
+			config := r.sessionConfig()
 			err = config.Session().Write(sessionToken)
 			if err != nil {
 				return xerrors.Errorf("write session token: %w", err)
@@ -299,6 +337,12 @@ func (r *RootCmd) login() *clibase.Cmd {
 			Description: "Specifies a username to use if creating the first user for the deployment.",
 			Value:       clibase.StringOf(&username),
 		},
+		{
+			Flag:        "first-user-full-name",
+			Env:         "CODER_FIRST_USER_FULL_NAME",
+			Description: "Specifies a full name to use if creating the first user for the deployment.",
+			Value:       clibase.StringOf(&fullName),
+		},
 		{
 			Flag:        "first-user-password",
 			Env:         "CODER_FIRST_USER_PASSWORD",
@@ -311,6 +355,11 @@ func (r *RootCmd) login() *clibase.Cmd {
 			Description: "Specifies whether a trial license should be provisioned for the Coder deployment or not.",
 			Value:       clibase.BoolOf(&trial),
 		},
+		{
+			Flag:        "force",
+			Description: "Log in even if the stored session is already valid for the deployment.",
+			Value:       clibase.BoolOf(&force),
+		},
 		{
 			Flag:        "use-token-as-session",
 			Description: "By default, the CLI will generate a new session token when logging in. This flag will instead use the provided token as the session token.",
@@ -320,6 +369,47 @@ func (r *RootCmd) login() *clibase.Cmd {
 	return cmd
 }
 
+// existingSession returns the username of the stored session if it is for
+// serverURL and still valid.
+func (r *RootCmd) existingSession(ctx context.Context, client *codersdk.Client, serverURL *url.URL) (string, bool) {
+	config := r.sessionConfig()
+	storedURL, err := config.URL().Read()
+	if err != nil || strings.TrimSpace(storedURL) != serverURL.String() {
+		return "", false
+	}
+	sessionToken, err := config.Session().Read()
+	if err != nil || sessionToken == "" {
+		return "", false
+	}
+	client.SetSessionToken(sessionToken)
+	user, err := client.User(ctx, codersdk.Me)
+	if err != nil {
+		client.SetSessionToken("")
+		return "", false
+	}
+	return user.Username, true
+}
+
+// browserAvailable guesses whether a browser can be opened for the user, so
+// that logging in from a headless machine prints the URL instead of trying to
+// open it.
+func browserAvailable(inv *clibase.Invocation) bool {
+	if inv.Environ.Get("BROWSER") != "" {
+		return true
+	}
+	// A browser opened over SSH would show up on the remote machine, if at all.
+	if inv.Environ.Get("SSH_CONNECTION") != "" || inv.Environ.Get("SSH_TTY") != "" {
+		return false
+	}
+	if runtime.GOOS == goosDarwin || runtime.GOOS == goosWindows {
+		return true
+	}
+	if wsl, err := isWSL(); err == nil && wsl {
+		return true
+	}
+	return inv.Environ.Get("DISPLAY") != "" || inv.Environ.Get("WAYLAND_DISPLAY") != ""
+}
+
 // isWSL determines if coder-cli is running within Windows Subsystem for Linux
 func isWSL() (bool, error) {
 	if runtime.GOOS == goosDarwin || runtime.GOOS == goosWindows {
@@ -341,6 +431,13 @@ func openURL(inv *clibase.Invocation, urlToOpen string) error {
 	if noOpen {
 		return xerrors.New("opening is blocked")
 	}
+	open, err := inv.ParsedFlags().GetBool(varOpen)
+	if err != nil {
+		panic(err)
+	}
+	if !open && !browserAvailable(inv) {
+		return xerrors.New("no browser available")
+	}
 	wsl, err := isWSL()
 	if err != nil {
 		return xerrors.Errorf("test running Windows Subsystem for Linux: %w", err)
@@ -351,7 +448,7 @@ func openURL(inv *clibase.Invocation, urlToOpen string) error {
 		return exec.Command("cmd.exe", "/c", "start", strings.ReplaceAll(urlToOpen, "&", "^&")).Start()
 	}
 
-	browserEnv := os.Getenv("BROWSER")
+	browserEnv := inv.Environ.Get("BROWSER")
 	if browserEnv != "" {
 		browserSh := fmt.Sprintf("%s '%s'", browserEnv, urlToOpen)
 		cmd := exec.CommandContext(inv.Context(), "sh", "-c", browserSh)
@@ -11,7 +11,9 @@ import (
 	"github.com/coder/coder/cli/clitest"
 	"github.com/coder/coder/cli/cliui"
 	"github.com/coder/coder/coderd/coderdtest"
+	"github.com/coder/coder/codersdk"
 	"github.com/coder/coder/pty/ptytest"
+	"github.com/coder/coder/testutil"
 )
 
 func TestLogin(t *testing.T) {
@@ -51,6 +53,7 @@ func TestLogin(t *testing.T) {
 		matches := []string{
 			"first user?", "yes",
 			"username", "testuser",
+			"full name", "Test User",
 			"email", "user@coder.com",
 			"password", "SomeSecurePassword!",
 			"password", "SomeSecurePassword!", // Confirm.
@@ -80,6 +83,7 @@ func TestLogin(t *testing.T) {
 		matches := []string{
 			"first user?", "yes",
 			"username", "testuser",
+			"full name", "Test User",
 			"email", "user@coder.com",
 			"password", "SomeSecurePassword!",
 			"password", "SomeSecurePassword!", // Confirm.
@@ -109,6 +113,23 @@ func TestLogin(t *testing.T) {
 		<-doneChan
 	})
 
+	t.Run("InitialUserFullNameFlag", func(t *testing.T) {
+		t.Parallel()
+		client := coderdtest.New(t, nil)
+		root, cfg := clitest.New(t, "login", client.URL.String(), "--first-user-username", "testuser", "--first-user-full-name", "Test User", "--first-user-email", "user@coder.com", "--first-user-password", "SomeSecurePassword!", "--first-user-trial")
+		pty := ptytest.New(t).Attach(root)
+		clitest.Start(t, root)
+		pty.ExpectMatch("Welcome to Coder")
+
+		sessionToken, err := cfg.Session().Read()
+		require.NoError(t, err)
+		client.SetSessionToken(sessionToken)
+		ctx := testutil.Context(t, testutil.WaitLong)
+		user, err := client.User(ctx, codersdk.Me)
+		require.NoError(t, err)
+		require.Equal(t, "Test User", user.Name)
+	})
+
 	t.Run("InitialUserTTYConfirmPasswordFailAndReprompt", func(t *testing.T) {
 		t.Parallel()
 		ctx, cancel := context.WithCancel(context.Background())
@@ -129,6 +150,7 @@ func TestLogin(t *testing.T) {
 		matches := []string{
 			"first user?", "yes",
 			"username", "testuser",
+			"full name", "Test User",
 			"email", "user@coder.com",
 			"password", "MyFirstSecurePassword!",
 			"password", "MyNonMatchingSecurePassword!", // Confirm.
@@ -173,6 +195,30 @@ func TestLogin(t *testing.T) {
 		<-doneChan
 	})
 
+	t.Run("ExistingUserHeadlessTTY", func(t *testing.T) {
+		t.Parallel()
+		client := coderdtest.New(t, nil)
+		coderdtest.CreateFirstUser(t, client)
+
+		doneChan := make(chan struct{})
+		// No --no-open: the SSH session should be detected as headless.
+		root, _ := clitest.New(t, "login", "--force-tty", client.URL.String())
+		root.Environ.Set("SSH_CONNECTION", "10.0.0.1 52000 10.0.0.2 22")
+		pty := ptytest.New(t).Attach(root)
+		go func() {
+			defer close(doneChan)
+			err := root.Run()
+			assert.NoError(t, err)
+		}()
+
+		pty.ExpectMatch("Open the following in your browser")
+		pty.ExpectMatch("/cli-auth")
+		pty.ExpectMatch("Paste your token here:")
+		pty.WriteLine(client.SessionToken())
+		pty.ExpectMatch("Welcome to Coder")
+		<-doneChan
+	})
+
 	t.Run("ExistingUserInvalidTokenTTY", func(t *testing.T) {
 		t.Parallel()
 		client := coderdtest.New(t, nil)
@@ -181,7 +227,7 @@ func TestLogin(t *testing.T) {
 		ctx, cancelFunc := context.WithCancel(context.Background())
 		defer cancelFunc()
 		doneChan := make(chan struct{})
-		root, _ := clitest.New(t, "login", client.URL.String(), "--no-open")
+		root, _ := clitest.New(t, "login", "--force-tty", client.URL.String(), "--no-open")
 		pty := ptytest.New(t).Attach(root)
 		go func() {
 			defer close(doneChan)
@@ -210,4 +256,89 @@ func TestLogin(t *testing.T) {
 		// This **should not be equal** to the token we passed in.
 		require.NotEqual(t, client.SessionToken(), sessionFile)
 	})
+
+	t.Run("ExistingSession", func(t *testing.T) {
+		t.Parallel()
+		client := coderdtest.New(t, nil)
+		coderdtest.CreateFirstUser(t, client)
+		root, cfg := clitest.New(t, "login", client.URL.String(), "--no-open")
+		clitest.SetupConfig(t, client, cfg)
+		pty := ptytest.New(t).Attach(root)
+		clitest.Start(t, root)
+
+		// The stored session is reused, so there is no prompt for a token.
+		pty.ExpectMatch("Already logged in")
+		sessionFile, err := cfg.Session().Read()
+		require.NoError(t, err)
+		require.Equal(t, client.SessionToken(), sessionFile)
+	})
+
+	t.Run("ExistingSessionForce", func(t *testing.T) {
+		t.Parallel()
+		client := coderdtest.New(t, nil)
+		coderdtest.CreateFirstUser(t, client)
+		root, cfg := clitest.New(t, "login", "--force-tty", client.URL.String(), "--no-open", "--force")
+		clitest.SetupConfig(t, client, cfg)
+		pty := ptytest.New(t).Attach(root)
+		clitest.Start(t, root)
+
+		pty.ExpectMatch("Paste your token here:")
+		pty.WriteLine(client.SessionToken())
+		pty.ExpectMatch("Welcome to Coder")
+	})
+
+	t.Run("ExistingSessionOtherURL", func(t *testing.T) {
+		t.Parallel()
+		client := coderdtest.New(t, nil)
+		coderdtest.CreateFirstUser(t, client)
+		root, cfg := clitest.New(t, "login", "--force-tty", client.URL.String(), "--no-open")
+		clitest.SetupConfig(t, client, cfg)
+		err := cfg.URL().Write("https://other.coder.example.com")
+		require.NoError(t, err)
+		pty := ptytest.New(t).Attach(root)
+		clitest.Start(t, root)
+
+		pty.ExpectMatch("Paste your token here:")
+		pty.WriteLine(client.SessionToken())
+		pty.ExpectMatch("Welcome to Coder")
+	})
+
+	t.Run("TokenEnv", func(t *testing.T) {
+		t.Parallel()
+		client := coderdtest.New(t, nil)
+		coderdtest.CreateFirstUser(t, client)
+		root, cfg := clitest.New(t, "login")
+		root.Environ.Set("CODER_URL", client.URL.String())
+		root.Environ.Set("CODER_SESSION_TOKEN", client.SessionToken())
+		err := root.Run()
+		require.NoError(t, err)
+		sessionFile, err := cfg.Session().Read()
+		require.NoError(t, err)
+		require.NotEqual(t, client.SessionToken(), sessionFile)
+		urlFile, err := cfg.URL().Read()
+		require.NoError(t, err)
+		require.Equal(t, client.URL.String(), urlFile)
+	})
+
+	t.Run("InvalidTokenEnv", func(t *testing.T) {
+		t.Parallel()
+		client := coderdtest.New(t, nil)
+		coderdtest.CreateFirstUser(t, client)
+		root, cfg := clitest.New(t, "login")
+		root.Environ.Set("CODER_URL", client.URL.String())
+		root.Environ.Set("CODER_SESSION_TOKEN", "an-invalid-token")
+		err := root.Run()
+		require.ErrorContains(t, err, "That's not a valid token!")
+		_, err = cfg.Session().Read()
+		require.Error(t, err)
+	})
+
+	t.Run("ExistingUserNoTTY", func(t *testing.T) {
+		t.Parallel()
+		client := coderdtest.New(t, nil)
+		coderdtest.CreateFirstUser(t, client)
+		root, _ := clitest.New(t, "login", client.URL.String(), "--no-open")
+		err := root.Run()
+		require.ErrorContains(t, err, "non-interactive")
+	})
 }
@@ -23,7 +23,7 @@ func (r *RootCmd) logout() *clibase.Cmd {
 		Handler: func(inv *clibase.Invocation) error {
 			var errors []error
 
-			config := r.createConfig()
+			config := r.sessionConfig()
 
 			var err error
 			_, err = cliui.Prompt(inv, cliui.PromptOptions{
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/coder/coder/cli/clibase"
+	"github.com/coder/coder/cli/cliui"
+	"github.com/coder/coder/cli/config"
+	"github.com/coder/coder/codersdk"
+)
+
+func (r *RootCmd) profiles() *clibase.Cmd {
+	cmd := &clibase.Cmd{
+		Use:     "profiles [subcommand]",
+		Short:   "Manage CLI profiles",
+		Long:    "Profiles let you stay logged in to several deployments at once.\nSelect a profile with --" + varProfile + " or $" + envProfile + ".",
+		Aliases: []string{"profile"},
+		Handler: func(inv *clibase.Invocation) error {
+			return inv.Command.HelpHandler(inv)
+		},
+		Children: []*clibase.Cmd{
+			r.profileList(),
+		},
+	}
+	return cmd
+}
+
+type profileListRow struct {
+	Name     string `json:"name" table:"name,default_sort"`
+	URL      string `json:"url" table:"url"`
+	Username string `json:"username" table:"username"`
+	Valid    bool   `json:"valid" table:"valid"`
+	Active   bool   `json:"active" table:"active"`
+}
+
+func (r *RootCmd) profileList() *clibase.Cmd {
+	formatter := cliui.NewOutputFormatter(
+		cliui.TableFormat([]profileListRow{}, []string{"name", "url", "username", "valid", "active"}),
+		cliui.JSONFormat(),
+	)
+
+	cmd := &clibase.Cmd{
+		Use:     "list",
+		Short:   "List profiles and whether their session tokens are still valid",
+		Aliases: []string{"ls"},
+		Middleware: clibase.Chain(
+			clibase.RequireNArgs(0),
+		),
+		Handler: func(inv *clibase.Invocation) error {
+			root := config.Root(r.globalConfig)
+			names, err := root.Profiles()
+			if err != nil {
+				return xerrors.Errorf("list profiles: %w", err)
+			}
+
+			active := r.profile
+			if active == "" {
+				active = config.DefaultProfile
+			}
+
+			rows := make([]profileListRow, 0, len(names))
+			for _, name := range names {
+				row := profileListRow{
+					Name:   name,
+					Active: name == active,
+				}
+				conf := root.Profile(name)
+				rawURL, err := conf.URL().Read()
+				if err != nil {
+					return xerrors.Errorf("read url of profile %q: %w", name, err)
+				}
+				row.URL = strings.TrimSpace(rawURL)
+
+				serverURL, err := url.Parse(row.URL)
+				if err != nil {
+					rows = append(rows, row)
+					continue
+				}
+				sessionToken, err := conf.Session().Read()
+				if err != nil || sessionToken == "" {
+					rows = append(rows, row)
+					continue
+				}
+				client, err := r.createUnauthenticatedClient(serverURL)
+				if err != nil {
+					return err
+				}
+				client.SetSessionToken(sessionToken)
+				user, err := client.User(inv.Context(), codersdk.Me)
+				if err == nil {
+					row.Username = user.Username
+					row.Valid = true
+				}
+				rows = append(rows, row)
+			}
+
+			out, err := formatter.Format(inv.Context(), rows)
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintln(inv.Stdout, out)
+			return err
+		},
+	}
+
+	formatter.AttachOptions(&cmd.Options)
+	return cmd
+}
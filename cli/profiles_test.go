@@ -0,0 +1,131 @@
+package cli_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/coder/cli/clitest"
+	"github.com/coder/coder/coderd/coderdtest"
+	"github.com/coder/coder/codersdk"
+	"github.com/coder/coder/testutil"
+)
+
+func TestProfiles(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Isolation", func(t *testing.T) {
+		t.Parallel()
+		dev := coderdtest.New(t, nil)
+		coderdtest.CreateFirstUser(t, dev)
+		prod := coderdtest.New(t, nil)
+		coderdtest.CreateFirstUser(t, prod)
+
+		inv, cfg := clitest.New(t, "login", "--profile", "dev", "--token", dev.SessionToken(), dev.URL.String())
+		err := inv.Run()
+		require.NoError(t, err)
+
+		inv, _ = clitest.New(t, "login", "--global-config", string(cfg), "--token", prod.SessionToken(), prod.URL.String())
+		inv.Environ.Set("CODER_PROFILE", "prod")
+		err = inv.Run()
+		require.NoError(t, err)
+
+		devURL, err := cfg.Profile("dev").URL().Read()
+		require.NoError(t, err)
+		require.Equal(t, dev.URL.String(), devURL)
+		devSession, err := cfg.Profile("dev").Session().Read()
+		require.NoError(t, err)
+		require.NotEmpty(t, devSession)
+
+		prodURL, err := cfg.Profile("prod").URL().Read()
+		require.NoError(t, err)
+		require.Equal(t, prod.URL.String(), prodURL)
+		prodSession, err := cfg.Profile("prod").Session().Read()
+		require.NoError(t, err)
+		require.NotEmpty(t, prodSession)
+		require.NotEqual(t, devSession, prodSession)
+
+		// The default profile was never logged in to.
+		_, err = cfg.URL().Read()
+		require.Error(t, err)
+		_, err = cfg.Session().Read()
+		require.Error(t, err)
+
+		// Commands read from the selected profile.
+		ctx := testutil.Context(t, testutil.WaitLong)
+		prodUser, err := prod.User(ctx, codersdk.Me)
+		require.NoError(t, err)
+		inv, _ = clitest.New(t, "users", "show", "me", "--global-config", string(cfg), "--profile", "prod", "-o", "json")
+		buf := new(bytes.Buffer)
+		inv.Stdout = buf
+		err = inv.WithContext(ctx).Run()
+		require.NoError(t, err)
+		var shown codersdk.User
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &shown))
+		require.Equal(t, prodUser.ID, shown.ID)
+
+		inv, _ = clitest.New(t, "users", "show", "me", "--global-config", string(cfg))
+		err = inv.WithContext(ctx).Run()
+		require.Error(t, err)
+
+		// Logging out of one profile leaves the other alone.
+		inv, _ = clitest.New(t, "logout", "--global-config", string(cfg), "--profile", "dev", "-y")
+		err = inv.WithContext(ctx).Run()
+		require.NoError(t, err)
+		_, err = cfg.Profile("dev").Session().Read()
+		require.Error(t, err)
+		_, err = cfg.Profile("prod").Session().Read()
+		require.NoError(t, err)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		t.Parallel()
+		dev := coderdtest.New(t, nil)
+		coderdtest.CreateFirstUser(t, dev)
+		prod := coderdtest.New(t, nil)
+		coderdtest.CreateFirstUser(t, prod)
+
+		_, cfg := clitest.New(t)
+		clitest.SetupConfig(t, dev, cfg.Profile("dev"))
+		clitest.SetupConfig(t, prod, cfg.Profile("prod"))
+		err := cfg.Profile("stale").URL().Write(dev.URL.String())
+		require.NoError(t, err)
+		err = cfg.Profile("stale").Session().Write("an-invalid-token")
+		require.NoError(t, err)
+
+		ctx := testutil.Context(t, testutil.WaitLong)
+		inv, _ := clitest.New(t, "profiles", "list", "--global-config", string(cfg), "--profile", "prod", "-o", "json")
+		buf := new(bytes.Buffer)
+		inv.Stdout = buf
+		err = inv.WithContext(ctx).Run()
+		require.NoError(t, err)
+
+		var rows []struct {
+			Name   string `json:"name"`
+			URL    string `json:"url"`
+			Valid  bool   `json:"valid"`
+			Active bool   `json:"active"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &rows))
+		require.Len(t, rows, 3)
+		require.Equal(t, "dev", rows[0].Name)
+		require.Equal(t, dev.URL.String(), rows[0].URL)
+		require.True(t, rows[0].Valid)
+		require.False(t, rows[0].Active)
+		require.Equal(t, "prod", rows[1].Name)
+		require.Equal(t, prod.URL.String(), rows[1].URL)
+		require.True(t, rows[1].Valid)
+		require.True(t, rows[1].Active)
+		require.Equal(t, "stale", rows[2].Name)
+		require.False(t, rows[2].Valid)
+	})
+
+	t.Run("InvalidName", func(t *testing.T) {
+		t.Parallel()
+		inv, _ := clitest.New(t, "profiles", "list", "--profile", "../escape")
+		err := inv.Run()
+		require.ErrorContains(t, err, "invalid profile name")
+	})
+}
@@ -56,11 +56,13 @@ const (
 	varAgentURL         = "agent-url"
 	varHeader           = "header"
 	varNoOpen           = "no-open"
+	varOpen             = "open"
 	varNoVersionCheck   = "no-version-warning"
 	varNoFeatureWarning = "no-feature-warning"
 	varForceTty         = "force-tty"
 	varVerbose          = "verbose"
 	varDisableDirect    = "disable-direct-connections"
+	varProfile          = "profile"
 	notLoggedInMessage  = "You are not logged in. Try logging in using 'coder login <url>'."
 
 	envNoVersionCheck   = "CODER_NO_VERSION_WARNING"
@@ -69,6 +71,7 @@ const (
 	//nolint:gosec
 	envAgentToken = "CODER_AGENT_TOKEN"
 	envURL        = "CODER_URL"
+	envProfile    = "CODER_PROFILE"
 )
 
 var errUnauthenticated = xerrors.New(notLoggedInMessage)
@@ -81,6 +84,7 @@ func (r *RootCmd) Core() []*clibase.Cmd {
 		r.logout(),
 		r.netcheck(),
 		r.portForward(),
+		r.profiles(),
 		r.publickey(),
 		r.resetPassword(),
 		r.state(),
@@ -364,6 +368,14 @@ func (r *RootCmd) Command(subcommands []*clibase.Cmd) (*clibase.Cmd, error) {
 			Hidden:      true,
 			Group:       globalGroup,
 		},
+		{
+			Flag:        varOpen,
+			Env:         "CODER_OPEN",
+			Description: "Open the browser after logging in, even if no browser appears to be available.",
+			Value:       clibase.BoolOf(&r.open),
+			Hidden:      true,
+			Group:       globalGroup,
+		},
 		{
 			Flag:        varForceTty,
 			Env:         "CODER_FORCE_TTY",
@@ -402,6 +414,18 @@ func (r *RootCmd) Command(subcommands []*clibase.Cmd) (*clibase.Cmd, error) {
 			Value:       clibase.StringOf(&r.globalConfig),
 			Group:       globalGroup,
 		},
+		{
+			Flag:        varProfile,
+			Env:         envProfile,
+			Description: "Name of the profile to use. Profiles store separate deployment URLs and session tokens.",
+			Value: clibase.Validate(clibase.StringOf(&r.profile), func(value *clibase.String) error {
+				if value.String() == "" {
+					return nil
+				}
+				return config.ValidateProfileName(value.String())
+			}),
+			Group: globalGroup,
+		},
 	}
 
 	err := cmd.PrepareAll()
@@ -436,11 +460,13 @@ type RootCmd struct {
 	clientURL     *url.URL
 	token         string
 	globalConfig  string
+	profile       string
 	header        []string
 	agentToken    string
 	agentURL      *url.URL
 	forceTTY      bool
 	noOpen        bool
+	open          bool
 	verbose       bool
 	disableDirect bool
 	debugHTTP     bool
@@ -502,7 +528,7 @@ func (r *RootCmd) InitClient(client *codersdk.Client) clibase.MiddlewareFunc {
 	}
 	return func(next clibase.HandlerFunc) clibase.HandlerFunc {
 		return func(inv *clibase.Invocation) error {
-			conf := r.createConfig()
+			conf := r.sessionConfig()
 			var err error
 			if r.clientURL == nil || r.clientURL.String() == "" {
 				rawURL, err := conf.URL().Read()
@@ -658,6 +684,13 @@ func (r *RootCmd) createConfig() config.Root {
 	return config.Root(r.globalConfig)
 }
 
+// sessionConfig returns the configuration directory of the selected profile,
+// which holds the session token and deployment URL.  Everything else is stored
+// in the config root, regardless of the profile.
+func (r *RootCmd) sessionConfig() config.Root {
+	return r.createConfig().Profile(r.profile)
+}
+
 // isTTY returns whether the passed reader is a TTY or not.
 func isTTY(inv *clibase.Invocation) bool {
 	// If the `--force-tty` command is available, and set,
@@ -66,6 +66,21 @@ func Test_formatExamples(t *testing.T) {
 	}
 }
 
+func Test_createConfigProfile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	unprofiled := &RootCmd{globalConfig: dir}
+	profiled := &RootCmd{globalConfig: dir, profile: "dev"}
+
+	// Only the session and URL are namespaced by the profile; e.g. the
+	// server's built-in database stays where it is.
+	require.Equal(t, unprofiled.createConfig().PostgresPath(), profiled.createConfig().PostgresPath())
+	require.Equal(t, unprofiled.sessionConfig().Session(), unprofiled.createConfig().Session())
+	require.NotEqual(t, unprofiled.sessionConfig().Session(), profiled.sessionConfig().Session())
+	require.NotEqual(t, unprofiled.sessionConfig().URL(), profiled.sessionConfig().URL())
+}
+
 func TestMain(m *testing.M) {
 	goleak.VerifyTestMain(m,
 		// The lumberjack library is used by by agent and seems to leave
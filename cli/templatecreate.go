@@ -229,6 +229,9 @@ type createValidTemplateVersionArgs struct {
 	// values.
 	ReuseParameters bool
 	ProvisionerTags map[string]string
+	// Throwaway creates a version that can only be inspected, see
+	// codersdk.CreateTemplateVersionRequest.
+	Throwaway bool
 }
 
 func createValidTemplateVersion(inv *clibase.Invocation, args createValidTemplateVersionArgs) (*codersdk.TemplateVersion, error) {
@@ -253,6 +256,7 @@ func createValidTemplateVersion(inv *clibase.Invocation, args createValidTemplat
 		Provisioner:        codersdk.ProvisionerType(args.Provisioner),
 		ProvisionerTags:    args.ProvisionerTags,
 		UserVariableValues: variableValues,
+		Throwaway:          args.Throwaway,
 	}
 	if args.Template != nil {
 		req.TemplateID = args.Template.ID
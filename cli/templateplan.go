@@ -1,18 +1,316 @@
 package cli
 
 import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+
 	"github.com/coder/coder/cli/clibase"
+	"github.com/coder/coder/cli/cliui"
+	"github.com/coder/coder/coderd/database"
+	"github.com/coder/coder/codersdk"
 )
 
-func (*RootCmd) templatePlan() *clibase.Cmd {
-	return &clibase.Cmd{
+func (r *RootCmd) templatePlan() *clibase.Cmd {
+	var (
+		templateName    string
+		provisioner     string
+		variablesFile   string
+		variables       []string
+		provisionerTags []string
+		uploadFlags     templateUploadFlags
+	)
+	client := new(codersdk.Client)
+	cmd := &clibase.Cmd{
 		Use: "plan <directory>",
 		Middleware: clibase.Chain(
 			clibase.RequireNArgs(1),
+			r.InitClient(client),
 		),
 		Short: "Plan a template push from the current directory",
+		Long: "Imports the template in the directory as a new version without promoting it, and prints the " +
+			"resources and parameters that would change compared to the template's active version. The planned " +
+			"version is a throwaway: it can't be used to create a template, be promoted or be built, and it is " +
+			"deleted after a day.",
 		Handler: func(inv *clibase.Invocation) error {
+			uploadFlags.directory = inv.Args[0]
+
+			organization, err := CurrentOrganization(inv, client)
+			if err != nil {
+				return err
+			}
+
+			var nameArgs []string
+			if templateName != "" {
+				nameArgs = []string{templateName}
+			}
+			name, err := uploadFlags.templateName(nameArgs)
+			if err != nil {
+				return err
+			}
+			template, err := client.TemplateByName(inv.Context(), organization.ID, name)
+			if err != nil {
+				return err
+			}
+
+			err = uploadFlags.checkForLockfile(inv)
+			if err != nil {
+				return xerrors.Errorf("check for lockfile: %w", err)
+			}
+
+			resp, err := uploadFlags.upload(inv, client)
+			if err != nil {
+				return err
+			}
+
+			tags, err := ParseProvisionerTags(provisionerTags)
+			if err != nil {
+				return err
+			}
+
+			// The server rejects throwaway versions when creating templates,
+			// promoting versions and building workspaces, and purges them
+			// after a day.
+			version, err := createValidTemplateVersion(inv, createValidTemplateVersionArgs{
+				Message:         "Planned from the CLI",
+				Client:          client,
+				Organization:    organization,
+				Provisioner:     database.ProvisionerType(provisioner),
+				FileID:          resp.ID,
+				ProvisionerTags: tags,
+				VariablesFile:   variablesFile,
+				Variables:       variables,
+				Throwaway:       true,
+			})
+			if err != nil {
+				return xerrors.Errorf("plan failed: %w", err)
+			}
+
+			activeResources, err := client.TemplateVersionResources(inv.Context(), template.ActiveVersionID)
+			if err != nil {
+				return xerrors.Errorf("get active version resources: %w", err)
+			}
+			plannedResources, err := client.TemplateVersionResources(inv.Context(), version.ID)
+			if err != nil {
+				return xerrors.Errorf("get planned version resources: %w", err)
+			}
+			activeParameters, err := client.TemplateVersionRichParameters(inv.Context(), template.ActiveVersionID)
+			if err != nil {
+				return xerrors.Errorf("get active version parameters: %w", err)
+			}
+			plannedParameters, err := client.TemplateVersionRichParameters(inv.Context(), version.ID)
+			if err != nil {
+				return xerrors.Errorf("get planned version parameters: %w", err)
+			}
+
+			_, _ = fmt.Fprintf(inv.Stdout, "\nChanges compared to the active version of %s:\n", cliui.DefaultStyles.Keyword.Render(template.Name))
+			writePlanChanges(inv.Stdout, "Resources", planResourceChanges(activeResources, plannedResources))
+			writePlanChanges(inv.Stdout, "Parameters", planParameterChanges(activeParameters, plannedParameters))
 			return nil
 		},
 	}
+
+	cmd.Options = clibase.OptionSet{
+		{
+			Flag:          "template",
+			FlagShorthand: "t",
+			Description:   "Specify the template to compare against. Defaults to the name of the directory.",
+			Value:         clibase.StringOf(&templateName),
+		},
+		{
+			Flag:        "test.provisioner",
+			Description: "Customize the provisioner backend.",
+			Default:     "terraform",
+			Value:       clibase.StringOf(&provisioner),
+			// This is for testing!
+			Hidden: true,
+		},
+		{
+			Flag:        "variables-file",
+			Description: "Specify a file path with values for Terraform-managed variables.",
+			Value:       clibase.StringOf(&variablesFile),
+		},
+		{
+			Flag:        "variable",
+			Description: "Specify a set of values for Terraform-managed variables.",
+			Value:       clibase.StringArrayOf(&variables),
+		},
+		{
+			Flag:        "var",
+			Description: "Alias of --variable.",
+			Value:       clibase.StringArrayOf(&variables),
+		},
+		{
+			Flag:        "provisioner-tag",
+			Description: "Specify a set of tags to target provisioner daemons.",
+			Value:       clibase.StringArrayOf(&provisionerTags),
+		},
+		{
+			Flag:        "ignore-lockfile",
+			Description: "Ignore warnings about not having a .terraform.lock.hcl file present in the template.",
+			Default:     "false",
+			Value:       clibase.BoolOf(&uploadFlags.ignoreLockfile),
+		},
+		cliui.SkipPromptOption(),
+	}
+	return cmd
+}
+
+// planChange is a single difference between the active and the planned
+// template version.
+type planChange struct {
+	// Kind is "+" for added, "-" for removed and "~" for changed.
+	Kind string
+	Name string
+	// Fields lists what changed, for changes.
+	Fields []string
+}
+
+func writePlanChanges(w io.Writer, title string, changes []planChange) {
+	_, _ = fmt.Fprintf(w, "\n%s:\n", title)
+	if len(changes) == 0 {
+		_, _ = fmt.Fprintln(w, "  No changes.")
+		return
+	}
+	for _, c := range changes {
+		if len(c.Fields) > 0 {
+			_, _ = fmt.Fprintf(w, "  %s %s (%s)\n", c.Kind, c.Name, strings.Join(c.Fields, ", "))
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "  %s %s\n", c.Kind, c.Name)
+	}
+}
+
+// planResourceChanges compares the resources of the start transition, as the
+// template preview does, identifying resources by type and name.
+func planResourceChanges(active, planned []codersdk.WorkspaceResource) []planChange {
+	index := func(resources []codersdk.WorkspaceResource) map[string]codersdk.WorkspaceResource {
+		m := map[string]codersdk.WorkspaceResource{}
+		for _, r := range resources {
+			if r.Transition == codersdk.WorkspaceTransitionStart {
+				m[r.Type+"."+r.Name] = r
+			}
+		}
+		return m
+	}
+	return planChanges(index(active), index(planned), func(a, p codersdk.WorkspaceResource) []string {
+		var fields []string
+		if !slices.Equal(planAgentNames(a.Agents), planAgentNames(p.Agents)) {
+			fields = append(fields, "agents")
+		}
+		if !slices.Equal(planMetadata(a.Metadata), planMetadata(p.Metadata)) {
+			fields = append(fields, "metadata")
+		}
+		if a.Hide != p.Hide {
+			fields = append(fields, "hide")
+		}
+		if a.Icon != p.Icon {
+			fields = append(fields, "icon")
+		}
+		if a.DailyCost != p.DailyCost {
+			fields = append(fields, "daily cost")
+		}
+		return fields
+	})
+}
+
+func planParameterChanges(active, planned []codersdk.TemplateVersionParameter) []planChange {
+	index := func(parameters []codersdk.TemplateVersionParameter) map[string]codersdk.TemplateVersionParameter {
+		m := map[string]codersdk.TemplateVersionParameter{}
+		for _, p := range parameters {
+			m[p.Name] = p
+		}
+		return m
+	}
+	return planChanges(index(active), index(planned), func(a, p codersdk.TemplateVersionParameter) []string {
+		var fields []string
+		if a.Type != p.Type {
+			fields = append(fields, "type")
+		}
+		if a.DefaultValue != p.DefaultValue {
+			fields = append(fields, "default value")
+		}
+		if a.Mutable != p.Mutable {
+			fields = append(fields, "mutable")
+		}
+		if a.Required != p.Required {
+			fields = append(fields, "required")
+		}
+		if a.Ephemeral != p.Ephemeral {
+			fields = append(fields, "ephemeral")
+		}
+		if !slices.Equal(planOptionValues(a.Options), planOptionValues(p.Options)) {
+			fields = append(fields, "options")
+		}
+		if a.ValidationRegex != p.ValidationRegex || !equalInt32Ptr(a.ValidationMin, p.ValidationMin) ||
+			!equalInt32Ptr(a.ValidationMax, p.ValidationMax) || a.ValidationMonotonic != p.ValidationMonotonic {
+			fields = append(fields, "validation")
+		}
+		return fields
+	})
+}
+
+// planChanges returns the changes between the active and planned objects,
+// sorted by name. diff returns the fields that changed between objects with
+// the same name.
+func planChanges[T any](active, planned map[string]T, diff func(active, planned T) []string) []planChange {
+	var changes []planChange
+	for name, a := range active {
+		p, ok := planned[name]
+		if !ok {
+			changes = append(changes, planChange{Kind: "-", Name: name})
+			continue
+		}
+		if fields := diff(a, p); len(fields) > 0 {
+			changes = append(changes, planChange{Kind: "~", Name: name, Fields: fields})
+		}
+	}
+	for name := range planned {
+		if _, ok := active[name]; !ok {
+			changes = append(changes, planChange{Kind: "+", Name: name})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+func planAgentNames(agents []codersdk.WorkspaceAgent) []string {
+	names := make([]string, 0, len(agents))
+	for _, a := range agents {
+		names = append(names, a.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// planMetadata returns the metadata as key=value pairs. Sensitive values are
+// compared too, but never printed.
+func planMetadata(metadata []codersdk.WorkspaceResourceMetadata) []string {
+	pairs := make([]string, 0, len(metadata))
+	for _, m := range metadata {
+		pairs = append(pairs, m.Key+"="+m.Value)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func planOptionValues(options []codersdk.TemplateVersionParameterOption) []string {
+	values := make([]string, 0, len(options))
+	for _, o := range options {
+		values = append(values, o.Value)
+	}
+	return values
+}
+
+func equalInt32Ptr(a, b *int32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
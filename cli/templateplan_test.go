@@ -0,0 +1,117 @@
+package cli_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/coder/cli/clitest"
+	"github.com/coder/coder/coderd/coderdtest"
+	"github.com/coder/coder/coderd/database"
+	"github.com/coder/coder/coderd/database/dbauthz"
+	"github.com/coder/coder/codersdk"
+	"github.com/coder/coder/provisioner/echo"
+	"github.com/coder/coder/provisionersdk/proto"
+	"github.com/coder/coder/pty/ptytest"
+	"github.com/coder/coder/testutil"
+)
+
+func TestTemplatePlan(t *testing.T) {
+	t.Parallel()
+
+	planResponses := func(parameters []*proto.RichParameter, resources ...string) *echo.Responses {
+		complete := &proto.Provision_Complete{Parameters: parameters}
+		for _, name := range resources {
+			complete.Resources = append(complete.Resources, &proto.Resource{Type: "aws_instance", Name: name})
+		}
+		return &echo.Responses{
+			Parse: echo.ParseComplete,
+			ProvisionPlan: []*proto.Provision_Response{{
+				Type: &proto.Provision_Response_Complete{Complete: complete},
+			}},
+			ProvisionApply: echo.ProvisionComplete,
+		}
+	}
+
+	t.Run("Changes", func(t *testing.T) {
+		t.Parallel()
+		client, _, api := coderdtest.NewWithAPI(t, &coderdtest.Options{IncludeProvisionerDaemon: true})
+		user := coderdtest.CreateFirstUser(t, client)
+		version := coderdtest.CreateTemplateVersion(t, client, user.OrganizationID, planResponses([]*proto.RichParameter{
+			{Name: "region", DefaultValue: "us", Mutable: true},
+			{Name: "size", DefaultValue: "small", Mutable: true},
+		}, "kept", "removed"))
+		_ = coderdtest.AwaitTemplateVersionJob(t, client, version.ID)
+		template := coderdtest.CreateTemplate(t, client, user.OrganizationID, version.ID)
+
+		source := clitest.CreateTemplateVersionSource(t, planResponses([]*proto.RichParameter{
+			{Name: "region", DefaultValue: "eu", Mutable: true},
+			{Name: "size", DefaultValue: "small", Mutable: true},
+			{Name: "zone", Mutable: true},
+		}, "kept", "added"))
+		inv, root := clitest.New(t, "templates", "plan", source, "--template", template.Name, "--test.provisioner", string(database.ProvisionerTypeEcho), "--yes")
+		clitest.SetupConfig(t, client, root)
+		pty := ptytest.New(t).Attach(inv)
+
+		execDone := make(chan error)
+		go func() {
+			execDone <- inv.Run()
+		}()
+
+		pty.ExpectMatch("Resources:")
+		pty.ExpectMatch("+ aws_instance.added")
+		pty.ExpectMatch("- aws_instance.removed")
+		pty.ExpectMatch("Parameters:")
+		pty.ExpectMatch("~ region (default value)")
+		pty.ExpectMatch("+ zone")
+		require.NoError(t, <-execDone)
+
+		// The planned version is never added to the template.
+		templateVersions, err := client.TemplateVersionsByTemplate(context.Background(), codersdk.TemplateVersionsByTemplateRequest{
+			TemplateID: template.ID,
+		})
+		require.NoError(t, err)
+		require.Len(t, templateVersions, 1)
+		template, err = client.Template(context.Background(), template.ID)
+		require.NoError(t, err)
+		require.Equal(t, version.ID, template.ActiveVersionID)
+
+		// Nor can it be used to create another template.
+		ctx := testutil.Context(t, testutil.WaitLong)
+		//nolint:gocritic // The planned version can't be listed through the API.
+		planned, err := api.Database.GetTemplateVersionsCreatedAfter(dbauthz.AsSystemRestricted(ctx), version.CreatedAt)
+		require.NoError(t, err)
+		require.Len(t, planned, 1)
+		require.True(t, planned[0].Throwaway)
+		_, err = client.CreateTemplate(ctx, user.OrganizationID, codersdk.CreateTemplateRequest{
+			Name:      "planned",
+			VersionID: planned[0].ID,
+		})
+		var apiErr *codersdk.Error
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, http.StatusBadRequest, apiErr.StatusCode())
+	})
+
+	t.Run("ImportFails", func(t *testing.T) {
+		t.Parallel()
+		client := coderdtest.New(t, &coderdtest.Options{IncludeProvisionerDaemon: true})
+		user := coderdtest.CreateFirstUser(t, client)
+		version := coderdtest.CreateTemplateVersion(t, client, user.OrganizationID, nil)
+		_ = coderdtest.AwaitTemplateVersionJob(t, client, version.ID)
+		template := coderdtest.CreateTemplate(t, client, user.OrganizationID, version.ID)
+
+		source := clitest.CreateTemplateVersionSource(t, &echo.Responses{
+			Parse:          echo.ParseComplete,
+			ProvisionPlan:  echo.ProvisionFailed,
+			ProvisionApply: echo.ProvisionComplete,
+		})
+		inv, root := clitest.New(t, "templates", "plan", source, "--template", template.Name, "--test.provisioner", string(database.ProvisionerTypeEcho), "--yes")
+		clitest.SetupConfig(t, client, root)
+		_ = ptytest.New(t).Attach(inv)
+
+		err := inv.Run()
+		require.ErrorContains(t, err, "plan failed")
+	})
+}
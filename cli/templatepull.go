@@ -1,10 +1,20 @@
 package cli
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/codeclysm/extract/v3"
 	"golang.org/x/xerrors"
@@ -15,7 +25,13 @@ import (
 )
 
 func (r *RootCmd) templatePull() *clibase.Cmd {
-	var tarMode bool
+	var (
+		tarMode     bool
+		zipMode     bool
+		versionName string
+		skipVerify  bool
+		force       bool
+	)
 
 	client := new(codersdk.Client)
 	cmd := &clibase.Cmd{
@@ -36,6 +52,10 @@ func (r *RootCmd) templatePull() *clibase.Cmd {
 				dest = inv.Args[1]
 			}
 
+			if tarMode && zipMode {
+				return xerrors.New("--tar and --zip are mutually exclusive")
+			}
+
 			// TODO(JonA): Do we need to add a flag for organization?
 			organization, err := CurrentOrganization(inv, client)
 			if err != nil {
@@ -47,28 +67,40 @@ func (r *RootCmd) templatePull() *clibase.Cmd {
 				return xerrors.Errorf("template by name: %w", err)
 			}
 
-			// Pull the versions for the template. We'll find the latest
-			// one and download the source.
-			versions, err := client.TemplateVersionsByTemplate(ctx, codersdk.TemplateVersionsByTemplateRequest{
-				TemplateID: template.ID,
-			})
-			if err != nil {
-				return xerrors.Errorf("template versions by template: %w", err)
-			}
+			var version codersdk.TemplateVersion
+			if versionName != "" {
+				version, err = client.TemplateVersionByName(ctx, template.ID, versionName)
+				if err != nil {
+					var sdkErr *codersdk.Error
+					if xerrors.As(err, &sdkErr) && sdkErr.StatusCode() == http.StatusNotFound {
+						return xerrors.Errorf("template %q has no version named %q", templateName, versionName)
+					}
+					return xerrors.Errorf("template version by name: %w", err)
+				}
+			} else {
+				// Pull the versions for the template. We'll find the latest
+				// one and download the source.
+				versions, err := client.TemplateVersionsByTemplate(ctx, codersdk.TemplateVersionsByTemplateRequest{
+					TemplateID: template.ID,
+				})
+				if err != nil {
+					return xerrors.Errorf("template versions by template: %w", err)
+				}
 
-			if len(versions) == 0 {
-				return xerrors.Errorf("no template versions for template %q", templateName)
-			}
+				if len(versions) == 0 {
+					return xerrors.Errorf("no template versions for template %q", templateName)
+				}
 
-			// Sort the slice from newest to oldest template.
-			sort.SliceStable(versions, func(i, j int) bool {
-				return versions[i].CreatedAt.After(versions[j].CreatedAt)
-			})
+				// Sort the slice from newest to oldest template.
+				sort.SliceStable(versions, func(i, j int) bool {
+					return versions[i].CreatedAt.After(versions[j].CreatedAt)
+				})
 
-			latest := versions[0]
+				version = versions[0]
+			}
 
 			// Download the tar archive.
-			raw, ctype, err := client.Download(ctx, latest.Job.FileID)
+			raw, ctype, hash, err := client.DownloadWithHash(ctx, version.Job.FileID)
 			if err != nil {
 				return xerrors.Errorf("download template: %w", err)
 			}
@@ -77,11 +109,41 @@ func (r *RootCmd) templatePull() *clibase.Cmd {
 				return xerrors.Errorf("unexpected Content-Type %q, expecting %q", ctype, codersdk.ContentTypeTar)
 			}
 
+			// Verify the archive before anything is written, so a corrupt
+			// download never leaves partial files behind.
+			if !skipVerify {
+				if hash == "" {
+					cliui.Warnf(inv.Stderr, "The server did not report a checksum for the template, skipping verification.")
+				} else if sum := sha256.Sum256(raw); !strings.EqualFold(hex.EncodeToString(sum[:]), hash) {
+					return xerrors.Errorf("template archive checksum mismatch: got %x, expected %s (use --skip-verify to ignore)", sum, hash)
+				}
+			}
+
 			if tarMode {
 				_, err = inv.Stdout.Write(raw)
 				return err
 			}
 
+			if zipMode {
+				if dest == "" {
+					return tarToZip(inv.Stdout, raw)
+				}
+				if !strings.HasSuffix(dest, ".zip") {
+					dest += ".zip"
+				}
+				f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+				if err != nil {
+					return xerrors.Errorf("create %q: %w", dest, err)
+				}
+				err = tarToZip(f, raw)
+				if err != nil {
+					_ = f.Close()
+					return xerrors.Errorf("write %q: %w", dest, err)
+				}
+				_, _ = fmt.Fprintf(inv.Stderr, "Wrote template to %q\n", dest)
+				return f.Close()
+			}
+
 			if dest == "" {
 				dest = templateName + "/"
 			}
@@ -96,18 +158,40 @@ func (r *RootCmd) templatePull() *clibase.Cmd {
 				return xerrors.Errorf("read dir %q: %w", dest, err)
 			}
 
-			if len(ents) > 0 {
-				_, err = cliui.Prompt(inv, cliui.PromptOptions{
-					Text:      fmt.Sprintf("Directory %q is not empty, existing files may be overwritten.\nContinue extracting?", dest),
-					Default:   "No",
-					Secret:    false,
-					IsConfirm: true,
-				})
-				if err != nil {
-					return err
+			overwrite := force
+			if len(ents) > 0 && !force {
+				answer := cliui.ConfirmYes
+				if yes, _ := inv.ParsedFlags().GetBool("yes"); !yes {
+					answer, err = cliui.Prompt(inv, cliui.PromptOptions{
+						Text: fmt.Sprintf("Directory %q is not empty, existing files may be overwritten.\n"+
+							"Continue extracting (%s), abort (%s) or replace the contents of the directory (%s)?",
+							dest, cliui.ConfirmYes, cliui.ConfirmNo, pullAnswerOverwrite),
+						Default: cliui.ConfirmNo,
+						Validate: func(s string) error {
+							switch s {
+							case cliui.ConfirmYes, "y", cliui.ConfirmNo, "n", pullAnswerOverwrite:
+								return nil
+							}
+							return xerrors.Errorf("Please answer %q, %q or %q.", cliui.ConfirmYes, cliui.ConfirmNo, pullAnswerOverwrite)
+						},
+					})
+					if err != nil {
+						return err
+					}
+				}
+				switch answer {
+				case cliui.ConfirmNo, "n":
+					return xerrors.Errorf("got %q: %w", answer, cliui.Canceled)
+				case pullAnswerOverwrite:
+					overwrite = true
 				}
 			}
 
+			if overwrite && len(ents) > 0 {
+				_, _ = fmt.Fprintf(inv.Stderr, "Replacing the contents of %q with the template\n", dest)
+				return replaceDirWithTar(ctx, raw, dest)
+			}
+
 			_, _ = fmt.Fprintf(inv.Stderr, "Extracting template to %q\n", dest)
 			err = extract.Tar(ctx, bytes.NewReader(raw), dest, nil)
 			return err
@@ -121,8 +205,121 @@ func (r *RootCmd) templatePull() *clibase.Cmd {
 
 			Value: clibase.BoolOf(&tarMode),
 		},
+		{
+			Description: "Output the template as a zip archive to stdout, or to <destination>.zip if a destination is given.",
+			Flag:        "zip",
+
+			Value: clibase.BoolOf(&zipMode),
+		},
+		{
+			Description: "The name of the template version to pull. Defaults to the latest version.",
+			Flag:        "version",
+
+			Value: clibase.StringOf(&versionName),
+		},
+		{
+			Description: "Skip verifying the checksum of the downloaded template archive.",
+			Flag:        "skip-verify",
+
+			Value: clibase.BoolOf(&skipVerify),
+		},
+		{
+			Description: "Replace the contents of the destination directory if it is not empty, without prompting. Files that are not part of the template are removed.",
+			Flag:        "force",
+
+			Value: clibase.BoolOf(&force),
+		},
 		cliui.SkipPromptOption(),
 	}
 
 	return cmd
 }
+
+// pullAnswerOverwrite is the answer to the "not empty" prompt of 'templates
+// pull' that replaces the contents of the destination directory.
+const pullAnswerOverwrite = "overwrite"
+
+// replaceDirWithTar replaces the contents of dir with the files of the tar
+// archive raw. The archive is extracted next to dir first, so that a failed
+// extraction leaves dir untouched.
+func replaceDirWithTar(ctx context.Context, raw []byte, dir string) error {
+	dir = filepath.Clean(dir)
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+"-pull-")
+	if err != nil {
+		return xerrors.Errorf("create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	err = extract.Tar(ctx, bytes.NewReader(raw), tmp, nil)
+	if err != nil {
+		return xerrors.Errorf("extract template: %w", err)
+	}
+
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return xerrors.Errorf("read dir %q: %w", dir, err)
+	}
+	for _, ent := range ents {
+		err = os.RemoveAll(filepath.Join(dir, ent.Name()))
+		if err != nil {
+			return xerrors.Errorf("remove %q: %w", ent.Name(), err)
+		}
+	}
+
+	ents, err = os.ReadDir(tmp)
+	if err != nil {
+		return xerrors.Errorf("read dir %q: %w", tmp, err)
+	}
+	for _, ent := range ents {
+		err = os.Rename(filepath.Join(tmp, ent.Name()), filepath.Join(dir, ent.Name()))
+		if err != nil {
+			return xerrors.Errorf("move %q: %w", ent.Name(), err)
+		}
+	}
+	return nil
+}
+
+// tarToZip converts the tar archive of a template to a zip archive with the
+// same files, written to w. Entries other than directories and regular files,
+// e.g. symlinks, are skipped.
+func tarToZip(w io.Writer, raw []byte) error {
+	tr := tar.NewReader(bytes.NewReader(raw))
+	zw := zip.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return xerrors.Errorf("read tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeDir && hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		zh, err := zip.FileInfoHeader(hdr.FileInfo())
+		if err != nil {
+			return xerrors.Errorf("zip header for %q: %w", hdr.Name, err)
+		}
+		zh.Name = strings.TrimPrefix(hdr.Name, "/")
+		if hdr.Typeflag == tar.TypeDir {
+			if !strings.HasSuffix(zh.Name, "/") {
+				zh.Name += "/"
+			}
+		} else {
+			zh.Method = zip.Deflate
+		}
+
+		fw, err := zw.CreateHeader(zh)
+		if err != nil {
+			return xerrors.Errorf("create zip entry %q: %w", zh.Name, err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			_, err = io.Copy(fw, tr)
+			if err != nil {
+				return xerrors.Errorf("write zip entry %q: %w", zh.Name, err)
+			}
+		}
+	}
+	return zw.Close()
+}
@@ -5,8 +5,13 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/codeclysm/extract/v3"
@@ -15,6 +20,7 @@ import (
 
 	"github.com/coder/coder/cli/clitest"
 	"github.com/coder/coder/coderd/coderdtest"
+	"github.com/coder/coder/codersdk"
 	"github.com/coder/coder/provisioner/echo"
 	"github.com/coder/coder/provisionersdk/proto"
 	"github.com/coder/coder/pty/ptytest"
@@ -89,6 +95,61 @@ func TestTemplatePull(t *testing.T) {
 		require.True(t, bytes.Equal(expected, buf.Bytes()), "tar files differ")
 	})
 
+	// Version tests that 'templates pull --version' pulls down the named
+	// version rather than the latest one.
+	t.Run("Version", func(t *testing.T) {
+		t.Parallel()
+
+		client := coderdtest.New(t, &coderdtest.Options{IncludeProvisionerDaemon: true})
+		user := coderdtest.CreateFirstUser(t, client)
+
+		source1 := genTemplateVersionSource()
+		source2 := genTemplateVersionSource()
+
+		expected, err := echo.Tar(source1)
+		require.NoError(t, err)
+
+		version1 := coderdtest.CreateTemplateVersion(t, client, user.OrganizationID, source1)
+		_ = coderdtest.AwaitTemplateVersionJob(t, client, version1.ID)
+
+		template := coderdtest.CreateTemplate(t, client, user.OrganizationID, version1.ID)
+
+		_ = coderdtest.UpdateTemplateVersion(t, client, user.OrganizationID, source2, template.ID)
+
+		inv, root := clitest.New(t, "templates", "pull", "--tar", template.Name, "--version", version1.Name)
+		clitest.SetupConfig(t, client, root)
+
+		var buf bytes.Buffer
+		inv.Stdout = &buf
+
+		err = inv.Run()
+		require.NoError(t, err)
+
+		require.True(t, bytes.Equal(expected, buf.Bytes()), "tar files differ")
+	})
+
+	t.Run("VersionNotFound", func(t *testing.T) {
+		t.Parallel()
+
+		client := coderdtest.New(t, &coderdtest.Options{IncludeProvisionerDaemon: true})
+		user := coderdtest.CreateFirstUser(t, client)
+
+		version := coderdtest.CreateTemplateVersion(t, client, user.OrganizationID, genTemplateVersionSource())
+		_ = coderdtest.AwaitTemplateVersionJob(t, client, version.ID)
+		template := coderdtest.CreateTemplate(t, client, user.OrganizationID, version.ID)
+
+		// A version of another template doesn't count.
+		otherVersion := coderdtest.CreateTemplateVersion(t, client, user.OrganizationID, genTemplateVersionSource())
+		_ = coderdtest.AwaitTemplateVersionJob(t, client, otherVersion.ID)
+		_ = coderdtest.CreateTemplate(t, client, user.OrganizationID, otherVersion.ID)
+
+		inv, root := clitest.New(t, "templates", "pull", "--tar", template.Name, "--version", otherVersion.Name)
+		clitest.SetupConfig(t, client, root)
+
+		err := inv.Run()
+		require.ErrorContains(t, err, "has no version named")
+	})
+
 	// ToDir tests that 'templates pull' pulls down the latest template
 	// and writes it to the correct directory.
 	t.Run("ToDir", func(t *testing.T) {
@@ -137,6 +198,124 @@ func TestTemplatePull(t *testing.T) {
 		)
 	})
 
+	// Zip tests that 'templates pull --zip' outputs the same files as the
+	// tar archive.
+	t.Run("Zip", func(t *testing.T) {
+		t.Parallel()
+
+		client := coderdtest.New(t, &coderdtest.Options{IncludeProvisionerDaemon: true})
+		user := coderdtest.CreateFirstUser(t, client)
+
+		source := genTemplateVersionSource()
+		expected, err := echo.Tar(source)
+		require.NoError(t, err)
+
+		version := coderdtest.CreateTemplateVersion(t, client, user.OrganizationID, source)
+		_ = coderdtest.AwaitTemplateVersionJob(t, client, version.ID)
+		template := coderdtest.CreateTemplate(t, client, user.OrganizationID, version.ID)
+
+		dir := t.TempDir()
+		expectedDest := filepath.Join(dir, "expected")
+		actualDest := filepath.Join(dir, "actual")
+		ctx := context.Background()
+
+		err = extract.Tar(ctx, bytes.NewReader(expected), expectedDest, nil)
+		require.NoError(t, err)
+
+		inv, root := clitest.New(t, "templates", "pull", "--zip", template.Name)
+		clitest.SetupConfig(t, client, root)
+
+		var buf bytes.Buffer
+		inv.Stdout = &buf
+
+		require.NoError(t, inv.Run())
+
+		err = extract.Zip(ctx, bytes.NewReader(buf.Bytes()), actualDest, nil)
+		require.NoError(t, err)
+
+		require.Equal(t,
+			dirSum(t, expectedDest),
+			dirSum(t, actualDest),
+		)
+
+		// With a destination, the archive is written to <destination>.zip.
+		zipDest := filepath.Join(dir, "template")
+		inv, root = clitest.New(t, "templates", "pull", "--zip", template.Name, zipDest)
+		clitest.SetupConfig(t, client, root)
+
+		require.NoError(t, inv.Run())
+
+		written, err := os.ReadFile(zipDest + ".zip")
+		require.NoError(t, err)
+		require.Equal(t, buf.Bytes(), written)
+	})
+
+	t.Run("TarAndZip", func(t *testing.T) {
+		t.Parallel()
+
+		client := coderdtest.New(t, nil)
+		_ = coderdtest.CreateFirstUser(t, client)
+
+		inv, root := clitest.New(t, "templates", "pull", "--tar", "--zip", "my-template")
+		clitest.SetupConfig(t, client, root)
+
+		err := inv.Run()
+		require.ErrorContains(t, err, "mutually exclusive")
+	})
+
+	// ChecksumMismatch tests that 'templates pull' refuses an archive that
+	// doesn't match the checksum reported by the server, before writing
+	// anything to the destination.
+	t.Run("ChecksumMismatch", func(t *testing.T) {
+		t.Parallel()
+
+		client := coderdtest.New(t, &coderdtest.Options{IncludeProvisionerDaemon: true})
+		user := coderdtest.CreateFirstUser(t, client)
+
+		version := coderdtest.CreateTemplateVersion(t, client, user.OrganizationID, genTemplateVersionSource())
+		_ = coderdtest.AwaitTemplateVersionJob(t, client, version.ID)
+		template := coderdtest.CreateTemplate(t, client, user.OrganizationID, version.ID)
+
+		// Report a bogus checksum for every downloaded file.
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rp := httputil.NewSingleHostReverseProxy(client.URL)
+			tp := &http.Transport{}
+			defer tp.CloseIdleConnections()
+			rp.Transport = tp
+			rp.ModifyResponse = func(res *http.Response) error {
+				if strings.HasPrefix(r.URL.Path, "/api/v2/files/") {
+					res.Header.Set(codersdk.FileHashHeader, strings.Repeat("0", 64))
+				}
+				return nil
+			}
+			rp.ServeHTTP(w, r)
+		}))
+		t.Cleanup(proxy.Close)
+
+		proxyURL, err := url.Parse(proxy.URL)
+		require.NoError(t, err)
+		proxyClient := codersdk.New(proxyURL)
+		proxyClient.SetSessionToken(client.SessionToken())
+		t.Cleanup(proxyClient.HTTPClient.CloseIdleConnections)
+
+		dest := filepath.Join(t.TempDir(), "template")
+		inv, root := clitest.New(t, "templates", "pull", template.Name, dest)
+		clitest.SetupConfig(t, proxyClient, root)
+
+		err = inv.Run()
+		require.ErrorContains(t, err, "checksum mismatch")
+		_, err = os.Stat(dest)
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		inv, root = clitest.New(t, "templates", "pull", "--skip-verify", template.Name, dest)
+		clitest.SetupConfig(t, proxyClient, root)
+
+		require.NoError(t, inv.Run())
+		ents, err := os.ReadDir(dest)
+		require.NoError(t, err)
+		require.NotEmpty(t, ents)
+	})
+
 	// FolderConflict tests that 'templates pull' fails when a folder with has
 	// existing
 	t.Run("FolderConflict", func(t *testing.T) {
@@ -199,6 +378,80 @@ func TestTemplatePull(t *testing.T) {
 
 		require.Len(t, ents, 1, "conflict folder should have single conflict file")
 	})
+
+	// FolderConflictOverwrite tests that 'templates pull' replaces the
+	// contents of a non-empty folder when asked to, either interactively or
+	// with --force.
+	t.Run("FolderConflictOverwrite", func(t *testing.T) {
+		t.Parallel()
+
+		client := coderdtest.New(t, &coderdtest.Options{IncludeProvisionerDaemon: true})
+		user := coderdtest.CreateFirstUser(t, client)
+
+		source := genTemplateVersionSource()
+		expected, err := echo.Tar(source)
+		require.NoError(t, err)
+
+		version := coderdtest.CreateTemplateVersion(t, client, user.OrganizationID, source)
+		_ = coderdtest.AwaitTemplateVersionJob(t, client, version.ID)
+		template := coderdtest.CreateTemplate(t, client, user.OrganizationID, version.ID)
+
+		expectedDest := filepath.Join(t.TempDir(), "expected")
+		err = extract.Tar(context.Background(), bytes.NewReader(expected), expectedDest, nil)
+		require.NoError(t, err)
+
+		// newConflictDest creates a folder with a file that conflicts with
+		// one of the template, and one that isn't part of the template.
+		newConflictDest := func(t *testing.T) string {
+			conflictDest := filepath.Join(t.TempDir(), "conflict")
+			err := os.MkdirAll(conflictDest, 0o700)
+			require.NoError(t, err)
+			err = os.WriteFile(filepath.Join(conflictDest, "0.parse.protobuf"), []byte("conflict"), 0o600)
+			require.NoError(t, err)
+			err = os.WriteFile(filepath.Join(conflictDest, "stale-file"), []byte("stale"), 0o600)
+			require.NoError(t, err)
+			return conflictDest
+		}
+
+		t.Run("Prompt", func(t *testing.T) {
+			t.Parallel()
+
+			conflictDest := newConflictDest(t)
+			inv, root := clitest.New(t, "templates", "pull", template.Name, conflictDest)
+			clitest.SetupConfig(t, client, root)
+
+			pty := ptytest.New(t).Attach(inv)
+
+			waiter := clitest.StartWithWaiter(t, inv)
+
+			pty.ExpectMatch("not empty")
+			pty.WriteLine("overwrite")
+
+			waiter.RequireSuccess()
+
+			require.Equal(t,
+				dirSum(t, expectedDest),
+				dirSum(t, conflictDest),
+			)
+			require.NoFileExists(t, filepath.Join(conflictDest, "stale-file"))
+		})
+
+		t.Run("Force", func(t *testing.T) {
+			t.Parallel()
+
+			conflictDest := newConflictDest(t)
+			inv, root := clitest.New(t, "templates", "pull", "--force", template.Name, conflictDest)
+			clitest.SetupConfig(t, client, root)
+
+			require.NoError(t, inv.Run())
+
+			require.Equal(t,
+				dirSum(t, expectedDest),
+				dirSum(t, conflictDest),
+			)
+			require.NoFileExists(t, filepath.Join(conflictDest, "stale-file"))
+		})
+	})
 }
 
 // genTemplateVersionSource returns a unique bundle that can be used to create
@@ -7236,6 +7236,10 @@ const docTemplate = `{
                 "email": {
                     "type": "string"
                 },
+                "name": {
+                    "description": "Name is the full name of the user, for display. It is optional.",
+                    "type": "string"
+                },
                 "password": {
                     "type": "string"
                 },
@@ -7427,6 +7431,10 @@ const docTemplate = `{
                     "type": "string",
                     "format": "uuid"
                 },
+                "throwaway": {
+                    "description": "Throwaway marks a version that is only imported to inspect its resources\nand parameters. It can't be used to create a template, be promoted or be\nbuilt, and is deleted after a day.",
+                    "type": "boolean"
+                },
                 "user_variable_values": {
                     "type": "array",
                     "items": {
@@ -9642,6 +9650,9 @@ const docTemplate = `{
                 "login_type": {
                     "$ref": "#/definitions/codersdk.LoginType"
                 },
+                "name": {
+                    "type": "string"
+                },
                 "organization_ids": {
                     "type": "array",
                     "items": {
@@ -10098,6 +10109,9 @@ const docTemplate = `{
                 "login_type": {
                     "$ref": "#/definitions/codersdk.LoginType"
                 },
+                "name": {
+                    "type": "string"
+                },
                 "organization_ids": {
                     "type": "array",
                     "items": {
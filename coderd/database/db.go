@@ -48,13 +48,29 @@ type DBTX interface {
 	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 }
 
+// Option configures a database store created by New.
+type Option func(*sqlQuerier)
+
+// WithAuthorizedQueryHook calls hook with each authorized query before it is executed, with the authorization filter
+// compiled from the RBAC policy inserted, e.g. to log it when an authorized query returns unexpected results.  The
+// arguments of the query are only referred to by their placeholders, so their values are never passed to the hook.
+func WithAuthorizedQueryHook(hook func(ctx context.Context, query string)) Option {
+	return func(q *sqlQuerier) {
+		q.authorizedQueryHook = hook
+	}
+}
+
 // New creates a new database store using a SQL database connection.
-func New(sdb *sql.DB) Store {
+func New(sdb *sql.DB, opts ...Option) Store {
 	dbx := sqlx.NewDb(sdb, "postgres")
-	return &sqlQuerier{
+	q := &sqlQuerier{
 		db:  dbx,
 		sdb: dbx,
 	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
 }
 
 // queries encompasses both are sqlc generated
@@ -67,6 +83,8 @@ type querier interface {
 type sqlQuerier struct {
 	sdb *sqlx.DB
 	db  DBTX
+
+	authorizedQueryHook func(ctx context.Context, query string)
 }
 
 func (*sqlQuerier) Wrappers() []string {
@@ -140,7 +158,7 @@ func (q *sqlQuerier) runTx(function func(Store) error, txOpts *sql.TxOptions) er
 		// couldn't roll back for some reason, extend returned error
 		err = xerrors.Errorf("defer (%s): %w", rerr.Error(), err)
 	}()
-	err = function(&sqlQuerier{db: transaction})
+	err = function(&sqlQuerier{db: transaction, authorizedQueryHook: q.authorizedQueryHook})
 	if err != nil {
 		return xerrors.Errorf("execute transaction: %w", err)
 	}
@@ -111,6 +111,7 @@ func User(user database.User, organizationIDs []uuid.UUID) codersdk.User {
 		CreatedAt:       user.CreatedAt,
 		LastSeenAt:      user.LastSeenAt,
 		Username:        user.Username,
+		Name:            user.Name,
 		Status:          codersdk.UserStatus(user.Status),
 		OrganizationIDs: organizationIDs,
 		Roles:           make([]codersdk.Role, 0, len(user.RBACRoles)),
@@ -722,6 +722,13 @@ func (q *querier) DeleteLicense(ctx context.Context, id int32) (int32, error) {
 	return id, nil
 }
 
+func (q *querier) DeleteOldThrowawayTemplateVersions(ctx context.Context) error {
+	if err := q.authorizeContext(ctx, rbac.ActionDelete, rbac.ResourceSystem); err != nil {
+		return err
+	}
+	return q.db.DeleteOldThrowawayTemplateVersions(ctx)
+}
+
 func (q *querier) DeleteOldWorkspaceAgentLogs(ctx context.Context) error {
 	if err := q.authorizeContext(ctx, rbac.ActionDelete, rbac.ResourceSystem); err != nil {
 		return err
@@ -757,6 +764,13 @@ func (q *querier) DeleteTailnetClient(ctx context.Context, arg database.DeleteTa
 	return q.db.DeleteTailnetClient(ctx, arg)
 }
 
+func (q *querier) FailStalePendingJobs(ctx context.Context, now time.Time) ([]database.ProvisionerJob, error) {
+	if err := q.authorizeContext(ctx, rbac.ActionUpdate, rbac.ResourceSystem); err != nil {
+		return nil, err
+	}
+	return q.db.FailStalePendingJobs(ctx, now)
+}
+
 func (q *querier) GetAPIKeyByID(ctx context.Context, id string) (database.APIKey, error) {
 	return fetch(q.log, q.auth, q.db.GetAPIKeyByID)(ctx, id)
 }
@@ -777,6 +791,13 @@ func (q *querier) GetAPIKeysLastUsedAfter(ctx context.Context, lastUsed time.Tim
 	return fetchWithPostFilter(q.auth, q.db.GetAPIKeysLastUsedAfter)(ctx, lastUsed)
 }
 
+func (q *querier) GetActiveJobCountsByTemplate(ctx context.Context) ([]database.GetActiveJobCountsByTemplateRow, error) {
+	if err := q.authorizeContext(ctx, rbac.ActionRead, rbac.ResourceSystem); err != nil {
+		return nil, err
+	}
+	return q.db.GetActiveJobCountsByTemplate(ctx)
+}
+
 func (q *querier) GetActiveUserCount(ctx context.Context) (int64, error) {
 	if err := q.authorizeContext(ctx, rbac.ActionRead, rbac.ResourceSystem); err != nil {
 		return 0, err
@@ -934,6 +955,24 @@ func (q *querier) GetLastUpdateCheck(ctx context.Context) (string, error) {
 	return q.db.GetLastUpdateCheck(ctx)
 }
 
+func (q *querier) GetLatestBuildByTemplateVersionID(ctx context.Context, templateVersionID uuid.UUID) (database.WorkspaceBuild, error) {
+	build, err := q.db.GetLatestBuildByTemplateVersionID(ctx, templateVersionID)
+	if err != nil {
+		return database.WorkspaceBuild{}, err
+	}
+	if _, err := q.GetWorkspaceByID(ctx, build.WorkspaceID); err != nil {
+		return database.WorkspaceBuild{}, err
+	}
+	return build, nil
+}
+
+func (q *querier) GetLatestSuccessfulWorkspaceBuildByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) (database.WorkspaceBuild, error) {
+	if _, err := q.GetWorkspaceByID(ctx, workspaceID); err != nil {
+		return database.WorkspaceBuild{}, err
+	}
+	return q.db.GetLatestSuccessfulWorkspaceBuildByWorkspaceID(ctx, workspaceID)
+}
+
 func (q *querier) GetLatestWorkspaceBuildByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) (database.WorkspaceBuild, error) {
 	if _, err := q.GetWorkspaceByID(ctx, workspaceID); err != nil {
 		return database.WorkspaceBuild{}, err
@@ -982,6 +1021,13 @@ func (q *querier) GetLogoURL(ctx context.Context) (string, error) {
 	return q.db.GetLogoURL(ctx)
 }
 
+func (q *querier) GetMostUsedTemplates(ctx context.Context, arg database.GetMostUsedTemplatesParams) ([]database.GetMostUsedTemplatesRow, error) {
+	if err := q.authorizeContext(ctx, rbac.ActionRead, rbac.ResourceSystem); err != nil {
+		return nil, err
+	}
+	return q.db.GetMostUsedTemplates(ctx, arg)
+}
+
 func (q *querier) GetOAuthSigningKey(ctx context.Context) (string, error) {
 	if err := q.authorizeContext(ctx, rbac.ActionUpdate, rbac.ResourceSystem); err != nil {
 		return "", err
@@ -1156,6 +1202,13 @@ func (q *querier) GetServiceBanner(ctx context.Context) (string, error) {
 	return q.db.GetServiceBanner(ctx)
 }
 
+func (q *querier) GetStuckRunningBuilds(ctx context.Context, startedBefore time.Time) ([]database.WorkspaceBuild, error) {
+	if err := q.authorizeContext(ctx, rbac.ActionRead, rbac.ResourceSystem); err != nil {
+		return nil, err
+	}
+	return q.db.GetStuckRunningBuilds(ctx, startedBefore)
+}
+
 func (q *querier) GetTailnetAgents(ctx context.Context, id uuid.UUID) ([]database.TailnetAgent, error) {
 	if err := q.authorizeContext(ctx, rbac.ActionRead, rbac.ResourceTailnetCoordinator); err != nil {
 		return nil, err
@@ -1178,6 +1231,13 @@ func (q *querier) GetTemplateAverageBuildTime(ctx context.Context, arg database.
 	return q.db.GetTemplateAverageBuildTime(ctx, arg)
 }
 
+func (q *querier) GetTemplateBuildsPerDay(ctx context.Context, arg database.GetTemplateBuildsPerDayParams) ([]database.GetTemplateBuildsPerDayRow, error) {
+	if err := q.authorizeContext(ctx, rbac.ActionRead, rbac.ResourceSystem); err != nil {
+		return nil, err
+	}
+	return q.db.GetTemplateBuildsPerDay(ctx, arg)
+}
+
 func (q *querier) GetTemplateByID(ctx context.Context, id uuid.UUID) (database.Template, error) {
 	return fetch(q.log, q.auth, q.db.GetTemplateByID)(ctx, id)
 }
@@ -1361,6 +1421,20 @@ func (q *querier) GetUnexpiredLicenses(ctx context.Context) ([]database.License,
 	return q.db.GetUnexpiredLicenses(ctx)
 }
 
+func (q *querier) GetUnusedTemplateVersions(ctx context.Context, templateID uuid.UUID) ([]database.TemplateVersion, error) {
+	// An actor can read template versions if they can read the related template.
+	template, err := q.db.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.authorizeContext(ctx, rbac.ActionRead, template); err != nil {
+		return nil, err
+	}
+
+	return q.db.GetUnusedTemplateVersions(ctx, templateID)
+}
+
 func (q *querier) GetUserByEmailOrUsername(ctx context.Context, arg database.GetUserByEmailOrUsernameParams) (database.User, error) {
 	return fetch(q.log, q.auth, q.db.GetUserByEmailOrUsername)(ctx, arg)
 }
@@ -1376,6 +1450,10 @@ func (q *querier) GetUserCount(ctx context.Context) (int64, error) {
 	return q.db.GetUserCount(ctx)
 }
 
+func (q *querier) GetUserGroups(ctx context.Context, userID uuid.UUID) ([]database.Group, error) {
+	return fetchWithPostFilter(q.auth, q.db.GetUserGroups)(ctx, userID)
+}
+
 func (q *querier) GetUserLatencyInsights(ctx context.Context, arg database.GetUserLatencyInsightsParams) ([]database.GetUserLatencyInsightsRow, error) {
 	if err := q.authorizeContext(ctx, rbac.ActionRead, rbac.ResourceSystem); err != nil {
 		return nil, err
@@ -1576,6 +1654,21 @@ func (q *querier) GetWorkspaceBuildByWorkspaceIDAndBuildNumber(ctx context.Conte
 	return q.db.GetWorkspaceBuildByWorkspaceIDAndBuildNumber(ctx, arg)
 }
 
+func (q *querier) GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(ctx context.Context, arg database.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams) (database.WorkspaceBuild, error) {
+	if _, err := q.GetWorkspaceByID(ctx, arg.WorkspaceID); err != nil {
+		return database.WorkspaceBuild{}, err
+	}
+	return q.db.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(ctx, arg)
+}
+
+func (q *querier) GetWorkspaceBuildIntentsByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) ([]database.WorkspaceBuildIntent, error) {
+	// Intents are readable if the workspace they belong to is readable.
+	if _, err := q.GetWorkspaceByID(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	return q.db.GetWorkspaceBuildIntentsByWorkspaceID(ctx, workspaceID)
+}
+
 func (q *querier) GetWorkspaceBuildParameters(ctx context.Context, workspaceBuildID uuid.UUID) ([]database.WorkspaceBuildParameter, error) {
 	// Authorized call to get the workspace build. If we can read the build,
 	// we can read the params.
@@ -1587,6 +1680,32 @@ func (q *querier) GetWorkspaceBuildParameters(ctx context.Context, workspaceBuil
 	return q.db.GetWorkspaceBuildParameters(ctx, workspaceBuildID)
 }
 
+func (q *querier) GetWorkspaceBuildVersionLineage(ctx context.Context, workspaceID uuid.UUID) ([]database.GetWorkspaceBuildVersionLineageRow, error) {
+	if _, err := q.GetWorkspaceByID(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	return q.db.GetWorkspaceBuildVersionLineage(ctx, workspaceID)
+}
+
+func (q *querier) GetWorkspaceBuilds(ctx context.Context, arg database.GetWorkspaceBuildsParams) ([]database.GetWorkspaceBuildsRow, error) {
+	// Builds are readable if the workspace they belong to is readable.
+	prep, err := prepareSQLFilter(ctx, q.auth, rbac.ActionRead, rbac.ResourceWorkspace.Type)
+	if err != nil {
+		return nil, xerrors.Errorf("(dev error) prepare sql filter: %w", err)
+	}
+	return q.db.GetAuthorizedWorkspaceBuilds(ctx, arg, prep)
+}
+
+func (q *querier) GetWorkspaceBuildsByInitiator(ctx context.Context, arg database.GetWorkspaceBuildsByInitiatorParams) ([]database.GetWorkspaceBuildsByInitiatorRow, error) {
+	// A user's builds are their activity, so reading them requires reading the
+	// initiator's workspaces. Authorizing up front rather than filtering rows
+	// keeps pages full.
+	if err := q.authorizeContext(ctx, rbac.ActionRead, rbac.ResourceWorkspace.WithOwner(arg.InitiatorID.String())); err != nil {
+		return nil, err
+	}
+	return q.db.GetWorkspaceBuildsByInitiator(ctx, arg)
+}
+
 func (q *querier) GetWorkspaceBuildsByWorkspaceID(ctx context.Context, arg database.GetWorkspaceBuildsByWorkspaceIDParams) ([]database.WorkspaceBuild, error) {
 	if _, err := q.GetWorkspaceByID(ctx, arg.WorkspaceID); err != nil {
 		return nil, err
@@ -1604,6 +1723,14 @@ func (q *querier) GetWorkspaceBuildsCreatedAfter(ctx context.Context, createdAt
 	return q.db.GetWorkspaceBuildsCreatedAfter(ctx, createdAt)
 }
 
+func (q *querier) GetWorkspaceBuildsWithParametersJSON(ctx context.Context, workspaceID uuid.UUID) ([]database.GetWorkspaceBuildsWithParametersJSONRow, error) {
+	// Authorized by reading the workspace the builds belong to.
+	if _, err := q.GetWorkspaceByID(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	return q.db.GetWorkspaceBuildsWithParametersJSON(ctx, workspaceID)
+}
+
 func (q *querier) GetWorkspaceByAgentID(ctx context.Context, agentID uuid.UUID) (database.Workspace, error) {
 	return fetch(q.log, q.auth, q.db.GetWorkspaceByAgentID)(ctx, agentID)
 }
@@ -1620,6 +1747,14 @@ func (q *querier) GetWorkspaceByWorkspaceAppID(ctx context.Context, workspaceApp
 	return fetch(q.log, q.auth, q.db.GetWorkspaceByWorkspaceAppID)(ctx, workspaceAppID)
 }
 
+func (q *querier) GetWorkspaceCountByOwner(ctx context.Context, arg database.GetWorkspaceCountByOwnerParams) (int64, error) {
+	err := q.authorizeContext(ctx, rbac.ActionRead, rbac.ResourceUserObject(arg.OwnerID))
+	if err != nil {
+		return -1, err
+	}
+	return q.db.GetWorkspaceCountByOwner(ctx, arg)
+}
+
 func (q *querier) GetWorkspaceProxies(ctx context.Context) ([]database.WorkspaceProxy, error) {
 	return fetchWithPostFilter(q.auth, func(ctx context.Context, _ interface{}) ([]database.WorkspaceProxy, error) {
 		return q.db.GetWorkspaceProxies(ctx)
@@ -1742,10 +1877,25 @@ func (q *querier) GetWorkspaces(ctx context.Context, arg database.GetWorkspacesP
 	return q.db.GetAuthorizedWorkspaces(ctx, arg, prep)
 }
 
+func (q *querier) GetWorkspacesDueForAutostop(ctx context.Context, now time.Time) ([]database.GetWorkspacesDueForAutostopRow, error) {
+	if err := q.authorizeContext(ctx, rbac.ActionRead, rbac.ResourceSystem); err != nil {
+		return nil, err
+	}
+	return q.db.GetWorkspacesDueForAutostop(ctx, now)
+}
+
 func (q *querier) GetWorkspacesEligibleForTransition(ctx context.Context, now time.Time) ([]database.Workspace, error) {
 	return q.db.GetWorkspacesEligibleForTransition(ctx, now)
 }
 
+func (q *querier) GetWorkspacesWithFailedLatestBuild(ctx context.Context, arg database.GetWorkspacesWithFailedLatestBuildParams) ([]database.GetWorkspacesWithFailedLatestBuildRow, error) {
+	prep, err := prepareSQLFilter(ctx, q.auth, rbac.ActionRead, rbac.ResourceWorkspace.Type)
+	if err != nil {
+		return nil, xerrors.Errorf("(dev error) prepare sql filter: %w", err)
+	}
+	return q.db.GetAuthorizedWorkspacesWithFailedLatestBuild(ctx, arg, prep)
+}
+
 func (q *querier) InsertAPIKey(ctx context.Context, arg database.InsertAPIKeyParams) (database.APIKey, error) {
 	return insert(q.log, q.auth,
 		rbac.ResourceAPIKey.WithOwner(arg.UserID.String()),
@@ -1993,6 +2143,25 @@ func (q *querier) InsertWorkspaceBuild(ctx context.Context, arg database.InsertW
 	return q.db.InsertWorkspaceBuild(ctx, arg)
 }
 
+func (q *querier) InsertWorkspaceBuildIntent(ctx context.Context, arg database.InsertWorkspaceBuildIntentParams) error {
+	// Recording the intent to build requires the same permission as the build.
+	w, err := q.db.GetWorkspaceByID(ctx, arg.WorkspaceID)
+	if err != nil {
+		return err
+	}
+
+	var action rbac.Action = rbac.ActionUpdate
+	if arg.Transition == database.WorkspaceTransitionDelete {
+		action = rbac.ActionDelete
+	}
+
+	if err = q.authorizeContext(ctx, action, w.WorkspaceBuildRBAC(arg.Transition)); err != nil {
+		return err
+	}
+
+	return q.db.InsertWorkspaceBuildIntent(ctx, arg)
+}
+
 func (q *querier) InsertWorkspaceBuildParameters(ctx context.Context, arg database.InsertWorkspaceBuildParametersParams) error {
 	// TODO: Optimize this. We always have the workspace and build already fetched.
 	build, err := q.db.GetWorkspaceBuildByID(ctx, arg.WorkspaceBuildID)
@@ -2515,6 +2684,19 @@ func (q *querier) UpdateWorkspaceBuildCostByID(ctx context.Context, arg database
 	return q.db.UpdateWorkspaceBuildCostByID(ctx, arg)
 }
 
+func (q *querier) UpdateWorkspaceBuildIntentBuildByID(ctx context.Context, arg database.UpdateWorkspaceBuildIntentBuildByIDParams) error {
+	workspace, err := q.db.GetWorkspaceByID(ctx, arg.WorkspaceID)
+	if err != nil {
+		return err
+	}
+	err = q.authorizeContext(ctx, rbac.ActionUpdate, workspace.RBACObject())
+	if err != nil {
+		return err
+	}
+
+	return q.db.UpdateWorkspaceBuildIntentBuildByID(ctx, arg)
+}
+
 // Deprecated: Use SoftDeleteWorkspaceByID
 func (q *querier) UpdateWorkspaceDeletedByID(ctx context.Context, arg database.UpdateWorkspaceDeletedByIDParams) error {
 	// TODO deleteQ me, placeholder for database.Store
@@ -2634,6 +2816,26 @@ func (q *querier) GetAuthorizedTemplates(ctx context.Context, arg database.GetTe
 	return q.GetTemplatesWithFilter(ctx, arg)
 }
 
+func (q *querier) CountAuthorizedTemplates(ctx context.Context, arg database.GetTemplatesWithFilterParams, _ rbac.PreparedAuthorized) (int64, error) {
+	prep, err := prepareSQLFilter(ctx, q.auth, rbac.ActionRead, rbac.ResourceTemplate.Type)
+	if err != nil {
+		return 0, xerrors.Errorf("(dev error) prepare sql filter: %w", err)
+	}
+	return q.db.CountAuthorizedTemplates(ctx, arg, prep)
+}
+
+func (q *querier) GetTemplateACL(ctx context.Context, id uuid.UUID) (database.TemplateACLRoles, error) {
+	// An actor is authorized to read the template ACL if they are authorized to update the template.
+	template, err := q.db.GetTemplateByID(ctx, id)
+	if err != nil {
+		return database.TemplateACLRoles{}, err
+	}
+	if err := q.authorizeContext(ctx, rbac.ActionUpdate, template); err != nil {
+		return database.TemplateACLRoles{}, err
+	}
+	return q.db.GetTemplateACL(ctx, id)
+}
+
 func (q *querier) GetTemplateGroupRoles(ctx context.Context, id uuid.UUID) ([]database.TemplateGroup, error) {
 	// An actor is authorized to read template group roles if they are authorized to update the template.
 	template, err := q.db.GetTemplateByID(ctx, id)
@@ -2663,6 +2865,37 @@ func (q *querier) GetAuthorizedWorkspaces(ctx context.Context, arg database.GetW
 	return q.GetWorkspaces(ctx, arg)
 }
 
+// GetAuthorizedWorkspacesWithFailedLatestBuild is not required for dbauthz
+// since GetWorkspacesWithFailedLatestBuild is already authenticated.
+func (q *querier) GetAuthorizedWorkspacesWithFailedLatestBuild(ctx context.Context, arg database.GetWorkspacesWithFailedLatestBuildParams, _ rbac.PreparedAuthorized) ([]database.GetWorkspacesWithFailedLatestBuildRow, error) {
+	// GetWorkspacesWithFailedLatestBuild is authenticated.
+	return q.GetWorkspacesWithFailedLatestBuild(ctx, arg)
+}
+
+// GetAuthorizedWorkspaceBuilds is not required for dbauthz since
+// GetWorkspaceBuilds is already authenticated.
+func (q *querier) GetAuthorizedWorkspaceBuilds(ctx context.Context, arg database.GetWorkspaceBuildsParams, _ rbac.PreparedAuthorized) ([]database.GetWorkspaceBuildsRow, error) {
+	// GetWorkspaceBuilds is authenticated.
+	return q.GetWorkspaceBuilds(ctx, arg)
+}
+
+func (q *querier) GetWorkspaceBuildParametersMap(ctx context.Context, workspaceBuildID uuid.UUID) (map[string]string, error) {
+	// GetWorkspaceBuildParameters is authenticated.
+	params, err := q.GetWorkspaceBuildParameters(ctx, workspaceBuildID)
+	if err != nil {
+		return nil, err
+	}
+	return database.WorkspaceBuildParametersMap(params), nil
+}
+
+func (q *querier) GetWorkspaceBuildsWithParameters(ctx context.Context, workspaceID uuid.UUID) ([]database.WorkspaceBuildWithParameters, error) {
+	// Authorized by reading the workspace the builds belong to.
+	if _, err := q.GetWorkspaceByID(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	return q.db.GetWorkspaceBuildsWithParameters(ctx, workspaceID)
+}
+
 // GetAuthorizedUsers is not required for dbauthz since GetUsers is already
 // authenticated.
 func (q *querier) GetAuthorizedUsers(ctx context.Context, arg database.GetUsersParams, _ rbac.PreparedAuthorized) ([]database.GetUsersRow, error) {
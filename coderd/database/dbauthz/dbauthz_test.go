@@ -290,6 +290,15 @@ func (s *MethodTestSuite) TestGroup() {
 		_ = dbgen.GroupMember(s.T(), db, database.GroupMember{})
 		check.Args(g.ID).Asserts(g, rbac.ActionRead)
 	}))
+	s.Run("GetUserGroups", s.Subtest(func(db database.Store, check *expects) {
+		u := dbgen.User(s.T(), db, database.User{})
+		a := dbgen.Group(s.T(), db, database.Group{Name: "a"})
+		b := dbgen.Group(s.T(), db, database.Group{Name: "b"})
+		_ = dbgen.GroupMember(s.T(), db, database.GroupMember{UserID: u.ID, GroupID: a.ID})
+		_ = dbgen.GroupMember(s.T(), db, database.GroupMember{UserID: u.ID, GroupID: b.ID})
+		check.Args(u.ID).Asserts(a, rbac.ActionRead, b, rbac.ActionRead).
+			Returns([]database.Group{a, b})
+	}))
 	s.Run("InsertAllUsersGroup", s.Subtest(func(db database.Store, check *expects) {
 		o := dbgen.Organization(s.T(), db, database.Organization{})
 		check.Args(o.ID).Asserts(rbac.ResourceGroup.InOrg(o.ID), rbac.ActionCreate)
@@ -696,6 +705,10 @@ func (s *MethodTestSuite) TestTemplate() {
 		})
 		check.Args(tv.ID).Asserts(t1, rbac.ActionRead).Returns([]database.TemplateVersionVariable{tvv1})
 	}))
+	s.Run("GetTemplateACL", s.Subtest(func(db database.Store, check *expects) {
+		t1 := dbgen.Template(s.T(), db, database.Template{})
+		check.Args(t1.ID).Asserts(t1, rbac.ActionUpdate)
+	}))
 	s.Run("GetTemplateGroupRoles", s.Subtest(func(db database.Store, check *expects) {
 		t1 := dbgen.Template(s.T(), db, database.Template{})
 		check.Args(t1.ID).Asserts(t1, rbac.ActionUpdate)
@@ -724,6 +737,14 @@ func (s *MethodTestSuite) TestTemplate() {
 		}).Asserts(t1, rbac.ActionRead).
 			Returns(slice.New(a, b))
 	}))
+	s.Run("GetUnusedTemplateVersions", s.Subtest(func(db database.Store, check *expects) {
+		t1 := dbgen.Template(s.T(), db, database.Template{})
+		a := dbgen.TemplateVersion(s.T(), db, database.TemplateVersion{
+			TemplateID: uuid.NullUUID{UUID: t1.ID, Valid: true},
+		})
+		check.Args(t1.ID).Asserts(t1, rbac.ActionRead).
+			Returns(slice.New(a))
+	}))
 	s.Run("GetTemplateVersionsCreatedAfter", s.Subtest(func(db database.Store, check *expects) {
 		now := time.Now()
 		t1 := dbgen.Template(s.T(), db, database.Template{})
@@ -750,6 +771,13 @@ func (s *MethodTestSuite) TestTemplate() {
 			Asserts().
 			Returns(slice.New(a))
 	}))
+	s.Run("CountAuthorizedTemplates", s.Subtest(func(db database.Store, check *expects) {
+		_ = dbgen.Template(s.T(), db, database.Template{})
+		// No asserts because SQLFilter.
+		check.Args(database.GetTemplatesWithFilterParams{}, emptyPreparedAuthorized{}).
+			Asserts().
+			Returns(int64(1))
+	}))
 	s.Run("InsertTemplate", s.Subtest(func(db database.Store, check *expects) {
 		orgID := uuid.New()
 		check.Args(database.InsertTemplateParams{
@@ -851,6 +879,11 @@ func (s *MethodTestSuite) TestUser() {
 		u := dbgen.User(s.T(), db, database.User{})
 		check.Args(u.ID).Asserts(u, rbac.ActionRead).Returns(int64(0))
 	}))
+	s.Run("GetWorkspaceCountByOwner", s.Subtest(func(db database.Store, check *expects) {
+		u := dbgen.User(s.T(), db, database.User{})
+		_ = dbgen.Workspace(s.T(), db, database.Workspace{OwnerID: u.ID})
+		check.Args(database.GetWorkspaceCountByOwnerParams{OwnerID: u.ID}).Asserts(u, rbac.ActionRead).Returns(int64(1))
+	}))
 	s.Run("GetUserByEmailOrUsername", s.Subtest(func(db database.Store, check *expects) {
 		u := dbgen.User(s.T(), db, database.User{})
 		check.Args(database.GetUserByEmailOrUsernameParams{
@@ -1019,11 +1052,34 @@ func (s *MethodTestSuite) TestWorkspace() {
 		// No asserts here because SQLFilter.
 		check.Args(database.GetWorkspacesParams{}, emptyPreparedAuthorized{}).Asserts()
 	}))
+	s.Run("GetWorkspacesWithFailedLatestBuild", s.Subtest(func(db database.Store, check *expects) {
+		_ = dbgen.Workspace(s.T(), db, database.Workspace{})
+		// No asserts here because SQLFilter.
+		check.Args(database.GetWorkspacesWithFailedLatestBuildParams{}).Asserts()
+	}))
+	s.Run("GetAuthorizedWorkspacesWithFailedLatestBuild", s.Subtest(func(db database.Store, check *expects) {
+		_ = dbgen.Workspace(s.T(), db, database.Workspace{})
+		// No asserts here because SQLFilter.
+		check.Args(database.GetWorkspacesWithFailedLatestBuildParams{}, emptyPreparedAuthorized{}).Asserts()
+	}))
 	s.Run("GetLatestWorkspaceBuildByWorkspaceID", s.Subtest(func(db database.Store, check *expects) {
 		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
 		b := dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID})
 		check.Args(ws.ID).Asserts(ws, rbac.ActionRead).Returns(b)
 	}))
+	s.Run("GetLatestBuildByTemplateVersionID", s.Subtest(func(db database.Store, check *expects) {
+		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
+		b := dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID})
+		check.Args(b.TemplateVersionID).Asserts(ws, rbac.ActionRead).Returns(b)
+	}))
+	s.Run("GetLatestSuccessfulWorkspaceBuildByWorkspaceID", s.Subtest(func(db database.Store, check *expects) {
+		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
+		j := dbgen.ProvisionerJob(s.T(), db, database.ProvisionerJob{
+			CompletedAt: sql.NullTime{Time: database.Now(), Valid: true},
+		})
+		b := dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, JobID: j.ID})
+		check.Args(ws.ID).Asserts(ws, rbac.ActionRead).Returns(b)
+	}))
 	s.Run("GetLatestWorkspaceBuildsByWorkspaceIDs", s.Subtest(func(db database.Store, check *expects) {
 		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
 		b := dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID})
@@ -1122,12 +1178,50 @@ func (s *MethodTestSuite) TestWorkspace() {
 			BuildNumber: build.BuildNumber,
 		}).Asserts(ws, rbac.ActionRead).Returns(build)
 	}))
+	s.Run("GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey", s.Subtest(func(db database.Store, check *expects) {
+		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
+		build := dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{
+			WorkspaceID:    ws.ID,
+			IdempotencyKey: sql.NullString{String: "retry", Valid: true},
+		})
+		check.Args(database.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams{
+			WorkspaceID:    ws.ID,
+			IdempotencyKey: build.IdempotencyKey,
+		}).Asserts(ws, rbac.ActionRead).Returns(build)
+	}))
 	s.Run("GetWorkspaceBuildParameters", s.Subtest(func(db database.Store, check *expects) {
 		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
 		build := dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID})
 		check.Args(build.ID).Asserts(ws, rbac.ActionRead).
 			Returns([]database.WorkspaceBuildParameter{})
 	}))
+	s.Run("GetWorkspaceBuildParametersMap", s.Subtest(func(db database.Store, check *expects) {
+		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
+		build := dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID})
+		check.Args(build.ID).Asserts(ws, rbac.ActionRead).
+			Returns(map[string]string{})
+	}))
+	s.Run("GetWorkspaceBuilds", s.Subtest(func(db database.Store, check *expects) {
+		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
+		_ = dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, BuildNumber: 1})
+		_ = dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, BuildNumber: 2})
+		// No asserts here because SQLFilter.
+		check.Args(database.GetWorkspaceBuildsParams{}).Asserts()
+	}))
+	s.Run("GetAuthorizedWorkspaceBuilds", s.Subtest(func(db database.Store, check *expects) {
+		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
+		_ = dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, BuildNumber: 1})
+		_ = dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, BuildNumber: 2})
+		// No asserts here because SQLFilter.
+		check.Args(database.GetWorkspaceBuildsParams{}, emptyPreparedAuthorized{}).Asserts()
+	}))
+	s.Run("GetWorkspaceBuildsByInitiator", s.Subtest(func(db database.Store, check *expects) {
+		u := dbgen.User(s.T(), db, database.User{})
+		tpl := dbgen.Template(s.T(), db, database.Template{})
+		ws := dbgen.Workspace(s.T(), db, database.Workspace{TemplateID: tpl.ID})
+		_ = dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, BuildNumber: 1, InitiatorID: u.ID})
+		check.Args(database.GetWorkspaceBuildsByInitiatorParams{InitiatorID: u.ID}).Asserts(rbac.ResourceWorkspace.WithOwner(u.ID.String()), rbac.ActionRead)
+	}))
 	s.Run("GetWorkspaceBuildsByWorkspaceID", s.Subtest(func(db database.Store, check *expects) {
 		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
 		_ = dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, BuildNumber: 1})
@@ -1135,6 +1229,26 @@ func (s *MethodTestSuite) TestWorkspace() {
 		_ = dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, BuildNumber: 3})
 		check.Args(database.GetWorkspaceBuildsByWorkspaceIDParams{WorkspaceID: ws.ID}).Asserts(ws, rbac.ActionRead) // ordering
 	}))
+	s.Run("GetWorkspaceBuildVersionLineage", s.Subtest(func(db database.Store, check *expects) {
+		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
+		tv := dbgen.TemplateVersion(s.T(), db, database.TemplateVersion{})
+		_ = dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, TemplateVersionID: tv.ID, BuildNumber: 1})
+		check.Args(ws.ID).Asserts(ws, rbac.ActionRead)
+	}))
+	s.Run("GetWorkspaceBuildIntentsByWorkspaceID", s.Subtest(func(db database.Store, check *expects) {
+		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
+		check.Args(ws.ID).Asserts(ws, rbac.ActionRead).Returns([]database.WorkspaceBuildIntent{})
+	}))
+	s.Run("GetWorkspaceBuildsWithParameters", s.Subtest(func(db database.Store, check *expects) {
+		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
+		_ = dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, BuildNumber: 1})
+		check.Args(ws.ID).Asserts(ws, rbac.ActionRead)
+	}))
+	s.Run("GetWorkspaceBuildsWithParametersJSON", s.Subtest(func(db database.Store, check *expects) {
+		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
+		_ = dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, BuildNumber: 1})
+		check.Args(ws.ID).Asserts(ws, rbac.ActionRead)
+	}))
 	s.Run("GetWorkspaceByAgentID", s.Subtest(func(db database.Store, check *expects) {
 		ws := dbgen.Workspace(s.T(), db, database.Workspace{})
 		build := dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: ws.ID, JobID: uuid.New()})
@@ -1194,6 +1308,41 @@ func (s *MethodTestSuite) TestWorkspace() {
 			Reason:      database.BuildReasonInitiator,
 		}).Asserts(w.WorkspaceBuildRBAC(database.WorkspaceTransitionDelete), rbac.ActionDelete)
 	}))
+	s.Run("Start/InsertWorkspaceBuildIntent", s.Subtest(func(db database.Store, check *expects) {
+		w := dbgen.Workspace(s.T(), db, database.Workspace{})
+		check.Args(database.InsertWorkspaceBuildIntentParams{
+			ID:          uuid.New(),
+			WorkspaceID: w.ID,
+			Transition:  database.WorkspaceTransitionStart,
+			Reason:      database.BuildReasonInitiator,
+		}).Asserts(w.WorkspaceBuildRBAC(database.WorkspaceTransitionStart), rbac.ActionUpdate)
+	}))
+	s.Run("Delete/InsertWorkspaceBuildIntent", s.Subtest(func(db database.Store, check *expects) {
+		w := dbgen.Workspace(s.T(), db, database.Workspace{})
+		check.Args(database.InsertWorkspaceBuildIntentParams{
+			ID:          uuid.New(),
+			WorkspaceID: w.ID,
+			Transition:  database.WorkspaceTransitionDelete,
+			Reason:      database.BuildReasonInitiator,
+		}).Asserts(w.WorkspaceBuildRBAC(database.WorkspaceTransitionDelete), rbac.ActionDelete)
+	}))
+	s.Run("UpdateWorkspaceBuildIntentBuildByID", s.Subtest(func(db database.Store, check *expects) {
+		w := dbgen.Workspace(s.T(), db, database.Workspace{})
+		b := dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: w.ID})
+		intentID := uuid.New()
+		err := db.InsertWorkspaceBuildIntent(context.Background(), database.InsertWorkspaceBuildIntentParams{
+			ID:          intentID,
+			WorkspaceID: w.ID,
+			Transition:  database.WorkspaceTransitionStart,
+			Reason:      database.BuildReasonInitiator,
+		})
+		require.NoError(s.T(), err)
+		check.Args(database.UpdateWorkspaceBuildIntentBuildByIDParams{
+			ID:               intentID,
+			WorkspaceID:      w.ID,
+			WorkspaceBuildID: uuid.NullUUID{UUID: b.ID, Valid: true},
+		}).Asserts(w, rbac.ActionUpdate)
+	}))
 	s.Run("InsertWorkspaceBuildParameters", s.Subtest(func(db database.Store, check *expects) {
 		w := dbgen.Workspace(s.T(), db, database.Workspace{})
 		b := dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{WorkspaceID: w.ID})
@@ -1387,10 +1536,34 @@ func (s *MethodTestSuite) TestSystemFunctions() {
 		require.NoError(s.T(), err)
 		check.Args().Asserts(rbac.ResourceSystem, rbac.ActionRead)
 	}))
+	s.Run("GetTemplateBuildsPerDay", s.Subtest(func(db database.Store, check *expects) {
+		check.Args(database.GetTemplateBuildsPerDayParams{
+			TemplateID: uuid.New(),
+			StartTime:  time.Now().Add(-time.Hour),
+			EndTime:    time.Now(),
+		}).Asserts(rbac.ResourceSystem, rbac.ActionRead)
+	}))
+	s.Run("GetMostUsedTemplates", s.Subtest(func(db database.Store, check *expects) {
+		check.Args(database.GetMostUsedTemplatesParams{
+			StartTime: time.Now().Add(-time.Hour),
+			EndTime:   time.Now(),
+			LimitOpt:  10,
+		}).Asserts(rbac.ResourceSystem, rbac.ActionRead)
+	}))
+	s.Run("GetActiveJobCountsByTemplate", s.Subtest(func(db database.Store, check *expects) {
+		check.Args().Asserts(rbac.ResourceSystem, rbac.ActionRead)
+	}))
 	s.Run("GetWorkspaceBuildsCreatedAfter", s.Subtest(func(db database.Store, check *expects) {
 		_ = dbgen.WorkspaceBuild(s.T(), db, database.WorkspaceBuild{CreatedAt: time.Now().Add(-time.Hour)})
 		check.Args(time.Now()).Asserts(rbac.ResourceSystem, rbac.ActionRead)
 	}))
+	s.Run("GetWorkspacesDueForAutostop", s.Subtest(func(db database.Store, check *expects) {
+		_ = dbgen.Workspace(s.T(), db, database.Workspace{})
+		check.Args(time.Now()).Asserts(rbac.ResourceSystem, rbac.ActionRead)
+	}))
+	s.Run("GetStuckRunningBuilds", s.Subtest(func(db database.Store, check *expects) {
+		check.Args(time.Now().Add(-time.Hour)).Asserts(rbac.ResourceSystem, rbac.ActionRead)
+	}))
 	s.Run("GetWorkspaceAgentsCreatedAfter", s.Subtest(func(db database.Store, check *expects) {
 		_ = dbgen.WorkspaceAgent(s.T(), db, database.WorkspaceAgent{CreatedAt: time.Now().Add(-time.Hour)})
 		check.Args(time.Now()).Asserts(rbac.ResourceSystem, rbac.ActionRead)
@@ -1410,6 +1583,15 @@ func (s *MethodTestSuite) TestSystemFunctions() {
 	s.Run("DeleteOldWorkspaceAgentStats", s.Subtest(func(db database.Store, check *expects) {
 		check.Args().Asserts(rbac.ResourceSystem, rbac.ActionDelete)
 	}))
+	s.Run("DeleteOldThrowawayTemplateVersions", s.Subtest(func(db database.Store, check *expects) {
+		check.Args().Asserts(rbac.ResourceSystem, rbac.ActionDelete)
+	}))
+	s.Run("FailStalePendingJobs", s.Subtest(func(db database.Store, check *expects) {
+		_ = dbgen.ProvisionerJob(s.T(), db, database.ProvisionerJob{
+			PendingDeadline: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+		})
+		check.Args(time.Now()).Asserts(rbac.ResourceSystem, rbac.ActionUpdate)
+	}))
 	s.Run("GetProvisionerJobsCreatedAfter", s.Subtest(func(db database.Store, check *expects) {
 		// TODO: add provisioner job resource type
 		_ = dbgen.ProvisionerJob(s.T(), db, database.ProvisionerJob{CreatedAt: time.Now().Add(-time.Hour)})
@@ -153,6 +153,8 @@ func (s *MethodTestSuite) Subtest(testCaseF func(db database.Store, check *expec
 		if len(testCase.assertions) > 0 ||
 			slice.Contains([]string{
 				"GetAuthorizedWorkspaces",
+				"GetAuthorizedWorkspaceBuilds",
+				"GetAuthorizedWorkspacesWithFailedLatestBuild",
 				"GetAuthorizedTemplates",
 			}, methodName) {
 			// Some methods do not make RBAC assertions because they use
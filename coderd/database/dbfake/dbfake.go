@@ -136,6 +136,7 @@ type data struct {
 	workspaceAgentLogs        []database.WorkspaceAgentLog
 	workspaceApps             []database.WorkspaceApp
 	workspaceBuilds           []database.WorkspaceBuildTable
+	workspaceBuildIntents     []database.WorkspaceBuildIntent
 	workspaceBuildParameters  []database.WorkspaceBuildParameter
 	workspaceResourceMetadata []database.WorkspaceResourceMetadatum
 	workspaceResources        []database.WorkspaceResource
@@ -283,6 +284,7 @@ func convertUsers(users []database.User, count int64) []database.GetUsersRow {
 			AvatarURL:      u.AvatarURL,
 			Deleted:        u.Deleted,
 			LastSeenAt:     u.LastSeenAt,
+			Name:           u.Name,
 			Count:          count,
 		}
 	}
@@ -513,6 +515,54 @@ func (q *FakeQuerier) workspaceBuildWithUserNoLock(tpl database.WorkspaceBuildTa
 	return withUser
 }
 
+// getWorkspaceBuildsWithParametersNoLock returns the builds of the workspace,
+// oldest first, each with its parameters ordered by name.
+func (q *FakeQuerier) getWorkspaceBuildsWithParametersNoLock(workspaceID uuid.UUID) []database.WorkspaceBuildWithParameters {
+	builds := make([]database.WorkspaceBuildWithParameters, 0)
+	for _, build := range q.workspaceBuilds {
+		if build.WorkspaceID != workspaceID {
+			continue
+		}
+		paramsBuildID := build.ID
+		if build.ParametersFromBuildID.Valid {
+			paramsBuildID = build.ParametersFromBuildID.UUID
+		}
+		params := make([]database.WorkspaceBuildParameter, 0)
+		for _, param := range q.workspaceBuildParameters {
+			if param.WorkspaceBuildID == paramsBuildID {
+				params = append(params, param)
+			}
+		}
+		sort.Slice(params, func(i, j int) bool {
+			return params[i].Name < params[j].Name
+		})
+		builds = append(builds, database.WorkspaceBuildWithParameters{
+			WorkspaceBuild: q.workspaceBuildWithUserNoLock(build),
+			Parameters:     params,
+		})
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].WorkspaceBuild.BuildNumber < builds[j].WorkspaceBuild.BuildNumber
+	})
+	return builds
+}
+
+// templateHasBuildsSinceNoLock reports whether any workspace using the
+// template has a build created after since.
+func (q *FakeQuerier) templateHasBuildsSinceNoLock(templateID uuid.UUID, since time.Time) bool {
+	for _, build := range q.workspaceBuilds {
+		if !build.CreatedAt.After(since) {
+			continue
+		}
+		for _, workspace := range q.workspaces {
+			if workspace.ID == build.WorkspaceID && workspace.TemplateID == templateID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (q *FakeQuerier) getTemplateVersionByIDNoLock(_ context.Context, templateVersionID uuid.UUID) (database.TemplateVersion, error) {
 	for _, templateVersion := range q.templateVersions {
 		if templateVersion.ID != templateVersionID {
@@ -557,6 +607,24 @@ func (q *FakeQuerier) getProvisionerJobByIDNoLock(_ context.Context, id uuid.UUI
 	return database.ProvisionerJob{}, sql.ErrNoRows
 }
 
+// provisionerJobQueueOrderNoLock returns the indexes of all provisioner jobs
+// in the order they would be acquired: highest priority first, with ties
+// broken by creation time.
+func (q *FakeQuerier) provisionerJobQueueOrderNoLock() []int {
+	order := make([]int, 0, len(q.provisionerJobs))
+	for index := range q.provisionerJobs {
+		order = append(order, index)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := q.provisionerJobs[order[i]], q.provisionerJobs[order[j]]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	return order
+}
+
 func (q *FakeQuerier) getWorkspaceResourcesByJobIDNoLock(_ context.Context, jobID uuid.UUID) ([]database.WorkspaceResource, error) {
 	resources := make([]database.WorkspaceResource, 0)
 	for _, resource := range q.workspaceResources {
@@ -608,7 +676,8 @@ func (q *FakeQuerier) AcquireProvisionerJob(_ context.Context, arg database.Acqu
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
-	for index, provisionerJob := range q.provisionerJobs {
+	for _, index := range q.provisionerJobQueueOrderNoLock() {
+		provisionerJob := q.provisionerJobs[index]
 		if provisionerJob.StartedAt.Valid {
 			continue
 		}
@@ -789,6 +858,22 @@ func (q *FakeQuerier) DeleteLicense(_ context.Context, id int32) (int32, error)
 	return 0, sql.ErrNoRows
 }
 
+func (q *FakeQuerier) DeleteOldThrowawayTemplateVersions(_ context.Context) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	before := database.Now().Add(-24 * time.Hour)
+	versions := make([]database.TemplateVersionTable, 0, len(q.templateVersions))
+	for _, version := range q.templateVersions {
+		if version.Throwaway && version.CreatedAt.Before(before) {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	q.templateVersions = versions
+	return nil
+}
+
 func (*FakeQuerier) DeleteOldWorkspaceAgentLogs(_ context.Context) error {
 	// noop
 	return nil
@@ -820,6 +905,31 @@ func (*FakeQuerier) DeleteTailnetClient(context.Context, database.DeleteTailnetC
 	return database.DeleteTailnetClientRow{}, ErrUnimplemented
 }
 
+func (q *FakeQuerier) FailStalePendingJobs(_ context.Context, now time.Time) ([]database.ProvisionerJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	failed := []database.ProvisionerJob{}
+	for index, job := range q.provisionerJobs {
+		if job.StartedAt.Valid || job.CanceledAt.Valid || job.CompletedAt.Valid {
+			continue
+		}
+		if !job.PendingDeadline.Valid || job.PendingDeadline.Time.After(now) {
+			continue
+		}
+		job.UpdatedAt = now
+		job.StartedAt = sql.NullTime{Time: now, Valid: true}
+		job.CompletedAt = sql.NullTime{Time: now, Valid: true}
+		job.Error = sql.NullString{
+			String: "Coder: Build was not picked up by a provisioner daemon before its pending timeout.",
+			Valid:  true,
+		}
+		q.provisionerJobs[index] = job
+		failed = append(failed, job)
+	}
+	return failed, nil
+}
+
 func (q *FakeQuerier) GetAPIKeyByID(_ context.Context, id string) (database.APIKey, error) {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
@@ -890,6 +1000,36 @@ func (q *FakeQuerier) GetAPIKeysLastUsedAfter(_ context.Context, after time.Time
 	return apiKeys, nil
 }
 
+func (q *FakeQuerier) GetActiveJobCountsByTemplate(_ context.Context) ([]database.GetActiveJobCountsByTemplateRow, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	counts := make(map[uuid.UUID]int64)
+	for _, build := range q.workspaceBuilds {
+		job, err := q.getProvisionerJobByIDNoLock(context.Background(), build.JobID)
+		if err != nil {
+			continue
+		}
+		if job.CompletedAt.Valid || job.CanceledAt.Valid {
+			continue
+		}
+		workspace, err := q.getWorkspaceByIDNoLock(context.Background(), build.WorkspaceID)
+		if err != nil {
+			continue
+		}
+		counts[workspace.TemplateID]++
+	}
+
+	rows := make([]database.GetActiveJobCountsByTemplateRow, 0, len(counts))
+	for templateID, count := range counts {
+		rows = append(rows, database.GetActiveJobCountsByTemplateRow{
+			TemplateID: templateID,
+			Count:      count,
+		})
+	}
+	return rows, nil
+}
+
 func (q *FakeQuerier) GetActiveUserCount(_ context.Context) (int64, error) {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
@@ -1396,6 +1536,52 @@ func (q *FakeQuerier) GetLastUpdateCheck(_ context.Context) (string, error) {
 	return string(q.lastUpdateCheck), nil
 }
 
+func (q *FakeQuerier) GetLatestBuildByTemplateVersionID(_ context.Context, templateVersionID uuid.UUID) (database.WorkspaceBuild, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	var (
+		row   database.WorkspaceBuildTable
+		found bool
+	)
+	for _, workspaceBuild := range q.workspaceBuilds {
+		if workspaceBuild.TemplateVersionID != templateVersionID {
+			continue
+		}
+		if !found || workspaceBuild.CreatedAt.After(row.CreatedAt) {
+			row = workspaceBuild
+			found = true
+		}
+	}
+	if !found {
+		return database.WorkspaceBuild{}, sql.ErrNoRows
+	}
+	return q.workspaceBuildWithUserNoLock(row), nil
+}
+
+func (q *FakeQuerier) GetLatestSuccessfulWorkspaceBuildByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) (database.WorkspaceBuild, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	var row database.WorkspaceBuildTable
+	var buildNum int32 = -1
+	for _, workspaceBuild := range q.workspaceBuilds {
+		if workspaceBuild.WorkspaceID != workspaceID || workspaceBuild.BuildNumber <= buildNum {
+			continue
+		}
+		job, err := q.getProvisionerJobByIDNoLock(ctx, workspaceBuild.JobID)
+		if err != nil || !job.CompletedAt.Valid || job.CanceledAt.Valid || job.Error.String != "" {
+			continue
+		}
+		row = workspaceBuild
+		buildNum = workspaceBuild.BuildNumber
+	}
+	if buildNum == -1 {
+		return database.WorkspaceBuild{}, sql.ErrNoRows
+	}
+	return q.workspaceBuildWithUserNoLock(row), nil
+}
+
 func (q *FakeQuerier) GetLatestWorkspaceBuildByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) (database.WorkspaceBuild, error) {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
@@ -1488,6 +1674,63 @@ func (q *FakeQuerier) GetLogoURL(_ context.Context) (string, error) {
 	return q.logoURL, nil
 }
 
+func (q *FakeQuerier) GetMostUsedTemplates(ctx context.Context, arg database.GetMostUsedTemplatesParams) ([]database.GetMostUsedTemplatesRow, error) {
+	err := validateDatabaseType(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	byTemplate := make(map[uuid.UUID]*database.GetMostUsedTemplatesRow)
+	for _, wb := range q.workspaceBuilds {
+		if wb.CreatedAt.Before(arg.StartTime) || !wb.CreatedAt.Before(arg.EndTime) {
+			continue
+		}
+		workspace, err := q.getWorkspaceByIDNoLock(ctx, wb.WorkspaceID)
+		if err != nil {
+			return nil, err
+		}
+		row, ok := byTemplate[workspace.TemplateID]
+		if !ok {
+			template, err := q.getTemplateByIDNoLock(ctx, workspace.TemplateID)
+			if xerrors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if template.Deleted {
+				continue
+			}
+			row = &database.GetMostUsedTemplatesRow{
+				TemplateID:          template.ID,
+				TemplateName:        template.Name,
+				TemplateDisplayName: template.DisplayName,
+				TemplateIcon:        template.Icon,
+			}
+			byTemplate[template.ID] = row
+		}
+		row.BuildCount++
+	}
+
+	rows := make([]database.GetMostUsedTemplatesRow, 0, len(byTemplate))
+	for _, row := range byTemplate {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].BuildCount != rows[j].BuildCount {
+			return rows[i].BuildCount > rows[j].BuildCount
+		}
+		return rows[i].TemplateName < rows[j].TemplateName
+	})
+	if arg.LimitOpt > 0 && len(rows) > int(arg.LimitOpt) {
+		rows = rows[:arg.LimitOpt]
+	}
+	return rows, nil
+}
+
 func (q *FakeQuerier) GetOAuthSigningKey(_ context.Context) (string, error) {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
@@ -1723,7 +1966,8 @@ func (q *FakeQuerier) GetProvisionerJobsByIDsWithQueuePosition(_ context.Context
 
 	jobs := make([]database.GetProvisionerJobsByIDsWithQueuePositionRow, 0)
 	queuePosition := int64(1)
-	for _, job := range q.provisionerJobs {
+	for _, index := range q.provisionerJobQueueOrderNoLock() {
+		job := q.provisionerJobs[index]
 		for _, id := range ids {
 			if id == job.ID {
 				job := database.GetProvisionerJobsByIDsWithQueuePositionRow{
@@ -1864,6 +2108,30 @@ func (q *FakeQuerier) GetServiceBanner(_ context.Context) (string, error) {
 	return string(q.serviceBanner), nil
 }
 
+func (q *FakeQuerier) GetStuckRunningBuilds(ctx context.Context, startedBefore time.Time) ([]database.WorkspaceBuild, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	startedAt := make(map[uuid.UUID]time.Time)
+	builds := make([]database.WorkspaceBuild, 0)
+	for _, build := range q.workspaceBuilds {
+		job, err := q.getProvisionerJobByIDNoLock(ctx, build.JobID)
+		if err != nil {
+			continue
+		}
+		if !job.StartedAt.Valid || job.CompletedAt.Valid || job.CanceledAt.Valid ||
+			!job.StartedAt.Time.Before(startedBefore) {
+			continue
+		}
+		startedAt[build.ID] = job.StartedAt.Time
+		builds = append(builds, q.workspaceBuildWithUserNoLock(build))
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		return startedAt[builds[i].ID].Before(startedAt[builds[j].ID])
+	})
+	return builds, nil
+}
+
 func (*FakeQuerier) GetTailnetAgents(context.Context, uuid.UUID) ([]database.TailnetAgent, error) {
 	return nil, ErrUnimplemented
 }
@@ -1926,6 +2194,57 @@ func (q *FakeQuerier) GetTemplateAverageBuildTime(ctx context.Context, arg datab
 	return row, nil
 }
 
+func (q *FakeQuerier) GetTemplateBuildsPerDay(ctx context.Context, arg database.GetTemplateBuildsPerDayParams) ([]database.GetTemplateBuildsPerDayRow, error) {
+	err := validateDatabaseType(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	byDay := make(map[time.Time]*database.GetTemplateBuildsPerDayRow)
+	for _, wb := range q.workspaceBuilds {
+		if wb.CreatedAt.Before(arg.StartTime) || !wb.CreatedAt.Before(arg.EndTime) {
+			continue
+		}
+		workspace, err := q.getWorkspaceByIDNoLock(ctx, wb.WorkspaceID)
+		if err != nil {
+			return nil, err
+		}
+		if workspace.TemplateID != arg.TemplateID {
+			continue
+		}
+
+		day := wb.CreatedAt.UTC().Truncate(24 * time.Hour)
+		row, ok := byDay[day]
+		if !ok {
+			row = &database.GetTemplateBuildsPerDayRow{Day: day}
+			byDay[day] = row
+		}
+		row.Count++
+		switch wb.Transition {
+		case database.WorkspaceTransitionStart:
+			row.StartCount++
+		case database.WorkspaceTransitionStop:
+			row.StopCount++
+		case database.WorkspaceTransitionDelete:
+			row.DeleteCount++
+		}
+	}
+
+	days := maps.Keys(byDay)
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+
+	rows := make([]database.GetTemplateBuildsPerDayRow, 0, len(days))
+	for _, day := range days {
+		rows = append(rows, *byDay[day])
+	}
+	return rows, nil
+}
+
 func (q *FakeQuerier) GetTemplateByID(ctx context.Context, id uuid.UUID) (database.Template, error) {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
@@ -2330,6 +2649,35 @@ func (q *FakeQuerier) GetUnexpiredLicenses(_ context.Context) ([]database.Licens
 	return results, nil
 }
 
+func (q *FakeQuerier) GetUnusedTemplateVersions(ctx context.Context, templateID uuid.UUID) ([]database.TemplateVersion, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	used := make(map[uuid.UUID]bool)
+	for _, build := range q.workspaceBuilds {
+		job, err := q.getProvisionerJobByIDNoLock(ctx, build.JobID)
+		if err != nil || !job.CompletedAt.Valid || job.CanceledAt.Valid || job.Error.String != "" {
+			continue
+		}
+		used[build.TemplateVersionID] = true
+	}
+
+	versions := make([]database.TemplateVersion, 0)
+	for _, version := range q.templateVersions {
+		if version.TemplateID.UUID != templateID || !version.TemplateID.Valid || used[version.ID] {
+			continue
+		}
+		versions = append(versions, q.templateVersionWithUserNoLock(version))
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].CreatedAt.Equal(versions[j].CreatedAt) {
+			return versions[i].ID.String() < versions[j].ID.String()
+		}
+		return versions[i].CreatedAt.Before(versions[j].CreatedAt)
+	})
+	return versions, nil
+}
+
 func (q *FakeQuerier) GetUserByEmailOrUsername(_ context.Context, arg database.GetUserByEmailOrUsernameParams) (database.User, error) {
 	if err := validateDatabaseType(arg); err != nil {
 		return database.User{}, err
@@ -2366,6 +2714,28 @@ func (q *FakeQuerier) GetUserCount(_ context.Context) (int64, error) {
 	return existing, nil
 }
 
+func (q *FakeQuerier) GetUserGroups(_ context.Context, userID uuid.UUID) ([]database.Group, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	var groups []database.Group
+	for _, member := range q.groupMembers {
+		if member.UserID != userID {
+			continue
+		}
+		for _, group := range q.groups {
+			if group.ID == member.GroupID {
+				groups = append(groups, group)
+				break
+			}
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups, nil
+}
+
 func (q *FakeQuerier) GetUserLatencyInsights(_ context.Context, arg database.GetUserLatencyInsightsParams) ([]database.GetUserLatencyInsightsRow, error) {
 	err := validateDatabaseType(arg)
 	if err != nil {
@@ -2982,18 +3352,170 @@ func (q *FakeQuerier) GetWorkspaceBuildByWorkspaceIDAndBuildNumber(_ context.Con
 	return database.WorkspaceBuild{}, sql.ErrNoRows
 }
 
+func (q *FakeQuerier) GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(_ context.Context, arg database.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams) (database.WorkspaceBuild, error) {
+	if err := validateDatabaseType(arg); err != nil {
+		return database.WorkspaceBuild{}, err
+	}
+
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	for _, workspaceBuild := range q.workspaceBuilds {
+		if workspaceBuild.WorkspaceID != arg.WorkspaceID {
+			continue
+		}
+		// NULL never matches, as in SQL.
+		if !workspaceBuild.IdempotencyKey.Valid || !arg.IdempotencyKey.Valid ||
+			workspaceBuild.IdempotencyKey.String != arg.IdempotencyKey.String {
+			continue
+		}
+		return q.workspaceBuildWithUserNoLock(workspaceBuild), nil
+	}
+	return database.WorkspaceBuild{}, sql.ErrNoRows
+}
+
+func (q *FakeQuerier) GetWorkspaceBuildIntentsByWorkspaceID(_ context.Context, workspaceID uuid.UUID) ([]database.WorkspaceBuildIntent, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	intents := make([]database.WorkspaceBuildIntent, 0)
+	for _, intent := range q.workspaceBuildIntents {
+		if intent.WorkspaceID != workspaceID {
+			continue
+		}
+		intents = append(intents, intent)
+	}
+	sort.SliceStable(intents, func(i, j int) bool {
+		return intents[i].CreatedAt.After(intents[j].CreatedAt)
+	})
+	return intents, nil
+}
+
 func (q *FakeQuerier) GetWorkspaceBuildParameters(_ context.Context, workspaceBuildID uuid.UUID) ([]database.WorkspaceBuildParameter, error) {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
 
+	for _, build := range q.workspaceBuilds {
+		if build.ID == workspaceBuildID && build.ParametersFromBuildID.Valid {
+			workspaceBuildID = build.ParametersFromBuildID.UUID
+			break
+		}
+	}
+
 	params := make([]database.WorkspaceBuildParameter, 0)
 	for _, param := range q.workspaceBuildParameters {
 		if param.WorkspaceBuildID != workspaceBuildID {
 			continue
 		}
-		params = append(params, param)
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+func (q *FakeQuerier) GetWorkspaceBuildVersionLineage(ctx context.Context, workspaceID uuid.UUID) ([]database.GetWorkspaceBuildVersionLineageRow, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	lineage := make([]database.GetWorkspaceBuildVersionLineageRow, 0)
+	for _, build := range q.workspaceBuilds {
+		if build.WorkspaceID != workspaceID {
+			continue
+		}
+		version, err := q.getTemplateVersionByIDNoLock(ctx, build.TemplateVersionID)
+		if err != nil {
+			continue
+		}
+		lineage = append(lineage, database.GetWorkspaceBuildVersionLineageRow{
+			ID:                  build.ID,
+			BuildNumber:         build.BuildNumber,
+			Transition:          build.Transition,
+			CreatedAt:           build.CreatedAt,
+			TemplateVersionID:   build.TemplateVersionID,
+			TemplateVersionName: version.Name,
+		})
+	}
+	slices.SortFunc(lineage, func(a, b database.GetWorkspaceBuildVersionLineageRow) bool {
+		return a.BuildNumber < b.BuildNumber
+	})
+	return lineage, nil
+}
+
+func (q *FakeQuerier) GetWorkspaceBuilds(ctx context.Context, arg database.GetWorkspaceBuildsParams) ([]database.GetWorkspaceBuildsRow, error) {
+	if err := validateDatabaseType(arg); err != nil {
+		return nil, err
+	}
+
+	return q.GetAuthorizedWorkspaceBuilds(ctx, arg, nil)
+}
+
+func (q *FakeQuerier) GetWorkspaceBuildsByInitiator(ctx context.Context, arg database.GetWorkspaceBuildsByInitiatorParams) ([]database.GetWorkspaceBuildsByInitiatorRow, error) {
+	if err := validateDatabaseType(arg); err != nil {
+		return nil, err
+	}
+
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	rows := make([]database.GetWorkspaceBuildsByInitiatorRow, 0)
+	for _, build := range q.workspaceBuilds {
+		if build.InitiatorID != arg.InitiatorID {
+			continue
+		}
+		workspace, err := q.getWorkspaceByIDNoLock(ctx, build.WorkspaceID)
+		if err != nil {
+			continue
+		}
+		template, err := q.getTemplateByIDNoLock(ctx, workspace.TemplateID)
+		if err != nil {
+			continue
+		}
+		withUser := q.workspaceBuildWithUserNoLock(build)
+		rows = append(rows, database.GetWorkspaceBuildsByInitiatorRow{
+			ID:                      withUser.ID,
+			CreatedAt:               withUser.CreatedAt,
+			UpdatedAt:               withUser.UpdatedAt,
+			WorkspaceID:             withUser.WorkspaceID,
+			TemplateVersionID:       withUser.TemplateVersionID,
+			BuildNumber:             withUser.BuildNumber,
+			Transition:              withUser.Transition,
+			InitiatorID:             withUser.InitiatorID,
+			ProvisionerState:        withUser.ProvisionerState,
+			JobID:                   withUser.JobID,
+			Deadline:                withUser.Deadline,
+			Reason:                  withUser.Reason,
+			DailyCost:               withUser.DailyCost,
+			MaxDeadline:             withUser.MaxDeadline,
+			RollbackOf:              withUser.RollbackOf,
+			ParametersFromBuildID:   withUser.ParametersFromBuildID,
+			StructuredReason:        withUser.StructuredReason,
+			IdempotencyKey:          withUser.IdempotencyKey,
+			TemplateFileHash:        withUser.TemplateFileHash,
+			ScheduleName:            withUser.ScheduleName,
+			InitiatorByAvatarUrl:    withUser.InitiatorByAvatarUrl,
+			InitiatorByUsername:     withUser.InitiatorByUsername,
+			WorkspaceName:           workspace.Name,
+			WorkspaceOwnerID:        workspace.OwnerID,
+			WorkspaceOrganizationID: workspace.OrganizationID,
+			TemplateName:            template.Name,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].CreatedAt.Equal(rows[j].CreatedAt) {
+			return rows[i].CreatedAt.After(rows[j].CreatedAt)
+		}
+		return rows[i].ID.String() > rows[j].ID.String()
+	})
+
+	if arg.OffsetOpt > 0 {
+		if int(arg.OffsetOpt) > len(rows) {
+			return nil, nil
+		}
+		rows = rows[arg.OffsetOpt:]
 	}
-	return params, nil
+	if arg.LimitOpt > 0 && int(arg.LimitOpt) < len(rows) {
+		rows = rows[:arg.LimitOpt]
+	}
+	return rows, nil
 }
 
 func (q *FakeQuerier) GetWorkspaceBuildsByWorkspaceID(_ context.Context,
@@ -3072,6 +3594,25 @@ func (q *FakeQuerier) GetWorkspaceBuildsCreatedAfter(_ context.Context, after ti
 	return workspaceBuilds, nil
 }
 
+func (q *FakeQuerier) GetWorkspaceBuildsWithParametersJSON(_ context.Context, workspaceID uuid.UUID) ([]database.GetWorkspaceBuildsWithParametersJSONRow, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	builds := q.getWorkspaceBuildsWithParametersNoLock(workspaceID)
+	rows := make([]database.GetWorkspaceBuildsWithParametersJSONRow, 0, len(builds))
+	for _, build := range builds {
+		raw, err := json.Marshal(build.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, database.GetWorkspaceBuildsWithParametersJSONRow{
+			WorkspaceBuild: build.WorkspaceBuild,
+			Parameters:     raw,
+		})
+	}
+	return rows, nil
+}
+
 func (q *FakeQuerier) GetWorkspaceByAgentID(ctx context.Context, agentID uuid.UUID) (database.Workspace, error) {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
@@ -3135,6 +3676,28 @@ func (q *FakeQuerier) GetWorkspaceByWorkspaceAppID(_ context.Context, workspaceA
 	return database.Workspace{}, sql.ErrNoRows
 }
 
+func (q *FakeQuerier) GetWorkspaceCountByOwner(_ context.Context, arg database.GetWorkspaceCountByOwnerParams) (int64, error) {
+	err := validateDatabaseType(arg)
+	if err != nil {
+		return 0, err
+	}
+
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	var count int64
+	for _, workspace := range q.workspaces {
+		if workspace.OwnerID != arg.OwnerID {
+			continue
+		}
+		if workspace.Deleted && !arg.IncludeDeleted {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
 func (q *FakeQuerier) GetWorkspaceProxies(_ context.Context) ([]database.WorkspaceProxy, error) {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
@@ -3309,6 +3872,62 @@ func (q *FakeQuerier) GetWorkspaces(ctx context.Context, arg database.GetWorkspa
 	return workspaceRows, err
 }
 
+func (q *FakeQuerier) GetWorkspacesDueForAutostop(ctx context.Context, now time.Time) ([]database.GetWorkspacesDueForAutostopRow, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	rows := make([]database.GetWorkspacesDueForAutostopRow, 0)
+	for _, workspace := range q.workspaces {
+		if workspace.Deleted {
+			continue
+		}
+		build, err := q.getLatestWorkspaceBuildByWorkspaceIDNoLock(ctx, workspace.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if build.Transition != database.WorkspaceTransitionStart ||
+			build.Deadline.IsZero() ||
+			!build.Deadline.Before(now) {
+			continue
+		}
+		job, err := q.getProvisionerJobByIDNoLock(ctx, build.JobID)
+		if err != nil {
+			return nil, xerrors.Errorf("get provisioner job by ID: %w", err)
+		}
+		if !job.CompletedAt.Valid || job.CanceledAt.Valid || job.Error.String != "" {
+			continue
+		}
+
+		rows = append(rows, database.GetWorkspacesDueForAutostopRow{
+			ID:                  workspace.ID,
+			CreatedAt:           workspace.CreatedAt,
+			UpdatedAt:           workspace.UpdatedAt,
+			OwnerID:             workspace.OwnerID,
+			OrganizationID:      workspace.OrganizationID,
+			TemplateID:          workspace.TemplateID,
+			Deleted:             workspace.Deleted,
+			Name:                workspace.Name,
+			AutostartSchedule:   workspace.AutostartSchedule,
+			Ttl:                 workspace.Ttl,
+			LastUsedAt:          workspace.LastUsedAt,
+			LockedAt:            workspace.LockedAt,
+			DeletingAt:          workspace.DeletingAt,
+			LatestBuildID:       build.ID,
+			LatestBuildDeadline: build.Deadline,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.GetWorkspacesDueForAutostopRow) bool {
+		if !a.LatestBuildDeadline.Equal(b.LatestBuildDeadline) {
+			return a.LatestBuildDeadline.Before(b.LatestBuildDeadline)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	return rows, nil
+}
+
 func (q *FakeQuerier) GetWorkspacesEligibleForTransition(ctx context.Context, now time.Time) ([]database.Workspace, error) {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
@@ -3361,6 +3980,14 @@ func (q *FakeQuerier) GetWorkspacesEligibleForTransition(ctx context.Context, no
 	return workspaces, nil
 }
 
+func (q *FakeQuerier) GetWorkspacesWithFailedLatestBuild(ctx context.Context, arg database.GetWorkspacesWithFailedLatestBuildParams) ([]database.GetWorkspacesWithFailedLatestBuildRow, error) {
+	if err := validateDatabaseType(arg); err != nil {
+		return nil, err
+	}
+
+	return q.GetAuthorizedWorkspacesWithFailedLatestBuild(ctx, arg, nil)
+}
+
 func (q *FakeQuerier) InsertAPIKey(_ context.Context, arg database.InsertAPIKeyParams) (database.APIKey, error) {
 	if err := validateDatabaseType(arg); err != nil {
 		return database.APIKey{}, err
@@ -3642,17 +4269,19 @@ func (q *FakeQuerier) InsertProvisionerJob(_ context.Context, arg database.Inser
 	defer q.mutex.Unlock()
 
 	job := database.ProvisionerJob{
-		ID:             arg.ID,
-		CreatedAt:      arg.CreatedAt,
-		UpdatedAt:      arg.UpdatedAt,
-		OrganizationID: arg.OrganizationID,
-		InitiatorID:    arg.InitiatorID,
-		Provisioner:    arg.Provisioner,
-		StorageMethod:  arg.StorageMethod,
-		FileID:         arg.FileID,
-		Type:           arg.Type,
-		Input:          arg.Input,
-		Tags:           arg.Tags,
+		ID:              arg.ID,
+		CreatedAt:       arg.CreatedAt,
+		UpdatedAt:       arg.UpdatedAt,
+		OrganizationID:  arg.OrganizationID,
+		InitiatorID:     arg.InitiatorID,
+		Provisioner:     arg.Provisioner,
+		StorageMethod:   arg.StorageMethod,
+		FileID:          arg.FileID,
+		Type:            arg.Type,
+		Input:           arg.Input,
+		Tags:            arg.Tags,
+		Priority:        arg.Priority,
+		PendingDeadline: arg.PendingDeadline,
 	}
 	q.provisionerJobs = append(q.provisionerJobs, job)
 	return job, nil
@@ -3766,6 +4395,7 @@ func (q *FakeQuerier) InsertTemplateVersion(_ context.Context, arg database.Inse
 		Readme:         arg.Readme,
 		JobID:          arg.JobID,
 		CreatedBy:      arg.CreatedBy,
+		Throwaway:      arg.Throwaway,
 	}
 	q.templateVersions = append(q.templateVersions, version)
 	return nil
@@ -3865,6 +4495,7 @@ func (q *FakeQuerier) InsertUser(_ context.Context, arg database.InsertUserParam
 		Status:         database.UserStatusActive,
 		RBACRoles:      arg.RBACRoles,
 		LoginType:      arg.LoginType,
+		Name:           arg.Name,
 	}
 	q.users = append(q.users, user)
 	return user, nil
@@ -4109,20 +4740,51 @@ func (q *FakeQuerier) InsertWorkspaceBuild(_ context.Context, arg database.Inser
 	defer q.mutex.Unlock()
 
 	workspaceBuild := database.WorkspaceBuildTable{
+		ID:                    arg.ID,
+		CreatedAt:             arg.CreatedAt,
+		UpdatedAt:             arg.UpdatedAt,
+		WorkspaceID:           arg.WorkspaceID,
+		TemplateVersionID:     arg.TemplateVersionID,
+		BuildNumber:           arg.BuildNumber,
+		Transition:            arg.Transition,
+		InitiatorID:           arg.InitiatorID,
+		JobID:                 arg.JobID,
+		ProvisionerState:      arg.ProvisionerState,
+		Deadline:              arg.Deadline,
+		Reason:                arg.Reason,
+		RollbackOf:            arg.RollbackOf,
+		ParametersFromBuildID: arg.ParametersFromBuildID,
+		StructuredReason:      arg.StructuredReason,
+		IdempotencyKey:        arg.IdempotencyKey,
+		ScheduleName:          arg.ScheduleName,
+	}
+	for _, file := range q.files {
+		if file.ID == arg.TemplateFileID {
+			workspaceBuild.TemplateFileHash = sql.NullString{String: file.Hash, Valid: true}
+			break
+		}
+	}
+	q.workspaceBuilds = append(q.workspaceBuilds, workspaceBuild)
+	return nil
+}
+
+func (q *FakeQuerier) InsertWorkspaceBuildIntent(_ context.Context, arg database.InsertWorkspaceBuildIntentParams) error {
+	if err := validateDatabaseType(arg); err != nil {
+		return err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.workspaceBuildIntents = append(q.workspaceBuildIntents, database.WorkspaceBuildIntent{
 		ID:                arg.ID,
 		CreatedAt:         arg.CreatedAt,
-		UpdatedAt:         arg.UpdatedAt,
 		WorkspaceID:       arg.WorkspaceID,
-		TemplateVersionID: arg.TemplateVersionID,
-		BuildNumber:       arg.BuildNumber,
-		Transition:        arg.Transition,
 		InitiatorID:       arg.InitiatorID,
-		JobID:             arg.JobID,
-		ProvisionerState:  arg.ProvisionerState,
-		Deadline:          arg.Deadline,
+		Transition:        arg.Transition,
 		Reason:            arg.Reason,
-	}
-	q.workspaceBuilds = append(q.workspaceBuilds, workspaceBuild)
+		TemplateVersionID: arg.TemplateVersionID,
+	})
 	return nil
 }
 
@@ -5074,6 +5736,25 @@ func (q *FakeQuerier) UpdateWorkspaceBuildCostByID(_ context.Context, arg databa
 	return sql.ErrNoRows
 }
 
+func (q *FakeQuerier) UpdateWorkspaceBuildIntentBuildByID(_ context.Context, arg database.UpdateWorkspaceBuildIntentBuildByIDParams) error {
+	if err := validateDatabaseType(arg); err != nil {
+		return err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for index, intent := range q.workspaceBuildIntents {
+		if intent.ID != arg.ID || intent.WorkspaceID != arg.WorkspaceID {
+			continue
+		}
+		intent.WorkspaceBuildID = arg.WorkspaceBuildID
+		q.workspaceBuildIntents[index] = intent
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
 func (q *FakeQuerier) UpdateWorkspaceDeletedByID(_ context.Context, arg database.UpdateWorkspaceDeletedByIDParams) error {
 	if err := validateDatabaseType(arg); err != nil {
 		return err
@@ -5342,6 +6023,14 @@ func (q *FakeQuerier) GetAuthorizedTemplates(ctx context.Context, arg database.G
 				continue
 			}
 		}
+
+		if !arg.NoBuildsSince.IsZero() && q.templateHasBuildsSinceNoLock(template.ID, arg.NoBuildsSince) {
+			continue
+		}
+
+		if arg.CreatedBy != uuid.Nil && template.CreatedBy != arg.CreatedBy {
+			continue
+		}
 		templates = append(templates, template)
 	}
 	if len(templates) > 0 {
@@ -5357,6 +6046,26 @@ func (q *FakeQuerier) GetAuthorizedTemplates(ctx context.Context, arg database.G
 	return nil, sql.ErrNoRows
 }
 
+func (q *FakeQuerier) CountAuthorizedTemplates(ctx context.Context, arg database.GetTemplatesWithFilterParams, prepared rbac.PreparedAuthorized) (int64, error) {
+	templates, err := q.GetAuthorizedTemplates(ctx, arg, prepared)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	return int64(len(templates)), nil
+}
+
+func (q *FakeQuerier) GetTemplateACL(ctx context.Context, id uuid.UUID) (database.TemplateACLRoles, error) {
+	users, err := q.GetTemplateUserRoles(ctx, id)
+	if err != nil {
+		return database.TemplateACLRoles{}, err
+	}
+	groups, err := q.GetTemplateGroupRoles(ctx, id)
+	if err != nil {
+		return database.TemplateACLRoles{}, err
+	}
+	return database.TemplateACLRoles{Users: users, Groups: groups}, nil
+}
+
 func (q *FakeQuerier) GetTemplateGroupRoles(_ context.Context, id uuid.UUID) ([]database.TemplateGroup, error) {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
@@ -5440,6 +6149,9 @@ func (q *FakeQuerier) GetAuthorizedWorkspaces(ctx context.Context, arg database.
 	if err := validateDatabaseType(arg); err != nil {
 		return nil, err
 	}
+	if err := database.ValidateWorkspacesSort(arg.SortBy, arg.SortOrder); err != nil {
+		return nil, err
+	}
 
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
@@ -5480,6 +6192,15 @@ func (q *FakeQuerier) GetAuthorizedWorkspaces(ctx context.Context, arg database.
 			continue
 		}
 
+		if arg.SearchQuery != "" {
+			search := strings.ToLower(arg.SearchQuery)
+			owner, err := q.getUserByIDNoLock(workspace.OwnerID)
+			if !strings.Contains(strings.ToLower(workspace.Name), search) &&
+				(err != nil || !strings.Contains(strings.ToLower(owner.Username), search)) {
+				continue
+			}
+		}
+
 		if arg.Status != "" {
 			build, err := q.getLatestWorkspaceBuildByWorkspaceIDNoLock(ctx, workspace.ID)
 			if err != nil {
@@ -5593,6 +6314,31 @@ func (q *FakeQuerier) GetAuthorizedWorkspaces(ctx context.Context, arg database.
 			}
 		}
 
+		if arg.LockedOnly && !workspace.LockedAt.Valid {
+			continue
+		}
+
+		if arg.RunningOnly {
+			build, err := q.getLatestWorkspaceBuildByWorkspaceIDNoLock(ctx, workspace.ID)
+			if err != nil {
+				// without a build, the workspace can't be running
+				continue
+			}
+
+			job, err := q.getProvisionerJobByIDNoLock(ctx, build.JobID)
+			if err != nil {
+				return nil, xerrors.Errorf("get provisioner job: %w", err)
+			}
+
+			// This logic should match the logic in the workspace.sql file.
+			if !(isNotNull(job.CompletedAt) &&
+				isNull(job.CanceledAt) &&
+				isNull(job.Error) &&
+				build.Transition == database.WorkspaceTransitionStart) {
+				continue
+			}
+		}
+
 		if len(arg.TemplateIDs) > 0 {
 			match := false
 			for _, id := range arg.TemplateIDs {
@@ -5649,6 +6395,26 @@ func (q *FakeQuerier) GetAuthorizedWorkspaces(ctx context.Context, arg database.
 		w1 := workspaces[i]
 		w2 := workspaces[j]
 
+		// Order by: the requested sort column, if any
+		if arg.SortOrder == database.SortOrderDesc {
+			w1, w2 = w2, w1
+		}
+		switch arg.SortBy {
+		case database.WorkspacesSortByLastUsedAt:
+			if !w1.LastUsedAt.Equal(w2.LastUsedAt) {
+				return w1.LastUsedAt.Before(w2.LastUsedAt)
+			}
+		case database.WorkspacesSortByName:
+			if n1, n2 := strings.ToLower(w1.Name), strings.ToLower(w2.Name); n1 != n2 {
+				return n1 < n2
+			}
+		case database.WorkspacesSortByCreatedAt:
+			if !w1.CreatedAt.Equal(w2.CreatedAt) {
+				return w1.CreatedAt.Before(w2.CreatedAt)
+			}
+		}
+		w1, w2 = workspaces[i], workspaces[j]
+
 		// Order by: running first
 		w1IsRunning := isRunning(preloadedWorkspaceBuilds[w1.ID], preloadedProvisionerJobs[w1.ID])
 		w2IsRunning := isRunning(preloadedWorkspaceBuilds[w2.ID], preloadedProvisionerJobs[w2.ID])
@@ -5688,6 +6454,199 @@ func (q *FakeQuerier) GetAuthorizedWorkspaces(ctx context.Context, arg database.
 	return q.convertToWorkspaceRowsNoLock(ctx, workspaces, int64(beforePageCount)), nil
 }
 
+func (q *FakeQuerier) GetAuthorizedWorkspacesWithFailedLatestBuild(ctx context.Context, arg database.GetWorkspacesWithFailedLatestBuildParams, prepared rbac.PreparedAuthorized) ([]database.GetWorkspacesWithFailedLatestBuildRow, error) {
+	if err := validateDatabaseType(arg); err != nil {
+		return nil, err
+	}
+
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	if prepared != nil {
+		// Call this to match the same function calls as the SQL implementation.
+		_, err := prepared.CompileToSQL(ctx, rbac.ConfigWithoutACL())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows := make([]database.GetWorkspacesWithFailedLatestBuildRow, 0)
+	for _, workspace := range q.workspaces {
+		if workspace.Deleted {
+			continue
+		}
+		if arg.OrganizationID != uuid.Nil && workspace.OrganizationID != arg.OrganizationID {
+			continue
+		}
+		// If the filter exists, ensure the object is authorized.
+		if prepared != nil && prepared.Authorize(ctx, workspace.RBACObject()) != nil {
+			continue
+		}
+		build, err := q.getLatestWorkspaceBuildByWorkspaceIDNoLock(ctx, workspace.ID)
+		if err != nil {
+			continue
+		}
+		job, err := q.getProvisionerJobByIDNoLock(ctx, build.JobID)
+		if err != nil {
+			continue
+		}
+		if !job.Error.Valid || (!job.CompletedAt.Valid && !job.CanceledAt.Valid) {
+			continue
+		}
+		rows = append(rows, database.GetWorkspacesWithFailedLatestBuildRow{
+			ID:                     workspace.ID,
+			CreatedAt:              workspace.CreatedAt,
+			UpdatedAt:              workspace.UpdatedAt,
+			OwnerID:                workspace.OwnerID,
+			OrganizationID:         workspace.OrganizationID,
+			TemplateID:             workspace.TemplateID,
+			Deleted:                workspace.Deleted,
+			Name:                   workspace.Name,
+			AutostartSchedule:      workspace.AutostartSchedule,
+			Ttl:                    workspace.Ttl,
+			LastUsedAt:             workspace.LastUsedAt,
+			LockedAt:               workspace.LockedAt,
+			DeletingAt:             workspace.DeletingAt,
+			LatestBuildID:          build.ID,
+			LatestBuildTransition:  build.Transition,
+			LatestBuildError:       job.Error.String,
+			LatestBuildCompletedAt: job.CompletedAt,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i].LatestBuildCompletedAt, rows[j].LatestBuildCompletedAt
+		if a.Valid != b.Valid {
+			// Nulls last.
+			return a.Valid
+		}
+		if !a.Time.Equal(b.Time) {
+			return a.Time.After(b.Time)
+		}
+		return rows[i].ID.String() < rows[j].ID.String()
+	})
+
+	count := int64(len(rows))
+	if arg.OffsetOpt > 0 {
+		if int(arg.OffsetOpt) > len(rows) {
+			rows = nil
+		} else {
+			rows = rows[arg.OffsetOpt:]
+		}
+	}
+	if arg.LimitOpt > 0 && int(arg.LimitOpt) < len(rows) {
+		rows = rows[:arg.LimitOpt]
+	}
+	for i := range rows {
+		rows[i].Count = count
+	}
+	return rows, nil
+}
+
+func (q *FakeQuerier) GetAuthorizedWorkspaceBuilds(ctx context.Context, arg database.GetWorkspaceBuildsParams, prepared rbac.PreparedAuthorized) ([]database.GetWorkspaceBuildsRow, error) {
+	if err := validateDatabaseType(arg); err != nil {
+		return nil, err
+	}
+
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	if prepared != nil {
+		// Call this to match the same function calls as the SQL implementation.
+		_, err := prepared.CompileToSQL(ctx, rbac.ConfigWithoutACL())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	workspaces := make(map[uuid.UUID]database.Workspace)
+	for _, workspace := range q.workspaces {
+		if arg.OrganizationID != uuid.Nil && workspace.OrganizationID != arg.OrganizationID {
+			continue
+		}
+		// If the filter exists, ensure the object is authorized.
+		if prepared != nil && prepared.Authorize(ctx, workspace.RBACObject()) != nil {
+			continue
+		}
+		workspaces[workspace.ID] = workspace
+	}
+
+	builds := make([]database.WorkspaceBuild, 0)
+	for _, build := range q.workspaceBuilds {
+		if _, ok := workspaces[build.WorkspaceID]; !ok {
+			continue
+		}
+		builds = append(builds, q.workspaceBuildWithUserNoLock(build))
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		if !builds[i].CreatedAt.Equal(builds[j].CreatedAt) {
+			return builds[i].CreatedAt.After(builds[j].CreatedAt)
+		}
+		return builds[i].ID.String() > builds[j].ID.String()
+	})
+
+	count := int64(len(builds))
+	if arg.OffsetOpt > 0 {
+		if int(arg.OffsetOpt) > len(builds) {
+			builds = nil
+		} else {
+			builds = builds[arg.OffsetOpt:]
+		}
+	}
+	if arg.LimitOpt > 0 && int(arg.LimitOpt) < len(builds) {
+		builds = builds[:arg.LimitOpt]
+	}
+
+	rows := make([]database.GetWorkspaceBuildsRow, 0, len(builds))
+	for _, build := range builds {
+		workspace := workspaces[build.WorkspaceID]
+		rows = append(rows, database.GetWorkspaceBuildsRow{
+			ID:                      build.ID,
+			CreatedAt:               build.CreatedAt,
+			UpdatedAt:               build.UpdatedAt,
+			WorkspaceID:             build.WorkspaceID,
+			TemplateVersionID:       build.TemplateVersionID,
+			BuildNumber:             build.BuildNumber,
+			Transition:              build.Transition,
+			InitiatorID:             build.InitiatorID,
+			ProvisionerState:        build.ProvisionerState,
+			JobID:                   build.JobID,
+			Deadline:                build.Deadline,
+			Reason:                  build.Reason,
+			DailyCost:               build.DailyCost,
+			MaxDeadline:             build.MaxDeadline,
+			RollbackOf:              build.RollbackOf,
+			ParametersFromBuildID:   build.ParametersFromBuildID,
+			StructuredReason:        build.StructuredReason,
+			IdempotencyKey:          build.IdempotencyKey,
+			TemplateFileHash:        build.TemplateFileHash,
+			ScheduleName:            build.ScheduleName,
+			InitiatorByAvatarUrl:    build.InitiatorByAvatarUrl,
+			InitiatorByUsername:     build.InitiatorByUsername,
+			WorkspaceName:           workspace.Name,
+			WorkspaceOwnerID:        workspace.OwnerID,
+			WorkspaceOrganizationID: workspace.OrganizationID,
+			WorkspaceTemplateID:     workspace.TemplateID,
+			Count:                   count,
+		})
+	}
+	return rows, nil
+}
+
+func (q *FakeQuerier) GetWorkspaceBuildParametersMap(ctx context.Context, workspaceBuildID uuid.UUID) (map[string]string, error) {
+	params, err := q.GetWorkspaceBuildParameters(ctx, workspaceBuildID)
+	if err != nil {
+		return nil, err
+	}
+	return database.WorkspaceBuildParametersMap(params), nil
+}
+
+func (q *FakeQuerier) GetWorkspaceBuildsWithParameters(_ context.Context, workspaceID uuid.UUID) ([]database.WorkspaceBuildWithParameters, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	return q.getWorkspaceBuildsWithParametersNoLock(workspaceID), nil
+}
+
 func (q *FakeQuerier) GetAuthorizedUsers(ctx context.Context, arg database.GetUsersParams, prepared rbac.PreparedAuthorized) ([]database.GetUsersRow, error) {
 	if err := validateDatabaseType(arg); err != nil {
 		return nil, err
@@ -184,18 +184,23 @@ func WorkspaceBuild(t testing.TB, db database.Store, orig database.WorkspaceBuil
 	var build database.WorkspaceBuild
 	err := db.InTx(func(db database.Store) error {
 		err := db.InsertWorkspaceBuild(genCtx, database.InsertWorkspaceBuildParams{
-			ID:                buildID,
-			CreatedAt:         takeFirst(orig.CreatedAt, database.Now()),
-			UpdatedAt:         takeFirst(orig.UpdatedAt, database.Now()),
-			WorkspaceID:       takeFirst(orig.WorkspaceID, uuid.New()),
-			TemplateVersionID: takeFirst(orig.TemplateVersionID, uuid.New()),
-			BuildNumber:       takeFirst(orig.BuildNumber, 1),
-			Transition:        takeFirst(orig.Transition, database.WorkspaceTransitionStart),
-			InitiatorID:       takeFirst(orig.InitiatorID, uuid.New()),
-			JobID:             takeFirst(orig.JobID, uuid.New()),
-			ProvisionerState:  takeFirstSlice(orig.ProvisionerState, []byte{}),
-			Deadline:          takeFirst(orig.Deadline, database.Now().Add(time.Hour)),
-			Reason:            takeFirst(orig.Reason, database.BuildReasonInitiator),
+			ID:                    buildID,
+			CreatedAt:             takeFirst(orig.CreatedAt, database.Now()),
+			UpdatedAt:             takeFirst(orig.UpdatedAt, database.Now()),
+			WorkspaceID:           takeFirst(orig.WorkspaceID, uuid.New()),
+			TemplateVersionID:     takeFirst(orig.TemplateVersionID, uuid.New()),
+			BuildNumber:           takeFirst(orig.BuildNumber, 1),
+			Transition:            takeFirst(orig.Transition, database.WorkspaceTransitionStart),
+			InitiatorID:           takeFirst(orig.InitiatorID, uuid.New()),
+			JobID:                 takeFirst(orig.JobID, uuid.New()),
+			ProvisionerState:      takeFirstSlice(orig.ProvisionerState, []byte{}),
+			Deadline:              takeFirst(orig.Deadline, database.Now().Add(time.Hour)),
+			Reason:                takeFirst(orig.Reason, database.BuildReasonInitiator),
+			RollbackOf:            orig.RollbackOf,
+			ParametersFromBuildID: orig.ParametersFromBuildID,
+			StructuredReason:      orig.StructuredReason,
+			IdempotencyKey:        orig.IdempotencyKey,
+			ScheduleName:          orig.ScheduleName,
 		})
 		if err != nil {
 			return err
@@ -221,6 +226,7 @@ func User(t testing.TB, db database.Store, orig database.User) database.User {
 		UpdatedAt:      takeFirst(orig.UpdatedAt, database.Now()),
 		RBACRoles:      takeFirstSlice(orig.RBACRoles, []string{}),
 		LoginType:      takeFirst(orig.LoginType, database.LoginTypePassword),
+		Name:           orig.Name,
 	})
 	require.NoError(t, err, "insert user")
 
@@ -309,17 +315,19 @@ func ProvisionerJob(t testing.TB, db database.Store, orig database.ProvisionerJo
 		orig.Tags[id.String()] = "true"
 	}
 	job, err := db.InsertProvisionerJob(genCtx, database.InsertProvisionerJobParams{
-		ID:             takeFirst(orig.ID, uuid.New()),
-		CreatedAt:      takeFirst(orig.CreatedAt, database.Now()),
-		UpdatedAt:      takeFirst(orig.UpdatedAt, database.Now()),
-		OrganizationID: takeFirst(orig.OrganizationID, uuid.New()),
-		InitiatorID:    takeFirst(orig.InitiatorID, uuid.New()),
-		Provisioner:    takeFirst(orig.Provisioner, database.ProvisionerTypeEcho),
-		StorageMethod:  takeFirst(orig.StorageMethod, database.ProvisionerStorageMethodFile),
-		FileID:         takeFirst(orig.FileID, uuid.New()),
-		Type:           takeFirst(orig.Type, database.ProvisionerJobTypeWorkspaceBuild),
-		Input:          takeFirstSlice(orig.Input, []byte("{}")),
-		Tags:           orig.Tags,
+		ID:              takeFirst(orig.ID, uuid.New()),
+		CreatedAt:       takeFirst(orig.CreatedAt, database.Now()),
+		UpdatedAt:       takeFirst(orig.UpdatedAt, database.Now()),
+		OrganizationID:  takeFirst(orig.OrganizationID, uuid.New()),
+		InitiatorID:     takeFirst(orig.InitiatorID, uuid.New()),
+		Provisioner:     takeFirst(orig.Provisioner, database.ProvisionerTypeEcho),
+		StorageMethod:   takeFirst(orig.StorageMethod, database.ProvisionerStorageMethodFile),
+		FileID:          takeFirst(orig.FileID, uuid.New()),
+		Type:            takeFirst(orig.Type, database.ProvisionerJobTypeWorkspaceBuild),
+		Input:           takeFirstSlice(orig.Input, []byte("{}")),
+		Tags:            orig.Tags,
+		Priority:        orig.Priority,
+		PendingDeadline: orig.PendingDeadline,
 	})
 	require.NoError(t, err, "insert job")
 
@@ -501,6 +509,7 @@ func TemplateVersion(t testing.TB, db database.Store, orig database.TemplateVers
 			Readme:         takeFirst(orig.Readme, namesgenerator.GetRandomName(1)),
 			JobID:          takeFirst(orig.JobID, uuid.New()),
 			CreatedBy:      takeFirst(orig.CreatedBy, uuid.New()),
+			Throwaway:      orig.Throwaway,
 		})
 		if err != nil {
 			return err
@@ -162,6 +162,13 @@ func (m metricsStore) DeleteLicense(ctx context.Context, id int32) (int32, error
 	return licenseID, err
 }
 
+func (m metricsStore) DeleteOldThrowawayTemplateVersions(ctx context.Context) error {
+	start := time.Now()
+	err := m.s.DeleteOldThrowawayTemplateVersions(ctx)
+	m.queryLatencies.WithLabelValues("DeleteOldThrowawayTemplateVersions").Observe(time.Since(start).Seconds())
+	return err
+}
+
 func (m metricsStore) DeleteOldWorkspaceAgentLogs(ctx context.Context) error {
 	start := time.Now()
 	r0 := m.s.DeleteOldWorkspaceAgentLogs(ctx)
@@ -195,6 +202,13 @@ func (m metricsStore) DeleteTailnetClient(ctx context.Context, arg database.Dele
 	return m.s.DeleteTailnetClient(ctx, arg)
 }
 
+func (m metricsStore) FailStalePendingJobs(ctx context.Context, now time.Time) ([]database.ProvisionerJob, error) {
+	start := time.Now()
+	jobs, err := m.s.FailStalePendingJobs(ctx, now)
+	m.queryLatencies.WithLabelValues("FailStalePendingJobs").Observe(time.Since(start).Seconds())
+	return jobs, err
+}
+
 func (m metricsStore) GetAPIKeyByID(ctx context.Context, id string) (database.APIKey, error) {
 	start := time.Now()
 	apiKey, err := m.s.GetAPIKeyByID(ctx, id)
@@ -230,6 +244,13 @@ func (m metricsStore) GetAPIKeysLastUsedAfter(ctx context.Context, lastUsed time
 	return apiKeys, err
 }
 
+func (m metricsStore) GetActiveJobCountsByTemplate(ctx context.Context) ([]database.GetActiveJobCountsByTemplateRow, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetActiveJobCountsByTemplate(ctx)
+	m.queryLatencies.WithLabelValues("GetActiveJobCountsByTemplate").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
 func (m metricsStore) GetActiveUserCount(ctx context.Context) (int64, error) {
 	start := time.Now()
 	count, err := m.s.GetActiveUserCount(ctx)
@@ -391,6 +412,20 @@ func (m metricsStore) GetLastUpdateCheck(ctx context.Context) (string, error) {
 	return version, err
 }
 
+func (m metricsStore) GetLatestBuildByTemplateVersionID(ctx context.Context, templateVersionID uuid.UUID) (database.WorkspaceBuild, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetLatestBuildByTemplateVersionID(ctx, templateVersionID)
+	m.queryLatencies.WithLabelValues("GetLatestBuildByTemplateVersionID").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
+func (m metricsStore) GetLatestSuccessfulWorkspaceBuildByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) (database.WorkspaceBuild, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetLatestSuccessfulWorkspaceBuildByWorkspaceID(ctx, workspaceID)
+	m.queryLatencies.WithLabelValues("GetLatestSuccessfulWorkspaceBuildByWorkspaceID").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
 func (m metricsStore) GetLatestWorkspaceBuildByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) (database.WorkspaceBuild, error) {
 	start := time.Now()
 	build, err := m.s.GetLatestWorkspaceBuildByWorkspaceID(ctx, workspaceID)
@@ -433,6 +468,13 @@ func (m metricsStore) GetLogoURL(ctx context.Context) (string, error) {
 	return url, err
 }
 
+func (m metricsStore) GetMostUsedTemplates(ctx context.Context, arg database.GetMostUsedTemplatesParams) ([]database.GetMostUsedTemplatesRow, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetMostUsedTemplates(ctx, arg)
+	m.queryLatencies.WithLabelValues("GetMostUsedTemplates").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
 func (m metricsStore) GetOAuthSigningKey(ctx context.Context) (string, error) {
 	start := time.Now()
 	r0, r1 := m.s.GetOAuthSigningKey(ctx)
@@ -580,6 +622,13 @@ func (m metricsStore) GetServiceBanner(ctx context.Context) (string, error) {
 	return banner, err
 }
 
+func (m metricsStore) GetStuckRunningBuilds(ctx context.Context, startedBefore time.Time) ([]database.WorkspaceBuild, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetStuckRunningBuilds(ctx, startedBefore)
+	m.queryLatencies.WithLabelValues("GetStuckRunningBuilds").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
 func (m metricsStore) GetTailnetAgents(ctx context.Context, id uuid.UUID) ([]database.TailnetAgent, error) {
 	start := time.Now()
 	defer m.queryLatencies.WithLabelValues("GetTailnetAgents").Observe(time.Since(start).Seconds())
@@ -599,6 +648,13 @@ func (m metricsStore) GetTemplateAverageBuildTime(ctx context.Context, arg datab
 	return buildTime, err
 }
 
+func (m metricsStore) GetTemplateBuildsPerDay(ctx context.Context, arg database.GetTemplateBuildsPerDayParams) ([]database.GetTemplateBuildsPerDayRow, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetTemplateBuildsPerDay(ctx, arg)
+	m.queryLatencies.WithLabelValues("GetTemplateBuildsPerDay").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
 func (m metricsStore) GetTemplateByID(ctx context.Context, id uuid.UUID) (database.Template, error) {
 	start := time.Now()
 	template, err := m.s.GetTemplateByID(ctx, id)
@@ -711,6 +767,13 @@ func (m metricsStore) GetUnexpiredLicenses(ctx context.Context) ([]database.Lice
 	return licenses, err
 }
 
+func (m metricsStore) GetUnusedTemplateVersions(ctx context.Context, templateID uuid.UUID) ([]database.TemplateVersion, error) {
+	start := time.Now()
+	versions, err := m.s.GetUnusedTemplateVersions(ctx, templateID)
+	m.queryLatencies.WithLabelValues("GetUnusedTemplateVersions").Observe(time.Since(start).Seconds())
+	return versions, err
+}
+
 func (m metricsStore) GetUserByEmailOrUsername(ctx context.Context, arg database.GetUserByEmailOrUsernameParams) (database.User, error) {
 	start := time.Now()
 	user, err := m.s.GetUserByEmailOrUsername(ctx, arg)
@@ -732,6 +795,13 @@ func (m metricsStore) GetUserCount(ctx context.Context) (int64, error) {
 	return count, err
 }
 
+func (m metricsStore) GetUserGroups(ctx context.Context, userID uuid.UUID) ([]database.Group, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetUserGroups(ctx, userID)
+	m.queryLatencies.WithLabelValues("GetUserGroups").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
 func (m metricsStore) GetUserLatencyInsights(ctx context.Context, arg database.GetUserLatencyInsightsParams) ([]database.GetUserLatencyInsightsRow, error) {
 	start := time.Now()
 	r0, r1 := m.s.GetUserLatencyInsights(ctx, arg)
@@ -893,6 +963,20 @@ func (m metricsStore) GetWorkspaceBuildByWorkspaceIDAndBuildNumber(ctx context.C
 	return build, err
 }
 
+func (m metricsStore) GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(ctx context.Context, arg database.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams) (database.WorkspaceBuild, error) {
+	start := time.Now()
+	build, err := m.s.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(ctx, arg)
+	m.queryLatencies.WithLabelValues("GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey").Observe(time.Since(start).Seconds())
+	return build, err
+}
+
+func (m metricsStore) GetWorkspaceBuildIntentsByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) ([]database.WorkspaceBuildIntent, error) {
+	start := time.Now()
+	intents, err := m.s.GetWorkspaceBuildIntentsByWorkspaceID(ctx, workspaceID)
+	m.queryLatencies.WithLabelValues("GetWorkspaceBuildIntentsByWorkspaceID").Observe(time.Since(start).Seconds())
+	return intents, err
+}
+
 func (m metricsStore) GetWorkspaceBuildParameters(ctx context.Context, workspaceBuildID uuid.UUID) ([]database.WorkspaceBuildParameter, error) {
 	start := time.Now()
 	params, err := m.s.GetWorkspaceBuildParameters(ctx, workspaceBuildID)
@@ -900,6 +984,27 @@ func (m metricsStore) GetWorkspaceBuildParameters(ctx context.Context, workspace
 	return params, err
 }
 
+func (m metricsStore) GetWorkspaceBuildVersionLineage(ctx context.Context, workspaceID uuid.UUID) ([]database.GetWorkspaceBuildVersionLineageRow, error) {
+	start := time.Now()
+	lineage, err := m.s.GetWorkspaceBuildVersionLineage(ctx, workspaceID)
+	m.queryLatencies.WithLabelValues("GetWorkspaceBuildVersionLineage").Observe(time.Since(start).Seconds())
+	return lineage, err
+}
+
+func (m metricsStore) GetWorkspaceBuilds(ctx context.Context, arg database.GetWorkspaceBuildsParams) ([]database.GetWorkspaceBuildsRow, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetWorkspaceBuilds(ctx, arg)
+	m.queryLatencies.WithLabelValues("GetWorkspaceBuilds").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
+func (m metricsStore) GetWorkspaceBuildsByInitiator(ctx context.Context, arg database.GetWorkspaceBuildsByInitiatorParams) ([]database.GetWorkspaceBuildsByInitiatorRow, error) {
+	start := time.Now()
+	builds, err := m.s.GetWorkspaceBuildsByInitiator(ctx, arg)
+	m.queryLatencies.WithLabelValues("GetWorkspaceBuildsByInitiator").Observe(time.Since(start).Seconds())
+	return builds, err
+}
+
 func (m metricsStore) GetWorkspaceBuildsByWorkspaceID(ctx context.Context, arg database.GetWorkspaceBuildsByWorkspaceIDParams) ([]database.WorkspaceBuild, error) {
 	start := time.Now()
 	builds, err := m.s.GetWorkspaceBuildsByWorkspaceID(ctx, arg)
@@ -914,6 +1019,13 @@ func (m metricsStore) GetWorkspaceBuildsCreatedAfter(ctx context.Context, create
 	return builds, err
 }
 
+func (m metricsStore) GetWorkspaceBuildsWithParametersJSON(ctx context.Context, workspaceID uuid.UUID) ([]database.GetWorkspaceBuildsWithParametersJSONRow, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetWorkspaceBuildsWithParametersJSON(ctx, workspaceID)
+	m.queryLatencies.WithLabelValues("GetWorkspaceBuildsWithParametersJSON").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
 func (m metricsStore) GetWorkspaceByAgentID(ctx context.Context, agentID uuid.UUID) (database.Workspace, error) {
 	start := time.Now()
 	workspace, err := m.s.GetWorkspaceByAgentID(ctx, agentID)
@@ -942,6 +1054,13 @@ func (m metricsStore) GetWorkspaceByWorkspaceAppID(ctx context.Context, workspac
 	return workspace, err
 }
 
+func (m metricsStore) GetWorkspaceCountByOwner(ctx context.Context, arg database.GetWorkspaceCountByOwnerParams) (int64, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetWorkspaceCountByOwner(ctx, arg)
+	m.queryLatencies.WithLabelValues("GetWorkspaceCountByOwner").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
 func (m metricsStore) GetWorkspaceProxies(ctx context.Context) ([]database.WorkspaceProxy, error) {
 	start := time.Now()
 	proxies, err := m.s.GetWorkspaceProxies(ctx)
@@ -1019,6 +1138,13 @@ func (m metricsStore) GetWorkspaces(ctx context.Context, arg database.GetWorkspa
 	return workspaces, err
 }
 
+func (m metricsStore) GetWorkspacesDueForAutostop(ctx context.Context, now time.Time) ([]database.GetWorkspacesDueForAutostopRow, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetWorkspacesDueForAutostop(ctx, now)
+	m.queryLatencies.WithLabelValues("GetWorkspacesDueForAutostop").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
 func (m metricsStore) GetWorkspacesEligibleForTransition(ctx context.Context, now time.Time) ([]database.Workspace, error) {
 	start := time.Now()
 	workspaces, err := m.s.GetWorkspacesEligibleForTransition(ctx, now)
@@ -1026,6 +1152,13 @@ func (m metricsStore) GetWorkspacesEligibleForTransition(ctx context.Context, no
 	return workspaces, err
 }
 
+func (m metricsStore) GetWorkspacesWithFailedLatestBuild(ctx context.Context, arg database.GetWorkspacesWithFailedLatestBuildParams) ([]database.GetWorkspacesWithFailedLatestBuildRow, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetWorkspacesWithFailedLatestBuild(ctx, arg)
+	m.queryLatencies.WithLabelValues("GetWorkspacesWithFailedLatestBuild").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
 func (m metricsStore) InsertAPIKey(ctx context.Context, arg database.InsertAPIKeyParams) (database.APIKey, error) {
 	start := time.Now()
 	key, err := m.s.InsertAPIKey(ctx, arg)
@@ -1243,6 +1376,13 @@ func (m metricsStore) InsertWorkspaceBuild(ctx context.Context, arg database.Ins
 	return err
 }
 
+func (m metricsStore) InsertWorkspaceBuildIntent(ctx context.Context, arg database.InsertWorkspaceBuildIntentParams) error {
+	start := time.Now()
+	err := m.s.InsertWorkspaceBuildIntent(ctx, arg)
+	m.queryLatencies.WithLabelValues("InsertWorkspaceBuildIntent").Observe(time.Since(start).Seconds())
+	return err
+}
+
 func (m metricsStore) InsertWorkspaceBuildParameters(ctx context.Context, arg database.InsertWorkspaceBuildParametersParams) error {
 	start := time.Now()
 	err := m.s.InsertWorkspaceBuildParameters(ctx, arg)
@@ -1544,6 +1684,13 @@ func (m metricsStore) UpdateWorkspaceBuildCostByID(ctx context.Context, arg data
 	return err
 }
 
+func (m metricsStore) UpdateWorkspaceBuildIntentBuildByID(ctx context.Context, arg database.UpdateWorkspaceBuildIntentBuildByIDParams) error {
+	start := time.Now()
+	err := m.s.UpdateWorkspaceBuildIntentBuildByID(ctx, arg)
+	m.queryLatencies.WithLabelValues("UpdateWorkspaceBuildIntentBuildByID").Observe(time.Since(start).Seconds())
+	return err
+}
+
 func (m metricsStore) UpdateWorkspaceDeletedByID(ctx context.Context, arg database.UpdateWorkspaceDeletedByIDParams) error {
 	start := time.Now()
 	err := m.s.UpdateWorkspaceDeletedByID(ctx, arg)
@@ -1660,6 +1807,20 @@ func (m metricsStore) GetAuthorizedTemplates(ctx context.Context, arg database.G
 	return templates, err
 }
 
+func (m metricsStore) CountAuthorizedTemplates(ctx context.Context, arg database.GetTemplatesWithFilterParams, prepared rbac.PreparedAuthorized) (int64, error) {
+	start := time.Now()
+	count, err := m.s.CountAuthorizedTemplates(ctx, arg, prepared)
+	m.queryLatencies.WithLabelValues("CountAuthorizedTemplates").Observe(time.Since(start).Seconds())
+	return count, err
+}
+
+func (m metricsStore) GetTemplateACL(ctx context.Context, id uuid.UUID) (database.TemplateACLRoles, error) {
+	start := time.Now()
+	acl, err := m.s.GetTemplateACL(ctx, id)
+	m.queryLatencies.WithLabelValues("GetTemplateACL").Observe(time.Since(start).Seconds())
+	return acl, err
+}
+
 func (m metricsStore) GetTemplateGroupRoles(ctx context.Context, id uuid.UUID) ([]database.TemplateGroup, error) {
 	start := time.Now()
 	roles, err := m.s.GetTemplateGroupRoles(ctx, id)
@@ -1681,6 +1842,34 @@ func (m metricsStore) GetAuthorizedWorkspaces(ctx context.Context, arg database.
 	return workspaces, err
 }
 
+func (m metricsStore) GetAuthorizedWorkspacesWithFailedLatestBuild(ctx context.Context, arg database.GetWorkspacesWithFailedLatestBuildParams, prepared rbac.PreparedAuthorized) ([]database.GetWorkspacesWithFailedLatestBuildRow, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetAuthorizedWorkspacesWithFailedLatestBuild(ctx, arg, prepared)
+	m.queryLatencies.WithLabelValues("GetAuthorizedWorkspacesWithFailedLatestBuild").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
+func (m metricsStore) GetAuthorizedWorkspaceBuilds(ctx context.Context, arg database.GetWorkspaceBuildsParams, prepared rbac.PreparedAuthorized) ([]database.GetWorkspaceBuildsRow, error) {
+	start := time.Now()
+	r0, r1 := m.s.GetAuthorizedWorkspaceBuilds(ctx, arg, prepared)
+	m.queryLatencies.WithLabelValues("GetAuthorizedWorkspaceBuilds").Observe(time.Since(start).Seconds())
+	return r0, r1
+}
+
+func (m metricsStore) GetWorkspaceBuildParametersMap(ctx context.Context, workspaceBuildID uuid.UUID) (map[string]string, error) {
+	start := time.Now()
+	params, err := m.s.GetWorkspaceBuildParametersMap(ctx, workspaceBuildID)
+	m.queryLatencies.WithLabelValues("GetWorkspaceBuildParametersMap").Observe(time.Since(start).Seconds())
+	return params, err
+}
+
+func (m metricsStore) GetWorkspaceBuildsWithParameters(ctx context.Context, workspaceID uuid.UUID) ([]database.WorkspaceBuildWithParameters, error) {
+	start := time.Now()
+	builds, err := m.s.GetWorkspaceBuildsWithParameters(ctx, workspaceID)
+	m.queryLatencies.WithLabelValues("GetWorkspaceBuildsWithParameters").Observe(time.Since(start).Seconds())
+	return builds, err
+}
+
 func (m metricsStore) GetAuthorizedUsers(ctx context.Context, arg database.GetUsersParams, prepared rbac.PreparedAuthorized) ([]database.GetUsersRow, error) {
 	start := time.Now()
 	r0, r1 := m.s.GetAuthorizedUsers(ctx, arg, prepared)
@@ -82,6 +82,21 @@ func (mr *MockStoreMockRecorder) CleanTailnetCoordinators(arg0 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanTailnetCoordinators", reflect.TypeOf((*MockStore)(nil).CleanTailnetCoordinators), arg0)
 }
 
+// CountAuthorizedTemplates mocks base method.
+func (m *MockStore) CountAuthorizedTemplates(arg0 context.Context, arg1 database.GetTemplatesWithFilterParams, arg2 rbac.PreparedAuthorized) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountAuthorizedTemplates", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountAuthorizedTemplates indicates an expected call of CountAuthorizedTemplates.
+func (mr *MockStoreMockRecorder) CountAuthorizedTemplates(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountAuthorizedTemplates", reflect.TypeOf((*MockStore)(nil).CountAuthorizedTemplates), arg0, arg1, arg2)
+}
+
 // DeleteAPIKeyByID mocks base method.
 func (m *MockStore) DeleteAPIKeyByID(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -209,6 +224,20 @@ func (mr *MockStoreMockRecorder) DeleteLicense(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLicense", reflect.TypeOf((*MockStore)(nil).DeleteLicense), arg0, arg1)
 }
 
+// DeleteOldThrowawayTemplateVersions mocks base method.
+func (m *MockStore) DeleteOldThrowawayTemplateVersions(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOldThrowawayTemplateVersions", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOldThrowawayTemplateVersions indicates an expected call of DeleteOldThrowawayTemplateVersions.
+func (mr *MockStoreMockRecorder) DeleteOldThrowawayTemplateVersions(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOldThrowawayTemplateVersions", reflect.TypeOf((*MockStore)(nil).DeleteOldThrowawayTemplateVersions), arg0)
+}
+
 // DeleteOldWorkspaceAgentLogs mocks base method.
 func (m *MockStore) DeleteOldWorkspaceAgentLogs(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -281,6 +310,21 @@ func (mr *MockStoreMockRecorder) DeleteTailnetClient(arg0, arg1 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTailnetClient", reflect.TypeOf((*MockStore)(nil).DeleteTailnetClient), arg0, arg1)
 }
 
+// FailStalePendingJobs mocks base method.
+func (m *MockStore) FailStalePendingJobs(arg0 context.Context, arg1 time.Time) ([]database.ProvisionerJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailStalePendingJobs", arg0, arg1)
+	ret0, _ := ret[0].([]database.ProvisionerJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FailStalePendingJobs indicates an expected call of FailStalePendingJobs.
+func (mr *MockStoreMockRecorder) FailStalePendingJobs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailStalePendingJobs", reflect.TypeOf((*MockStore)(nil).FailStalePendingJobs), arg0, arg1)
+}
+
 // GetAPIKeyByID mocks base method.
 func (m *MockStore) GetAPIKeyByID(arg0 context.Context, arg1 string) (database.APIKey, error) {
 	m.ctrl.T.Helper()
@@ -356,6 +400,21 @@ func (mr *MockStoreMockRecorder) GetAPIKeysLastUsedAfter(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeysLastUsedAfter", reflect.TypeOf((*MockStore)(nil).GetAPIKeysLastUsedAfter), arg0, arg1)
 }
 
+// GetActiveJobCountsByTemplate mocks base method.
+func (m *MockStore) GetActiveJobCountsByTemplate(arg0 context.Context) ([]database.GetActiveJobCountsByTemplateRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActiveJobCountsByTemplate", arg0)
+	ret0, _ := ret[0].([]database.GetActiveJobCountsByTemplateRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActiveJobCountsByTemplate indicates an expected call of GetActiveJobCountsByTemplate.
+func (mr *MockStoreMockRecorder) GetActiveJobCountsByTemplate(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveJobCountsByTemplate", reflect.TypeOf((*MockStore)(nil).GetActiveJobCountsByTemplate), arg0)
+}
+
 // GetActiveUserCount mocks base method.
 func (m *MockStore) GetActiveUserCount(arg0 context.Context) (int64, error) {
 	m.ctrl.T.Helper()
@@ -476,6 +535,21 @@ func (mr *MockStoreMockRecorder) GetAuthorizedUsers(arg0, arg1, arg2 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthorizedUsers", reflect.TypeOf((*MockStore)(nil).GetAuthorizedUsers), arg0, arg1, arg2)
 }
 
+// GetAuthorizedWorkspaceBuilds mocks base method.
+func (m *MockStore) GetAuthorizedWorkspaceBuilds(arg0 context.Context, arg1 database.GetWorkspaceBuildsParams, arg2 rbac.PreparedAuthorized) ([]database.GetWorkspaceBuildsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuthorizedWorkspaceBuilds", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]database.GetWorkspaceBuildsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuthorizedWorkspaceBuilds indicates an expected call of GetAuthorizedWorkspaceBuilds.
+func (mr *MockStoreMockRecorder) GetAuthorizedWorkspaceBuilds(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthorizedWorkspaceBuilds", reflect.TypeOf((*MockStore)(nil).GetAuthorizedWorkspaceBuilds), arg0, arg1, arg2)
+}
+
 // GetAuthorizedWorkspaces mocks base method.
 func (m *MockStore) GetAuthorizedWorkspaces(arg0 context.Context, arg1 database.GetWorkspacesParams, arg2 rbac.PreparedAuthorized) ([]database.GetWorkspacesRow, error) {
 	m.ctrl.T.Helper()
@@ -491,6 +565,21 @@ func (mr *MockStoreMockRecorder) GetAuthorizedWorkspaces(arg0, arg1, arg2 interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthorizedWorkspaces", reflect.TypeOf((*MockStore)(nil).GetAuthorizedWorkspaces), arg0, arg1, arg2)
 }
 
+// GetAuthorizedWorkspacesWithFailedLatestBuild mocks base method.
+func (m *MockStore) GetAuthorizedWorkspacesWithFailedLatestBuild(arg0 context.Context, arg1 database.GetWorkspacesWithFailedLatestBuildParams, arg2 rbac.PreparedAuthorized) ([]database.GetWorkspacesWithFailedLatestBuildRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuthorizedWorkspacesWithFailedLatestBuild", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]database.GetWorkspacesWithFailedLatestBuildRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuthorizedWorkspacesWithFailedLatestBuild indicates an expected call of GetAuthorizedWorkspacesWithFailedLatestBuild.
+func (mr *MockStoreMockRecorder) GetAuthorizedWorkspacesWithFailedLatestBuild(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthorizedWorkspacesWithFailedLatestBuild", reflect.TypeOf((*MockStore)(nil).GetAuthorizedWorkspacesWithFailedLatestBuild), arg0, arg1, arg2)
+}
+
 // GetDERPMeshKey mocks base method.
 func (m *MockStore) GetDERPMeshKey(arg0 context.Context) (string, error) {
 	m.ctrl.T.Helper()
@@ -746,6 +835,36 @@ func (mr *MockStoreMockRecorder) GetLastUpdateCheck(arg0 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastUpdateCheck", reflect.TypeOf((*MockStore)(nil).GetLastUpdateCheck), arg0)
 }
 
+// GetLatestBuildByTemplateVersionID mocks base method.
+func (m *MockStore) GetLatestBuildByTemplateVersionID(arg0 context.Context, arg1 uuid.UUID) (database.WorkspaceBuild, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestBuildByTemplateVersionID", arg0, arg1)
+	ret0, _ := ret[0].(database.WorkspaceBuild)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestBuildByTemplateVersionID indicates an expected call of GetLatestBuildByTemplateVersionID.
+func (mr *MockStoreMockRecorder) GetLatestBuildByTemplateVersionID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestBuildByTemplateVersionID", reflect.TypeOf((*MockStore)(nil).GetLatestBuildByTemplateVersionID), arg0, arg1)
+}
+
+// GetLatestSuccessfulWorkspaceBuildByWorkspaceID mocks base method.
+func (m *MockStore) GetLatestSuccessfulWorkspaceBuildByWorkspaceID(arg0 context.Context, arg1 uuid.UUID) (database.WorkspaceBuild, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestSuccessfulWorkspaceBuildByWorkspaceID", arg0, arg1)
+	ret0, _ := ret[0].(database.WorkspaceBuild)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestSuccessfulWorkspaceBuildByWorkspaceID indicates an expected call of GetLatestSuccessfulWorkspaceBuildByWorkspaceID.
+func (mr *MockStoreMockRecorder) GetLatestSuccessfulWorkspaceBuildByWorkspaceID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestSuccessfulWorkspaceBuildByWorkspaceID", reflect.TypeOf((*MockStore)(nil).GetLatestSuccessfulWorkspaceBuildByWorkspaceID), arg0, arg1)
+}
+
 // GetLatestWorkspaceBuildByWorkspaceID mocks base method.
 func (m *MockStore) GetLatestWorkspaceBuildByWorkspaceID(arg0 context.Context, arg1 uuid.UUID) (database.WorkspaceBuild, error) {
 	m.ctrl.T.Helper()
@@ -836,6 +955,21 @@ func (mr *MockStoreMockRecorder) GetLogoURL(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogoURL", reflect.TypeOf((*MockStore)(nil).GetLogoURL), arg0)
 }
 
+// GetMostUsedTemplates mocks base method.
+func (m *MockStore) GetMostUsedTemplates(arg0 context.Context, arg1 database.GetMostUsedTemplatesParams) ([]database.GetMostUsedTemplatesRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMostUsedTemplates", arg0, arg1)
+	ret0, _ := ret[0].([]database.GetMostUsedTemplatesRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMostUsedTemplates indicates an expected call of GetMostUsedTemplates.
+func (mr *MockStoreMockRecorder) GetMostUsedTemplates(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMostUsedTemplates", reflect.TypeOf((*MockStore)(nil).GetMostUsedTemplates), arg0, arg1)
+}
+
 // GetOAuthSigningKey mocks base method.
 func (m *MockStore) GetOAuthSigningKey(arg0 context.Context) (string, error) {
 	m.ctrl.T.Helper()
@@ -1151,6 +1285,21 @@ func (mr *MockStoreMockRecorder) GetServiceBanner(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServiceBanner", reflect.TypeOf((*MockStore)(nil).GetServiceBanner), arg0)
 }
 
+// GetStuckRunningBuilds mocks base method.
+func (m *MockStore) GetStuckRunningBuilds(arg0 context.Context, arg1 time.Time) ([]database.WorkspaceBuild, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStuckRunningBuilds", arg0, arg1)
+	ret0, _ := ret[0].([]database.WorkspaceBuild)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStuckRunningBuilds indicates an expected call of GetStuckRunningBuilds.
+func (mr *MockStoreMockRecorder) GetStuckRunningBuilds(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStuckRunningBuilds", reflect.TypeOf((*MockStore)(nil).GetStuckRunningBuilds), arg0, arg1)
+}
+
 // GetTailnetAgents mocks base method.
 func (m *MockStore) GetTailnetAgents(arg0 context.Context, arg1 uuid.UUID) ([]database.TailnetAgent, error) {
 	m.ctrl.T.Helper()
@@ -1196,6 +1345,21 @@ func (mr *MockStoreMockRecorder) GetTemplateAverageBuildTime(arg0, arg1 interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTemplateAverageBuildTime", reflect.TypeOf((*MockStore)(nil).GetTemplateAverageBuildTime), arg0, arg1)
 }
 
+// GetTemplateBuildsPerDay mocks base method.
+func (m *MockStore) GetTemplateBuildsPerDay(arg0 context.Context, arg1 database.GetTemplateBuildsPerDayParams) ([]database.GetTemplateBuildsPerDayRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTemplateBuildsPerDay", arg0, arg1)
+	ret0, _ := ret[0].([]database.GetTemplateBuildsPerDayRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTemplateBuildsPerDay indicates an expected call of GetTemplateBuildsPerDay.
+func (mr *MockStoreMockRecorder) GetTemplateBuildsPerDay(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTemplateBuildsPerDay", reflect.TypeOf((*MockStore)(nil).GetTemplateBuildsPerDay), arg0, arg1)
+}
+
 // GetTemplateByID mocks base method.
 func (m *MockStore) GetTemplateByID(arg0 context.Context, arg1 uuid.UUID) (database.Template, error) {
 	m.ctrl.T.Helper()
@@ -1256,6 +1420,21 @@ func (mr *MockStoreMockRecorder) GetTemplateDailyInsights(arg0, arg1 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTemplateDailyInsights", reflect.TypeOf((*MockStore)(nil).GetTemplateDailyInsights), arg0, arg1)
 }
 
+// GetTemplateACL mocks base method.
+func (m *MockStore) GetTemplateACL(arg0 context.Context, arg1 uuid.UUID) (database.TemplateACLRoles, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTemplateACL", arg0, arg1)
+	ret0, _ := ret[0].(database.TemplateACLRoles)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTemplateACL indicates an expected call of GetTemplateACL.
+func (mr *MockStoreMockRecorder) GetTemplateACL(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTemplateACL", reflect.TypeOf((*MockStore)(nil).GetTemplateACL), arg0, arg1)
+}
+
 // GetTemplateGroupRoles mocks base method.
 func (m *MockStore) GetTemplateGroupRoles(arg0 context.Context, arg1 uuid.UUID) ([]database.TemplateGroup, error) {
 	m.ctrl.T.Helper()
@@ -1466,6 +1645,21 @@ func (mr *MockStoreMockRecorder) GetUnexpiredLicenses(arg0 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnexpiredLicenses", reflect.TypeOf((*MockStore)(nil).GetUnexpiredLicenses), arg0)
 }
 
+// GetUnusedTemplateVersions mocks base method.
+func (m *MockStore) GetUnusedTemplateVersions(arg0 context.Context, arg1 uuid.UUID) ([]database.TemplateVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUnusedTemplateVersions", arg0, arg1)
+	ret0, _ := ret[0].([]database.TemplateVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUnusedTemplateVersions indicates an expected call of GetUnusedTemplateVersions.
+func (mr *MockStoreMockRecorder) GetUnusedTemplateVersions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnusedTemplateVersions", reflect.TypeOf((*MockStore)(nil).GetUnusedTemplateVersions), arg0, arg1)
+}
+
 // GetUserByEmailOrUsername mocks base method.
 func (m *MockStore) GetUserByEmailOrUsername(arg0 context.Context, arg1 database.GetUserByEmailOrUsernameParams) (database.User, error) {
 	m.ctrl.T.Helper()
@@ -1511,6 +1705,21 @@ func (mr *MockStoreMockRecorder) GetUserCount(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserCount", reflect.TypeOf((*MockStore)(nil).GetUserCount), arg0)
 }
 
+// GetUserGroups mocks base method.
+func (m *MockStore) GetUserGroups(arg0 context.Context, arg1 uuid.UUID) ([]database.Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserGroups", arg0, arg1)
+	ret0, _ := ret[0].([]database.Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserGroups indicates an expected call of GetUserGroups.
+func (mr *MockStoreMockRecorder) GetUserGroups(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserGroups", reflect.TypeOf((*MockStore)(nil).GetUserGroups), arg0, arg1)
+}
+
 // GetUserLatencyInsights mocks base method.
 func (m *MockStore) GetUserLatencyInsights(arg0 context.Context, arg1 database.GetUserLatencyInsightsParams) ([]database.GetUserLatencyInsightsRow, error) {
 	m.ctrl.T.Helper()
@@ -1856,6 +2065,36 @@ func (mr *MockStoreMockRecorder) GetWorkspaceBuildByWorkspaceIDAndBuildNumber(ar
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceBuildByWorkspaceIDAndBuildNumber", reflect.TypeOf((*MockStore)(nil).GetWorkspaceBuildByWorkspaceIDAndBuildNumber), arg0, arg1)
 }
 
+// GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey mocks base method.
+func (m *MockStore) GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(arg0 context.Context, arg1 database.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams) (database.WorkspaceBuild, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey", arg0, arg1)
+	ret0, _ := ret[0].(database.WorkspaceBuild)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey indicates an expected call of GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey.
+func (mr *MockStoreMockRecorder) GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey", reflect.TypeOf((*MockStore)(nil).GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey), arg0, arg1)
+}
+
+// GetWorkspaceBuildIntentsByWorkspaceID mocks base method.
+func (m *MockStore) GetWorkspaceBuildIntentsByWorkspaceID(arg0 context.Context, arg1 uuid.UUID) ([]database.WorkspaceBuildIntent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaceBuildIntentsByWorkspaceID", arg0, arg1)
+	ret0, _ := ret[0].([]database.WorkspaceBuildIntent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaceBuildIntentsByWorkspaceID indicates an expected call of GetWorkspaceBuildIntentsByWorkspaceID.
+func (mr *MockStoreMockRecorder) GetWorkspaceBuildIntentsByWorkspaceID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceBuildIntentsByWorkspaceID", reflect.TypeOf((*MockStore)(nil).GetWorkspaceBuildIntentsByWorkspaceID), arg0, arg1)
+}
+
 // GetWorkspaceBuildParameters mocks base method.
 func (m *MockStore) GetWorkspaceBuildParameters(arg0 context.Context, arg1 uuid.UUID) ([]database.WorkspaceBuildParameter, error) {
 	m.ctrl.T.Helper()
@@ -1871,6 +2110,66 @@ func (mr *MockStoreMockRecorder) GetWorkspaceBuildParameters(arg0, arg1 interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceBuildParameters", reflect.TypeOf((*MockStore)(nil).GetWorkspaceBuildParameters), arg0, arg1)
 }
 
+// GetWorkspaceBuildParametersMap mocks base method.
+func (m *MockStore) GetWorkspaceBuildParametersMap(arg0 context.Context, arg1 uuid.UUID) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaceBuildParametersMap", arg0, arg1)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaceBuildParametersMap indicates an expected call of GetWorkspaceBuildParametersMap.
+func (mr *MockStoreMockRecorder) GetWorkspaceBuildParametersMap(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceBuildParametersMap", reflect.TypeOf((*MockStore)(nil).GetWorkspaceBuildParametersMap), arg0, arg1)
+}
+
+// GetWorkspaceBuildVersionLineage mocks base method.
+func (m *MockStore) GetWorkspaceBuildVersionLineage(arg0 context.Context, arg1 uuid.UUID) ([]database.GetWorkspaceBuildVersionLineageRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaceBuildVersionLineage", arg0, arg1)
+	ret0, _ := ret[0].([]database.GetWorkspaceBuildVersionLineageRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaceBuildVersionLineage indicates an expected call of GetWorkspaceBuildVersionLineage.
+func (mr *MockStoreMockRecorder) GetWorkspaceBuildVersionLineage(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceBuildVersionLineage", reflect.TypeOf((*MockStore)(nil).GetWorkspaceBuildVersionLineage), arg0, arg1)
+}
+
+// GetWorkspaceBuilds mocks base method.
+func (m *MockStore) GetWorkspaceBuilds(arg0 context.Context, arg1 database.GetWorkspaceBuildsParams) ([]database.GetWorkspaceBuildsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaceBuilds", arg0, arg1)
+	ret0, _ := ret[0].([]database.GetWorkspaceBuildsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaceBuilds indicates an expected call of GetWorkspaceBuilds.
+func (mr *MockStoreMockRecorder) GetWorkspaceBuilds(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceBuilds", reflect.TypeOf((*MockStore)(nil).GetWorkspaceBuilds), arg0, arg1)
+}
+
+// GetWorkspaceBuildsByInitiator mocks base method.
+func (m *MockStore) GetWorkspaceBuildsByInitiator(arg0 context.Context, arg1 database.GetWorkspaceBuildsByInitiatorParams) ([]database.GetWorkspaceBuildsByInitiatorRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaceBuildsByInitiator", arg0, arg1)
+	ret0, _ := ret[0].([]database.GetWorkspaceBuildsByInitiatorRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaceBuildsByInitiator indicates an expected call of GetWorkspaceBuildsByInitiator.
+func (mr *MockStoreMockRecorder) GetWorkspaceBuildsByInitiator(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceBuildsByInitiator", reflect.TypeOf((*MockStore)(nil).GetWorkspaceBuildsByInitiator), arg0, arg1)
+}
+
 // GetWorkspaceBuildsByWorkspaceID mocks base method.
 func (m *MockStore) GetWorkspaceBuildsByWorkspaceID(arg0 context.Context, arg1 database.GetWorkspaceBuildsByWorkspaceIDParams) ([]database.WorkspaceBuild, error) {
 	m.ctrl.T.Helper()
@@ -1901,6 +2200,36 @@ func (mr *MockStoreMockRecorder) GetWorkspaceBuildsCreatedAfter(arg0, arg1 inter
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceBuildsCreatedAfter", reflect.TypeOf((*MockStore)(nil).GetWorkspaceBuildsCreatedAfter), arg0, arg1)
 }
 
+// GetWorkspaceBuildsWithParameters mocks base method.
+func (m *MockStore) GetWorkspaceBuildsWithParameters(arg0 context.Context, arg1 uuid.UUID) ([]database.WorkspaceBuildWithParameters, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaceBuildsWithParameters", arg0, arg1)
+	ret0, _ := ret[0].([]database.WorkspaceBuildWithParameters)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaceBuildsWithParameters indicates an expected call of GetWorkspaceBuildsWithParameters.
+func (mr *MockStoreMockRecorder) GetWorkspaceBuildsWithParameters(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceBuildsWithParameters", reflect.TypeOf((*MockStore)(nil).GetWorkspaceBuildsWithParameters), arg0, arg1)
+}
+
+// GetWorkspaceBuildsWithParametersJSON mocks base method.
+func (m *MockStore) GetWorkspaceBuildsWithParametersJSON(arg0 context.Context, arg1 uuid.UUID) ([]database.GetWorkspaceBuildsWithParametersJSONRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaceBuildsWithParametersJSON", arg0, arg1)
+	ret0, _ := ret[0].([]database.GetWorkspaceBuildsWithParametersJSONRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaceBuildsWithParametersJSON indicates an expected call of GetWorkspaceBuildsWithParametersJSON.
+func (mr *MockStoreMockRecorder) GetWorkspaceBuildsWithParametersJSON(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceBuildsWithParametersJSON", reflect.TypeOf((*MockStore)(nil).GetWorkspaceBuildsWithParametersJSON), arg0, arg1)
+}
+
 // GetWorkspaceByAgentID mocks base method.
 func (m *MockStore) GetWorkspaceByAgentID(arg0 context.Context, arg1 uuid.UUID) (database.Workspace, error) {
 	m.ctrl.T.Helper()
@@ -1961,6 +2290,21 @@ func (mr *MockStoreMockRecorder) GetWorkspaceByWorkspaceAppID(arg0, arg1 interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceByWorkspaceAppID", reflect.TypeOf((*MockStore)(nil).GetWorkspaceByWorkspaceAppID), arg0, arg1)
 }
 
+// GetWorkspaceCountByOwner mocks base method.
+func (m *MockStore) GetWorkspaceCountByOwner(arg0 context.Context, arg1 database.GetWorkspaceCountByOwnerParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaceCountByOwner", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaceCountByOwner indicates an expected call of GetWorkspaceCountByOwner.
+func (mr *MockStoreMockRecorder) GetWorkspaceCountByOwner(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceCountByOwner", reflect.TypeOf((*MockStore)(nil).GetWorkspaceCountByOwner), arg0, arg1)
+}
+
 // GetWorkspaceProxies mocks base method.
 func (m *MockStore) GetWorkspaceProxies(arg0 context.Context) ([]database.WorkspaceProxy, error) {
 	m.ctrl.T.Helper()
@@ -2126,6 +2470,21 @@ func (mr *MockStoreMockRecorder) GetWorkspaces(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaces", reflect.TypeOf((*MockStore)(nil).GetWorkspaces), arg0, arg1)
 }
 
+// GetWorkspacesDueForAutostop mocks base method.
+func (m *MockStore) GetWorkspacesDueForAutostop(arg0 context.Context, arg1 time.Time) ([]database.GetWorkspacesDueForAutostopRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspacesDueForAutostop", arg0, arg1)
+	ret0, _ := ret[0].([]database.GetWorkspacesDueForAutostopRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspacesDueForAutostop indicates an expected call of GetWorkspacesDueForAutostop.
+func (mr *MockStoreMockRecorder) GetWorkspacesDueForAutostop(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspacesDueForAutostop", reflect.TypeOf((*MockStore)(nil).GetWorkspacesDueForAutostop), arg0, arg1)
+}
+
 // GetWorkspacesEligibleForTransition mocks base method.
 func (m *MockStore) GetWorkspacesEligibleForTransition(arg0 context.Context, arg1 time.Time) ([]database.Workspace, error) {
 	m.ctrl.T.Helper()
@@ -2141,6 +2500,21 @@ func (mr *MockStoreMockRecorder) GetWorkspacesEligibleForTransition(arg0, arg1 i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspacesEligibleForTransition", reflect.TypeOf((*MockStore)(nil).GetWorkspacesEligibleForTransition), arg0, arg1)
 }
 
+// GetWorkspacesWithFailedLatestBuild mocks base method.
+func (m *MockStore) GetWorkspacesWithFailedLatestBuild(arg0 context.Context, arg1 database.GetWorkspacesWithFailedLatestBuildParams) ([]database.GetWorkspacesWithFailedLatestBuildRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspacesWithFailedLatestBuild", arg0, arg1)
+	ret0, _ := ret[0].([]database.GetWorkspacesWithFailedLatestBuildRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspacesWithFailedLatestBuild indicates an expected call of GetWorkspacesWithFailedLatestBuild.
+func (mr *MockStoreMockRecorder) GetWorkspacesWithFailedLatestBuild(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspacesWithFailedLatestBuild", reflect.TypeOf((*MockStore)(nil).GetWorkspacesWithFailedLatestBuild), arg0, arg1)
+}
+
 // InTx mocks base method.
 func (m *MockStore) InTx(arg0 func(database.Store) error, arg1 *sql.TxOptions) error {
 	m.ctrl.T.Helper()
@@ -2612,6 +2986,20 @@ func (mr *MockStoreMockRecorder) InsertWorkspaceBuild(arg0, arg1 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertWorkspaceBuild", reflect.TypeOf((*MockStore)(nil).InsertWorkspaceBuild), arg0, arg1)
 }
 
+// InsertWorkspaceBuildIntent mocks base method.
+func (m *MockStore) InsertWorkspaceBuildIntent(arg0 context.Context, arg1 database.InsertWorkspaceBuildIntentParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertWorkspaceBuildIntent", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertWorkspaceBuildIntent indicates an expected call of InsertWorkspaceBuildIntent.
+func (mr *MockStoreMockRecorder) InsertWorkspaceBuildIntent(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertWorkspaceBuildIntent", reflect.TypeOf((*MockStore)(nil).InsertWorkspaceBuildIntent), arg0, arg1)
+}
+
 // InsertWorkspaceBuildParameters mocks base method.
 func (m *MockStore) InsertWorkspaceBuildParameters(arg0 context.Context, arg1 database.InsertWorkspaceBuildParametersParams) error {
 	m.ctrl.T.Helper()
@@ -3248,6 +3636,20 @@ func (mr *MockStoreMockRecorder) UpdateWorkspaceBuildCostByID(arg0, arg1 interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWorkspaceBuildCostByID", reflect.TypeOf((*MockStore)(nil).UpdateWorkspaceBuildCostByID), arg0, arg1)
 }
 
+// UpdateWorkspaceBuildIntentBuildByID mocks base method.
+func (m *MockStore) UpdateWorkspaceBuildIntentBuildByID(arg0 context.Context, arg1 database.UpdateWorkspaceBuildIntentBuildByIDParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWorkspaceBuildIntentBuildByID", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWorkspaceBuildIntentBuildByID indicates an expected call of UpdateWorkspaceBuildIntentBuildByID.
+func (mr *MockStoreMockRecorder) UpdateWorkspaceBuildIntentBuildByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWorkspaceBuildIntentBuildByID", reflect.TypeOf((*MockStore)(nil).UpdateWorkspaceBuildIntentBuildByID), arg0, arg1)
+}
+
 // UpdateWorkspaceDeletedByID mocks base method.
 func (m *MockStore) UpdateWorkspaceDeletedByID(arg0 context.Context, arg1 database.UpdateWorkspaceDeletedByIDParams) error {
 	m.ctrl.T.Helper()
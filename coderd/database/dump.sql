@@ -413,9 +413,15 @@ CREATE TABLE provisioner_jobs (
     file_id uuid NOT NULL,
     tags jsonb DEFAULT '{"scope": "organization"}'::jsonb NOT NULL,
     error_code text,
-    trace_metadata jsonb
+    trace_metadata jsonb,
+    priority integer DEFAULT 0 NOT NULL,
+    pending_deadline timestamp with time zone
 );
 
+COMMENT ON COLUMN provisioner_jobs.priority IS 'Jobs with a higher priority are acquired by provisioner daemons first. Jobs with equal priority are acquired in the order they were created.';
+
+COMMENT ON COLUMN provisioner_jobs.pending_deadline IS 'If set, the job is failed if it has not been acquired by a provisioner daemon by this time.';
+
 CREATE TABLE replicas (
     id uuid NOT NULL,
     created_at timestamp with time zone NOT NULL,
@@ -547,13 +553,16 @@ CREATE TABLE template_versions (
     job_id uuid NOT NULL,
     created_by uuid NOT NULL,
     git_auth_providers text[],
-    message character varying(1048576) DEFAULT ''::character varying NOT NULL
+    message character varying(1048576) DEFAULT ''::character varying NOT NULL,
+    throwaway boolean DEFAULT false NOT NULL
 );
 
 COMMENT ON COLUMN template_versions.git_auth_providers IS 'IDs of Git auth providers for a specific template version';
 
 COMMENT ON COLUMN template_versions.message IS 'Message describing the changes in this version of the template, similar to a Git commit message. Like a commit message, this should be a short, high-level description of the changes in this version of the template. This message is immutable and should not be updated after the fact.';
 
+COMMENT ON COLUMN template_versions.throwaway IS 'Throwaway versions are only imported to inspect their resources and parameters, e.g. by "coder templates plan". They can''t be used to create a template, be promoted or be built, and are purged after a day.';
+
 CREATE TABLE users (
     id uuid NOT NULL,
     email text NOT NULL,
@@ -567,11 +576,14 @@ CREATE TABLE users (
     avatar_url text,
     deleted boolean DEFAULT false NOT NULL,
     last_seen_at timestamp without time zone DEFAULT '0001-01-01 00:00:00'::timestamp without time zone NOT NULL,
-    quiet_hours_schedule text DEFAULT ''::text NOT NULL
+    quiet_hours_schedule text DEFAULT ''::text NOT NULL,
+    name text DEFAULT ''::text NOT NULL
 );
 
 COMMENT ON COLUMN users.quiet_hours_schedule IS 'Daily (!) cron schedule (with optional CRON_TZ) signifying the start of the user''s quiet hours. If empty, the default quiet hours on the instance is used instead.';
 
+COMMENT ON COLUMN users.name IS 'Full name of the user, for display. Empty if not set.';
+
 CREATE VIEW visible_users AS
  SELECT users.id,
     users.username,
@@ -592,6 +604,7 @@ CREATE VIEW template_version_with_user AS
     template_versions.created_by,
     template_versions.git_auth_providers,
     template_versions.message,
+    template_versions.throwaway,
     COALESCE(visible_users.avatar_url, ''::text) AS created_by_avatar_url,
     COALESCE(visible_users.username, ''::text) AS created_by_username
    FROM (public.template_versions
@@ -814,6 +827,23 @@ CREATE TABLE workspace_apps (
     external boolean DEFAULT false NOT NULL
 );
 
+CREATE TABLE workspace_build_intents (
+    id uuid NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    workspace_id uuid NOT NULL,
+    initiator_id uuid NOT NULL,
+    transition workspace_transition NOT NULL,
+    reason build_reason NOT NULL,
+    template_version_id uuid,
+    workspace_build_id uuid
+);
+
+COMMENT ON TABLE workspace_build_intents IS 'Requests for workspace builds, recorded before the build is computed, so that requests rejected before a build was created are visible.';
+
+COMMENT ON COLUMN workspace_build_intents.template_version_id IS 'The template version requested for the build, if the request named one.';
+
+COMMENT ON COLUMN workspace_build_intents.workspace_build_id IS 'The build created for the request, or NULL if no build was created.';
+
 CREATE TABLE workspace_build_parameters (
     workspace_build_id uuid NOT NULL,
     name text NOT NULL,
@@ -838,9 +868,27 @@ CREATE TABLE workspace_builds (
     deadline timestamp with time zone DEFAULT '0001-01-01 00:00:00+00'::timestamp with time zone NOT NULL,
     reason build_reason DEFAULT 'initiator'::build_reason NOT NULL,
     daily_cost integer DEFAULT 0 NOT NULL,
-    max_deadline timestamp with time zone DEFAULT '0001-01-01 00:00:00+00'::timestamp with time zone NOT NULL
+    max_deadline timestamp with time zone DEFAULT '0001-01-01 00:00:00+00'::timestamp with time zone NOT NULL,
+    rollback_of uuid,
+    parameters_from_build_id uuid,
+    structured_reason jsonb,
+    idempotency_key text,
+    template_file_hash text,
+    schedule_name text
 );
 
+COMMENT ON COLUMN workspace_builds.idempotency_key IS 'Optional client-provided key identifying the request that created the build, so that retried requests return the existing build rather than creating a duplicate.';
+
+COMMENT ON COLUMN workspace_builds.parameters_from_build_id IS 'The prior build of the same workspace whose parameters this build shares, if it did not store its own.';
+
+COMMENT ON COLUMN workspace_builds.structured_reason IS 'Optional structured reason for the build, with a category, subcategory and source system. The reason column holds the coarse equivalent.';
+
+COMMENT ON COLUMN workspace_builds.rollback_of IS 'The prior build of the same workspace that this build rolls back to, if any.';
+
+COMMENT ON COLUMN workspace_builds.template_file_hash IS 'The hash of the source file of the template version, as of when the build was created, so that the source that produced a workspace can be verified even after the template is updated.';
+
+COMMENT ON COLUMN workspace_builds.schedule_name IS 'The name of the schedule that triggered the build, if it was started or stopped by a schedule.';
+
 CREATE VIEW workspace_build_with_user AS
  SELECT workspace_builds.id,
     workspace_builds.created_at,
@@ -856,6 +904,12 @@ CREATE VIEW workspace_build_with_user AS
     workspace_builds.reason,
     workspace_builds.daily_cost,
     workspace_builds.max_deadline,
+    workspace_builds.rollback_of,
+    workspace_builds.parameters_from_build_id,
+    workspace_builds.structured_reason,
+    workspace_builds.idempotency_key,
+    workspace_builds.template_file_hash,
+    workspace_builds.schedule_name,
     COALESCE(visible_users.avatar_url, ''::text) AS initiator_by_avatar_url,
     COALESCE(visible_users.username, ''::text) AS initiator_by_username
    FROM (public.workspace_builds
@@ -1068,6 +1122,9 @@ ALTER TABLE ONLY workspace_apps
 ALTER TABLE ONLY workspace_apps
     ADD CONSTRAINT workspace_apps_pkey PRIMARY KEY (id);
 
+ALTER TABLE ONLY workspace_build_intents
+    ADD CONSTRAINT workspace_build_intents_pkey PRIMARY KEY (id);
+
 ALTER TABLE ONLY workspace_build_parameters
     ADD CONSTRAINT workspace_build_parameters_workspace_build_id_name_key UNIQUE (workspace_build_id, name);
 
@@ -1148,6 +1205,10 @@ CREATE INDEX workspace_agents_auth_token_idx ON workspace_agents USING btree (au
 
 CREATE INDEX workspace_agents_resource_id_idx ON workspace_agents USING btree (resource_id);
 
+CREATE INDEX workspace_build_intents_workspace_id_idx ON workspace_build_intents USING btree (workspace_id);
+
+CREATE UNIQUE INDEX workspace_builds_workspace_id_idempotency_key_idx ON workspace_builds USING btree (workspace_id, idempotency_key) WHERE (idempotency_key IS NOT NULL);
+
 CREATE UNIQUE INDEX workspace_proxies_lower_name_idx ON workspace_proxies USING btree (lower(name)) WHERE (deleted = false);
 
 CREATE INDEX workspace_resources_job_id_idx ON workspace_resources USING btree (job_id);
@@ -1236,12 +1297,24 @@ ALTER TABLE ONLY workspace_agents
 ALTER TABLE ONLY workspace_apps
     ADD CONSTRAINT workspace_apps_agent_id_fkey FOREIGN KEY (agent_id) REFERENCES workspace_agents(id) ON DELETE CASCADE;
 
+ALTER TABLE ONLY workspace_build_intents
+    ADD CONSTRAINT workspace_build_intents_workspace_build_id_fkey FOREIGN KEY (workspace_build_id) REFERENCES workspace_builds(id) ON DELETE SET NULL;
+
+ALTER TABLE ONLY workspace_build_intents
+    ADD CONSTRAINT workspace_build_intents_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE;
+
 ALTER TABLE ONLY workspace_build_parameters
     ADD CONSTRAINT workspace_build_parameters_workspace_build_id_fkey FOREIGN KEY (workspace_build_id) REFERENCES workspace_builds(id) ON DELETE CASCADE;
 
 ALTER TABLE ONLY workspace_builds
     ADD CONSTRAINT workspace_builds_job_id_fkey FOREIGN KEY (job_id) REFERENCES provisioner_jobs(id) ON DELETE CASCADE;
 
+ALTER TABLE ONLY workspace_builds
+    ADD CONSTRAINT workspace_builds_parameters_from_build_id_fkey FOREIGN KEY (parameters_from_build_id) REFERENCES workspace_builds(id) ON DELETE SET NULL;
+
+ALTER TABLE ONLY workspace_builds
+    ADD CONSTRAINT workspace_builds_rollback_of_fkey FOREIGN KEY (rollback_of) REFERENCES workspace_builds(id) ON DELETE SET NULL;
+
 ALTER TABLE ONLY workspace_builds
     ADD CONSTRAINT workspace_builds_template_version_id_fkey FOREIGN KEY (template_version_id) REFERENCES template_versions(id) ON DELETE CASCADE;
 
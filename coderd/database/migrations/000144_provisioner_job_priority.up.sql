@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE provisioner_jobs
+	ADD COLUMN priority integer NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN provisioner_jobs.priority IS 'Jobs with a higher priority are acquired by provisioner daemons first. Jobs with equal priority are acquired in the order they were created.';
+
+COMMIT;
@@ -0,0 +1,28 @@
+BEGIN;
+
+-- The view has to be recreated so that it picks up the new column.
+DROP VIEW workspace_build_with_user;
+
+ALTER TABLE workspace_builds
+	ADD COLUMN structured_reason jsonb NULL;
+
+COMMENT ON COLUMN workspace_builds.structured_reason IS 'Optional structured reason for the build, with a category, subcategory and source system. The reason column holds the coarse equivalent.';
+
+-- If you need to update this view, put 'DROP VIEW workspace_build_with_user;' before this.
+CREATE VIEW
+	workspace_build_with_user
+AS
+SELECT
+	workspace_builds.*,
+	coalesce(visible_users.avatar_url, '') AS initiator_by_avatar_url,
+	coalesce(visible_users.username, '') AS initiator_by_username
+FROM
+	workspace_builds
+	LEFT JOIN
+		visible_users
+	ON
+		workspace_builds.initiator_id = visible_users.id;
+
+COMMENT ON VIEW workspace_build_with_user IS 'Joins in the username + avatar url of the initiated by user.';
+
+COMMIT;
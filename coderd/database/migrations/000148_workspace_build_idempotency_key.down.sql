@@ -0,0 +1,26 @@
+BEGIN;
+
+DROP VIEW workspace_build_with_user;
+
+DROP INDEX workspace_builds_workspace_id_idempotency_key_idx;
+
+ALTER TABLE workspace_builds
+	DROP COLUMN idempotency_key;
+
+CREATE VIEW
+	workspace_build_with_user
+AS
+SELECT
+	workspace_builds.*,
+	coalesce(visible_users.avatar_url, '') AS initiator_by_avatar_url,
+	coalesce(visible_users.username, '') AS initiator_by_username
+FROM
+	workspace_builds
+	LEFT JOIN
+		visible_users
+	ON
+		workspace_builds.initiator_id = visible_users.id;
+
+COMMENT ON VIEW workspace_build_with_user IS 'Joins in the username + avatar url of the initiated by user.';
+
+COMMIT;
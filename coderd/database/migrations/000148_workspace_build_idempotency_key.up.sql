@@ -0,0 +1,30 @@
+BEGIN;
+
+-- The view has to be recreated so that it picks up the new column.
+DROP VIEW workspace_build_with_user;
+
+ALTER TABLE workspace_builds
+	ADD COLUMN idempotency_key text NULL;
+
+COMMENT ON COLUMN workspace_builds.idempotency_key IS 'Optional client-provided key identifying the request that created the build, so that retried requests return the existing build rather than creating a duplicate.';
+
+CREATE UNIQUE INDEX workspace_builds_workspace_id_idempotency_key_idx ON workspace_builds (workspace_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+-- If you need to update this view, put 'DROP VIEW workspace_build_with_user;' before this.
+CREATE VIEW
+	workspace_build_with_user
+AS
+SELECT
+	workspace_builds.*,
+	coalesce(visible_users.avatar_url, '') AS initiator_by_avatar_url,
+	coalesce(visible_users.username, '') AS initiator_by_username
+FROM
+	workspace_builds
+	LEFT JOIN
+		visible_users
+	ON
+		workspace_builds.initiator_id = visible_users.id;
+
+COMMENT ON VIEW workspace_build_with_user IS 'Joins in the username + avatar url of the initiated by user.';
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE provisioner_jobs
+	DROP COLUMN pending_deadline;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE provisioner_jobs
+	ADD COLUMN pending_deadline timestamp with time zone NULL;
+
+COMMENT ON COLUMN provisioner_jobs.pending_deadline IS 'If set, the job is failed if it has not been acquired by a provisioner daemon by this time.';
+
+COMMIT;
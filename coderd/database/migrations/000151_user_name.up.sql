@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE users
+	ADD COLUMN name text NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN users.name IS 'Full name of the user, for display. Empty if not set.';
+
+COMMIT;
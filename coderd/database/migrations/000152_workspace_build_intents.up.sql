@@ -0,0 +1,23 @@
+BEGIN;
+
+CREATE TABLE workspace_build_intents (
+	id uuid NOT NULL,
+	created_at timestamp with time zone NOT NULL,
+	workspace_id uuid NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+	initiator_id uuid NOT NULL,
+	transition workspace_transition NOT NULL,
+	reason build_reason NOT NULL,
+	template_version_id uuid NULL,
+	workspace_build_id uuid NULL REFERENCES workspace_builds(id) ON DELETE SET NULL,
+	PRIMARY KEY (id)
+);
+
+COMMENT ON TABLE workspace_build_intents IS 'Requests for workspace builds, recorded before the build is computed, so that requests rejected before a build was created are visible.';
+
+COMMENT ON COLUMN workspace_build_intents.template_version_id IS 'The template version requested for the build, if the request named one.';
+
+COMMENT ON COLUMN workspace_build_intents.workspace_build_id IS 'The build created for the request, or NULL if no build was created.';
+
+CREATE INDEX workspace_build_intents_workspace_id_idx ON workspace_build_intents USING btree (workspace_id);
+
+COMMIT;
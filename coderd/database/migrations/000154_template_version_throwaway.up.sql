@@ -0,0 +1,28 @@
+BEGIN;
+
+-- The view has to be recreated so that it picks up the new column.
+DROP VIEW template_version_with_user;
+
+ALTER TABLE template_versions
+	ADD COLUMN throwaway boolean NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN template_versions.throwaway IS 'Throwaway versions are only imported to inspect their resources and parameters, e.g. by "coder templates plan". They can''t be used to create a template, be promoted or be built, and are purged after a day.';
+
+-- If you need to update this view, put 'DROP VIEW template_version_with_user;' before this.
+CREATE VIEW
+	template_version_with_user
+AS
+SELECT
+	template_versions.*,
+	coalesce(visible_users.avatar_url, '') AS created_by_avatar_url,
+	coalesce(visible_users.username, '') AS created_by_username
+FROM
+	template_versions
+	LEFT JOIN
+		visible_users
+	ON
+		template_versions.created_by = visible_users.id;
+
+COMMENT ON VIEW template_version_with_user IS 'Joins in the username + avatar url of the created by user.';
+
+COMMIT;
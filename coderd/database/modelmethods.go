@@ -334,6 +334,7 @@ func ConvertUserRows(rows []GetUsersRow) []User {
 			AvatarURL:      r.AvatarURL,
 			Deleted:        r.Deleted,
 			LastSeenAt:     r.LastSeenAt,
+			Name:           r.Name,
 		}
 	}
 
@@ -362,3 +363,13 @@ func ConvertWorkspaceRows(rows []GetWorkspacesRow) []Workspace {
 
 	return workspaces
 }
+
+// WorkspaceBuildParametersMap converts workspace build parameters into a map
+// of name to value. If a name appears more than once, the last value wins.
+func WorkspaceBuildParametersMap(params []WorkspaceBuildParameter) map[string]string {
+	m := make(map[string]string, len(params))
+	for _, p := range params {
+		m[p.Name] = p.Value
+	}
+	return m
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -23,13 +24,16 @@ const (
 type customQuerier interface {
 	templateQuerier
 	workspaceQuerier
+	workspaceBuildQuerier
 	userQuerier
 }
 
 type templateQuerier interface {
 	GetAuthorizedTemplates(ctx context.Context, arg GetTemplatesWithFilterParams, prepared rbac.PreparedAuthorized) ([]Template, error)
+	CountAuthorizedTemplates(ctx context.Context, arg GetTemplatesWithFilterParams, prepared rbac.PreparedAuthorized) (int64, error)
 	GetTemplateGroupRoles(ctx context.Context, id uuid.UUID) ([]TemplateGroup, error)
 	GetTemplateUserRoles(ctx context.Context, id uuid.UUID) ([]TemplateUser, error)
+	GetTemplateACL(ctx context.Context, id uuid.UUID) (TemplateACLRoles, error)
 }
 
 func (q *sqlQuerier) GetAuthorizedTemplates(ctx context.Context, arg GetTemplatesWithFilterParams, prepared rbac.PreparedAuthorized) ([]Template, error) {
@@ -47,11 +51,14 @@ func (q *sqlQuerier) GetAuthorizedTemplates(ctx context.Context, arg GetTemplate
 
 	// The name comment is for metric tracking
 	query := fmt.Sprintf("-- name: GetAuthorizedTemplates :many\n%s", filtered)
+	q.onAuthorizedQuery(ctx, query)
 	rows, err := q.db.QueryContext(ctx, query,
 		arg.Deleted,
 		arg.OrganizationID,
 		arg.ExactName,
 		pq.Array(arg.IDs),
+		arg.NoBuildsSince,
+		arg.CreatedBy,
 	)
 	if err != nil {
 		return nil, err
@@ -101,48 +108,51 @@ func (q *sqlQuerier) GetAuthorizedTemplates(ctx context.Context, arg GetTemplate
 	return items, nil
 }
 
+// CountAuthorizedTemplates returns the number of templates GetAuthorizedTemplates would return for the same
+// arguments, e.g. to paginate them.  The templates query is counted as a subquery, so both apply identical filters.
+func (q *sqlQuerier) CountAuthorizedTemplates(ctx context.Context, arg GetTemplatesWithFilterParams, prepared rbac.PreparedAuthorized) (int64, error) {
+	authorizedFilter, err := prepared.CompileToSQL(ctx, regosql.ConvertConfig{
+		VariableConverter: regosql.TemplateConverter(),
+	})
+	if err != nil {
+		return 0, xerrors.Errorf("compile authorized filter: %w", err)
+	}
+
+	filtered, err := insertAuthorizedFilter(getTemplatesWithFilter, fmt.Sprintf(" AND %s", authorizedFilter))
+	if err != nil {
+		return 0, xerrors.Errorf("insert authorized filter: %w", err)
+	}
+
+	// The name comment is for metric tracking
+	query := fmt.Sprintf("-- name: CountAuthorizedTemplates :one\nSELECT count(*) FROM (\n%s\n) AS templates",
+		strings.TrimSuffix(strings.TrimSpace(filtered), ";"))
+	q.onAuthorizedQuery(ctx, query)
+	row := q.db.QueryRowContext(ctx, query,
+		arg.Deleted,
+		arg.OrganizationID,
+		arg.ExactName,
+		pq.Array(arg.IDs),
+		arg.NoBuildsSince,
+		arg.CreatedBy,
+	)
+	var count int64
+	err = row.Scan(&count)
+	return count, err
+}
+
 type TemplateUser struct {
 	User
 	Actions Actions `db:"actions"`
 }
 
+// GetTemplateUserRoles returns the roles of the active users in the template's
+// ACL.  It is implemented with GetTemplateACL.
 func (q *sqlQuerier) GetTemplateUserRoles(ctx context.Context, id uuid.UUID) ([]TemplateUser, error) {
-	const query = `
-	SELECT
-		perms.value as actions, users.*
-	FROM
-		users
-	JOIN
-		(
-			SELECT
-				*
-			FROM
-				jsonb_each_text(
-					(
-						SELECT
-							templates.user_acl
-						FROM
-							templates
-						WHERE
-							id = $1
-					)
-				)
-		) AS perms
-	ON
-		users.id::text = perms.key
-	WHERE
-		users.deleted = false
-	AND
-		users.status = 'active';
-	`
-
-	var tus []TemplateUser
-	err := q.db.SelectContext(ctx, &tus, query, id.String())
+	acl, err := q.GetTemplateACL(ctx, id)
 	if err != nil {
 		return nil, xerrors.Errorf("select user actions: %w", err)
 	}
-
-	return tus, nil
+	return acl.Users, nil
 }
 
 type TemplateGroup struct {
@@ -150,49 +160,123 @@ type TemplateGroup struct {
 	Actions Actions `db:"actions"`
 }
 
+// GetTemplateGroupRoles returns the roles of the groups in the template's ACL.
+// It is implemented with GetTemplateACL.
 func (q *sqlQuerier) GetTemplateGroupRoles(ctx context.Context, id uuid.UUID) ([]TemplateGroup, error) {
-	const query = `
+	acl, err := q.GetTemplateACL(ctx, id)
+	if err != nil {
+		return nil, xerrors.Errorf("select group roles: %w", err)
+	}
+	return acl.Groups, nil
+}
+
+// TemplateACLRoles is the combined access control list of a template.  Like
+// GetTemplateUserRoles, only active users are included.
+type TemplateACLRoles struct {
+	Users  []TemplateUser
+	Groups []TemplateGroup
+}
+
+// templateACLCTE looks up the ACLs of the template with ID $1, for the user
+// and group halves of GetTemplateACL.
+const templateACLCTE = `
+	WITH template AS (
+		SELECT
+			templates.user_acl, templates.group_acl
+		FROM
+			templates
+		WHERE
+			id = $1
+	)
+`
+
+// GetTemplateACL returns both the user and group roles of the template, so
+// that callers rendering its permissions make a single call.  The user and
+// group halves are selected by two queries over the same template lookup.
+func (q *sqlQuerier) GetTemplateACL(ctx context.Context, id uuid.UUID) (TemplateACLRoles, error) {
+	const usersQuery = templateACLCTE + `
 	SELECT
-		perms.value as actions, groups.*
+		perms.value AS actions, users.*
+	FROM
+		users
+	JOIN
+		jsonb_each_text((SELECT user_acl FROM template)) AS perms
+	ON
+		users.id::text = perms.key
+	WHERE
+		users.deleted = false
+	AND
+		users.status = 'active';
+	`
+	const groupsQuery = templateACLCTE + `
+	SELECT
+		perms.value AS actions, groups.*
 	FROM
 		groups
 	JOIN
-		(
-			SELECT
-				*
-			FROM
-				jsonb_each_text(
-					(
-						SELECT
-							templates.group_acl
-						FROM
-							templates
-						WHERE
-							id = $1
-					)
-				)
-		) AS perms
+		jsonb_each_text((SELECT group_acl FROM template)) AS perms
 	ON
 		groups.id::text = perms.key;
 	`
 
-	var tgs []TemplateGroup
-	err := q.db.SelectContext(ctx, &tgs, query, id.String())
+	var acl TemplateACLRoles
+	err := q.db.SelectContext(ctx, &acl.Users, usersQuery, id.String())
 	if err != nil {
-		return nil, xerrors.Errorf("select group roles: %w", err)
+		return TemplateACLRoles{}, xerrors.Errorf("select user actions: %w", err)
 	}
-
-	return tgs, nil
+	err = q.db.SelectContext(ctx, &acl.Groups, groupsQuery, id.String())
+	if err != nil {
+		return TemplateACLRoles{}, xerrors.Errorf("select group actions: %w", err)
+	}
+	return acl, nil
 }
 
 type workspaceQuerier interface {
 	GetAuthorizedWorkspaces(ctx context.Context, arg GetWorkspacesParams, prepared rbac.PreparedAuthorized) ([]GetWorkspacesRow, error)
+	GetAuthorizedWorkspacesWithFailedLatestBuild(ctx context.Context, arg GetWorkspacesWithFailedLatestBuildParams, prepared rbac.PreparedAuthorized) ([]GetWorkspacesWithFailedLatestBuildRow, error)
+}
+
+// Columns accepted by GetWorkspacesParams.SortBy.
+const (
+	WorkspacesSortByLastUsedAt = "last_used_at"
+	WorkspacesSortByName       = "name"
+	WorkspacesSortByCreatedAt  = "created_at"
+)
+
+// Directions accepted by GetWorkspacesParams.SortOrder.
+const (
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+)
+
+// ValidateWorkspacesSort returns an error if the sort column or direction is
+// not one of the whitelisted values. An empty sort column keeps the default
+// ordering, and an empty direction sorts ascending.
+func ValidateWorkspacesSort(sortBy, sortOrder string) error {
+	switch sortBy {
+	case "", WorkspacesSortByLastUsedAt, WorkspacesSortByName, WorkspacesSortByCreatedAt:
+	default:
+		return xerrors.Errorf("unknown workspace sort column %q", sortBy)
+	}
+	switch sortOrder {
+	case "", SortOrderAsc, SortOrderDesc:
+	default:
+		return xerrors.Errorf("unknown sort order %q", sortOrder)
+	}
+	if sortBy == "" && sortOrder != "" {
+		return xerrors.Errorf("sort order %q requires a sort column", sortOrder)
+	}
+	return nil
 }
 
 // GetAuthorizedWorkspaces returns all workspaces that the user is authorized to access.
 // This code is copied from `GetWorkspaces` and adds the authorized filter WHERE
 // clause.
 func (q *sqlQuerier) GetAuthorizedWorkspaces(ctx context.Context, arg GetWorkspacesParams, prepared rbac.PreparedAuthorized) ([]GetWorkspacesRow, error) {
+	if err := ValidateWorkspacesSort(arg.SortBy, arg.SortOrder); err != nil {
+		return nil, err
+	}
+
 	authorizedFilter, err := prepared.CompileToSQL(ctx, rbac.ConfigWithoutACL())
 	if err != nil {
 		return nil, xerrors.Errorf("compile authorized filter: %w", err)
@@ -207,6 +291,7 @@ func (q *sqlQuerier) GetAuthorizedWorkspaces(ctx context.Context, arg GetWorkspa
 
 	// The name comment is for metric tracking
 	query := fmt.Sprintf("-- name: GetAuthorizedWorkspaces :many\n%s", filtered)
+	q.onAuthorizedQuery(ctx, query)
 	rows, err := q.db.QueryContext(ctx, query,
 		arg.Deleted,
 		arg.Status,
@@ -215,8 +300,13 @@ func (q *sqlQuerier) GetAuthorizedWorkspaces(ctx context.Context, arg GetWorkspa
 		arg.TemplateName,
 		pq.Array(arg.TemplateIDs),
 		arg.Name,
+		arg.SearchQuery,
 		arg.HasAgent,
 		arg.AgentInactiveDisconnectTimeoutSeconds,
+		arg.LockedOnly,
+		arg.RunningOnly,
+		arg.SortBy,
+		arg.SortOrder,
 		arg.Offset,
 		arg.Limit,
 	)
@@ -259,6 +349,175 @@ func (q *sqlQuerier) GetAuthorizedWorkspaces(ctx context.Context, arg GetWorkspa
 	return items, nil
 }
 
+// GetAuthorizedWorkspacesWithFailedLatestBuild returns the workspaces the user
+// is authorized to read whose latest build failed. This code is copied from
+// `GetWorkspacesWithFailedLatestBuild` and adds the authorized filter.
+func (q *sqlQuerier) GetAuthorizedWorkspacesWithFailedLatestBuild(ctx context.Context, arg GetWorkspacesWithFailedLatestBuildParams, prepared rbac.PreparedAuthorized) ([]GetWorkspacesWithFailedLatestBuildRow, error) {
+	authorizedFilter, err := prepared.CompileToSQL(ctx, rbac.ConfigWithoutACL())
+	if err != nil {
+		return nil, xerrors.Errorf("compile authorized filter: %w", err)
+	}
+
+	filtered, err := insertAuthorizedFilter(getWorkspacesWithFailedLatestBuild, fmt.Sprintf(" AND %s", authorizedFilter))
+	if err != nil {
+		return nil, xerrors.Errorf("insert authorized filter: %w", err)
+	}
+
+	// The name comment is for metric tracking
+	query := fmt.Sprintf("-- name: GetAuthorizedWorkspacesWithFailedLatestBuild :many\n%s", filtered)
+	q.onAuthorizedQuery(ctx, query)
+	rows, err := q.db.QueryContext(ctx, query, arg.OrganizationID, arg.OffsetOpt, arg.LimitOpt)
+	if err != nil {
+		return nil, xerrors.Errorf("get authorized workspaces with failed latest build: %w", err)
+	}
+	defer rows.Close()
+	var items []GetWorkspacesWithFailedLatestBuildRow
+	for rows.Next() {
+		var i GetWorkspacesWithFailedLatestBuildRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.OrganizationID,
+			&i.TemplateID,
+			&i.Deleted,
+			&i.Name,
+			&i.AutostartSchedule,
+			&i.Ttl,
+			&i.LastUsedAt,
+			&i.LockedAt,
+			&i.DeletingAt,
+			&i.LatestBuildID,
+			&i.LatestBuildTransition,
+			&i.LatestBuildError,
+			&i.LatestBuildCompletedAt,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+type workspaceBuildQuerier interface {
+	GetAuthorizedWorkspaceBuilds(ctx context.Context, arg GetWorkspaceBuildsParams, prepared rbac.PreparedAuthorized) ([]GetWorkspaceBuildsRow, error)
+	GetWorkspaceBuildParametersMap(ctx context.Context, workspaceBuildID uuid.UUID) (map[string]string, error)
+	GetWorkspaceBuildsWithParameters(ctx context.Context, workspaceID uuid.UUID) ([]WorkspaceBuildWithParameters, error)
+}
+
+// GetAuthorizedWorkspaceBuilds returns the builds of all workspaces that the
+// user is authorized to read, along with the workspace and initiator of each.
+// This code is copied from `GetWorkspaceBuilds` and adds the authorized filter
+// to the workspaces subquery.
+func (q *sqlQuerier) GetAuthorizedWorkspaceBuilds(ctx context.Context, arg GetWorkspaceBuildsParams, prepared rbac.PreparedAuthorized) ([]GetWorkspaceBuildsRow, error) {
+	authorizedFilter, err := prepared.CompileToSQL(ctx, rbac.ConfigWithoutACL())
+	if err != nil {
+		return nil, xerrors.Errorf("compile authorized filter: %w", err)
+	}
+
+	filtered, err := insertAuthorizedFilter(getWorkspaceBuilds, fmt.Sprintf(" AND %s", authorizedFilter))
+	if err != nil {
+		return nil, xerrors.Errorf("insert authorized filter: %w", err)
+	}
+
+	// The name comment is for metric tracking
+	query := fmt.Sprintf("-- name: GetAuthorizedWorkspaceBuilds :many\n%s", filtered)
+	q.onAuthorizedQuery(ctx, query)
+	rows, err := q.db.QueryContext(ctx, query, arg.OrganizationID, arg.OffsetOpt, arg.LimitOpt)
+	if err != nil {
+		return nil, xerrors.Errorf("get authorized workspace builds: %w", err)
+	}
+	defer rows.Close()
+	var items []GetWorkspaceBuildsRow
+	for rows.Next() {
+		var i GetWorkspaceBuildsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.WorkspaceID,
+			&i.TemplateVersionID,
+			&i.BuildNumber,
+			&i.Transition,
+			&i.InitiatorID,
+			&i.ProvisionerState,
+			&i.JobID,
+			&i.Deadline,
+			&i.Reason,
+			&i.DailyCost,
+			&i.MaxDeadline,
+			&i.RollbackOf,
+			&i.ParametersFromBuildID,
+			&i.StructuredReason,
+			&i.IdempotencyKey,
+			&i.TemplateFileHash,
+			&i.ScheduleName,
+			&i.InitiatorByAvatarUrl,
+			&i.InitiatorByUsername,
+			&i.WorkspaceName,
+			&i.WorkspaceOwnerID,
+			&i.WorkspaceOrganizationID,
+			&i.WorkspaceTemplateID,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetWorkspaceBuildParametersMap returns the parameters of a workspace build
+// keyed by name. Names are unique per build, but should a name appear more
+// than once the last value returned by GetWorkspaceBuildParameters wins.
+func (q *sqlQuerier) GetWorkspaceBuildParametersMap(ctx context.Context, workspaceBuildID uuid.UUID) (map[string]string, error) {
+	params, err := q.GetWorkspaceBuildParameters(ctx, workspaceBuildID)
+	if err != nil {
+		return nil, err
+	}
+	return WorkspaceBuildParametersMap(params), nil
+}
+
+// WorkspaceBuildWithParameters is a workspace build along with the
+// parameters it was built with, ordered by name.
+type WorkspaceBuildWithParameters struct {
+	WorkspaceBuild WorkspaceBuild            `json:"workspace_build"`
+	Parameters     []WorkspaceBuildParameter `json:"parameters"`
+}
+
+// GetWorkspaceBuildsWithParameters returns all builds of the workspace, oldest
+// first, with the parameters that GetWorkspaceBuildsWithParametersJSON
+// aggregates decoded.
+func (q *sqlQuerier) GetWorkspaceBuildsWithParameters(ctx context.Context, workspaceID uuid.UUID) ([]WorkspaceBuildWithParameters, error) {
+	rows, err := q.GetWorkspaceBuildsWithParametersJSON(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	builds := make([]WorkspaceBuildWithParameters, 0, len(rows))
+	for _, row := range rows {
+		build := WorkspaceBuildWithParameters{WorkspaceBuild: row.WorkspaceBuild}
+		if err := json.Unmarshal(row.Parameters, &build.Parameters); err != nil {
+			return nil, xerrors.Errorf("decode parameters of workspace build %s: %w", row.WorkspaceBuild.ID, err)
+		}
+		builds = append(builds, build)
+	}
+	return builds, nil
+}
+
 type userQuerier interface {
 	GetAuthorizedUsers(ctx context.Context, arg GetUsersParams, prepared rbac.PreparedAuthorized) ([]GetUsersRow, error)
 }
@@ -277,6 +536,7 @@ func (q *sqlQuerier) GetAuthorizedUsers(ctx context.Context, arg GetUsersParams,
 	}
 
 	query := fmt.Sprintf("-- name: GetAuthorizedUsers :many\n%s", filtered)
+	q.onAuthorizedQuery(ctx, query)
 	rows, err := q.db.QueryContext(ctx, query,
 		arg.AfterID,
 		arg.Search,
@@ -308,6 +568,7 @@ func (q *sqlQuerier) GetAuthorizedUsers(ctx context.Context, arg GetUsersParams,
 			&i.Deleted,
 			&i.LastSeenAt,
 			&i.QuietHoursSchedule,
+			&i.Name,
 			&i.Count,
 		); err != nil {
 			return nil, err
@@ -323,10 +584,41 @@ func (q *sqlQuerier) GetAuthorizedUsers(ctx context.Context, arg GetUsersParams,
 	return items, nil
 }
 
+// CompileAuthorizedFilterSQL returns the SQL filter that authorized queries insert for prepared, without executing
+// any query.  It is meant for debugging, e.g. to tell whether an authorized query returning unexpected results is due
+// to the filter generated from the RBAC policy.
+func CompileAuthorizedFilterSQL(ctx context.Context, prepared rbac.PreparedAuthorized, cfg regosql.ConvertConfig) (string, error) {
+	filter, err := prepared.CompileToSQL(ctx, cfg)
+	if err != nil {
+		return "", xerrors.Errorf("compile authorized filter: %w", err)
+	}
+	return filter, nil
+}
+
+// onAuthorizedQuery passes the query, with the authorized filter inserted, to the hook set by
+// WithAuthorizedQueryHook, if any.
+func (q *sqlQuerier) onAuthorizedQuery(ctx context.Context, query string) {
+	if q.authorizedQueryHook != nil {
+		q.authorizedQueryHook(ctx, query)
+	}
+}
+
+// insertAuthorizedFilter inserts the authorization filter in place of the placeholder of a query that contains it
+// exactly once.
 func insertAuthorizedFilter(query string, replaceWith string) (string, error) {
-	if !strings.Contains(query, authorizedQueryPlaceholder) {
+	return insertAuthorizedFilterN(query, replaceWith, 1)
+}
+
+// insertAuthorizedFilterN inserts the authorization filter in place of every placeholder of the query, e.g. in each
+// branch of a UNION.  The query must contain exactly n placeholders, so that a placeholder added to or dropped from a
+// query can't leave part of it unfiltered without notice.
+func insertAuthorizedFilterN(query string, replaceWith string, n int) (string, error) {
+	count := strings.Count(query, authorizedQueryPlaceholder)
+	if count == 0 {
 		return "", xerrors.Errorf("query does not contain authorized replace string, this is not an authorized query")
 	}
-	filtered := strings.Replace(query, authorizedQueryPlaceholder, replaceWith, 1)
-	return filtered, nil
+	if count != n {
+		return "", xerrors.Errorf("query contains %d authorized replace strings, expected %d", count, n)
+	}
+	return strings.ReplaceAll(query, authorizedQueryPlaceholder, replaceWith), nil
 }
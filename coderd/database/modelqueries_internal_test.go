@@ -1,9 +1,16 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"testing"
 
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
+
+	"github.com/coder/coder/coderd/rbac"
+	"github.com/coder/coder/coderd/rbac/regosql"
 )
 
 func TestIsAuthorizedQuery(t *testing.T) {
@@ -13,3 +20,75 @@ func TestIsAuthorizedQuery(t *testing.T) {
 	_, err := insertAuthorizedFilter(query, "")
 	require.ErrorContains(t, err, "does not contain authorized replace string", "ensure replace string")
 }
+
+func TestInsertAuthorizedFilterMultiple(t *testing.T) {
+	t.Parallel()
+
+	query := `SELECT id FROM templates WHERE deleted = false
+	-- @authorize_filter
+UNION ALL
+SELECT id FROM workspaces WHERE deleted = false
+	-- @authorize_filter
+;`
+	filtered, err := insertAuthorizedFilterN(query, " AND owner_id = 'me'", 2)
+	require.NoError(t, err)
+	require.NotContains(t, filtered, authorizedQueryPlaceholder)
+	require.Contains(t, filtered, "FROM templates WHERE deleted = false\n\t AND owner_id = 'me'\nUNION ALL")
+	require.Contains(t, filtered, "FROM workspaces WHERE deleted = false\n\t AND owner_id = 'me'\n;")
+
+	// A query with more placeholders than expected must not be partially filtered.
+	_, err = insertAuthorizedFilter(query, " AND owner_id = 'me'")
+	require.ErrorContains(t, err, "contains 2 authorized replace strings, expected 1")
+	_, err = insertAuthorizedFilterN(query, " AND owner_id = 'me'", 3)
+	require.ErrorContains(t, err, "contains 2 authorized replace strings, expected 3")
+}
+
+func TestValidateWorkspacesSort(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, ValidateWorkspacesSort("", ""))
+	require.NoError(t, ValidateWorkspacesSort(WorkspacesSortByLastUsedAt, ""))
+	require.NoError(t, ValidateWorkspacesSort(WorkspacesSortByName, SortOrderAsc))
+	require.NoError(t, ValidateWorkspacesSort(WorkspacesSortByCreatedAt, SortOrderDesc))
+
+	require.ErrorContains(t, ValidateWorkspacesSort("id; DROP TABLE workspaces", ""), "unknown workspace sort column")
+	require.ErrorContains(t, ValidateWorkspacesSort(WorkspacesSortByName, "sideways"), "unknown sort order")
+	require.ErrorContains(t, ValidateWorkspacesSort("", SortOrderDesc), "requires a sort column")
+}
+
+func TestAuthorizedQueryHook(t *testing.T) {
+	t.Parallel()
+
+	// The hook is called before the query is executed, so the database doesn't need to exist.
+	sqlDB, err := sql.Open("postgres", "host=/nonexistent sslmode=disable")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	var queries []string
+	db := New(sqlDB, WithAuthorizedQueryHook(func(_ context.Context, query string) {
+		queries = append(queries, query)
+	}))
+
+	ctx := context.Background()
+	prepared, err := rbac.NewAuthorizer(prometheus.NewRegistry()).Prepare(ctx, rbac.Subject{
+		ID:    uuid.NewString(),
+		Roles: rbac.RoleNames{rbac.RoleMember()},
+		Scope: rbac.ScopeAll,
+	}, rbac.ActionRead, rbac.ResourceTemplate.Type)
+	require.NoError(t, err)
+	filter, err := CompileAuthorizedFilterSQL(ctx, prepared, regosql.ConvertConfig{
+		VariableConverter: regosql.TemplateConverter(),
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, filter)
+
+	_, err = db.GetAuthorizedTemplates(ctx, GetTemplatesWithFilterParams{ExactName: "secret-template"}, prepared)
+	require.Error(t, err, "no database to query")
+	require.Len(t, queries, 1)
+	require.Contains(t, queries[0], "-- name: GetAuthorizedTemplates :many")
+	require.Contains(t, queries[0], filter)
+	require.NotContains(t, queries[0], authorizedQueryPlaceholder)
+	// Arguments are only passed as placeholders.
+	require.NotContains(t, queries[0], "secret-template")
+}
@@ -1585,6 +1585,10 @@ type ProvisionerJob struct {
 	Tags           StringMap                `db:"tags" json:"tags"`
 	ErrorCode      sql.NullString           `db:"error_code" json:"error_code"`
 	TraceMetadata  pqtype.NullRawMessage    `db:"trace_metadata" json:"trace_metadata"`
+	// Jobs with a higher priority are acquired by provisioner daemons first. Jobs with equal priority are acquired in the order they were created.
+	Priority int32 `db:"priority" json:"priority"`
+	// If set, the job is failed if it has not been acquired by a provisioner daemon by this time.
+	PendingDeadline sql.NullTime `db:"pending_deadline" json:"pending_deadline"`
 }
 
 type ProvisionerJobLog struct {
@@ -1715,6 +1719,7 @@ type TemplateVersion struct {
 	CreatedBy          uuid.UUID      `db:"created_by" json:"created_by"`
 	GitAuthProviders   []string       `db:"git_auth_providers" json:"git_auth_providers"`
 	Message            string         `db:"message" json:"message"`
+	Throwaway          bool           `db:"throwaway" json:"throwaway"`
 	CreatedByAvatarURL sql.NullString `db:"created_by_avatar_url" json:"created_by_avatar_url"`
 	CreatedByUsername  string         `db:"created_by_username" json:"created_by_username"`
 }
@@ -1769,6 +1774,8 @@ type TemplateVersionTable struct {
 	GitAuthProviders []string `db:"git_auth_providers" json:"git_auth_providers"`
 	// Message describing the changes in this version of the template, similar to a Git commit message. Like a commit message, this should be a short, high-level description of the changes in this version of the template. This message is immutable and should not be updated after the fact.
 	Message string `db:"message" json:"message"`
+	// Throwaway versions are only imported to inspect their resources and parameters, e.g. by "coder templates plan". They can't be used to create a template, be promoted or be built, and are purged after a day.
+	Throwaway bool `db:"throwaway" json:"throwaway"`
 }
 
 type TemplateVersionVariable struct {
@@ -1804,6 +1811,8 @@ type User struct {
 	LastSeenAt     time.Time      `db:"last_seen_at" json:"last_seen_at"`
 	// Daily (!) cron schedule (with optional CRON_TZ) signifying the start of the user's quiet hours. If empty, the default quiet hours on the instance is used instead.
 	QuietHoursSchedule string `db:"quiet_hours_schedule" json:"quiet_hours_schedule"`
+	// Full name of the user, for display. Empty if not set.
+	Name string `db:"name" json:"name"`
 }
 
 type UserLink struct {
@@ -1949,22 +1958,42 @@ type WorkspaceApp struct {
 
 // Joins in the username + avatar url of the initiated by user.
 type WorkspaceBuild struct {
-	ID                   uuid.UUID           `db:"id" json:"id"`
-	CreatedAt            time.Time           `db:"created_at" json:"created_at"`
-	UpdatedAt            time.Time           `db:"updated_at" json:"updated_at"`
-	WorkspaceID          uuid.UUID           `db:"workspace_id" json:"workspace_id"`
-	TemplateVersionID    uuid.UUID           `db:"template_version_id" json:"template_version_id"`
-	BuildNumber          int32               `db:"build_number" json:"build_number"`
-	Transition           WorkspaceTransition `db:"transition" json:"transition"`
-	InitiatorID          uuid.UUID           `db:"initiator_id" json:"initiator_id"`
-	ProvisionerState     []byte              `db:"provisioner_state" json:"provisioner_state"`
-	JobID                uuid.UUID           `db:"job_id" json:"job_id"`
-	Deadline             time.Time           `db:"deadline" json:"deadline"`
-	Reason               BuildReason         `db:"reason" json:"reason"`
-	DailyCost            int32               `db:"daily_cost" json:"daily_cost"`
-	MaxDeadline          time.Time           `db:"max_deadline" json:"max_deadline"`
-	InitiatorByAvatarUrl sql.NullString      `db:"initiator_by_avatar_url" json:"initiator_by_avatar_url"`
-	InitiatorByUsername  string              `db:"initiator_by_username" json:"initiator_by_username"`
+	ID                    uuid.UUID             `db:"id" json:"id"`
+	CreatedAt             time.Time             `db:"created_at" json:"created_at"`
+	UpdatedAt             time.Time             `db:"updated_at" json:"updated_at"`
+	WorkspaceID           uuid.UUID             `db:"workspace_id" json:"workspace_id"`
+	TemplateVersionID     uuid.UUID             `db:"template_version_id" json:"template_version_id"`
+	BuildNumber           int32                 `db:"build_number" json:"build_number"`
+	Transition            WorkspaceTransition   `db:"transition" json:"transition"`
+	InitiatorID           uuid.UUID             `db:"initiator_id" json:"initiator_id"`
+	ProvisionerState      []byte                `db:"provisioner_state" json:"provisioner_state"`
+	JobID                 uuid.UUID             `db:"job_id" json:"job_id"`
+	Deadline              time.Time             `db:"deadline" json:"deadline"`
+	Reason                BuildReason           `db:"reason" json:"reason"`
+	DailyCost             int32                 `db:"daily_cost" json:"daily_cost"`
+	MaxDeadline           time.Time             `db:"max_deadline" json:"max_deadline"`
+	RollbackOf            uuid.NullUUID         `db:"rollback_of" json:"rollback_of"`
+	ParametersFromBuildID uuid.NullUUID         `db:"parameters_from_build_id" json:"parameters_from_build_id"`
+	StructuredReason      StructuredBuildReason `db:"structured_reason" json:"structured_reason"`
+	IdempotencyKey        sql.NullString        `db:"idempotency_key" json:"idempotency_key"`
+	TemplateFileHash      sql.NullString        `db:"template_file_hash" json:"template_file_hash"`
+	ScheduleName          sql.NullString        `db:"schedule_name" json:"schedule_name"`
+	InitiatorByAvatarUrl  sql.NullString        `db:"initiator_by_avatar_url" json:"initiator_by_avatar_url"`
+	InitiatorByUsername   string                `db:"initiator_by_username" json:"initiator_by_username"`
+}
+
+// Requests for workspace builds, recorded before the build is computed, so that requests rejected before a build was created are visible.
+type WorkspaceBuildIntent struct {
+	ID          uuid.UUID           `db:"id" json:"id"`
+	CreatedAt   time.Time           `db:"created_at" json:"created_at"`
+	WorkspaceID uuid.UUID           `db:"workspace_id" json:"workspace_id"`
+	InitiatorID uuid.UUID           `db:"initiator_id" json:"initiator_id"`
+	Transition  WorkspaceTransition `db:"transition" json:"transition"`
+	Reason      BuildReason         `db:"reason" json:"reason"`
+	// The template version requested for the build, if the request named one.
+	TemplateVersionID uuid.NullUUID `db:"template_version_id" json:"template_version_id"`
+	// The build created for the request, or NULL if no build was created.
+	WorkspaceBuildID uuid.NullUUID `db:"workspace_build_id" json:"workspace_build_id"`
 }
 
 type WorkspaceBuildParameter struct {
@@ -1990,6 +2019,18 @@ type WorkspaceBuildTable struct {
 	Reason            BuildReason         `db:"reason" json:"reason"`
 	DailyCost         int32               `db:"daily_cost" json:"daily_cost"`
 	MaxDeadline       time.Time           `db:"max_deadline" json:"max_deadline"`
+	// The prior build of the same workspace that this build rolls back to, if any.
+	RollbackOf uuid.NullUUID `db:"rollback_of" json:"rollback_of"`
+	// The prior build of the same workspace whose parameters this build shares, if it did not store its own.
+	ParametersFromBuildID uuid.NullUUID `db:"parameters_from_build_id" json:"parameters_from_build_id"`
+	// Optional structured reason for the build, with a category, subcategory and source system. The reason column holds the coarse equivalent.
+	StructuredReason StructuredBuildReason `db:"structured_reason" json:"structured_reason"`
+	// Optional client-provided key identifying the request that created the build, so that retried requests return the existing build rather than creating a duplicate.
+	IdempotencyKey sql.NullString `db:"idempotency_key" json:"idempotency_key"`
+	// The hash of the source file of the template version, as of when the build was created, so that the source that produced a workspace can be verified even after the template is updated.
+	TemplateFileHash sql.NullString `db:"template_file_hash" json:"template_file_hash"`
+	// The name of the schedule that triggered the build, if it was started or stopped by a schedule.
+	ScheduleName sql.NullString `db:"schedule_name" json:"schedule_name"`
 }
 
 type WorkspaceProxy struct {
@@ -36,17 +36,27 @@ type sqlcQuerier interface {
 	DeleteLicense(ctx context.Context, id int32) (int32, error)
 	// If an agent hasn't connected in the last 7 days, we purge it's logs.
 	// Logs can take up a lot of space, so it's important we clean up frequently.
+	// Throwaway versions are only needed while they are inspected, so they are
+	// purged a day after they are created.
+	DeleteOldThrowawayTemplateVersions(ctx context.Context) error
 	DeleteOldWorkspaceAgentLogs(ctx context.Context) error
 	DeleteOldWorkspaceAgentStats(ctx context.Context) error
 	DeleteReplicasUpdatedBefore(ctx context.Context, updatedAt time.Time) error
 	DeleteTailnetAgent(ctx context.Context, arg DeleteTailnetAgentParams) (DeleteTailnetAgentRow, error)
 	DeleteTailnetClient(ctx context.Context, arg DeleteTailnetClientParams) (DeleteTailnetClientRow, error)
+	// Fails the jobs that have not been acquired by a provisioner daemon by their
+	// pending deadline. The jobs are marked as started too, so that they read as
+	// failed rather than pending.
+	FailStalePendingJobs(ctx context.Context, now time.Time) ([]ProvisionerJob, error)
 	GetAPIKeyByID(ctx context.Context, id string) (APIKey, error)
 	// there is no unique constraint on empty token names
 	GetAPIKeyByName(ctx context.Context, arg GetAPIKeyByNameParams) (APIKey, error)
 	GetAPIKeysByLoginType(ctx context.Context, loginType LoginType) ([]APIKey, error)
 	GetAPIKeysByUserID(ctx context.Context, arg GetAPIKeysByUserIDParams) ([]APIKey, error)
 	GetAPIKeysLastUsedAfter(ctx context.Context, lastUsed time.Time) ([]APIKey, error)
+	// Returns the number of pending or running workspace build jobs for each
+	// template.
+	GetActiveJobCountsByTemplate(ctx context.Context) ([]GetActiveJobCountsByTemplateRow, error)
 	GetActiveUserCount(ctx context.Context) (int64, error)
 	GetAllTailnetAgents(ctx context.Context) ([]TailnetAgent, error)
 	GetAllTailnetClients(ctx context.Context) ([]TailnetClient, error)
@@ -75,12 +85,23 @@ type sqlcQuerier interface {
 	GetGroupsByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]Group, error)
 	GetHungProvisionerJobs(ctx context.Context, updatedAt time.Time) ([]ProvisionerJob, error)
 	GetLastUpdateCheck(ctx context.Context) (string, error)
+	// Returns the most recently created build using the template version, across
+	// all workspaces.
+	GetLatestBuildByTemplateVersionID(ctx context.Context, templateVersionID uuid.UUID) (WorkspaceBuild, error)
+	// Returns the most recent build of the workspace whose provisioner job
+	// completed without error, regardless of transition.
+	GetLatestSuccessfulWorkspaceBuildByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) (WorkspaceBuild, error)
 	GetLatestWorkspaceBuildByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) (WorkspaceBuild, error)
 	GetLatestWorkspaceBuilds(ctx context.Context) ([]WorkspaceBuild, error)
 	GetLatestWorkspaceBuildsByWorkspaceIDs(ctx context.Context, ids []uuid.UUID) ([]WorkspaceBuild, error)
 	GetLicenseByID(ctx context.Context, id int32) (License, error)
 	GetLicenses(ctx context.Context) ([]License, error)
 	GetLogoURL(ctx context.Context) (string, error)
+	// GetMostUsedTemplates returns the templates ranked by the number of workspace
+	// builds created between start and end time, most used first, along with the
+	// number of builds. Templates without builds in the period and deleted
+	// templates are omitted.
+	GetMostUsedTemplates(ctx context.Context, arg GetMostUsedTemplatesParams) ([]GetMostUsedTemplatesRow, error)
 	GetOAuthSigningKey(ctx context.Context) (string, error)
 	GetOrganizationByID(ctx context.Context, id uuid.UUID) (Organization, error)
 	GetOrganizationByName(ctx context.Context, name string) (Organization, error)
@@ -102,9 +123,17 @@ type sqlcQuerier interface {
 	GetReplicaByID(ctx context.Context, id uuid.UUID) (Replica, error)
 	GetReplicasUpdatedAfter(ctx context.Context, updatedAt time.Time) ([]Replica, error)
 	GetServiceBanner(ctx context.Context) (string, error)
+	// Returns the builds whose job is still running and started before
+	// started_before, longest running first, for alerting on builds that are
+	// stuck. Jobs that are being canceled are not considered running.
+	GetStuckRunningBuilds(ctx context.Context, startedBefore time.Time) ([]WorkspaceBuild, error)
 	GetTailnetAgents(ctx context.Context, id uuid.UUID) ([]TailnetAgent, error)
 	GetTailnetClientsForAgent(ctx context.Context, agentID uuid.UUID) ([]TailnetClient, error)
 	GetTemplateAverageBuildTime(ctx context.Context, arg GetTemplateAverageBuildTimeParams) (GetTemplateAverageBuildTimeRow, error)
+	// GetTemplateBuildsPerDay returns the number of workspace builds created per
+	// day for workspaces of the given template between start and end time, along
+	// with a breakdown per transition. Days without any builds are omitted.
+	GetTemplateBuildsPerDay(ctx context.Context, arg GetTemplateBuildsPerDayParams) ([]GetTemplateBuildsPerDayRow, error)
 	GetTemplateByID(ctx context.Context, id uuid.UUID) (Template, error)
 	GetTemplateByOrganizationAndName(ctx context.Context, arg GetTemplateByOrganizationAndNameParams) (Template, error)
 	GetTemplateDAUs(ctx context.Context, arg GetTemplateDAUsParams) ([]GetTemplateDAUsRow, error)
@@ -127,9 +156,17 @@ type sqlcQuerier interface {
 	GetTemplates(ctx context.Context) ([]Template, error)
 	GetTemplatesWithFilter(ctx context.Context, arg GetTemplatesWithFilterParams) ([]Template, error)
 	GetUnexpiredLicenses(ctx context.Context) ([]License, error)
+	// Returns the versions of the template that no workspace has been built with
+	// successfully, i.e. without a build whose provisioner job completed without
+	// error. These are candidates for cleanup.
+	GetUnusedTemplateVersions(ctx context.Context, templateID uuid.UUID) ([]TemplateVersion, error)
 	GetUserByEmailOrUsername(ctx context.Context, arg GetUserByEmailOrUsernameParams) (User, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
 	GetUserCount(ctx context.Context) (int64, error)
+	// Returns the groups the user is an explicit member of, across all
+	// organizations. The "Everyone" group of an organization has no explicit
+	// members, so it is not included.
+	GetUserGroups(ctx context.Context, userID uuid.UUID) ([]Group, error)
 	// GetUserLatencyInsights returns the median and 95th percentile connection
 	// latency that users have experienced. The result can be filtered on
 	// template_ids, meaning only user data from workspaces based on those templates
@@ -161,13 +198,34 @@ type sqlcQuerier interface {
 	GetWorkspaceBuildByID(ctx context.Context, id uuid.UUID) (WorkspaceBuild, error)
 	GetWorkspaceBuildByJobID(ctx context.Context, jobID uuid.UUID) (WorkspaceBuild, error)
 	GetWorkspaceBuildByWorkspaceIDAndBuildNumber(ctx context.Context, arg GetWorkspaceBuildByWorkspaceIDAndBuildNumberParams) (WorkspaceBuild, error)
+	GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(ctx context.Context, arg GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams) (WorkspaceBuild, error)
+	GetWorkspaceBuildIntentsByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) ([]WorkspaceBuildIntent, error)
+	// Builds that share the parameters of a prior build resolve to that build's
+	// parameters.
 	GetWorkspaceBuildParameters(ctx context.Context, workspaceBuildID uuid.UUID) ([]WorkspaceBuildParameter, error)
+	// Returns the builds of a workspace with the name of the template version each
+	// one used, oldest first, e.g. to draw how the workspace moved between
+	// versions over time.
+	GetWorkspaceBuildVersionLineage(ctx context.Context, workspaceID uuid.UUID) ([]GetWorkspaceBuildVersionLineageRow, error)
+	GetWorkspaceBuilds(ctx context.Context, arg GetWorkspaceBuildsParams) ([]GetWorkspaceBuildsRow, error)
+	// Returns the builds started by the initiator across all workspaces, newest
+	// first, along with the names of the workspace and template they belong to.
+	GetWorkspaceBuildsByInitiator(ctx context.Context, arg GetWorkspaceBuildsByInitiatorParams) ([]GetWorkspaceBuildsByInitiatorRow, error)
 	GetWorkspaceBuildsByWorkspaceID(ctx context.Context, arg GetWorkspaceBuildsByWorkspaceIDParams) ([]WorkspaceBuild, error)
 	GetWorkspaceBuildsCreatedAfter(ctx context.Context, createdAt time.Time) ([]WorkspaceBuild, error)
+	// Returns all builds of the workspace, oldest first, each with its parameters
+	// as a JSON array ordered by name. A build that shares the parameters of a
+	// prior build gets that build's parameters. Fetching both in a single query
+	// means exporting a workspace doesn't take a query per build.
+	// GetWorkspaceBuildsWithParameters decodes the parameters.
+	GetWorkspaceBuildsWithParametersJSON(ctx context.Context, workspaceID uuid.UUID) ([]GetWorkspaceBuildsWithParametersJSONRow, error)
 	GetWorkspaceByAgentID(ctx context.Context, agentID uuid.UUID) (Workspace, error)
 	GetWorkspaceByID(ctx context.Context, id uuid.UUID) (Workspace, error)
 	GetWorkspaceByOwnerIDAndName(ctx context.Context, arg GetWorkspaceByOwnerIDAndNameParams) (Workspace, error)
 	GetWorkspaceByWorkspaceAppID(ctx context.Context, workspaceAppID uuid.UUID) (Workspace, error)
+	// Counts the workspaces owned by a user, e.g. for quota enforcement. Deleted
+	// workspaces are only counted if include_deleted is true.
+	GetWorkspaceCountByOwner(ctx context.Context, arg GetWorkspaceCountByOwnerParams) (int64, error)
 	GetWorkspaceProxies(ctx context.Context) ([]WorkspaceProxy, error)
 	// Finds a workspace proxy that has an access URL or app hostname that matches
 	// the provided hostname. This is to check if a hostname matches any workspace
@@ -186,7 +244,14 @@ type sqlcQuerier interface {
 	GetWorkspaceResourcesByJobIDs(ctx context.Context, ids []uuid.UUID) ([]WorkspaceResource, error)
 	GetWorkspaceResourcesCreatedAfter(ctx context.Context, createdAt time.Time) ([]WorkspaceResource, error)
 	GetWorkspaces(ctx context.Context, arg GetWorkspacesParams) ([]GetWorkspacesRow, error)
+	// Returns the running workspaces whose latest build has passed its deadline,
+	// along with that build, so that the autostop loop needn't look each build up.
+	// A zero deadline means the build has none.
+	GetWorkspacesDueForAutostop(ctx context.Context, now time.Time) ([]GetWorkspacesDueForAutostopRow, error)
 	GetWorkspacesEligibleForTransition(ctx context.Context, now time.Time) ([]Workspace, error)
+	// Returns the workspaces whose latest build failed, most recently failed first,
+	// along with the error reported by the build's provisioner job.
+	GetWorkspacesWithFailedLatestBuild(ctx context.Context, arg GetWorkspacesWithFailedLatestBuildParams) ([]GetWorkspacesWithFailedLatestBuildRow, error)
 	InsertAPIKey(ctx context.Context, arg InsertAPIKeyParams) (APIKey, error)
 	// We use the organization_id as the id
 	// for simplicity since all users is
@@ -222,6 +287,7 @@ type sqlcQuerier interface {
 	InsertWorkspaceAgentStat(ctx context.Context, arg InsertWorkspaceAgentStatParams) (WorkspaceAgentStat, error)
 	InsertWorkspaceApp(ctx context.Context, arg InsertWorkspaceAppParams) (WorkspaceApp, error)
 	InsertWorkspaceBuild(ctx context.Context, arg InsertWorkspaceBuildParams) error
+	InsertWorkspaceBuildIntent(ctx context.Context, arg InsertWorkspaceBuildIntentParams) error
 	InsertWorkspaceBuildParameters(ctx context.Context, arg InsertWorkspaceBuildParametersParams) error
 	InsertWorkspaceProxy(ctx context.Context, arg InsertWorkspaceProxyParams) (WorkspaceProxy, error)
 	InsertWorkspaceResource(ctx context.Context, arg InsertWorkspaceResourceParams) (WorkspaceResource, error)
@@ -269,6 +335,7 @@ type sqlcQuerier interface {
 	UpdateWorkspaceAutostart(ctx context.Context, arg UpdateWorkspaceAutostartParams) error
 	UpdateWorkspaceBuildByID(ctx context.Context, arg UpdateWorkspaceBuildByIDParams) error
 	UpdateWorkspaceBuildCostByID(ctx context.Context, arg UpdateWorkspaceBuildCostByIDParams) error
+	UpdateWorkspaceBuildIntentBuildByID(ctx context.Context, arg UpdateWorkspaceBuildIntentBuildByIDParams) error
 	UpdateWorkspaceDeletedByID(ctx context.Context, arg UpdateWorkspaceDeletedByIDParams) error
 	UpdateWorkspaceLastUsedAt(ctx context.Context, arg UpdateWorkspaceLastUsedAtParams) error
 	UpdateWorkspaceLockedDeletingAt(ctx context.Context, arg UpdateWorkspaceLockedDeletingAtParams) error
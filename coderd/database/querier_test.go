@@ -11,11 +11,13 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 
 	"github.com/coder/coder/coderd/database"
 	"github.com/coder/coder/coderd/database/dbgen"
 	"github.com/coder/coder/coderd/database/migrations"
+	"github.com/coder/coder/coderd/rbac"
 	"github.com/coder/coder/testutil"
 )
 
@@ -390,6 +392,131 @@ func TestQueuePosition(t *testing.T) {
 	}
 }
 
+func TestQueuePositionPriority(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.SkipNow()
+	}
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	now := database.Now()
+	// Jobs are inserted oldest first, but the expected queue order is by
+	// priority and then by creation time.
+	low := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+		OrganizationID: org.ID,
+		CreatedAt:      now.Add(-3 * time.Minute),
+		Tags:           database.StringMap{},
+	})
+	highOld := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+		OrganizationID: org.ID,
+		CreatedAt:      now.Add(-2 * time.Minute),
+		Tags:           database.StringMap{},
+		Priority:       10,
+	})
+	highNew := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+		OrganizationID: org.ID,
+		CreatedAt:      now.Add(-time.Minute),
+		Tags:           database.StringMap{},
+		Priority:       10,
+	})
+	require.EqualValues(t, 10, highOld.Priority)
+	expected := []uuid.UUID{highOld.ID, highNew.ID, low.ID}
+
+	queued, err := db.GetProvisionerJobsByIDsWithQueuePosition(ctx, expected)
+	require.NoError(t, err)
+	require.Len(t, queued, len(expected))
+	sort.Slice(queued, func(i, j int) bool {
+		return queued[i].QueuePosition < queued[j].QueuePosition
+	})
+	for index, job := range queued {
+		require.Equal(t, int64(index+1), job.QueuePosition)
+		require.Equal(t, expected[index], job.ProvisionerJob.ID)
+	}
+
+	for _, id := range expected {
+		job, err := db.AcquireProvisionerJob(ctx, database.AcquireProvisionerJobParams{
+			StartedAt: sql.NullTime{
+				Time:  database.Now(),
+				Valid: true,
+			},
+			Types: database.AllProvisionerTypeValues(),
+			WorkerID: uuid.NullUUID{
+				UUID:  uuid.New(),
+				Valid: true,
+			},
+			Tags: json.RawMessage("{}"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, id, job.ID)
+	}
+}
+
+func TestFailStalePendingJobs(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.SkipNow()
+	}
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	now := database.Now()
+	past := sql.NullTime{Time: now.Add(-time.Minute), Valid: true}
+	stale := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+		OrganizationID:  org.ID,
+		PendingDeadline: past,
+	})
+	// Not yet past its deadline.
+	fresh := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+		OrganizationID:  org.ID,
+		PendingDeadline: sql.NullTime{Time: now.Add(time.Minute), Valid: true},
+	})
+	// No deadline, so it waits indefinitely.
+	unbounded := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+		OrganizationID: org.ID,
+	})
+	// Acquired by a daemon before the deadline passed.
+	started := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+		OrganizationID:  org.ID,
+		PendingDeadline: past,
+		StartedAt:       sql.NullTime{Time: now.Add(-2 * time.Minute), Valid: true},
+	})
+
+	failed, err := db.FailStalePendingJobs(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	require.Equal(t, stale.ID, failed[0].ID)
+
+	job, err := db.GetProvisionerJobByID(ctx, stale.ID)
+	require.NoError(t, err)
+	require.True(t, job.CompletedAt.Valid)
+	// The job is marked as started, so that it reads as failed.
+	require.True(t, job.StartedAt.Valid)
+	require.True(t, job.Error.Valid)
+	require.Contains(t, job.Error.String, "pending timeout")
+
+	for _, id := range []uuid.UUID{fresh.ID, unbounded.ID, started.ID} {
+		job, err := db.GetProvisionerJobByID(ctx, id)
+		require.NoError(t, err)
+		require.False(t, job.CompletedAt.Valid)
+	}
+
+	// Failed jobs aren't failed again.
+	failed, err = db.FailStalePendingJobs(ctx, now)
+	require.NoError(t, err)
+	require.Empty(t, failed)
+}
+
 func TestUserLastSeenFilter(t *testing.T) {
 	t.Parallel()
 	if testing.Short() {
@@ -494,3 +621,2197 @@ func requireUsersMatch(t testing.TB, expected []database.User, found []database.
 	t.Helper()
 	require.ElementsMatch(t, expected, database.ConvertUserRows(found), msg)
 }
+
+func TestGetTemplateBuildsPerDay(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	workspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+
+	// A build on another template must not be counted.
+	otherTemplate := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	otherVersion := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: otherTemplate.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	otherWorkspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     otherTemplate.ID,
+	})
+
+	day1 := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	day3 := day1.AddDate(0, 0, 2)
+
+	buildNumber := int32(0)
+	build := func(ws database.Workspace, tv database.TemplateVersion, createdAt time.Time, transition database.WorkspaceTransition) {
+		buildNumber++
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+		})
+		dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			CreatedAt:         createdAt,
+			WorkspaceID:       ws.ID,
+			TemplateVersionID: tv.ID,
+			BuildNumber:       buildNumber,
+			Transition:        transition,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+		})
+	}
+	build(workspace, version, day1.Add(time.Hour), database.WorkspaceTransitionStart)
+	build(workspace, version, day1.Add(2*time.Hour), database.WorkspaceTransitionStop)
+	build(workspace, version, day1.Add(3*time.Hour), database.WorkspaceTransitionStart)
+	build(workspace, version, day3.Add(time.Hour), database.WorkspaceTransitionDelete)
+	// Outside of the requested range.
+	build(workspace, version, day3.AddDate(0, 0, 1).Add(time.Hour), database.WorkspaceTransitionStart)
+	build(otherWorkspace, otherVersion, day2.Add(time.Hour), database.WorkspaceTransitionStart)
+
+	rows, err := db.GetTemplateBuildsPerDay(ctx, database.GetTemplateBuildsPerDayParams{
+		TemplateID: template.ID,
+		StartTime:  day1,
+		EndTime:    day3.AddDate(0, 0, 1),
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	require.True(t, day1.Equal(rows[0].Day), "first day")
+	require.Equal(t, int64(3), rows[0].Count)
+	require.Equal(t, int64(2), rows[0].StartCount)
+	require.Equal(t, int64(1), rows[0].StopCount)
+	require.Equal(t, int64(0), rows[0].DeleteCount)
+
+	require.True(t, day3.Equal(rows[1].Day), "last day")
+	require.Equal(t, int64(1), rows[1].Count)
+	require.Equal(t, int64(0), rows[1].StartCount)
+	require.Equal(t, int64(0), rows[1].StopCount)
+	require.Equal(t, int64(1), rows[1].DeleteCount)
+}
+
+func TestGetMostUsedTemplates(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	type templateWorkspace struct {
+		template  database.Template
+		version   database.TemplateVersion
+		workspace database.Workspace
+	}
+	newTemplate := func(name string) templateWorkspace {
+		template := dbgen.Template(t, db, database.Template{
+			OrganizationID: org.ID,
+			CreatedBy:      user.ID,
+			Name:           name,
+		})
+		return templateWorkspace{
+			template: template,
+			version: dbgen.TemplateVersion(t, db, database.TemplateVersion{
+				OrganizationID: org.ID,
+				TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+				CreatedBy:      user.ID,
+			}),
+			workspace: dbgen.Workspace(t, db, database.Workspace{
+				OwnerID:        user.ID,
+				OrganizationID: org.ID,
+				TemplateID:     template.ID,
+			}),
+		}
+	}
+	busy := newTemplate("busy")
+	quiet := newTemplate("quiet")
+	medium := newTemplate("medium")
+	unused := newTemplate("unused")
+	deleted := newTemplate("deleted")
+
+	from := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	buildNumbers := make(map[uuid.UUID]int32)
+	build := func(tw templateWorkspace, createdAt time.Time) {
+		buildNumbers[tw.workspace.ID]++
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+		})
+		dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			CreatedAt:         createdAt,
+			WorkspaceID:       tw.workspace.ID,
+			TemplateVersionID: tw.version.ID,
+			BuildNumber:       buildNumbers[tw.workspace.ID],
+			Transition:        database.WorkspaceTransitionStart,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+		})
+	}
+	for i := 0; i < 3; i++ {
+		build(busy, from.Add(time.Duration(i)*time.Hour))
+	}
+	for i := 0; i < 2; i++ {
+		build(medium, from.AddDate(0, 0, 1).Add(time.Duration(i)*time.Hour))
+	}
+	build(quiet, to.Add(-time.Hour))
+	// Builds outside of the window must not be counted.
+	for i := 0; i < 4; i++ {
+		build(quiet, from.Add(-time.Duration(i+1)*time.Hour))
+		build(unused, to.Add(time.Duration(i)*time.Hour))
+	}
+	// Deleted templates are omitted.
+	for i := 0; i < 5; i++ {
+		build(deleted, from.Add(time.Duration(i)*time.Hour))
+	}
+	err = db.UpdateTemplateDeletedByID(ctx, database.UpdateTemplateDeletedByIDParams{
+		ID:        deleted.template.ID,
+		Deleted:   true,
+		UpdatedAt: database.Now(),
+	})
+	require.NoError(t, err)
+
+	rows, err := db.GetMostUsedTemplates(ctx, database.GetMostUsedTemplatesParams{
+		StartTime: from,
+		EndTime:   to,
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	for i, want := range []struct {
+		template database.Template
+		count    int64
+	}{
+		{busy.template, 3},
+		{medium.template, 2},
+		{quiet.template, 1},
+	} {
+		require.Equal(t, want.template.ID, rows[i].TemplateID, "rank %d", i)
+		require.Equal(t, want.template.Name, rows[i].TemplateName, "rank %d", i)
+		require.Equal(t, want.count, rows[i].BuildCount, "rank %d", i)
+	}
+
+	rows, err = db.GetMostUsedTemplates(ctx, database.GetMostUsedTemplatesParams{
+		StartTime: from,
+		EndTime:   to,
+		LimitOpt:  2,
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, busy.template.ID, rows[0].TemplateID)
+	require.Equal(t, medium.template.ID, rows[1].TemplateID)
+}
+
+func TestGetTemplatesWithFilterNoBuildsSince(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	now := database.Now()
+	cutoff := now.AddDate(0, 0, -30)
+
+	buildNumber := int32(0)
+	templateWithBuildAt := func(createdAt time.Time) database.Template {
+		template := dbgen.Template(t, db, database.Template{
+			OrganizationID: org.ID,
+			CreatedBy:      user.ID,
+		})
+		version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+			OrganizationID: org.ID,
+			TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+			CreatedBy:      user.ID,
+		})
+		workspace := dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        user.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+		})
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+		})
+		buildNumber++
+		dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			CreatedAt:         createdAt,
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       buildNumber,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+		})
+		return template
+	}
+	active := templateWithBuildAt(now.Add(-time.Hour))
+	idle := templateWithBuildAt(cutoff.Add(-time.Hour))
+
+	templates, err := db.GetTemplatesWithFilter(ctx, database.GetTemplatesWithFilterParams{
+		OrganizationID: org.ID,
+		IDs:            []uuid.UUID{active.ID, idle.ID},
+		NoBuildsSince:  cutoff,
+	})
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+	require.Equal(t, idle.ID, templates[0].ID)
+
+	// Without the filter both templates are returned.
+	templates, err = db.GetTemplatesWithFilter(ctx, database.GetTemplatesWithFilterParams{
+		OrganizationID: org.ID,
+		IDs:            []uuid.UUID{active.ID, idle.ID},
+	})
+	require.NoError(t, err)
+	require.Len(t, templates, 2)
+}
+
+func TestGetAuthorizedWorkspaceBuilds(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	alice := dbgen.User(t, db, database.User{})
+	bob := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      alice.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      alice.ID,
+	})
+
+	now := database.Now()
+	buildsFor := func(owner database.User, count int) []database.WorkspaceBuild {
+		workspace := dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        owner.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+		})
+		builds := make([]database.WorkspaceBuild, 0, count)
+		for i := 0; i < count; i++ {
+			job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+				OrganizationID: org.ID,
+				InitiatorID:    owner.ID,
+			})
+			builds = append(builds, dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+				CreatedAt:         now.Add(time.Duration(i) * time.Minute),
+				WorkspaceID:       workspace.ID,
+				TemplateVersionID: version.ID,
+				BuildNumber:       int32(i) + 1,
+				InitiatorID:       owner.ID,
+				JobID:             job.ID,
+			}))
+		}
+		return builds
+	}
+	aliceBuilds := buildsFor(alice, 3)
+	_ = buildsFor(bob, 2)
+
+	authorizer := rbac.NewAuthorizer(prometheus.NewRegistry())
+	prepared, err := authorizer.Prepare(ctx, rbac.Subject{
+		ID:    alice.ID.String(),
+		Roles: rbac.RoleNames{rbac.RoleMember(), rbac.RoleOrgMember(org.ID)},
+		Scope: rbac.ScopeAll,
+	}, rbac.ActionRead, rbac.ResourceWorkspace.Type)
+	require.NoError(t, err)
+
+	rows, err := db.GetAuthorizedWorkspaceBuilds(ctx, database.GetWorkspaceBuildsParams{
+		OrganizationID: org.ID,
+	}, prepared)
+	require.NoError(t, err)
+	require.Len(t, rows, len(aliceBuilds))
+	aliceWorkspace, err := db.GetWorkspaceByID(ctx, aliceBuilds[0].WorkspaceID)
+	require.NoError(t, err)
+	for i, row := range rows {
+		// Builds are ordered newest first.
+		require.Equal(t, aliceBuilds[len(aliceBuilds)-1-i].ID, row.ID)
+		require.Equal(t, int64(len(aliceBuilds)), row.Count)
+		// Each build comes with its workspace and initiator.
+		require.Equal(t, aliceWorkspace.Name, row.WorkspaceName)
+		require.Equal(t, alice.ID, row.WorkspaceOwnerID)
+		require.Equal(t, org.ID, row.WorkspaceOrganizationID)
+		require.Equal(t, template.ID, row.WorkspaceTemplateID)
+		require.Equal(t, alice.Username, row.InitiatorByUsername)
+	}
+
+	// The count reflects all builds, regardless of pagination.
+	rows, err = db.GetAuthorizedWorkspaceBuilds(ctx, database.GetWorkspaceBuildsParams{
+		OrganizationID: org.ID,
+		OffsetOpt:      1,
+		LimitOpt:       1,
+	}, prepared)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, aliceBuilds[1].ID, rows[0].ID)
+	require.Equal(t, int64(len(aliceBuilds)), rows[0].Count)
+}
+
+func TestGetActiveJobCountsByTemplate(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	now := database.Now()
+
+	buildNumber := int32(0)
+	type jobState int
+	const (
+		pending jobState = iota
+		running
+		completed
+		canceled
+	)
+	seed := func(states ...jobState) database.Template {
+		template := dbgen.Template(t, db, database.Template{
+			OrganizationID: org.ID,
+			CreatedBy:      user.ID,
+		})
+		version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+			OrganizationID: org.ID,
+			TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+			CreatedBy:      user.ID,
+		})
+		workspace := dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        user.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+		})
+		for _, state := range states {
+			job := database.ProvisionerJob{
+				OrganizationID: org.ID,
+				InitiatorID:    user.ID,
+			}
+			switch state {
+			case running:
+				job.StartedAt = sql.NullTime{Time: now, Valid: true}
+			case completed:
+				job.StartedAt = sql.NullTime{Time: now, Valid: true}
+				job.CompletedAt = sql.NullTime{Time: now, Valid: true}
+			case canceled:
+				job.CanceledAt = sql.NullTime{Time: now, Valid: true}
+				job.CompletedAt = sql.NullTime{Time: now, Valid: true}
+			}
+			job = dbgen.ProvisionerJob(t, db, job)
+			buildNumber++
+			dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+				WorkspaceID:       workspace.ID,
+				TemplateVersionID: version.ID,
+				BuildNumber:       buildNumber,
+				InitiatorID:       user.ID,
+				JobID:             job.ID,
+			})
+		}
+		return template
+	}
+	busy := seed(pending, running, completed)
+	quiet := seed(running)
+	idle := seed(completed, canceled)
+
+	rows, err := db.GetActiveJobCountsByTemplate(ctx)
+	require.NoError(t, err)
+	counts := make(map[uuid.UUID]int64)
+	for _, row := range rows {
+		counts[row.TemplateID] = row.Count
+	}
+	require.Equal(t, int64(2), counts[busy.ID])
+	require.Equal(t, int64(1), counts[quiet.ID])
+	require.NotContains(t, counts, idle.ID)
+}
+
+func TestWorkspaceBuildRollbackOf(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	workspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	newBuild := func(number int32, rollbackOf uuid.NullUUID) database.WorkspaceBuild {
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+		})
+		return dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       number,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+			RollbackOf:        rollbackOf,
+		})
+	}
+	first := newBuild(1, uuid.NullUUID{})
+	_ = newBuild(2, uuid.NullUUID{})
+	rollback := newBuild(3, uuid.NullUUID{UUID: first.ID, Valid: true})
+
+	require.False(t, first.RollbackOf.Valid)
+	got, err := db.GetWorkspaceBuildByID(ctx, rollback.ID)
+	require.NoError(t, err)
+	require.Equal(t, uuid.NullUUID{UUID: first.ID, Valid: true}, got.RollbackOf)
+}
+
+func TestGetWorkspaceBuildParametersShared(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	workspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	newBuild := func(number int32, parametersFrom uuid.NullUUID) database.WorkspaceBuild {
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+		})
+		return dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:           workspace.ID,
+			TemplateVersionID:     version.ID,
+			BuildNumber:           number,
+			InitiatorID:           user.ID,
+			JobID:                 job.ID,
+			ParametersFromBuildID: parametersFrom,
+		})
+	}
+	start := newBuild(1, uuid.NullUUID{})
+	err = db.InsertWorkspaceBuildParameters(ctx, database.InsertWorkspaceBuildParametersParams{
+		WorkspaceBuildID: start.ID,
+		Name:             []string{"region"},
+		Value:            []string{"eu"},
+	})
+	require.NoError(t, err)
+	stop := newBuild(2, uuid.NullUUID{UUID: start.ID, Valid: true})
+	require.Equal(t, uuid.NullUUID{UUID: start.ID, Valid: true}, stop.ParametersFromBuildID)
+
+	params, err := db.GetWorkspaceBuildParameters(ctx, stop.ID)
+	require.NoError(t, err)
+	require.Len(t, params, 1)
+	require.Equal(t, "region", params[0].Name)
+	require.Equal(t, "eu", params[0].Value)
+
+	// A build that stores its own parameters is unaffected.
+	params, err = db.GetWorkspaceBuildParameters(ctx, start.ID)
+	require.NoError(t, err)
+	require.Len(t, params, 1)
+}
+
+func TestGetLatestBuildByTemplateVersionID(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	newVersion := func() database.TemplateVersion {
+		return dbgen.TemplateVersion(t, db, database.TemplateVersion{
+			OrganizationID: org.ID,
+			TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+			CreatedBy:      user.ID,
+		})
+	}
+	version := newVersion()
+	otherVersion := newVersion()
+	newWorkspace := func() database.Workspace {
+		return dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        user.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+		})
+	}
+	newBuild := func(workspace database.Workspace, version database.TemplateVersion, number int32, createdAt time.Time) database.WorkspaceBuild {
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+		})
+		return dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       number,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+			CreatedAt:         createdAt,
+		})
+	}
+
+	_, err = db.GetLatestBuildByTemplateVersionID(ctx, version.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	now := database.Now()
+	first, second := newWorkspace(), newWorkspace()
+	_ = newBuild(first, version, 1, now.Add(-3*time.Hour))
+	latest := newBuild(second, version, 1, now.Add(-time.Hour))
+	_ = newBuild(first, version, 2, now.Add(-2*time.Hour))
+	// A newer build on another version is not considered.
+	_ = newBuild(second, otherVersion, 2, now)
+
+	build, err := db.GetLatestBuildByTemplateVersionID(ctx, version.ID)
+	require.NoError(t, err)
+	require.Equal(t, latest.ID, build.ID)
+	require.Equal(t, second.ID, build.WorkspaceID)
+}
+
+func TestGetLatestSuccessfulWorkspaceBuildByWorkspaceID(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	workspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	newBuild := func(number int32, job database.ProvisionerJob) database.WorkspaceBuild {
+		version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+			OrganizationID: org.ID,
+			TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+			CreatedBy:      user.ID,
+		})
+		job.OrganizationID = org.ID
+		job.InitiatorID = user.ID
+		job = dbgen.ProvisionerJob(t, db, job)
+		return dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       number,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+		})
+	}
+
+	_, err = db.GetLatestSuccessfulWorkspaceBuildByWorkspaceID(ctx, workspace.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	now := database.Now()
+	succeeded := newBuild(1, database.ProvisionerJob{
+		StartedAt:   sql.NullTime{Time: now, Valid: true},
+		CompletedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	_ = newBuild(2, database.ProvisionerJob{
+		StartedAt:   sql.NullTime{Time: now, Valid: true},
+		CompletedAt: sql.NullTime{Time: now, Valid: true},
+		Error:       sql.NullString{String: "broken version", Valid: true},
+	})
+	_ = newBuild(3, database.ProvisionerJob{
+		StartedAt:   sql.NullTime{Time: now, Valid: true},
+		CanceledAt:  sql.NullTime{Time: now, Valid: true},
+		CompletedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	// Still running.
+	_ = newBuild(4, database.ProvisionerJob{})
+
+	build, err := db.GetLatestSuccessfulWorkspaceBuildByWorkspaceID(ctx, workspace.ID)
+	require.NoError(t, err)
+	require.Equal(t, succeeded.ID, build.ID)
+	require.Equal(t, succeeded.TemplateVersionID, build.TemplateVersionID)
+}
+
+func TestGetWorkspaceBuildParametersMap(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	workspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+		OrganizationID: org.ID,
+		InitiatorID:    user.ID,
+	})
+	build := dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+		WorkspaceID:       workspace.ID,
+		TemplateVersionID: version.ID,
+		InitiatorID:       user.ID,
+		JobID:             job.ID,
+	})
+	err = db.InsertWorkspaceBuildParameters(ctx, database.InsertWorkspaceBuildParametersParams{
+		WorkspaceBuildID: build.ID,
+		Name:             []string{"region", "size"},
+		Value:            []string{"eu", "small"},
+	})
+	require.NoError(t, err)
+
+	params, err := db.GetWorkspaceBuildParameters(ctx, build.ID)
+	require.NoError(t, err)
+	paramsMap, err := db.GetWorkspaceBuildParametersMap(ctx, build.ID)
+	require.NoError(t, err)
+	require.Len(t, paramsMap, len(params))
+	for _, param := range params {
+		require.Equal(t, param.Value, paramsMap[param.Name])
+	}
+	require.Equal(t, map[string]string{"region": "eu", "size": "small"}, paramsMap)
+}
+
+func TestGetWorkspacesWithFailedLatestBuild(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	alice := dbgen.User(t, db, database.User{})
+	bob := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      alice.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      alice.ID,
+	})
+
+	now := database.Now()
+	// workspaceWithBuilds creates a workspace with a completed build for each
+	// of the given errors, in order. An empty error means the build succeeded.
+	workspaceWithBuilds := func(owner database.User, errs ...string) database.Workspace {
+		workspace := dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        owner.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+		})
+		for i, e := range errs {
+			completedAt := now.Add(time.Duration(i) * time.Minute)
+			job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+				OrganizationID: org.ID,
+				InitiatorID:    owner.ID,
+				CompletedAt:    sql.NullTime{Time: completedAt, Valid: true},
+				Error:          sql.NullString{String: e, Valid: e != ""},
+			})
+			dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+				WorkspaceID:       workspace.ID,
+				TemplateVersionID: version.ID,
+				BuildNumber:       int32(i) + 1,
+				InitiatorID:       owner.ID,
+				JobID:             job.ID,
+			})
+		}
+		return workspace
+	}
+	_ = workspaceWithBuilds(alice, "")
+	failed := workspaceWithBuilds(alice, "", "terraform apply failed")
+	_ = workspaceWithBuilds(alice, "quota exceeded", "")
+	failedEarlier := workspaceWithBuilds(alice, "provisioner crashed")
+	bobFailed := workspaceWithBuilds(bob, "bob's build failed")
+
+	rows, err := db.GetWorkspacesWithFailedLatestBuild(ctx, database.GetWorkspacesWithFailedLatestBuildParams{
+		OrganizationID: org.ID,
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	// The most recent failure comes first.
+	require.Equal(t, failed.ID, rows[0].ID)
+	require.Equal(t, "terraform apply failed", rows[0].LatestBuildError)
+	require.ElementsMatch(t,
+		[]uuid.UUID{failedEarlier.ID, bobFailed.ID},
+		[]uuid.UUID{rows[1].ID, rows[2].ID},
+	)
+	for _, row := range rows {
+		require.Equal(t, int64(3), row.Count)
+		require.NotEmpty(t, row.LatestBuildError)
+	}
+
+	authorizer := rbac.NewAuthorizer(prometheus.NewRegistry())
+	prepared, err := authorizer.Prepare(ctx, rbac.Subject{
+		ID:    alice.ID.String(),
+		Roles: rbac.RoleNames{rbac.RoleMember(), rbac.RoleOrgMember(org.ID)},
+		Scope: rbac.ScopeAll,
+	}, rbac.ActionRead, rbac.ResourceWorkspace.Type)
+	require.NoError(t, err)
+
+	rows, err = db.GetAuthorizedWorkspacesWithFailedLatestBuild(ctx, database.GetWorkspacesWithFailedLatestBuildParams{
+		OrganizationID: org.ID,
+	}, prepared)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, failed.ID, rows[0].ID)
+	require.Equal(t, "terraform apply failed", rows[0].LatestBuildError)
+	require.Equal(t, failedEarlier.ID, rows[1].ID)
+	require.Equal(t, "provisioner crashed", rows[1].LatestBuildError)
+}
+
+func TestWorkspaceBuildStructuredReason(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	workspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	newBuild := func(number int32, reason database.StructuredBuildReason) database.WorkspaceBuild {
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+		})
+		return dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       number,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+			Reason:            database.BuildReasonAutostop,
+			StructuredReason:  reason,
+		})
+	}
+	structured := database.StructuredBuildReason{
+		Category:    "capacity",
+		Subcategory: "rebalance",
+		Source:      "scheduler",
+	}
+	plain := newBuild(1, database.StructuredBuildReason{})
+	detailed := newBuild(2, structured)
+
+	require.Equal(t, database.StructuredBuildReason{}, plain.StructuredReason)
+	got, err := db.GetWorkspaceBuildByID(ctx, detailed.ID)
+	require.NoError(t, err)
+	require.Equal(t, structured, got.StructuredReason)
+	require.Equal(t, database.BuildReasonAutostop, got.Reason)
+
+	// The zero value is stored as NULL, and the structured reason can be
+	// queried on directly.
+	var plainIsNull bool
+	err = sqlDB.QueryRowContext(ctx,
+		"SELECT structured_reason IS NULL FROM workspace_builds WHERE id = $1", plain.ID,
+	).Scan(&plainIsNull)
+	require.NoError(t, err)
+	require.True(t, plainIsNull)
+
+	var ids []uuid.UUID
+	rows, err := sqlDB.QueryContext(ctx,
+		"SELECT id FROM workspace_builds WHERE structured_reason->>'category' = $1", structured.Category,
+	)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var id uuid.UUID
+		require.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []uuid.UUID{detailed.ID}, ids)
+}
+
+func TestGetUserGroups(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	otherOrg := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	otherUser := dbgen.User(t, db, database.User{})
+	frontend := dbgen.Group(t, db, database.Group{OrganizationID: org.ID, Name: "frontend"})
+	backend := dbgen.Group(t, db, database.Group{OrganizationID: org.ID, Name: "backend"})
+	other := dbgen.Group(t, db, database.Group{OrganizationID: otherOrg.ID, Name: "other"})
+	unrelated := dbgen.Group(t, db, database.Group{OrganizationID: org.ID, Name: "unrelated"})
+	for _, group := range []database.Group{frontend, backend, other} {
+		_ = dbgen.GroupMember(t, db, database.GroupMember{UserID: user.ID, GroupID: group.ID})
+	}
+	_ = dbgen.GroupMember(t, db, database.GroupMember{UserID: otherUser.ID, GroupID: unrelated.ID})
+
+	groups, err := db.GetUserGroups(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, []database.Group{backend, frontend, other}, groups)
+}
+
+func TestGetWorkspaceCountByOwner(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	otherUser := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	for i := 0; i < 3; i++ {
+		_ = dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        user.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+		})
+	}
+	for i := 0; i < 2; i++ {
+		workspace := dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        user.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+		})
+		err = db.UpdateWorkspaceDeletedByID(ctx, database.UpdateWorkspaceDeletedByIDParams{
+			ID:      workspace.ID,
+			Deleted: true,
+		})
+		require.NoError(t, err)
+	}
+	// Another user's workspace isn't counted.
+	_ = dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        otherUser.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+
+	count, err := db.GetWorkspaceCountByOwner(ctx, database.GetWorkspaceCountByOwnerParams{
+		OwnerID: user.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), count)
+
+	count, err = db.GetWorkspaceCountByOwner(ctx, database.GetWorkspaceCountByOwnerParams{
+		OwnerID:        user.ID,
+		IncludeDeleted: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), count)
+}
+
+func TestWorkspaceBuildIdempotencyKey(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	newWorkspace := func() database.Workspace {
+		return dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        user.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+		})
+	}
+	insertBuild := func(workspaceID uuid.UUID, number int32, key sql.NullString) (uuid.UUID, error) {
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+		})
+		id := uuid.New()
+		return id, db.InsertWorkspaceBuild(ctx, database.InsertWorkspaceBuildParams{
+			ID:                id,
+			CreatedAt:         database.Now(),
+			UpdatedAt:         database.Now(),
+			WorkspaceID:       workspaceID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       number,
+			Transition:        database.WorkspaceTransitionStart,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+			Reason:            database.BuildReasonInitiator,
+			IdempotencyKey:    key,
+		})
+	}
+	key := sql.NullString{String: "retry", Valid: true}
+
+	workspace := newWorkspace()
+	keyed, err := insertBuild(workspace.ID, 1, key)
+	require.NoError(t, err)
+	// Builds without a key don't conflict with each other.
+	_, err = insertBuild(workspace.ID, 2, sql.NullString{})
+	require.NoError(t, err)
+	_, err = insertBuild(workspace.ID, 3, sql.NullString{})
+	require.NoError(t, err)
+	// The key is unique per workspace.
+	_, err = insertBuild(workspace.ID, 4, key)
+	require.True(t, database.IsUniqueViolation(err, database.UniqueWorkspaceBuildsWorkspaceIDIdempotencyKeyIndex), err)
+	_, err = insertBuild(newWorkspace().ID, 1, key)
+	require.NoError(t, err)
+
+	got, err := db.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(ctx, database.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams{
+		WorkspaceID:    workspace.ID,
+		IdempotencyKey: key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, keyed, got.ID)
+	require.Equal(t, key, got.IdempotencyKey)
+
+	_, err = db.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(ctx, database.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams{
+		WorkspaceID:    workspace.ID,
+		IdempotencyKey: sql.NullString{String: "other", Valid: true},
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestGetWorkspaceBuildsByInitiator(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	alice := dbgen.User(t, db, database.User{})
+	bob := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      alice.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      alice.ID,
+	})
+	aliceWorkspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        alice.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	bobWorkspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        bob.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	now := database.Now()
+	build := func(workspace database.Workspace, initiator database.User, number int32, createdAt time.Time) database.WorkspaceBuild {
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    initiator.ID,
+		})
+		return dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       number,
+			InitiatorID:       initiator.ID,
+			JobID:             job.ID,
+			CreatedAt:         createdAt,
+		})
+	}
+
+	first := build(aliceWorkspace, alice, 1, now.Add(-3*time.Hour))
+	_ = build(bobWorkspace, bob, 1, now.Add(-2*time.Hour))
+	// Alice builds in Bob's workspace too, e.g. as an admin.
+	second := build(bobWorkspace, alice, 2, now.Add(-time.Hour))
+	_ = build(aliceWorkspace, bob, 2, now)
+
+	rows, err := db.GetWorkspaceBuildsByInitiator(ctx, database.GetWorkspaceBuildsByInitiatorParams{
+		InitiatorID: alice.ID,
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, second.ID, rows[0].ID)
+	require.Equal(t, bobWorkspace.Name, rows[0].WorkspaceName)
+	require.Equal(t, bob.ID, rows[0].WorkspaceOwnerID)
+	require.Equal(t, first.ID, rows[1].ID)
+	require.Equal(t, aliceWorkspace.Name, rows[1].WorkspaceName)
+	for _, row := range rows {
+		require.Equal(t, alice.ID, row.InitiatorID)
+		require.Equal(t, template.Name, row.TemplateName)
+		require.Equal(t, alice.Username, row.InitiatorByUsername)
+	}
+
+	// Pagination applies after ordering.
+	rows, err = db.GetWorkspaceBuildsByInitiator(ctx, database.GetWorkspaceBuildsByInitiatorParams{
+		InitiatorID: alice.ID,
+		OffsetOpt:   1,
+		LimitOpt:    1,
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, first.ID, rows[0].ID)
+}
+
+func TestGetTemplateACL(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	active := dbgen.User(t, db, database.User{})
+	suspended := dbgen.User(t, db, database.User{})
+	_, err = db.UpdateUserStatus(ctx, database.UpdateUserStatusParams{
+		ID:        suspended.ID,
+		Status:    database.UserStatusSuspended,
+		UpdatedAt: database.Now(),
+	})
+	require.NoError(t, err)
+	group := dbgen.Group(t, db, database.Group{OrganizationID: org.ID})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      active.ID,
+		UserACL: database.TemplateACL{
+			active.ID.String():    []rbac.Action{rbac.ActionRead},
+			suspended.ID.String(): []rbac.Action{rbac.ActionRead},
+		},
+		GroupACL: database.TemplateACL{
+			group.ID.String(): []rbac.Action{rbac.ActionRead, rbac.ActionUpdate},
+		},
+	})
+
+	acl, err := db.GetTemplateACL(ctx, template.ID)
+	require.NoError(t, err)
+	users, err := db.GetTemplateUserRoles(ctx, template.ID)
+	require.NoError(t, err)
+	groups, err := db.GetTemplateGroupRoles(ctx, template.ID)
+	require.NoError(t, err)
+	require.Equal(t, users, acl.Users)
+	require.Equal(t, groups, acl.Groups)
+
+	// Suspended users are filtered out, as by GetTemplateUserRoles.
+	require.Len(t, acl.Users, 1)
+	require.Equal(t, active.ID, acl.Users[0].ID)
+	require.Len(t, acl.Groups, 1)
+	require.Equal(t, group.ID, acl.Groups[0].ID)
+	require.Equal(t, database.Actions{rbac.ActionRead, rbac.ActionUpdate}, acl.Groups[0].Actions)
+
+	// Users and groups are read in the same query, so make sure every column
+	// ends up in the right field.
+	activeUser, err := db.GetUserByID(ctx, active.ID)
+	require.NoError(t, err)
+	require.Equal(t, activeUser, acl.Users[0].User)
+	require.Equal(t, database.Actions{rbac.ActionRead}, acl.Users[0].Actions)
+	require.Equal(t, group, acl.Groups[0].Group)
+}
+
+func TestGetWorkspaceBuildsWithParameters(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	newWorkspace := func() database.Workspace {
+		return dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        user.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+		})
+	}
+	newBuild := func(workspace database.Workspace, number int32, parametersFrom uuid.NullUUID, params map[string]string) database.WorkspaceBuild {
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+		})
+		build := dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:           workspace.ID,
+			TemplateVersionID:     version.ID,
+			BuildNumber:           number,
+			InitiatorID:           user.ID,
+			JobID:                 job.ID,
+			ParametersFromBuildID: parametersFrom,
+		})
+		insert := database.InsertWorkspaceBuildParametersParams{WorkspaceBuildID: build.ID}
+		for name, value := range params {
+			insert.Name = append(insert.Name, name)
+			insert.Value = append(insert.Value, value)
+		}
+		err := db.InsertWorkspaceBuildParameters(ctx, insert)
+		require.NoError(t, err)
+		return build
+	}
+
+	workspace := newWorkspace()
+	first := newBuild(workspace, 1, uuid.NullUUID{}, map[string]string{"region": "eu", "size": "large"})
+	second := newBuild(workspace, 2, uuid.NullUUID{}, map[string]string{"region": "us"})
+	shared := newBuild(workspace, 3, uuid.NullUUID{UUID: first.ID, Valid: true}, nil)
+	empty := newBuild(workspace, 4, uuid.NullUUID{}, nil)
+	// Builds of other workspaces are excluded.
+	other := newWorkspace()
+	_ = newBuild(other, 1, uuid.NullUUID{}, map[string]string{"region": "ap"})
+
+	builds, err := db.GetWorkspaceBuildsWithParameters(ctx, workspace.ID)
+	require.NoError(t, err)
+	require.Len(t, builds, 4)
+
+	require.Equal(t, first.ID, builds[0].WorkspaceBuild.ID)
+	require.Equal(t, []database.WorkspaceBuildParameter{
+		{WorkspaceBuildID: first.ID, Name: "region", Value: "eu"},
+		{WorkspaceBuildID: first.ID, Name: "size", Value: "large"},
+	}, builds[0].Parameters)
+
+	require.Equal(t, second.ID, builds[1].WorkspaceBuild.ID)
+	require.Equal(t, []database.WorkspaceBuildParameter{
+		{WorkspaceBuildID: second.ID, Name: "region", Value: "us"},
+	}, builds[1].Parameters)
+
+	// A build that shares the parameters of a prior build gets that build's.
+	require.Equal(t, shared.ID, builds[2].WorkspaceBuild.ID)
+	require.Equal(t, builds[0].Parameters, builds[2].Parameters)
+
+	require.Equal(t, empty.ID, builds[3].WorkspaceBuild.ID)
+	require.Empty(t, builds[3].Parameters)
+}
+
+func TestGetAuthorizedWorkspacesSort(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+
+	now := database.Now()
+	newWorkspace := func(name string, createdAt, lastUsedAt time.Time) database.Workspace {
+		return dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        user.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+			Name:           name,
+			CreatedAt:      createdAt,
+			LastUsedAt:     lastUsedAt,
+		})
+	}
+	// Each column orders the workspaces differently.
+	alpha := newWorkspace("alpha", now.Add(-time.Hour), now.Add(-time.Minute))
+	bravo := newWorkspace("Bravo", now.Add(-3*time.Hour), now.Add(-2*time.Minute))
+	charlie := newWorkspace("charlie", now.Add(-2*time.Hour), now.Add(-3*time.Minute))
+
+	authorizer := rbac.NewAuthorizer(prometheus.NewRegistry())
+	prepared, err := authorizer.Prepare(ctx, rbac.Subject{
+		ID:    user.ID.String(),
+		Roles: rbac.RoleNames{rbac.RoleMember(), rbac.RoleOrgMember(org.ID)},
+		Scope: rbac.ScopeAll,
+	}, rbac.ActionRead, rbac.ResourceWorkspace.Type)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		sortBy    string
+		sortOrder string
+		expected  []uuid.UUID
+	}{
+		{"", "", []uuid.UUID{alpha.ID, bravo.ID, charlie.ID}},
+		{database.WorkspacesSortByName, database.SortOrderDesc, []uuid.UUID{charlie.ID, bravo.ID, alpha.ID}},
+		{database.WorkspacesSortByCreatedAt, "", []uuid.UUID{bravo.ID, charlie.ID, alpha.ID}},
+		{database.WorkspacesSortByCreatedAt, database.SortOrderDesc, []uuid.UUID{alpha.ID, charlie.ID, bravo.ID}},
+		{database.WorkspacesSortByLastUsedAt, database.SortOrderAsc, []uuid.UUID{charlie.ID, bravo.ID, alpha.ID}},
+	} {
+		rows, err := db.GetAuthorizedWorkspaces(ctx, database.GetWorkspacesParams{
+			SortBy:    tc.sortBy,
+			SortOrder: tc.sortOrder,
+		}, prepared)
+		require.NoError(t, err)
+		ids := make([]uuid.UUID, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		require.Equal(t, tc.expected, ids, "sort by %q %q", tc.sortBy, tc.sortOrder)
+	}
+
+	_, err = db.GetAuthorizedWorkspaces(ctx, database.GetWorkspacesParams{
+		SortBy: "owner_id",
+	}, prepared)
+	require.ErrorContains(t, err, "unknown workspace sort column")
+}
+
+func TestGetAuthorizedWorkspacesSearchQuery(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	alice := dbgen.User(t, db, database.User{Username: "alice"})
+	bob := dbgen.User(t, db, database.User{Username: "bob"})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      alice.ID,
+	})
+	newWorkspace := func(owner database.User, name string) database.Workspace {
+		return dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        owner.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+			Name:           name,
+		})
+	}
+	aliceDev := newWorkspace(alice, "dev")
+	aliceBobcat := newWorkspace(alice, "bobcat")
+	bobDev := newWorkspace(bob, "dev")
+	bobScratch := newWorkspace(bob, "scratch")
+
+	prepare := func(user database.User, roles ...string) rbac.PreparedAuthorized {
+		authorizer := rbac.NewAuthorizer(prometheus.NewRegistry())
+		prepared, err := authorizer.Prepare(ctx, rbac.Subject{
+			ID:    user.ID.String(),
+			Roles: rbac.RoleNames(roles),
+			Scope: rbac.ScopeAll,
+		}, rbac.ActionRead, rbac.ResourceWorkspace.Type)
+		require.NoError(t, err)
+		return prepared
+	}
+	search := func(arg database.GetWorkspacesParams, prepared rbac.PreparedAuthorized) []uuid.UUID {
+		rows, err := db.GetAuthorizedWorkspaces(ctx, arg, prepared)
+		require.NoError(t, err)
+		ids := make([]uuid.UUID, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		return ids
+	}
+	admin := prepare(alice, rbac.RoleOwner())
+	member := prepare(alice, rbac.RoleMember(), rbac.RoleOrgMember(org.ID))
+
+	// The search matches either the workspace name or the owner's username.
+	require.ElementsMatch(t,
+		[]uuid.UUID{aliceBobcat.ID, bobDev.ID, bobScratch.ID},
+		search(database.GetWorkspacesParams{SearchQuery: "BOB"}, admin),
+	)
+	require.ElementsMatch(t,
+		[]uuid.UUID{aliceDev.ID, aliceBobcat.ID},
+		search(database.GetWorkspacesParams{SearchQuery: "ali"}, admin),
+	)
+	// The authorized filter still applies to the matches.
+	require.ElementsMatch(t,
+		[]uuid.UUID{aliceBobcat.ID},
+		search(database.GetWorkspacesParams{SearchQuery: "bob"}, member),
+	)
+	// The exact filters are combined with the search.
+	require.ElementsMatch(t,
+		[]uuid.UUID{bobDev.ID},
+		search(database.GetWorkspacesParams{SearchQuery: "dev", OwnerUsername: "bob"}, admin),
+	)
+	// The search term is never interpreted as SQL.
+	require.Empty(t, search(database.GetWorkspacesParams{SearchQuery: "' OR 1=1 --"}, admin))
+}
+
+func TestWorkspaceBuildTemplateFileHash(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	file := dbgen.File(t, db, database.File{
+		CreatedBy: user.ID,
+		Hash:      "d2a84f4b8b650937ec8f73cd8be2c74add5a911ba64df27458ed8229da804a26",
+	})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	workspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	insertBuild := func(number int32, fileID uuid.UUID) database.WorkspaceBuild {
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+			FileID:         fileID,
+		})
+		id := uuid.New()
+		err := db.InsertWorkspaceBuild(ctx, database.InsertWorkspaceBuildParams{
+			ID:                id,
+			CreatedAt:         database.Now(),
+			UpdatedAt:         database.Now(),
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       number,
+			Transition:        database.WorkspaceTransitionStart,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+			Reason:            database.BuildReasonInitiator,
+			TemplateFileID:    fileID,
+		})
+		require.NoError(t, err)
+		build, err := db.GetWorkspaceBuildByID(ctx, id)
+		require.NoError(t, err)
+		return build
+	}
+
+	build := insertBuild(1, file.ID)
+	require.Equal(t, sql.NullString{String: file.Hash, Valid: true}, build.TemplateFileHash)
+
+	// A file that is no longer stored leaves the hash unset rather than
+	// failing the build.
+	build = insertBuild(2, uuid.New())
+	require.False(t, build.TemplateFileHash.Valid)
+}
+
+func TestWorkspaceBuildScheduleName(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	workspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	insertBuild := func(number int32, reason database.BuildReason, scheduleName sql.NullString) database.WorkspaceBuild {
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+		})
+		return dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       number,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+			Reason:            reason,
+			ScheduleName:      scheduleName,
+		})
+	}
+
+	manual := insertBuild(1, database.BuildReasonInitiator, sql.NullString{})
+	require.False(t, manual.ScheduleName.Valid)
+
+	scheduleName := sql.NullString{String: "weekday-mornings", Valid: true}
+	autostart := insertBuild(2, database.BuildReasonAutostart, scheduleName)
+	require.Equal(t, database.BuildReasonAutostart, autostart.Reason)
+	require.Equal(t, scheduleName, autostart.ScheduleName)
+
+	latest, err := db.GetLatestWorkspaceBuildByWorkspaceID(ctx, workspace.ID)
+	require.NoError(t, err)
+	require.Equal(t, autostart.ID, latest.ID)
+	require.Equal(t, scheduleName, latest.ScheduleName)
+}
+
+func TestGetTemplateGroupRolesDeletedGroup(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	kept := dbgen.Group(t, db, database.Group{OrganizationID: org.ID})
+	deleted := dbgen.Group(t, db, database.Group{OrganizationID: org.ID})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+		GroupACL: database.TemplateACL{
+			kept.ID.String():    []rbac.Action{rbac.ActionRead},
+			deleted.ID.String(): []rbac.Action{rbac.ActionRead},
+		},
+	})
+
+	groups, err := db.GetTemplateGroupRoles(ctx, template.ID)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+
+	// Deleting a group leaves its entry in the template's ACL, but it must
+	// not be returned.
+	err = db.DeleteGroupByID(ctx, deleted.ID)
+	require.NoError(t, err)
+	groups, err = db.GetTemplateGroupRoles(ctx, template.ID)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	require.Equal(t, kept.ID, groups[0].ID)
+}
+
+func TestGetStuckRunningBuilds(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	workspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+
+	now := database.Now()
+	buildNumber := int32(0)
+	newBuild := func(job database.ProvisionerJob) database.WorkspaceBuild {
+		job.OrganizationID = org.ID
+		job.InitiatorID = user.ID
+		job = dbgen.ProvisionerJob(t, db, job)
+		buildNumber++
+		return dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       buildNumber,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+		})
+	}
+	stuck := newBuild(database.ProvisionerJob{
+		StartedAt: sql.NullTime{Time: now.Add(-2 * time.Hour), Valid: true},
+	})
+	// Freshly running.
+	_ = newBuild(database.ProvisionerJob{
+		StartedAt: sql.NullTime{Time: now.Add(-time.Minute), Valid: true},
+	})
+	// Started long ago, but completed.
+	_ = newBuild(database.ProvisionerJob{
+		StartedAt:   sql.NullTime{Time: now.Add(-3 * time.Hour), Valid: true},
+		CompletedAt: sql.NullTime{Time: now.Add(-2 * time.Hour), Valid: true},
+	})
+	// Still pending.
+	_ = newBuild(database.ProvisionerJob{})
+
+	builds, err := db.GetStuckRunningBuilds(ctx, now.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, builds, 1)
+	require.Equal(t, stuck.ID, builds[0].ID)
+}
+
+func TestGetAuthorizedTemplatesCreatedBy(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	otherOrg := dbgen.Organization(t, db, database.Organization{})
+	alice := dbgen.User(t, db, database.User{})
+	bob := dbgen.User(t, db, database.User{})
+	newTemplate := func(orgID uuid.UUID, createdBy database.User) database.Template {
+		return dbgen.Template(t, db, database.Template{
+			OrganizationID: orgID,
+			CreatedBy:      createdBy.ID,
+		})
+	}
+	aliceFirst := newTemplate(org.ID, alice)
+	aliceSecond := newTemplate(org.ID, alice)
+	_ = newTemplate(org.ID, bob)
+	// Created by alice, but not visible to an admin of the first organization.
+	_ = newTemplate(otherOrg.ID, alice)
+
+	authorizer := rbac.NewAuthorizer(prometheus.NewRegistry())
+	prepared, err := authorizer.Prepare(ctx, rbac.Subject{
+		ID:    bob.ID.String(),
+		Roles: rbac.RoleNames{rbac.RoleMember(), rbac.RoleOrgAdmin(org.ID)},
+		Scope: rbac.ScopeAll,
+	}, rbac.ActionRead, rbac.ResourceTemplate.Type)
+	require.NoError(t, err)
+
+	templates, err := db.GetAuthorizedTemplates(ctx, database.GetTemplatesWithFilterParams{
+		CreatedBy: alice.ID,
+	}, prepared)
+	require.NoError(t, err)
+	ids := make([]uuid.UUID, 0, len(templates))
+	for _, template := range templates {
+		require.Equal(t, alice.ID, template.CreatedBy)
+		ids = append(ids, template.ID)
+	}
+	require.ElementsMatch(t, []uuid.UUID{aliceFirst.ID, aliceSecond.ID}, ids)
+
+	// Without the filter, every template in the organization is returned.
+	templates, err = db.GetAuthorizedTemplates(ctx, database.GetTemplatesWithFilterParams{}, prepared)
+	require.NoError(t, err)
+	require.Len(t, templates, 3)
+}
+
+func TestCountAuthorizedTemplates(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	otherOrg := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	for i := 0; i < 3; i++ {
+		_ = dbgen.Template(t, db, database.Template{OrganizationID: org.ID, CreatedBy: user.ID})
+	}
+	deleted := dbgen.Template(t, db, database.Template{OrganizationID: org.ID, CreatedBy: user.ID})
+	err = db.UpdateTemplateDeletedByID(ctx, database.UpdateTemplateDeletedByIDParams{
+		ID:        deleted.ID,
+		Deleted:   true,
+		UpdatedAt: database.Now(),
+	})
+	require.NoError(t, err)
+	// Not visible to an admin of the first organization.
+	_ = dbgen.Template(t, db, database.Template{OrganizationID: otherOrg.ID, CreatedBy: user.ID})
+
+	authorizer := rbac.NewAuthorizer(prometheus.NewRegistry())
+	prepared, err := authorizer.Prepare(ctx, rbac.Subject{
+		ID:    user.ID.String(),
+		Roles: rbac.RoleNames{rbac.RoleMember(), rbac.RoleOrgAdmin(org.ID)},
+		Scope: rbac.ScopeAll,
+	}, rbac.ActionRead, rbac.ResourceTemplate.Type)
+	require.NoError(t, err)
+
+	for _, arg := range []database.GetTemplatesWithFilterParams{
+		{},
+		{Deleted: true},
+		{OrganizationID: otherOrg.ID},
+	} {
+		templates, err := db.GetAuthorizedTemplates(ctx, arg, prepared)
+		require.NoError(t, err)
+		count, err := db.CountAuthorizedTemplates(ctx, arg, prepared)
+		require.NoError(t, err)
+		require.EqualValues(t, len(templates), count, "count for %+v", arg)
+	}
+	count, err := db.CountAuthorizedTemplates(ctx, database.GetTemplatesWithFilterParams{}, prepared)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, count)
+}
+
+func TestGetWorkspaceBuildVersionLineage(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	newVersion := func(name string) database.TemplateVersion {
+		return dbgen.TemplateVersion(t, db, database.TemplateVersion{
+			OrganizationID: org.ID,
+			TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+			CreatedBy:      user.ID,
+			Name:           name,
+		})
+	}
+	v1 := newVersion("v1")
+	v2 := newVersion("v2")
+	workspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	otherWorkspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	build := func(workspace database.Workspace, version database.TemplateVersion, number int32) database.WorkspaceBuild {
+		job := dbgen.ProvisionerJob(t, db, database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+		})
+		return dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       number,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+		})
+	}
+
+	// Inserted out of order, to show that the lineage is ordered by build number.
+	third := build(workspace, v1, 3)
+	first := build(workspace, v1, 1)
+	second := build(workspace, v2, 2)
+	_ = build(otherWorkspace, v2, 1)
+
+	lineage, err := db.GetWorkspaceBuildVersionLineage(ctx, workspace.ID)
+	require.NoError(t, err)
+	require.Len(t, lineage, 3)
+	for i, want := range []struct {
+		build   database.WorkspaceBuild
+		version database.TemplateVersion
+	}{
+		{first, v1},
+		{second, v2},
+		{third, v1},
+	} {
+		require.Equal(t, want.build.ID, lineage[i].ID)
+		require.Equal(t, want.build.BuildNumber, lineage[i].BuildNumber)
+		require.Equal(t, want.version.ID, lineage[i].TemplateVersionID)
+		require.Equal(t, want.version.Name, lineage[i].TemplateVersionName)
+	}
+}
+
+func TestGetAuthorizedWorkspacesStatusOnly(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	now := database.Now()
+	newWorkspace := func(transition database.WorkspaceTransition, completed bool) database.Workspace {
+		workspace := dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        user.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+		})
+		job := database.ProvisionerJob{
+			OrganizationID: org.ID,
+			InitiatorID:    user.ID,
+			StartedAt:      sql.NullTime{Time: now, Valid: true},
+		}
+		if completed {
+			job.CompletedAt = sql.NullTime{Time: now, Valid: true}
+		}
+		job = dbgen.ProvisionerJob(t, db, job)
+		_ = dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       1,
+			Transition:        transition,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+		})
+		return workspace
+	}
+	running := newWorkspace(database.WorkspaceTransitionStart, true)
+	starting := newWorkspace(database.WorkspaceTransitionStart, false)
+	stopped := newWorkspace(database.WorkspaceTransitionStop, true)
+	locked := newWorkspace(database.WorkspaceTransitionStop, true)
+	err = db.UpdateWorkspaceLockedDeletingAt(ctx, database.UpdateWorkspaceLockedDeletingAtParams{
+		ID:       locked.ID,
+		LockedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	require.NoError(t, err)
+
+	authorizer := rbac.NewAuthorizer(prometheus.NewRegistry())
+	prepared, err := authorizer.Prepare(ctx, rbac.Subject{
+		ID:    user.ID.String(),
+		Roles: rbac.RoleNames{rbac.RoleOwner()},
+		Scope: rbac.ScopeAll,
+	}, rbac.ActionRead, rbac.ResourceWorkspace.Type)
+	require.NoError(t, err)
+	search := func(arg database.GetWorkspacesParams) []uuid.UUID {
+		rows, err := db.GetAuthorizedWorkspaces(ctx, arg, prepared)
+		require.NoError(t, err)
+		ids := make([]uuid.UUID, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		return ids
+	}
+
+	// The filters are ignored when false.
+	require.ElementsMatch(t,
+		[]uuid.UUID{running.ID, starting.ID, stopped.ID, locked.ID},
+		search(database.GetWorkspacesParams{}),
+	)
+	// A start build that is still in progress is not running yet.
+	require.ElementsMatch(t, []uuid.UUID{running.ID}, search(database.GetWorkspacesParams{RunningOnly: true}))
+	require.ElementsMatch(t, []uuid.UUID{locked.ID}, search(database.GetWorkspacesParams{LockedOnly: true}))
+	// The filters are combined with each other and with the status.
+	require.Empty(t, search(database.GetWorkspacesParams{RunningOnly: true, LockedOnly: true}))
+	require.Empty(t, search(database.GetWorkspacesParams{RunningOnly: true, Status: string(database.WorkspaceStatusStopped)}))
+	require.ElementsMatch(t,
+		[]uuid.UUID{locked.ID},
+		search(database.GetWorkspacesParams{LockedOnly: true, Status: string(database.WorkspaceStatusStopped)}),
+	)
+}
+
+func TestGetUnusedTemplateVersions(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	workspace := dbgen.Workspace(t, db, database.Workspace{
+		OwnerID:        user.ID,
+		OrganizationID: org.ID,
+		TemplateID:     template.ID,
+	})
+	newVersion := func() database.TemplateVersion {
+		return dbgen.TemplateVersion(t, db, database.TemplateVersion{
+			OrganizationID: org.ID,
+			TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+			CreatedBy:      user.ID,
+		})
+	}
+	buildNumber := int32(0)
+	build := func(version database.TemplateVersion, job database.ProvisionerJob) {
+		buildNumber++
+		job.OrganizationID = org.ID
+		job.InitiatorID = user.ID
+		job = dbgen.ProvisionerJob(t, db, job)
+		dbgen.WorkspaceBuild(t, db, database.WorkspaceBuild{
+			WorkspaceID:       workspace.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       buildNumber,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+		})
+	}
+
+	now := database.Now()
+	used := newVersion()
+	build(used, database.ProvisionerJob{
+		StartedAt:   sql.NullTime{Time: now, Valid: true},
+		CompletedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	unused := newVersion()
+	// Failed, canceled and running builds don't count as use.
+	failed := newVersion()
+	build(failed, database.ProvisionerJob{
+		StartedAt:   sql.NullTime{Time: now, Valid: true},
+		CompletedAt: sql.NullTime{Time: now, Valid: true},
+		Error:       sql.NullString{String: "broken version", Valid: true},
+	})
+	canceled := newVersion()
+	build(canceled, database.ProvisionerJob{
+		StartedAt:   sql.NullTime{Time: now, Valid: true},
+		CanceledAt:  sql.NullTime{Time: now, Valid: true},
+		CompletedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	running := newVersion()
+	build(running, database.ProvisionerJob{})
+
+	// Versions of other templates are ignored.
+	_ = dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+
+	versions, err := db.GetUnusedTemplateVersions(ctx, template.ID)
+	require.NoError(t, err)
+	ids := make([]uuid.UUID, 0, len(versions))
+	for _, version := range versions {
+		ids = append(ids, version.ID)
+	}
+	require.ElementsMatch(t, []uuid.UUID{unused.ID, failed.ID, canceled.ID, running.ID}, ids)
+	require.NotContains(t, ids, used.ID)
+}
+
+func TestDeleteOldThrowawayTemplateVersions(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	newVersion := func(throwaway bool, createdAt time.Time) database.TemplateVersion {
+		return dbgen.TemplateVersion(t, db, database.TemplateVersion{
+			OrganizationID: org.ID,
+			CreatedBy:      user.ID,
+			CreatedAt:      createdAt,
+			Throwaway:      throwaway,
+		})
+	}
+	old := database.Now().Add(-25 * time.Hour)
+	oldThrowaway := newVersion(true, old)
+	newThrowaway := newVersion(true, database.Now())
+	oldVersion := newVersion(false, old)
+
+	err = db.DeleteOldThrowawayTemplateVersions(ctx)
+	require.NoError(t, err)
+
+	_, err = db.GetTemplateVersionByID(ctx, oldThrowaway.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = db.GetTemplateVersionByID(ctx, newThrowaway.ID)
+	require.NoError(t, err)
+	_, err = db.GetTemplateVersionByID(ctx, oldVersion.ID)
+	require.NoError(t, err)
+}
+
+func TestGetWorkspacesDueForAutostop(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	sqlDB := testSQLDB(t)
+	err := migrations.Up(sqlDB)
+	require.NoError(t, err)
+	db := database.New(sqlDB)
+	ctx := testutil.Context(t, testutil.WaitLong)
+
+	org := dbgen.Organization(t, db, database.Organization{})
+	user := dbgen.User(t, db, database.User{})
+	template := dbgen.Template(t, db, database.Template{
+		OrganizationID: org.ID,
+		CreatedBy:      user.ID,
+	})
+	version := dbgen.TemplateVersion(t, db, database.TemplateVersion{
+		OrganizationID: org.ID,
+		TemplateID:     uuid.NullUUID{UUID: template.ID, Valid: true},
+		CreatedBy:      user.ID,
+	})
+	newWorkspace := func() database.Workspace {
+		return dbgen.Workspace(t, db, database.Workspace{
+			OwnerID:        user.ID,
+			OrganizationID: org.ID,
+			TemplateID:     template.ID,
+		})
+	}
+
+	now := database.Now()
+	succeeded := database.ProvisionerJob{
+		StartedAt:   sql.NullTime{Time: now, Valid: true},
+		CompletedAt: sql.NullTime{Time: now, Valid: true},
+	}
+	// build inserts a build directly, as dbgen replaces a zero deadline.
+	build := func(ws database.Workspace, number int32, trans database.WorkspaceTransition, deadline time.Time, job database.ProvisionerJob) uuid.UUID {
+		job.OrganizationID = org.ID
+		job.InitiatorID = user.ID
+		job = dbgen.ProvisionerJob(t, db, job)
+		id := uuid.New()
+		err := db.InsertWorkspaceBuild(ctx, database.InsertWorkspaceBuildParams{
+			ID:                id,
+			CreatedAt:         now,
+			UpdatedAt:         now,
+			WorkspaceID:       ws.ID,
+			TemplateVersionID: version.ID,
+			BuildNumber:       number,
+			Transition:        trans,
+			InitiatorID:       user.ID,
+			JobID:             job.ID,
+			Deadline:          deadline,
+			Reason:            database.BuildReasonInitiator,
+		})
+		require.NoError(t, err)
+		return id
+	}
+
+	pastDeadline := now.Add(-time.Hour)
+	past := newWorkspace()
+	pastBuild := build(past, 1, database.WorkspaceTransitionStart, pastDeadline, succeeded)
+	future := newWorkspace()
+	_ = build(future, 1, database.WorkspaceTransitionStart, now.Add(time.Hour), succeeded)
+	noDeadline := newWorkspace()
+	_ = build(noDeadline, 1, database.WorkspaceTransitionStart, time.Time{}, succeeded)
+
+	// Workspaces that aren't running are ignored, even past their deadline.
+	stopped := newWorkspace()
+	_ = build(stopped, 1, database.WorkspaceTransitionStart, pastDeadline, succeeded)
+	_ = build(stopped, 2, database.WorkspaceTransitionStop, pastDeadline, succeeded)
+	failed := newWorkspace()
+	_ = build(failed, 1, database.WorkspaceTransitionStart, pastDeadline, database.ProvisionerJob{
+		StartedAt:   sql.NullTime{Time: now, Valid: true},
+		CompletedAt: sql.NullTime{Time: now, Valid: true},
+		Error:       sql.NullString{String: "failed", Valid: true},
+	})
+	starting := newWorkspace()
+	_ = build(starting, 1, database.WorkspaceTransitionStart, pastDeadline, database.ProvisionerJob{})
+
+	rows, err := db.GetWorkspacesDueForAutostop(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, past.ID, rows[0].ID)
+	require.Equal(t, pastBuild, rows[0].LatestBuildID)
+	require.WithinDuration(t, pastDeadline, rows[0].LatestBuildDeadline, time.Second)
+}
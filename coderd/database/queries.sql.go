@@ -1066,7 +1066,7 @@ func (q *sqlQuerier) DeleteGroupMembersByOrgAndUser(ctx context.Context, arg Del
 
 const getGroupMembers = `-- name: GetGroupMembers :many
 SELECT
-	users.id, users.email, users.username, users.hashed_password, users.created_at, users.updated_at, users.status, users.rbac_roles, users.login_type, users.avatar_url, users.deleted, users.last_seen_at, users.quiet_hours_schedule
+	users.id, users.email, users.username, users.hashed_password, users.created_at, users.updated_at, users.status, users.rbac_roles, users.login_type, users.avatar_url, users.deleted, users.last_seen_at, users.quiet_hours_schedule, users.name
 FROM
 	users
 JOIN
@@ -1104,6 +1104,7 @@ func (q *sqlQuerier) GetGroupMembers(ctx context.Context, groupID uuid.UUID) ([]
 			&i.Deleted,
 			&i.LastSeenAt,
 			&i.QuietHoursSchedule,
+			&i.Name,
 		); err != nil {
 			return nil, err
 		}
@@ -1273,6 +1274,51 @@ func (q *sqlQuerier) GetGroupsByOrganizationID(ctx context.Context, organization
 	return items, nil
 }
 
+const getUserGroups = `-- name: GetUserGroups :many
+SELECT
+	groups.id, groups.name, groups.organization_id, groups.avatar_url, groups.quota_allowance
+FROM
+	groups
+JOIN
+	group_members ON group_members.group_id = groups.id
+WHERE
+	group_members.user_id = $1
+ORDER BY
+	groups.name ASC
+`
+
+// Returns the groups the user is an explicit member of, across all
+// organizations. The "Everyone" group of an organization has no explicit
+// members, so it is not included.
+func (q *sqlQuerier) GetUserGroups(ctx context.Context, userID uuid.UUID) ([]Group, error) {
+	rows, err := q.db.QueryContext(ctx, getUserGroups, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Group
+	for rows.Next() {
+		var i Group
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.OrganizationID,
+			&i.AvatarURL,
+			&i.QuotaAllowance,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const insertAllUsersGroup = `-- name: InsertAllUsersGroup :one
 INSERT INTO groups (
 	id,
@@ -2381,12 +2427,13 @@ WHERE
 			-- Ensure the caller satisfies all job tags.
 			AND nested.tags <@ $4 :: jsonb
 		ORDER BY
+			nested.priority DESC,
 			nested.created_at
 		FOR UPDATE
 		SKIP LOCKED
 		LIMIT
 			1
-	) RETURNING id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata
+	) RETURNING id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata, priority, pending_deadline
 `
 
 type AcquireProvisionerJobParams struct {
@@ -2429,13 +2476,125 @@ func (q *sqlQuerier) AcquireProvisionerJob(ctx context.Context, arg AcquireProvi
 		&i.Tags,
 		&i.ErrorCode,
 		&i.TraceMetadata,
+		&i.Priority,
+		&i.PendingDeadline,
 	)
 	return i, err
 }
 
+const failStalePendingJobs = `-- name: FailStalePendingJobs :many
+UPDATE
+	provisioner_jobs
+SET
+	updated_at = $1 :: timestamptz,
+	started_at = COALESCE(started_at, $1 :: timestamptz),
+	completed_at = $1 :: timestamptz,
+	error = 'Coder: Build was not picked up by a provisioner daemon before its pending timeout.'
+WHERE
+	started_at IS NULL
+	AND canceled_at IS NULL
+	AND completed_at IS NULL
+	AND pending_deadline <= $1 :: timestamptz
+RETURNING id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata, priority, pending_deadline
+`
+
+// Fails the jobs that have not been acquired by a provisioner daemon by their
+// pending deadline. The jobs are marked as started too, so that they read as
+// failed rather than pending.
+func (q *sqlQuerier) FailStalePendingJobs(ctx context.Context, now time.Time) ([]ProvisionerJob, error) {
+	rows, err := q.db.QueryContext(ctx, failStalePendingJobs, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ProvisionerJob
+	for rows.Next() {
+		var i ProvisionerJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartedAt,
+			&i.CanceledAt,
+			&i.CompletedAt,
+			&i.Error,
+			&i.OrganizationID,
+			&i.InitiatorID,
+			&i.Provisioner,
+			&i.StorageMethod,
+			&i.Type,
+			&i.Input,
+			&i.WorkerID,
+			&i.FileID,
+			&i.Tags,
+			&i.ErrorCode,
+			&i.TraceMetadata,
+			&i.Priority,
+			&i.PendingDeadline,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getActiveJobCountsByTemplate = `-- name: GetActiveJobCountsByTemplate :many
+SELECT
+	workspaces.template_id,
+	COUNT(*) AS count
+FROM
+	provisioner_jobs
+INNER JOIN
+	workspace_builds ON workspace_builds.job_id = provisioner_jobs.id
+INNER JOIN
+	workspaces ON workspaces.id = workspace_builds.workspace_id
+WHERE
+	provisioner_jobs.completed_at IS NULL
+	AND provisioner_jobs.canceled_at IS NULL
+GROUP BY
+	workspaces.template_id
+`
+
+type GetActiveJobCountsByTemplateRow struct {
+	TemplateID uuid.UUID `db:"template_id" json:"template_id"`
+	Count      int64     `db:"count" json:"count"`
+}
+
+// Returns the number of pending or running workspace build jobs for each
+// template.
+func (q *sqlQuerier) GetActiveJobCountsByTemplate(ctx context.Context) ([]GetActiveJobCountsByTemplateRow, error) {
+	rows, err := q.db.QueryContext(ctx, getActiveJobCountsByTemplate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetActiveJobCountsByTemplateRow
+	for rows.Next() {
+		var i GetActiveJobCountsByTemplateRow
+		if err := rows.Scan(&i.TemplateID, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getHungProvisionerJobs = `-- name: GetHungProvisionerJobs :many
 SELECT
-	id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata
+	id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata, priority, pending_deadline
 FROM
 	provisioner_jobs
 WHERE
@@ -2472,6 +2631,8 @@ func (q *sqlQuerier) GetHungProvisionerJobs(ctx context.Context, updatedAt time.
 			&i.Tags,
 			&i.ErrorCode,
 			&i.TraceMetadata,
+			&i.Priority,
+			&i.PendingDeadline,
 		); err != nil {
 			return nil, err
 		}
@@ -2488,7 +2649,7 @@ func (q *sqlQuerier) GetHungProvisionerJobs(ctx context.Context, updatedAt time.
 
 const getProvisionerJobByID = `-- name: GetProvisionerJobByID :one
 SELECT
-	id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata
+	id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata, priority, pending_deadline
 FROM
 	provisioner_jobs
 WHERE
@@ -2517,13 +2678,15 @@ func (q *sqlQuerier) GetProvisionerJobByID(ctx context.Context, id uuid.UUID) (P
 		&i.Tags,
 		&i.ErrorCode,
 		&i.TraceMetadata,
+		&i.Priority,
+		&i.PendingDeadline,
 	)
 	return i, err
 }
 
 const getProvisionerJobsByIDs = `-- name: GetProvisionerJobsByIDs :many
 SELECT
-	id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata
+	id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata, priority, pending_deadline
 FROM
 	provisioner_jobs
 WHERE
@@ -2558,6 +2721,8 @@ func (q *sqlQuerier) GetProvisionerJobsByIDs(ctx context.Context, ids []uuid.UUI
 			&i.Tags,
 			&i.ErrorCode,
 			&i.TraceMetadata,
+			&i.Priority,
+			&i.PendingDeadline,
 		); err != nil {
 			return nil, err
 		}
@@ -2575,7 +2740,7 @@ func (q *sqlQuerier) GetProvisionerJobsByIDs(ctx context.Context, ids []uuid.UUI
 const getProvisionerJobsByIDsWithQueuePosition = `-- name: GetProvisionerJobsByIDsWithQueuePosition :many
 WITH unstarted_jobs AS (
     SELECT
-        id, created_at
+        id, created_at, priority
     FROM
         provisioner_jobs
     WHERE
@@ -2584,7 +2749,7 @@ WITH unstarted_jobs AS (
 queue_position AS (
     SELECT
         id,
-        ROW_NUMBER() OVER (ORDER BY created_at ASC) AS queue_position
+        ROW_NUMBER() OVER (ORDER BY priority DESC, created_at ASC) AS queue_position
     FROM
         unstarted_jobs
 ),
@@ -2592,7 +2757,7 @@ queue_size AS (
 	SELECT COUNT(*) as count FROM unstarted_jobs
 )
 SELECT
-	pj.id, pj.created_at, pj.updated_at, pj.started_at, pj.canceled_at, pj.completed_at, pj.error, pj.organization_id, pj.initiator_id, pj.provisioner, pj.storage_method, pj.type, pj.input, pj.worker_id, pj.file_id, pj.tags, pj.error_code, pj.trace_metadata,
+	pj.id, pj.created_at, pj.updated_at, pj.started_at, pj.canceled_at, pj.completed_at, pj.error, pj.organization_id, pj.initiator_id, pj.provisioner, pj.storage_method, pj.type, pj.input, pj.worker_id, pj.file_id, pj.tags, pj.error_code, pj.trace_metadata, pj.priority, pj.pending_deadline,
     COALESCE(qp.queue_position, 0) AS queue_position,
     COALESCE(qs.count, 0) AS queue_size
 FROM
@@ -2639,6 +2804,8 @@ func (q *sqlQuerier) GetProvisionerJobsByIDsWithQueuePosition(ctx context.Contex
 			&i.ProvisionerJob.Tags,
 			&i.ProvisionerJob.ErrorCode,
 			&i.ProvisionerJob.TraceMetadata,
+			&i.ProvisionerJob.Priority,
+			&i.ProvisionerJob.PendingDeadline,
 			&i.QueuePosition,
 			&i.QueueSize,
 		); err != nil {
@@ -2656,7 +2823,7 @@ func (q *sqlQuerier) GetProvisionerJobsByIDsWithQueuePosition(ctx context.Contex
 }
 
 const getProvisionerJobsCreatedAfter = `-- name: GetProvisionerJobsCreatedAfter :many
-SELECT id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata FROM provisioner_jobs WHERE created_at > $1
+SELECT id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata, priority, pending_deadline FROM provisioner_jobs WHERE created_at > $1
 `
 
 func (q *sqlQuerier) GetProvisionerJobsCreatedAfter(ctx context.Context, createdAt time.Time) ([]ProvisionerJob, error) {
@@ -2687,6 +2854,8 @@ func (q *sqlQuerier) GetProvisionerJobsCreatedAfter(ctx context.Context, created
 			&i.Tags,
 			&i.ErrorCode,
 			&i.TraceMetadata,
+			&i.Priority,
+			&i.PendingDeadline,
 		); err != nil {
 			return nil, err
 		}
@@ -2715,25 +2884,29 @@ INSERT INTO
 		"type",
 		"input",
 		tags,
-		trace_metadata
+		trace_metadata,
+		priority,
+		pending_deadline
 	)
 VALUES
-	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id, created_at, updated_at, started_at, canceled_at, completed_at, error, organization_id, initiator_id, provisioner, storage_method, type, input, worker_id, file_id, tags, error_code, trace_metadata, priority, pending_deadline
 `
 
 type InsertProvisionerJobParams struct {
-	ID             uuid.UUID                `db:"id" json:"id"`
-	CreatedAt      time.Time                `db:"created_at" json:"created_at"`
-	UpdatedAt      time.Time                `db:"updated_at" json:"updated_at"`
-	OrganizationID uuid.UUID                `db:"organization_id" json:"organization_id"`
-	InitiatorID    uuid.UUID                `db:"initiator_id" json:"initiator_id"`
-	Provisioner    ProvisionerType          `db:"provisioner" json:"provisioner"`
-	StorageMethod  ProvisionerStorageMethod `db:"storage_method" json:"storage_method"`
-	FileID         uuid.UUID                `db:"file_id" json:"file_id"`
-	Type           ProvisionerJobType       `db:"type" json:"type"`
-	Input          json.RawMessage          `db:"input" json:"input"`
-	Tags           StringMap                `db:"tags" json:"tags"`
-	TraceMetadata  pqtype.NullRawMessage    `db:"trace_metadata" json:"trace_metadata"`
+	ID              uuid.UUID                `db:"id" json:"id"`
+	CreatedAt       time.Time                `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time                `db:"updated_at" json:"updated_at"`
+	OrganizationID  uuid.UUID                `db:"organization_id" json:"organization_id"`
+	InitiatorID     uuid.UUID                `db:"initiator_id" json:"initiator_id"`
+	Provisioner     ProvisionerType          `db:"provisioner" json:"provisioner"`
+	StorageMethod   ProvisionerStorageMethod `db:"storage_method" json:"storage_method"`
+	FileID          uuid.UUID                `db:"file_id" json:"file_id"`
+	Type            ProvisionerJobType       `db:"type" json:"type"`
+	Input           json.RawMessage          `db:"input" json:"input"`
+	Tags            StringMap                `db:"tags" json:"tags"`
+	TraceMetadata   pqtype.NullRawMessage    `db:"trace_metadata" json:"trace_metadata"`
+	Priority        int32                    `db:"priority" json:"priority"`
+	PendingDeadline sql.NullTime             `db:"pending_deadline" json:"pending_deadline"`
 }
 
 func (q *sqlQuerier) InsertProvisionerJob(ctx context.Context, arg InsertProvisionerJobParams) (ProvisionerJob, error) {
@@ -2750,6 +2923,8 @@ func (q *sqlQuerier) InsertProvisionerJob(ctx context.Context, arg InsertProvisi
 		arg.Input,
 		arg.Tags,
 		arg.TraceMetadata,
+		arg.Priority,
+		arg.PendingDeadline,
 	)
 	var i ProvisionerJob
 	err := row.Scan(
@@ -2771,6 +2946,8 @@ func (q *sqlQuerier) InsertProvisionerJob(ctx context.Context, arg InsertProvisi
 		&i.Tags,
 		&i.ErrorCode,
 		&i.TraceMetadata,
+		&i.Priority,
+		&i.PendingDeadline,
 	)
 	return i, err
 }
@@ -3936,6 +4113,79 @@ func (q *sqlQuerier) UpsertTailnetCoordinator(ctx context.Context, id uuid.UUID)
 	return i, err
 }
 
+const getMostUsedTemplates = `-- name: GetMostUsedTemplates :many
+SELECT
+	templates.id AS template_id,
+	templates.name AS template_name,
+	templates.display_name AS template_display_name,
+	templates.icon AS template_icon,
+	COUNT(*) AS build_count
+FROM
+	workspace_builds
+JOIN workspaces ON
+	workspace_builds.workspace_id = workspaces.id
+JOIN templates ON
+	workspaces.template_id = templates.id
+WHERE
+	templates.deleted = false AND
+	workspace_builds.created_at >= $1::timestamptz AND
+	workspace_builds.created_at < $2::timestamptz
+GROUP BY
+	templates.id
+ORDER BY
+	build_count DESC, templates.name ASC
+LIMIT
+	-- A null limit means "no limit", so 0 means return all
+	NULLIF($3 :: int, 0)
+`
+
+type GetMostUsedTemplatesParams struct {
+	StartTime time.Time `db:"start_time" json:"start_time"`
+	EndTime   time.Time `db:"end_time" json:"end_time"`
+	LimitOpt  int32     `db:"limit_opt" json:"limit_opt"`
+}
+
+type GetMostUsedTemplatesRow struct {
+	TemplateID          uuid.UUID `db:"template_id" json:"template_id"`
+	TemplateName        string    `db:"template_name" json:"template_name"`
+	TemplateDisplayName string    `db:"template_display_name" json:"template_display_name"`
+	TemplateIcon        string    `db:"template_icon" json:"template_icon"`
+	BuildCount          int64     `db:"build_count" json:"build_count"`
+}
+
+// GetMostUsedTemplates returns the templates ranked by the number of workspace
+// builds created between start and end time, most used first, along with the
+// number of builds. Templates without builds in the period and deleted
+// templates are omitted.
+func (q *sqlQuerier) GetMostUsedTemplates(ctx context.Context, arg GetMostUsedTemplatesParams) ([]GetMostUsedTemplatesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getMostUsedTemplates, arg.StartTime, arg.EndTime, arg.LimitOpt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMostUsedTemplatesRow
+	for rows.Next() {
+		var i GetMostUsedTemplatesRow
+		if err := rows.Scan(
+			&i.TemplateID,
+			&i.TemplateName,
+			&i.TemplateDisplayName,
+			&i.TemplateIcon,
+			&i.BuildCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getTemplateAverageBuildTime = `-- name: GetTemplateAverageBuildTime :one
 WITH build_times AS (
 SELECT
@@ -3996,6 +4246,73 @@ func (q *sqlQuerier) GetTemplateAverageBuildTime(ctx context.Context, arg GetTem
 	return i, err
 }
 
+const getTemplateBuildsPerDay = `-- name: GetTemplateBuildsPerDay :many
+SELECT
+	date_trunc('day', workspace_builds.created_at)::timestamptz AS day,
+	COUNT(*) AS count,
+	COUNT(*) FILTER (WHERE workspace_builds.transition = 'start') AS start_count,
+	COUNT(*) FILTER (WHERE workspace_builds.transition = 'stop') AS stop_count,
+	COUNT(*) FILTER (WHERE workspace_builds.transition = 'delete') AS delete_count
+FROM
+	workspace_builds
+JOIN workspaces ON
+	workspace_builds.workspace_id = workspaces.id
+WHERE
+	workspaces.template_id = $1 AND
+	workspace_builds.created_at >= $2::timestamptz AND
+	workspace_builds.created_at < $3::timestamptz
+GROUP BY
+	day
+ORDER BY
+	day ASC
+`
+
+type GetTemplateBuildsPerDayParams struct {
+	TemplateID uuid.UUID `db:"template_id" json:"template_id"`
+	StartTime  time.Time `db:"start_time" json:"start_time"`
+	EndTime    time.Time `db:"end_time" json:"end_time"`
+}
+
+type GetTemplateBuildsPerDayRow struct {
+	Day         time.Time `db:"day" json:"day"`
+	Count       int64     `db:"count" json:"count"`
+	StartCount  int64     `db:"start_count" json:"start_count"`
+	StopCount   int64     `db:"stop_count" json:"stop_count"`
+	DeleteCount int64     `db:"delete_count" json:"delete_count"`
+}
+
+// GetTemplateBuildsPerDay returns the number of workspace builds created per
+// day for workspaces of the given template between start and end time, along
+// with a breakdown per transition. Days without any builds are omitted.
+func (q *sqlQuerier) GetTemplateBuildsPerDay(ctx context.Context, arg GetTemplateBuildsPerDayParams) ([]GetTemplateBuildsPerDayRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTemplateBuildsPerDay, arg.TemplateID, arg.StartTime, arg.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTemplateBuildsPerDayRow
+	for rows.Next() {
+		var i GetTemplateBuildsPerDayRow
+		if err := rows.Scan(
+			&i.Day,
+			&i.Count,
+			&i.StartCount,
+			&i.StopCount,
+			&i.DeleteCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getTemplateByID = `-- name: GetTemplateByID :one
 SELECT
 	id, created_at, updated_at, organization_id, deleted, name, provisioner, active_version_id, description, default_ttl, created_by, icon, user_acl, group_acl, display_name, allow_user_cancel_workspace_jobs, max_ttl, allow_user_autostart, allow_user_autostop, failure_ttl, inactivity_ttl, locked_ttl, restart_requirement_days_of_week, restart_requirement_weeks, created_by_avatar_url, created_by_username
@@ -4175,6 +4492,28 @@ WHERE
 			id = ANY($4)
 		ELSE true
 	END
+	-- Filter out templates with any workspace builds since the cutoff
+	AND CASE
+		WHEN $5 :: timestamp with time zone != '0001-01-01 00:00:00Z' THEN
+			NOT EXISTS (
+				SELECT
+					1
+				FROM
+					workspace_builds
+				INNER JOIN
+					workspaces ON workspaces.id = workspace_builds.workspace_id
+				WHERE
+					workspaces.template_id = templates.id
+					AND workspace_builds.created_at > $5
+			)
+		ELSE true
+	END
+	-- Filter by created_by
+	AND CASE
+		WHEN $6 :: uuid != '00000000-0000-0000-0000-000000000000'::uuid THEN
+			created_by = $6
+		ELSE true
+	END
   -- Authorize Filter clause will be injected below in GetAuthorizedTemplates
   -- @authorize_filter
 ORDER BY (name, id) ASC
@@ -4185,6 +4524,8 @@ type GetTemplatesWithFilterParams struct {
 	OrganizationID uuid.UUID   `db:"organization_id" json:"organization_id"`
 	ExactName      string      `db:"exact_name" json:"exact_name"`
 	IDs            []uuid.UUID `db:"ids" json:"ids"`
+	NoBuildsSince  time.Time   `db:"no_builds_since" json:"no_builds_since"`
+	CreatedBy      uuid.UUID   `db:"created_by" json:"created_by"`
 }
 
 func (q *sqlQuerier) GetTemplatesWithFilter(ctx context.Context, arg GetTemplatesWithFilterParams) ([]Template, error) {
@@ -4193,6 +4534,8 @@ func (q *sqlQuerier) GetTemplatesWithFilter(ctx context.Context, arg GetTemplate
 		arg.OrganizationID,
 		arg.ExactName,
 		pq.Array(arg.IDs),
+		arg.NoBuildsSince,
+		arg.CreatedBy,
 	)
 	if err != nil {
 		return nil, err
@@ -4601,9 +4944,20 @@ func (q *sqlQuerier) InsertTemplateVersionParameter(ctx context.Context, arg Ins
 	return i, err
 }
 
+const deleteOldThrowawayTemplateVersions = `-- name: DeleteOldThrowawayTemplateVersions :exec
+DELETE FROM template_versions WHERE throwaway AND created_at < NOW() - INTERVAL '1 day'
+`
+
+// Throwaway versions are only needed while they are inspected, so they are
+// purged a day after they are created.
+func (q *sqlQuerier) DeleteOldThrowawayTemplateVersions(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteOldThrowawayTemplateVersions)
+	return err
+}
+
 const getPreviousTemplateVersion = `-- name: GetPreviousTemplateVersion :one
 SELECT
-	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, created_by_avatar_url, created_by_username
+	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, throwaway, created_by_avatar_url, created_by_username
 FROM
 	template_version_with_user AS template_versions
 WHERE
@@ -4639,6 +4993,7 @@ func (q *sqlQuerier) GetPreviousTemplateVersion(ctx context.Context, arg GetPrev
 		&i.CreatedBy,
 		pq.Array(&i.GitAuthProviders),
 		&i.Message,
+		&i.Throwaway,
 		&i.CreatedByAvatarURL,
 		&i.CreatedByUsername,
 	)
@@ -4647,7 +5002,7 @@ func (q *sqlQuerier) GetPreviousTemplateVersion(ctx context.Context, arg GetPrev
 
 const getTemplateVersionByID = `-- name: GetTemplateVersionByID :one
 SELECT
-	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, created_by_avatar_url, created_by_username
+	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, throwaway, created_by_avatar_url, created_by_username
 FROM
 	template_version_with_user AS template_versions
 WHERE
@@ -4669,6 +5024,7 @@ func (q *sqlQuerier) GetTemplateVersionByID(ctx context.Context, id uuid.UUID) (
 		&i.CreatedBy,
 		pq.Array(&i.GitAuthProviders),
 		&i.Message,
+		&i.Throwaway,
 		&i.CreatedByAvatarURL,
 		&i.CreatedByUsername,
 	)
@@ -4677,7 +5033,7 @@ func (q *sqlQuerier) GetTemplateVersionByID(ctx context.Context, id uuid.UUID) (
 
 const getTemplateVersionByJobID = `-- name: GetTemplateVersionByJobID :one
 SELECT
-	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, created_by_avatar_url, created_by_username
+	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, throwaway, created_by_avatar_url, created_by_username
 FROM
 	template_version_with_user AS template_versions
 WHERE
@@ -4699,6 +5055,7 @@ func (q *sqlQuerier) GetTemplateVersionByJobID(ctx context.Context, jobID uuid.U
 		&i.CreatedBy,
 		pq.Array(&i.GitAuthProviders),
 		&i.Message,
+		&i.Throwaway,
 		&i.CreatedByAvatarURL,
 		&i.CreatedByUsername,
 	)
@@ -4707,7 +5064,7 @@ func (q *sqlQuerier) GetTemplateVersionByJobID(ctx context.Context, jobID uuid.U
 
 const getTemplateVersionByTemplateIDAndName = `-- name: GetTemplateVersionByTemplateIDAndName :one
 SELECT
-	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, created_by_avatar_url, created_by_username
+	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, throwaway, created_by_avatar_url, created_by_username
 FROM
 	template_version_with_user AS template_versions
 WHERE
@@ -4735,6 +5092,7 @@ func (q *sqlQuerier) GetTemplateVersionByTemplateIDAndName(ctx context.Context,
 		&i.CreatedBy,
 		pq.Array(&i.GitAuthProviders),
 		&i.Message,
+		&i.Throwaway,
 		&i.CreatedByAvatarURL,
 		&i.CreatedByUsername,
 	)
@@ -4743,7 +5101,7 @@ func (q *sqlQuerier) GetTemplateVersionByTemplateIDAndName(ctx context.Context,
 
 const getTemplateVersionsByIDs = `-- name: GetTemplateVersionsByIDs :many
 SELECT
-	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, created_by_avatar_url, created_by_username
+	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, throwaway, created_by_avatar_url, created_by_username
 FROM
 	template_version_with_user AS template_versions
 WHERE
@@ -4771,6 +5129,7 @@ func (q *sqlQuerier) GetTemplateVersionsByIDs(ctx context.Context, ids []uuid.UU
 			&i.CreatedBy,
 			pq.Array(&i.GitAuthProviders),
 			&i.Message,
+			&i.Throwaway,
 			&i.CreatedByAvatarURL,
 			&i.CreatedByUsername,
 		); err != nil {
@@ -4789,7 +5148,7 @@ func (q *sqlQuerier) GetTemplateVersionsByIDs(ctx context.Context, ids []uuid.UU
 
 const getTemplateVersionsByTemplateID = `-- name: GetTemplateVersionsByTemplateID :many
 SELECT
-	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, created_by_avatar_url, created_by_username
+	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, throwaway, created_by_avatar_url, created_by_username
 FROM
 	template_version_with_user AS template_versions
 WHERE
@@ -4855,6 +5214,7 @@ func (q *sqlQuerier) GetTemplateVersionsByTemplateID(ctx context.Context, arg Ge
 			&i.CreatedBy,
 			pq.Array(&i.GitAuthProviders),
 			&i.Message,
+			&i.Throwaway,
 			&i.CreatedByAvatarURL,
 			&i.CreatedByUsername,
 		); err != nil {
@@ -4872,7 +5232,7 @@ func (q *sqlQuerier) GetTemplateVersionsByTemplateID(ctx context.Context, arg Ge
 }
 
 const getTemplateVersionsCreatedAfter = `-- name: GetTemplateVersionsCreatedAfter :many
-SELECT id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, created_by_avatar_url, created_by_username FROM template_version_with_user AS template_versions WHERE created_at > $1
+SELECT id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, throwaway, created_by_avatar_url, created_by_username FROM template_version_with_user AS template_versions WHERE created_at > $1
 `
 
 func (q *sqlQuerier) GetTemplateVersionsCreatedAfter(ctx context.Context, createdAt time.Time) ([]TemplateVersion, error) {
@@ -4896,6 +5256,72 @@ func (q *sqlQuerier) GetTemplateVersionsCreatedAfter(ctx context.Context, create
 			&i.CreatedBy,
 			pq.Array(&i.GitAuthProviders),
 			&i.Message,
+			&i.Throwaway,
+			&i.CreatedByAvatarURL,
+			&i.CreatedByUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnusedTemplateVersions = `-- name: GetUnusedTemplateVersions :many
+SELECT
+	id, template_id, organization_id, created_at, updated_at, name, readme, job_id, created_by, git_auth_providers, message, throwaway, created_by_avatar_url, created_by_username
+FROM
+	template_version_with_user AS template_versions
+WHERE
+	template_versions.template_id = $1 :: uuid
+	AND NOT EXISTS (
+		SELECT
+			1
+		FROM
+			workspace_builds
+		JOIN
+			provisioner_jobs ON provisioner_jobs.id = workspace_builds.job_id
+		WHERE
+			workspace_builds.template_version_id = template_versions.id
+			AND provisioner_jobs.completed_at IS NOT NULL
+			AND provisioner_jobs.canceled_at IS NULL
+			AND COALESCE(provisioner_jobs.error, '') = ''
+	)
+ORDER BY
+	template_versions.created_at ASC, template_versions.id ASC
+`
+
+// Returns the versions of the template that no workspace has been built with
+// successfully, i.e. without a build whose provisioner job completed without
+// error. These are candidates for cleanup.
+func (q *sqlQuerier) GetUnusedTemplateVersions(ctx context.Context, templateID uuid.UUID) ([]TemplateVersion, error) {
+	rows, err := q.db.QueryContext(ctx, getUnusedTemplateVersions, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TemplateVersion
+	for rows.Next() {
+		var i TemplateVersion
+		if err := rows.Scan(
+			&i.ID,
+			&i.TemplateID,
+			&i.OrganizationID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Readme,
+			&i.JobID,
+			&i.CreatedBy,
+			pq.Array(&i.GitAuthProviders),
+			&i.Message,
+			&i.Throwaway,
 			&i.CreatedByAvatarURL,
 			&i.CreatedByUsername,
 		); err != nil {
@@ -4924,10 +5350,11 @@ INSERT INTO
 		message,
 		readme,
 		job_id,
-		created_by
+		created_by,
+		throwaway
 	)
 VALUES
-	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 `
 
 type InsertTemplateVersionParams struct {
@@ -4941,6 +5368,7 @@ type InsertTemplateVersionParams struct {
 	Readme         string        `db:"readme" json:"readme"`
 	JobID          uuid.UUID     `db:"job_id" json:"job_id"`
 	CreatedBy      uuid.UUID     `db:"created_by" json:"created_by"`
+	Throwaway      bool          `db:"throwaway" json:"throwaway"`
 }
 
 func (q *sqlQuerier) InsertTemplateVersion(ctx context.Context, arg InsertTemplateVersionParams) error {
@@ -4955,6 +5383,7 @@ func (q *sqlQuerier) InsertTemplateVersion(ctx context.Context, arg InsertTempla
 		arg.Readme,
 		arg.JobID,
 		arg.CreatedBy,
+		arg.Throwaway,
 	)
 	return err
 }
@@ -5373,7 +5802,7 @@ func (q *sqlQuerier) GetAuthorizationUserRoles(ctx context.Context, userID uuid.
 
 const getUserByEmailOrUsername = `-- name: GetUserByEmailOrUsername :one
 SELECT
-	id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule
+	id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, name
 FROM
 	users
 WHERE
@@ -5405,13 +5834,14 @@ func (q *sqlQuerier) GetUserByEmailOrUsername(ctx context.Context, arg GetUserBy
 		&i.Deleted,
 		&i.LastSeenAt,
 		&i.QuietHoursSchedule,
+		&i.Name,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
 SELECT
-	id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule
+	id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, name
 FROM
 	users
 WHERE
@@ -5437,6 +5867,7 @@ func (q *sqlQuerier) GetUserByID(ctx context.Context, id uuid.UUID) (User, error
 		&i.Deleted,
 		&i.LastSeenAt,
 		&i.QuietHoursSchedule,
+		&i.Name,
 	)
 	return i, err
 }
@@ -5459,7 +5890,7 @@ func (q *sqlQuerier) GetUserCount(ctx context.Context) (int64, error) {
 
 const getUsers = `-- name: GetUsers :many
 SELECT
-	id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, COUNT(*) OVER() AS count
+	id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, name, COUNT(*) OVER() AS count
 FROM
 	users
 WHERE
@@ -5556,6 +5987,7 @@ type GetUsersRow struct {
 	Deleted            bool           `db:"deleted" json:"deleted"`
 	LastSeenAt         time.Time      `db:"last_seen_at" json:"last_seen_at"`
 	QuietHoursSchedule string         `db:"quiet_hours_schedule" json:"quiet_hours_schedule"`
+	Name               string         `db:"name" json:"name"`
 	Count              int64          `db:"count" json:"count"`
 }
 
@@ -5592,6 +6024,7 @@ func (q *sqlQuerier) GetUsers(ctx context.Context, arg GetUsersParams) ([]GetUse
 			&i.Deleted,
 			&i.LastSeenAt,
 			&i.QuietHoursSchedule,
+			&i.Name,
 			&i.Count,
 		); err != nil {
 			return nil, err
@@ -5608,7 +6041,7 @@ func (q *sqlQuerier) GetUsers(ctx context.Context, arg GetUsersParams) ([]GetUse
 }
 
 const getUsersByIDs = `-- name: GetUsersByIDs :many
-SELECT id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule FROM users WHERE id = ANY($1 :: uuid [ ])
+SELECT id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, name FROM users WHERE id = ANY($1 :: uuid [ ])
 `
 
 // This shouldn't check for deleted, because it's frequently used
@@ -5637,6 +6070,7 @@ func (q *sqlQuerier) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]User
 			&i.Deleted,
 			&i.LastSeenAt,
 			&i.QuietHoursSchedule,
+			&i.Name,
 		); err != nil {
 			return nil, err
 		}
@@ -5661,10 +6095,11 @@ INSERT INTO
 		created_at,
 		updated_at,
 		rbac_roles,
-		login_type
+		login_type,
+		name
 	)
 VALUES
-	($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule
+	($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, name
 `
 
 type InsertUserParams struct {
@@ -5676,6 +6111,7 @@ type InsertUserParams struct {
 	UpdatedAt      time.Time      `db:"updated_at" json:"updated_at"`
 	RBACRoles      pq.StringArray `db:"rbac_roles" json:"rbac_roles"`
 	LoginType      LoginType      `db:"login_type" json:"login_type"`
+	Name           string         `db:"name" json:"name"`
 }
 
 func (q *sqlQuerier) InsertUser(ctx context.Context, arg InsertUserParams) (User, error) {
@@ -5688,6 +6124,7 @@ func (q *sqlQuerier) InsertUser(ctx context.Context, arg InsertUserParams) (User
 		arg.UpdatedAt,
 		arg.RBACRoles,
 		arg.LoginType,
+		arg.Name,
 	)
 	var i User
 	err := row.Scan(
@@ -5704,6 +6141,7 @@ func (q *sqlQuerier) InsertUser(ctx context.Context, arg InsertUserParams) (User
 		&i.Deleted,
 		&i.LastSeenAt,
 		&i.QuietHoursSchedule,
+		&i.Name,
 	)
 	return i, err
 }
@@ -5753,7 +6191,7 @@ SET
 	last_seen_at = $2,
 	updated_at = $3
 WHERE
-	id = $1 RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule
+	id = $1 RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, name
 `
 
 type UpdateUserLastSeenAtParams struct {
@@ -5779,6 +6217,7 @@ func (q *sqlQuerier) UpdateUserLastSeenAt(ctx context.Context, arg UpdateUserLas
 		&i.Deleted,
 		&i.LastSeenAt,
 		&i.QuietHoursSchedule,
+		&i.Name,
 	)
 	return i, err
 }
@@ -5796,7 +6235,7 @@ SET
 		'':: bytea
 	END
 WHERE
-	id = $2 RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule
+	id = $2 RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, name
 `
 
 type UpdateUserLoginTypeParams struct {
@@ -5821,6 +6260,7 @@ func (q *sqlQuerier) UpdateUserLoginType(ctx context.Context, arg UpdateUserLogi
 		&i.Deleted,
 		&i.LastSeenAt,
 		&i.QuietHoursSchedule,
+		&i.Name,
 	)
 	return i, err
 }
@@ -5834,7 +6274,7 @@ SET
 	avatar_url = $4,
 	updated_at = $5
 WHERE
-	id = $1 RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule
+	id = $1 RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, name
 `
 
 type UpdateUserProfileParams struct {
@@ -5868,6 +6308,7 @@ func (q *sqlQuerier) UpdateUserProfile(ctx context.Context, arg UpdateUserProfil
 		&i.Deleted,
 		&i.LastSeenAt,
 		&i.QuietHoursSchedule,
+		&i.Name,
 	)
 	return i, err
 }
@@ -5879,7 +6320,7 @@ SET
 	quiet_hours_schedule = $2
 WHERE
 	id = $1
-RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule
+RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, name
 `
 
 type UpdateUserQuietHoursScheduleParams struct {
@@ -5904,6 +6345,7 @@ func (q *sqlQuerier) UpdateUserQuietHoursSchedule(ctx context.Context, arg Updat
 		&i.Deleted,
 		&i.LastSeenAt,
 		&i.QuietHoursSchedule,
+		&i.Name,
 	)
 	return i, err
 }
@@ -5916,7 +6358,7 @@ SET
 	rbac_roles = ARRAY(SELECT DISTINCT UNNEST($1 :: text[]))
 WHERE
 	id = $2
-RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule
+RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, name
 `
 
 type UpdateUserRolesParams struct {
@@ -5941,6 +6383,7 @@ func (q *sqlQuerier) UpdateUserRoles(ctx context.Context, arg UpdateUserRolesPar
 		&i.Deleted,
 		&i.LastSeenAt,
 		&i.QuietHoursSchedule,
+		&i.Name,
 	)
 	return i, err
 }
@@ -5952,7 +6395,7 @@ SET
 	status = $2,
 	updated_at = $3
 WHERE
-	id = $1 RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule
+	id = $1 RETURNING id, email, username, hashed_password, created_at, updated_at, status, rbac_roles, login_type, avatar_url, deleted, last_seen_at, quiet_hours_schedule, name
 `
 
 type UpdateUserStatusParams struct {
@@ -5978,6 +6421,7 @@ func (q *sqlQuerier) UpdateUserStatus(ctx context.Context, arg UpdateUserStatusP
 		&i.Deleted,
 		&i.LastSeenAt,
 		&i.QuietHoursSchedule,
+		&i.Name,
 	)
 	return i, err
 }
@@ -7505,13 +7949,118 @@ func (q *sqlQuerier) UpdateWorkspaceAppHealthByID(ctx context.Context, arg Updat
 	return err
 }
 
+const getWorkspaceBuildIntentsByWorkspaceID = `-- name: GetWorkspaceBuildIntentsByWorkspaceID :many
+SELECT
+	id, created_at, workspace_id, initiator_id, transition, reason, template_version_id, workspace_build_id
+FROM
+	workspace_build_intents
+WHERE
+	workspace_id = $1
+ORDER BY
+	created_at DESC
+`
+
+func (q *sqlQuerier) GetWorkspaceBuildIntentsByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) ([]WorkspaceBuildIntent, error) {
+	rows, err := q.db.QueryContext(ctx, getWorkspaceBuildIntentsByWorkspaceID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WorkspaceBuildIntent
+	for rows.Next() {
+		var i WorkspaceBuildIntent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.WorkspaceID,
+			&i.InitiatorID,
+			&i.Transition,
+			&i.Reason,
+			&i.TemplateVersionID,
+			&i.WorkspaceBuildID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertWorkspaceBuildIntent = `-- name: InsertWorkspaceBuildIntent :exec
+INSERT INTO
+	workspace_build_intents (
+		id,
+		created_at,
+		workspace_id,
+		initiator_id,
+		transition,
+		reason,
+		template_version_id
+	)
+VALUES
+	($1, $2, $3, $4, $5, $6, $7)
+`
+
+type InsertWorkspaceBuildIntentParams struct {
+	ID                uuid.UUID           `db:"id" json:"id"`
+	CreatedAt         time.Time           `db:"created_at" json:"created_at"`
+	WorkspaceID       uuid.UUID           `db:"workspace_id" json:"workspace_id"`
+	InitiatorID       uuid.UUID           `db:"initiator_id" json:"initiator_id"`
+	Transition        WorkspaceTransition `db:"transition" json:"transition"`
+	Reason            BuildReason         `db:"reason" json:"reason"`
+	TemplateVersionID uuid.NullUUID       `db:"template_version_id" json:"template_version_id"`
+}
+
+func (q *sqlQuerier) InsertWorkspaceBuildIntent(ctx context.Context, arg InsertWorkspaceBuildIntentParams) error {
+	_, err := q.db.ExecContext(ctx, insertWorkspaceBuildIntent,
+		arg.ID,
+		arg.CreatedAt,
+		arg.WorkspaceID,
+		arg.InitiatorID,
+		arg.Transition,
+		arg.Reason,
+		arg.TemplateVersionID,
+	)
+	return err
+}
+
+const updateWorkspaceBuildIntentBuildByID = `-- name: UpdateWorkspaceBuildIntentBuildByID :exec
+UPDATE
+	workspace_build_intents
+SET
+	workspace_build_id = $3
+WHERE
+	id = $1
+	AND workspace_id = $2
+`
+
+type UpdateWorkspaceBuildIntentBuildByIDParams struct {
+	ID               uuid.UUID     `db:"id" json:"id"`
+	WorkspaceID      uuid.UUID     `db:"workspace_id" json:"workspace_id"`
+	WorkspaceBuildID uuid.NullUUID `db:"workspace_build_id" json:"workspace_build_id"`
+}
+
+func (q *sqlQuerier) UpdateWorkspaceBuildIntentBuildByID(ctx context.Context, arg UpdateWorkspaceBuildIntentBuildByIDParams) error {
+	_, err := q.db.ExecContext(ctx, updateWorkspaceBuildIntentBuildByID, arg.ID, arg.WorkspaceID, arg.WorkspaceBuildID)
+	return err
+}
+
 const getWorkspaceBuildParameters = `-- name: GetWorkspaceBuildParameters :many
 SELECT
     workspace_build_id, name, value
 FROM
     workspace_build_parameters
 WHERE
-    workspace_build_id = $1
+    workspace_build_id = COALESCE(
+        (SELECT parameters_from_build_id FROM workspace_builds WHERE id = $1 :: uuid),
+        $1 :: uuid
+    )
 `
 
 func (q *sqlQuerier) GetWorkspaceBuildParameters(ctx context.Context, workspaceBuildID uuid.UUID) ([]WorkspaceBuildParameter, error) {
@@ -7558,9 +8107,104 @@ func (q *sqlQuerier) InsertWorkspaceBuildParameters(ctx context.Context, arg Ins
 	return err
 }
 
+const getLatestBuildByTemplateVersionID = `-- name: GetLatestBuildByTemplateVersionID :one
+SELECT
+	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, rollback_of, parameters_from_build_id, structured_reason, idempotency_key, template_file_hash, schedule_name, initiator_by_avatar_url, initiator_by_username
+FROM
+	workspace_build_with_user AS workspace_builds
+WHERE
+	template_version_id = $1
+ORDER BY
+	created_at desc
+LIMIT
+	1
+`
+
+// Returns the most recently created build using the template version, across
+// all workspaces.
+func (q *sqlQuerier) GetLatestBuildByTemplateVersionID(ctx context.Context, templateVersionID uuid.UUID) (WorkspaceBuild, error) {
+	row := q.db.QueryRowContext(ctx, getLatestBuildByTemplateVersionID, templateVersionID)
+	var i WorkspaceBuild
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.WorkspaceID,
+		&i.TemplateVersionID,
+		&i.BuildNumber,
+		&i.Transition,
+		&i.InitiatorID,
+		&i.ProvisionerState,
+		&i.JobID,
+		&i.Deadline,
+		&i.Reason,
+		&i.DailyCost,
+		&i.MaxDeadline,
+		&i.RollbackOf,
+		&i.ParametersFromBuildID,
+		&i.StructuredReason,
+		&i.IdempotencyKey,
+		&i.TemplateFileHash,
+		&i.ScheduleName,
+		&i.InitiatorByAvatarUrl,
+		&i.InitiatorByUsername,
+	)
+	return i, err
+}
+
+const getLatestSuccessfulWorkspaceBuildByWorkspaceID = `-- name: GetLatestSuccessfulWorkspaceBuildByWorkspaceID :one
+SELECT
+	workspace_builds.id, workspace_builds.created_at, workspace_builds.updated_at, workspace_builds.workspace_id, workspace_builds.template_version_id, workspace_builds.build_number, workspace_builds.transition, workspace_builds.initiator_id, workspace_builds.provisioner_state, workspace_builds.job_id, workspace_builds.deadline, workspace_builds.reason, workspace_builds.daily_cost, workspace_builds.max_deadline, workspace_builds.rollback_of, workspace_builds.parameters_from_build_id, workspace_builds.structured_reason, workspace_builds.idempotency_key, workspace_builds.template_file_hash, workspace_builds.schedule_name, workspace_builds.initiator_by_avatar_url, workspace_builds.initiator_by_username
+FROM
+	workspace_build_with_user AS workspace_builds
+JOIN
+	provisioner_jobs ON provisioner_jobs.id = workspace_builds.job_id
+WHERE
+	workspace_builds.workspace_id = $1
+	AND provisioner_jobs.completed_at IS NOT NULL
+	AND provisioner_jobs.canceled_at IS NULL
+	AND COALESCE(provisioner_jobs.error, '') = ''
+ORDER BY
+	workspace_builds.build_number desc
+LIMIT
+	1
+`
+
+// Returns the most recent build of the workspace whose provisioner job
+// completed without error, regardless of transition.
+func (q *sqlQuerier) GetLatestSuccessfulWorkspaceBuildByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) (WorkspaceBuild, error) {
+	row := q.db.QueryRowContext(ctx, getLatestSuccessfulWorkspaceBuildByWorkspaceID, workspaceID)
+	var i WorkspaceBuild
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.WorkspaceID,
+		&i.TemplateVersionID,
+		&i.BuildNumber,
+		&i.Transition,
+		&i.InitiatorID,
+		&i.ProvisionerState,
+		&i.JobID,
+		&i.Deadline,
+		&i.Reason,
+		&i.DailyCost,
+		&i.MaxDeadline,
+		&i.RollbackOf,
+		&i.ParametersFromBuildID,
+		&i.StructuredReason,
+		&i.IdempotencyKey,
+		&i.TemplateFileHash,
+		&i.ScheduleName,
+		&i.InitiatorByAvatarUrl,
+		&i.InitiatorByUsername,
+	)
+	return i, err
+}
+
 const getLatestWorkspaceBuildByWorkspaceID = `-- name: GetLatestWorkspaceBuildByWorkspaceID :one
 SELECT
-	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, initiator_by_avatar_url, initiator_by_username
+	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, rollback_of, parameters_from_build_id, structured_reason, idempotency_key, template_file_hash, schedule_name, initiator_by_avatar_url, initiator_by_username
 FROM
 	workspace_build_with_user AS workspace_builds
 WHERE
@@ -7589,6 +8233,12 @@ func (q *sqlQuerier) GetLatestWorkspaceBuildByWorkspaceID(ctx context.Context, w
 		&i.Reason,
 		&i.DailyCost,
 		&i.MaxDeadline,
+		&i.RollbackOf,
+		&i.ParametersFromBuildID,
+		&i.StructuredReason,
+		&i.IdempotencyKey,
+		&i.TemplateFileHash,
+		&i.ScheduleName,
 		&i.InitiatorByAvatarUrl,
 		&i.InitiatorByUsername,
 	)
@@ -7596,7 +8246,7 @@ func (q *sqlQuerier) GetLatestWorkspaceBuildByWorkspaceID(ctx context.Context, w
 }
 
 const getLatestWorkspaceBuilds = `-- name: GetLatestWorkspaceBuilds :many
-SELECT wb.id, wb.created_at, wb.updated_at, wb.workspace_id, wb.template_version_id, wb.build_number, wb.transition, wb.initiator_id, wb.provisioner_state, wb.job_id, wb.deadline, wb.reason, wb.daily_cost, wb.max_deadline, wb.initiator_by_avatar_url, wb.initiator_by_username
+SELECT wb.id, wb.created_at, wb.updated_at, wb.workspace_id, wb.template_version_id, wb.build_number, wb.transition, wb.initiator_id, wb.provisioner_state, wb.job_id, wb.deadline, wb.reason, wb.daily_cost, wb.max_deadline, wb.rollback_of, wb.parameters_from_build_id, wb.structured_reason, wb.idempotency_key, wb.template_file_hash, wb.schedule_name, wb.initiator_by_avatar_url, wb.initiator_by_username
 FROM (
     SELECT
         workspace_id, MAX(build_number) as max_build_number
@@ -7634,6 +8284,12 @@ func (q *sqlQuerier) GetLatestWorkspaceBuilds(ctx context.Context) ([]WorkspaceB
 			&i.Reason,
 			&i.DailyCost,
 			&i.MaxDeadline,
+			&i.RollbackOf,
+			&i.ParametersFromBuildID,
+			&i.StructuredReason,
+			&i.IdempotencyKey,
+			&i.TemplateFileHash,
+			&i.ScheduleName,
 			&i.InitiatorByAvatarUrl,
 			&i.InitiatorByUsername,
 		); err != nil {
@@ -7651,7 +8307,7 @@ func (q *sqlQuerier) GetLatestWorkspaceBuilds(ctx context.Context) ([]WorkspaceB
 }
 
 const getLatestWorkspaceBuildsByWorkspaceIDs = `-- name: GetLatestWorkspaceBuildsByWorkspaceIDs :many
-SELECT wb.id, wb.created_at, wb.updated_at, wb.workspace_id, wb.template_version_id, wb.build_number, wb.transition, wb.initiator_id, wb.provisioner_state, wb.job_id, wb.deadline, wb.reason, wb.daily_cost, wb.max_deadline, wb.initiator_by_avatar_url, wb.initiator_by_username
+SELECT wb.id, wb.created_at, wb.updated_at, wb.workspace_id, wb.template_version_id, wb.build_number, wb.transition, wb.initiator_id, wb.provisioner_state, wb.job_id, wb.deadline, wb.reason, wb.daily_cost, wb.max_deadline, wb.rollback_of, wb.parameters_from_build_id, wb.structured_reason, wb.idempotency_key, wb.template_file_hash, wb.schedule_name, wb.initiator_by_avatar_url, wb.initiator_by_username
 FROM (
     SELECT
         workspace_id, MAX(build_number) as max_build_number
@@ -7691,6 +8347,82 @@ func (q *sqlQuerier) GetLatestWorkspaceBuildsByWorkspaceIDs(ctx context.Context,
 			&i.Reason,
 			&i.DailyCost,
 			&i.MaxDeadline,
+			&i.RollbackOf,
+			&i.ParametersFromBuildID,
+			&i.StructuredReason,
+			&i.IdempotencyKey,
+			&i.TemplateFileHash,
+			&i.ScheduleName,
+			&i.InitiatorByAvatarUrl,
+			&i.InitiatorByUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStuckRunningBuilds = `-- name: GetStuckRunningBuilds :many
+-- Returns the builds whose job is still running and started before
+-- started_before, longest running first, for alerting on builds that are
+-- stuck. Jobs that are being canceled are not considered running.
+SELECT
+	workspace_builds.id, workspace_builds.created_at, workspace_builds.updated_at, workspace_builds.workspace_id, workspace_builds.template_version_id, workspace_builds.build_number, workspace_builds.transition, workspace_builds.initiator_id, workspace_builds.provisioner_state, workspace_builds.job_id, workspace_builds.deadline, workspace_builds.reason, workspace_builds.daily_cost, workspace_builds.max_deadline, workspace_builds.rollback_of, workspace_builds.parameters_from_build_id, workspace_builds.structured_reason, workspace_builds.idempotency_key, workspace_builds.template_file_hash, workspace_builds.schedule_name, workspace_builds.initiator_by_avatar_url, workspace_builds.initiator_by_username
+FROM
+	workspace_build_with_user AS workspace_builds
+JOIN
+	provisioner_jobs
+ON
+	provisioner_jobs.id = workspace_builds.job_id
+WHERE
+	provisioner_jobs.started_at IS NOT NULL
+	AND provisioner_jobs.completed_at IS NULL
+	AND provisioner_jobs.canceled_at IS NULL
+	AND provisioner_jobs.started_at < $1 :: timestamptz
+ORDER BY
+	provisioner_jobs.started_at ASC
+`
+
+// Returns the builds whose job is still running and started before
+// started_before, longest running first, for alerting on builds that are
+// stuck. Jobs that are being canceled are not considered running.
+func (q *sqlQuerier) GetStuckRunningBuilds(ctx context.Context, startedBefore time.Time) ([]WorkspaceBuild, error) {
+	rows, err := q.db.QueryContext(ctx, getStuckRunningBuilds, startedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WorkspaceBuild
+	for rows.Next() {
+		var i WorkspaceBuild
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.WorkspaceID,
+			&i.TemplateVersionID,
+			&i.BuildNumber,
+			&i.Transition,
+			&i.InitiatorID,
+			&i.ProvisionerState,
+			&i.JobID,
+			&i.Deadline,
+			&i.Reason,
+			&i.DailyCost,
+			&i.MaxDeadline,
+			&i.RollbackOf,
+			&i.ParametersFromBuildID,
+			&i.StructuredReason,
+			&i.IdempotencyKey,
+			&i.TemplateFileHash,
+			&i.ScheduleName,
 			&i.InitiatorByAvatarUrl,
 			&i.InitiatorByUsername,
 		); err != nil {
@@ -7709,7 +8441,7 @@ func (q *sqlQuerier) GetLatestWorkspaceBuildsByWorkspaceIDs(ctx context.Context,
 
 const getWorkspaceBuildByID = `-- name: GetWorkspaceBuildByID :one
 SELECT
-	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, initiator_by_avatar_url, initiator_by_username
+	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, rollback_of, parameters_from_build_id, structured_reason, idempotency_key, template_file_hash, schedule_name, initiator_by_avatar_url, initiator_by_username
 FROM
 	workspace_build_with_user AS workspace_builds
 WHERE
@@ -7736,6 +8468,12 @@ func (q *sqlQuerier) GetWorkspaceBuildByID(ctx context.Context, id uuid.UUID) (W
 		&i.Reason,
 		&i.DailyCost,
 		&i.MaxDeadline,
+		&i.RollbackOf,
+		&i.ParametersFromBuildID,
+		&i.StructuredReason,
+		&i.IdempotencyKey,
+		&i.TemplateFileHash,
+		&i.ScheduleName,
 		&i.InitiatorByAvatarUrl,
 		&i.InitiatorByUsername,
 	)
@@ -7744,7 +8482,7 @@ func (q *sqlQuerier) GetWorkspaceBuildByID(ctx context.Context, id uuid.UUID) (W
 
 const getWorkspaceBuildByJobID = `-- name: GetWorkspaceBuildByJobID :one
 SELECT
-	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, initiator_by_avatar_url, initiator_by_username
+	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, rollback_of, parameters_from_build_id, structured_reason, idempotency_key, template_file_hash, schedule_name, initiator_by_avatar_url, initiator_by_username
 FROM
 	workspace_build_with_user AS workspace_builds
 WHERE
@@ -7771,6 +8509,12 @@ func (q *sqlQuerier) GetWorkspaceBuildByJobID(ctx context.Context, jobID uuid.UU
 		&i.Reason,
 		&i.DailyCost,
 		&i.MaxDeadline,
+		&i.RollbackOf,
+		&i.ParametersFromBuildID,
+		&i.StructuredReason,
+		&i.IdempotencyKey,
+		&i.TemplateFileHash,
+		&i.ScheduleName,
 		&i.InitiatorByAvatarUrl,
 		&i.InitiatorByUsername,
 	)
@@ -7779,7 +8523,7 @@ func (q *sqlQuerier) GetWorkspaceBuildByJobID(ctx context.Context, jobID uuid.UU
 
 const getWorkspaceBuildByWorkspaceIDAndBuildNumber = `-- name: GetWorkspaceBuildByWorkspaceIDAndBuildNumber :one
 SELECT
-	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, initiator_by_avatar_url, initiator_by_username
+	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, rollback_of, parameters_from_build_id, structured_reason, idempotency_key, template_file_hash, schedule_name, initiator_by_avatar_url, initiator_by_username
 FROM
 	workspace_build_with_user AS workspace_builds
 WHERE
@@ -7810,84 +8554,439 @@ func (q *sqlQuerier) GetWorkspaceBuildByWorkspaceIDAndBuildNumber(ctx context.Co
 		&i.Reason,
 		&i.DailyCost,
 		&i.MaxDeadline,
+		&i.RollbackOf,
+		&i.ParametersFromBuildID,
+		&i.StructuredReason,
+		&i.IdempotencyKey,
+		&i.TemplateFileHash,
+		&i.ScheduleName,
 		&i.InitiatorByAvatarUrl,
 		&i.InitiatorByUsername,
 	)
 	return i, err
 }
 
-const getWorkspaceBuildsByWorkspaceID = `-- name: GetWorkspaceBuildsByWorkspaceID :many
+const getWorkspaceBuildByWorkspaceIDAndIdempotencyKey = `-- name: GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey :one
 SELECT
-	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, initiator_by_avatar_url, initiator_by_username
+	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, rollback_of, parameters_from_build_id, structured_reason, idempotency_key, template_file_hash, schedule_name, initiator_by_avatar_url, initiator_by_username
 FROM
 	workspace_build_with_user AS workspace_builds
 WHERE
-	workspace_builds.workspace_id = $1
-	AND workspace_builds.created_at > $2
-    AND CASE
-		-- This allows using the last element on a page as effectively a cursor.
-		-- This is an important option for scripts that need to paginate without
-		-- duplicating or missing data.
-		WHEN $3 :: uuid != '00000000-0000-0000-0000-000000000000'::uuid THEN (
-			-- The pagination cursor is the last ID of the previous page.
-			-- The query is ordered by the build_number field, so select all
-			-- rows after the cursor.
-			build_number > (
-				SELECT
-					build_number
-				FROM
-					workspace_builds
-				WHERE
-					id = $3
-			)
-		)
-		ELSE true
-END
-ORDER BY
-    build_number desc OFFSET $4
-LIMIT
-    -- A null limit means "no limit", so 0 means return all
-    NULLIF($5 :: int, 0)
+	workspace_id = $1
+	AND idempotency_key = $2
 `
 
-type GetWorkspaceBuildsByWorkspaceIDParams struct {
-	WorkspaceID uuid.UUID `db:"workspace_id" json:"workspace_id"`
-	Since       time.Time `db:"since" json:"since"`
-	AfterID     uuid.UUID `db:"after_id" json:"after_id"`
-	OffsetOpt   int32     `db:"offset_opt" json:"offset_opt"`
-	LimitOpt    int32     `db:"limit_opt" json:"limit_opt"`
+type GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams struct {
+	WorkspaceID    uuid.UUID      `db:"workspace_id" json:"workspace_id"`
+	IdempotencyKey sql.NullString `db:"idempotency_key" json:"idempotency_key"`
 }
 
-func (q *sqlQuerier) GetWorkspaceBuildsByWorkspaceID(ctx context.Context, arg GetWorkspaceBuildsByWorkspaceIDParams) ([]WorkspaceBuild, error) {
-	rows, err := q.db.QueryContext(ctx, getWorkspaceBuildsByWorkspaceID,
-		arg.WorkspaceID,
-		arg.Since,
-		arg.AfterID,
-		arg.OffsetOpt,
-		arg.LimitOpt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []WorkspaceBuild
-	for rows.Next() {
-		var i WorkspaceBuild
-		if err := rows.Scan(
-			&i.ID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.WorkspaceID,
-			&i.TemplateVersionID,
-			&i.BuildNumber,
-			&i.Transition,
-			&i.InitiatorID,
+func (q *sqlQuerier) GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(ctx context.Context, arg GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams) (WorkspaceBuild, error) {
+	row := q.db.QueryRowContext(ctx, getWorkspaceBuildByWorkspaceIDAndIdempotencyKey, arg.WorkspaceID, arg.IdempotencyKey)
+	var i WorkspaceBuild
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.WorkspaceID,
+		&i.TemplateVersionID,
+		&i.BuildNumber,
+		&i.Transition,
+		&i.InitiatorID,
+		&i.ProvisionerState,
+		&i.JobID,
+		&i.Deadline,
+		&i.Reason,
+		&i.DailyCost,
+		&i.MaxDeadline,
+		&i.RollbackOf,
+		&i.ParametersFromBuildID,
+		&i.StructuredReason,
+		&i.IdempotencyKey,
+		&i.TemplateFileHash,
+		&i.ScheduleName,
+		&i.InitiatorByAvatarUrl,
+		&i.InitiatorByUsername,
+	)
+	return i, err
+}
+
+const getWorkspaceBuildVersionLineage = `-- name: GetWorkspaceBuildVersionLineage :many
+-- Returns the builds of a workspace with the name of the template version each
+-- one used, oldest first, e.g. to draw how the workspace moved between
+-- versions over time.
+SELECT
+	workspace_builds.id,
+	workspace_builds.build_number,
+	workspace_builds.transition,
+	workspace_builds.created_at,
+	workspace_builds.template_version_id,
+	template_versions.name AS template_version_name
+FROM
+	workspace_builds
+JOIN
+	template_versions ON template_versions.id = workspace_builds.template_version_id
+WHERE
+	workspace_builds.workspace_id = $1
+ORDER BY
+	workspace_builds.build_number ASC;
+`
+
+type GetWorkspaceBuildVersionLineageRow struct {
+	ID                  uuid.UUID           `db:"id" json:"id"`
+	BuildNumber         int32               `db:"build_number" json:"build_number"`
+	Transition          WorkspaceTransition `db:"transition" json:"transition"`
+	CreatedAt           time.Time           `db:"created_at" json:"created_at"`
+	TemplateVersionID   uuid.UUID           `db:"template_version_id" json:"template_version_id"`
+	TemplateVersionName string              `db:"template_version_name" json:"template_version_name"`
+}
+
+// Returns the builds of a workspace with the name of the template version each
+// one used, oldest first, e.g. to draw how the workspace moved between
+// versions over time.
+func (q *sqlQuerier) GetWorkspaceBuildVersionLineage(ctx context.Context, workspaceID uuid.UUID) ([]GetWorkspaceBuildVersionLineageRow, error) {
+	rows, err := q.db.QueryContext(ctx, getWorkspaceBuildVersionLineage, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWorkspaceBuildVersionLineageRow
+	for rows.Next() {
+		var i GetWorkspaceBuildVersionLineageRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.BuildNumber,
+			&i.Transition,
+			&i.CreatedAt,
+			&i.TemplateVersionID,
+			&i.TemplateVersionName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWorkspaceBuilds = `-- name: GetWorkspaceBuilds :many
+SELECT
+	workspace_builds.id, workspace_builds.created_at, workspace_builds.updated_at, workspace_builds.workspace_id, workspace_builds.template_version_id, workspace_builds.build_number, workspace_builds.transition, workspace_builds.initiator_id, workspace_builds.provisioner_state, workspace_builds.job_id, workspace_builds.deadline, workspace_builds.reason, workspace_builds.daily_cost, workspace_builds.max_deadline, workspace_builds.rollback_of, workspace_builds.parameters_from_build_id, workspace_builds.structured_reason, workspace_builds.idempotency_key, workspace_builds.template_file_hash, workspace_builds.schedule_name, workspace_builds.initiator_by_avatar_url, workspace_builds.initiator_by_username,
+	workspaces.name AS workspace_name,
+	workspaces.owner_id AS workspace_owner_id,
+	workspaces.organization_id AS workspace_organization_id,
+	workspaces.template_id AS workspace_template_id,
+	COUNT(*) OVER () AS count
+FROM
+	workspace_build_with_user AS workspace_builds
+INNER JOIN
+	workspaces ON workspaces.id = workspace_builds.workspace_id
+WHERE
+	workspace_builds.workspace_id IN (
+		SELECT
+			id
+		FROM
+			workspaces
+		WHERE
+			-- Filter by organization_id
+			CASE
+				WHEN $1 :: uuid != '00000000-0000-0000-0000-000000000000'::uuid THEN
+					organization_id = $1
+				ELSE true
+			END
+			-- Authorize Filter clause will be injected below in GetAuthorizedWorkspaceBuilds
+			-- @authorize_filter
+	)
+ORDER BY
+	workspace_builds.created_at DESC,
+	workspace_builds.id DESC
+OFFSET $2
+LIMIT
+	-- A null limit means "no limit", so 0 means return all
+	NULLIF($3 :: int, 0)
+`
+
+type GetWorkspaceBuildsParams struct {
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+	OffsetOpt      int32     `db:"offset_opt" json:"offset_opt"`
+	LimitOpt       int32     `db:"limit_opt" json:"limit_opt"`
+}
+
+type GetWorkspaceBuildsRow struct {
+	ID                      uuid.UUID             `db:"id" json:"id"`
+	CreatedAt               time.Time             `db:"created_at" json:"created_at"`
+	UpdatedAt               time.Time             `db:"updated_at" json:"updated_at"`
+	WorkspaceID             uuid.UUID             `db:"workspace_id" json:"workspace_id"`
+	TemplateVersionID       uuid.UUID             `db:"template_version_id" json:"template_version_id"`
+	BuildNumber             int32                 `db:"build_number" json:"build_number"`
+	Transition              WorkspaceTransition   `db:"transition" json:"transition"`
+	InitiatorID             uuid.UUID             `db:"initiator_id" json:"initiator_id"`
+	ProvisionerState        []byte                `db:"provisioner_state" json:"provisioner_state"`
+	JobID                   uuid.UUID             `db:"job_id" json:"job_id"`
+	Deadline                time.Time             `db:"deadline" json:"deadline"`
+	Reason                  BuildReason           `db:"reason" json:"reason"`
+	DailyCost               int32                 `db:"daily_cost" json:"daily_cost"`
+	MaxDeadline             time.Time             `db:"max_deadline" json:"max_deadline"`
+	RollbackOf              uuid.NullUUID         `db:"rollback_of" json:"rollback_of"`
+	ParametersFromBuildID   uuid.NullUUID         `db:"parameters_from_build_id" json:"parameters_from_build_id"`
+	StructuredReason        StructuredBuildReason `db:"structured_reason" json:"structured_reason"`
+	IdempotencyKey          sql.NullString        `db:"idempotency_key" json:"idempotency_key"`
+	TemplateFileHash        sql.NullString        `db:"template_file_hash" json:"template_file_hash"`
+	ScheduleName            sql.NullString        `db:"schedule_name" json:"schedule_name"`
+	InitiatorByAvatarUrl    sql.NullString        `db:"initiator_by_avatar_url" json:"initiator_by_avatar_url"`
+	InitiatorByUsername     string                `db:"initiator_by_username" json:"initiator_by_username"`
+	WorkspaceName           string                `db:"workspace_name" json:"workspace_name"`
+	WorkspaceOwnerID        uuid.UUID             `db:"workspace_owner_id" json:"workspace_owner_id"`
+	WorkspaceOrganizationID uuid.UUID             `db:"workspace_organization_id" json:"workspace_organization_id"`
+	WorkspaceTemplateID     uuid.UUID             `db:"workspace_template_id" json:"workspace_template_id"`
+	Count                   int64                 `db:"count" json:"count"`
+}
+
+func (q *sqlQuerier) GetWorkspaceBuilds(ctx context.Context, arg GetWorkspaceBuildsParams) ([]GetWorkspaceBuildsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getWorkspaceBuilds, arg.OrganizationID, arg.OffsetOpt, arg.LimitOpt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWorkspaceBuildsRow
+	for rows.Next() {
+		var i GetWorkspaceBuildsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.WorkspaceID,
+			&i.TemplateVersionID,
+			&i.BuildNumber,
+			&i.Transition,
+			&i.InitiatorID,
+			&i.ProvisionerState,
+			&i.JobID,
+			&i.Deadline,
+			&i.Reason,
+			&i.DailyCost,
+			&i.MaxDeadline,
+			&i.RollbackOf,
+			&i.ParametersFromBuildID,
+			&i.StructuredReason,
+			&i.IdempotencyKey,
+			&i.TemplateFileHash,
+			&i.ScheduleName,
+			&i.InitiatorByAvatarUrl,
+			&i.InitiatorByUsername,
+			&i.WorkspaceName,
+			&i.WorkspaceOwnerID,
+			&i.WorkspaceOrganizationID,
+			&i.WorkspaceTemplateID,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWorkspaceBuildsByInitiator = `-- name: GetWorkspaceBuildsByInitiator :many
+SELECT
+	workspace_builds.id, workspace_builds.created_at, workspace_builds.updated_at, workspace_builds.workspace_id, workspace_builds.template_version_id, workspace_builds.build_number, workspace_builds.transition, workspace_builds.initiator_id, workspace_builds.provisioner_state, workspace_builds.job_id, workspace_builds.deadline, workspace_builds.reason, workspace_builds.daily_cost, workspace_builds.max_deadline, workspace_builds.rollback_of, workspace_builds.parameters_from_build_id, workspace_builds.structured_reason, workspace_builds.idempotency_key, workspace_builds.template_file_hash, workspace_builds.schedule_name, workspace_builds.initiator_by_avatar_url, workspace_builds.initiator_by_username,
+	workspaces.name AS workspace_name,
+	workspaces.owner_id AS workspace_owner_id,
+	workspaces.organization_id AS workspace_organization_id,
+	templates.name AS template_name
+FROM
+	workspace_build_with_user AS workspace_builds
+JOIN
+	workspaces ON workspaces.id = workspace_builds.workspace_id
+JOIN
+	templates ON templates.id = workspaces.template_id
+WHERE
+	workspace_builds.initiator_id = $1
+ORDER BY
+	workspace_builds.created_at DESC,
+	workspace_builds.id DESC
+OFFSET $2
+LIMIT
+	-- A null limit means "no limit", so 0 means return all
+	NULLIF($3 :: int, 0)
+`
+
+type GetWorkspaceBuildsByInitiatorParams struct {
+	InitiatorID uuid.UUID `db:"initiator_id" json:"initiator_id"`
+	OffsetOpt   int32     `db:"offset_opt" json:"offset_opt"`
+	LimitOpt    int32     `db:"limit_opt" json:"limit_opt"`
+}
+
+type GetWorkspaceBuildsByInitiatorRow struct {
+	ID                      uuid.UUID             `db:"id" json:"id"`
+	CreatedAt               time.Time             `db:"created_at" json:"created_at"`
+	UpdatedAt               time.Time             `db:"updated_at" json:"updated_at"`
+	WorkspaceID             uuid.UUID             `db:"workspace_id" json:"workspace_id"`
+	TemplateVersionID       uuid.UUID             `db:"template_version_id" json:"template_version_id"`
+	BuildNumber             int32                 `db:"build_number" json:"build_number"`
+	Transition              WorkspaceTransition   `db:"transition" json:"transition"`
+	InitiatorID             uuid.UUID             `db:"initiator_id" json:"initiator_id"`
+	ProvisionerState        []byte                `db:"provisioner_state" json:"provisioner_state"`
+	JobID                   uuid.UUID             `db:"job_id" json:"job_id"`
+	Deadline                time.Time             `db:"deadline" json:"deadline"`
+	Reason                  BuildReason           `db:"reason" json:"reason"`
+	DailyCost               int32                 `db:"daily_cost" json:"daily_cost"`
+	MaxDeadline             time.Time             `db:"max_deadline" json:"max_deadline"`
+	RollbackOf              uuid.NullUUID         `db:"rollback_of" json:"rollback_of"`
+	ParametersFromBuildID   uuid.NullUUID         `db:"parameters_from_build_id" json:"parameters_from_build_id"`
+	StructuredReason        StructuredBuildReason `db:"structured_reason" json:"structured_reason"`
+	IdempotencyKey          sql.NullString        `db:"idempotency_key" json:"idempotency_key"`
+	TemplateFileHash        sql.NullString        `db:"template_file_hash" json:"template_file_hash"`
+	ScheduleName            sql.NullString        `db:"schedule_name" json:"schedule_name"`
+	InitiatorByAvatarUrl    sql.NullString        `db:"initiator_by_avatar_url" json:"initiator_by_avatar_url"`
+	InitiatorByUsername     string                `db:"initiator_by_username" json:"initiator_by_username"`
+	WorkspaceName           string                `db:"workspace_name" json:"workspace_name"`
+	WorkspaceOwnerID        uuid.UUID             `db:"workspace_owner_id" json:"workspace_owner_id"`
+	WorkspaceOrganizationID uuid.UUID             `db:"workspace_organization_id" json:"workspace_organization_id"`
+	TemplateName            string                `db:"template_name" json:"template_name"`
+}
+
+// Returns the builds started by the initiator across all workspaces, newest
+// first, along with the names of the workspace and template they belong to.
+func (q *sqlQuerier) GetWorkspaceBuildsByInitiator(ctx context.Context, arg GetWorkspaceBuildsByInitiatorParams) ([]GetWorkspaceBuildsByInitiatorRow, error) {
+	rows, err := q.db.QueryContext(ctx, getWorkspaceBuildsByInitiator, arg.InitiatorID, arg.OffsetOpt, arg.LimitOpt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWorkspaceBuildsByInitiatorRow
+	for rows.Next() {
+		var i GetWorkspaceBuildsByInitiatorRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.WorkspaceID,
+			&i.TemplateVersionID,
+			&i.BuildNumber,
+			&i.Transition,
+			&i.InitiatorID,
+			&i.ProvisionerState,
+			&i.JobID,
+			&i.Deadline,
+			&i.Reason,
+			&i.DailyCost,
+			&i.MaxDeadline,
+			&i.RollbackOf,
+			&i.ParametersFromBuildID,
+			&i.StructuredReason,
+			&i.IdempotencyKey,
+			&i.TemplateFileHash,
+			&i.ScheduleName,
+			&i.InitiatorByAvatarUrl,
+			&i.InitiatorByUsername,
+			&i.WorkspaceName,
+			&i.WorkspaceOwnerID,
+			&i.WorkspaceOrganizationID,
+			&i.TemplateName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWorkspaceBuildsByWorkspaceID = `-- name: GetWorkspaceBuildsByWorkspaceID :many
+SELECT
+	id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, rollback_of, parameters_from_build_id, structured_reason, idempotency_key, template_file_hash, schedule_name, initiator_by_avatar_url, initiator_by_username
+FROM
+	workspace_build_with_user AS workspace_builds
+WHERE
+	workspace_builds.workspace_id = $1
+	AND workspace_builds.created_at > $2
+    AND CASE
+		-- This allows using the last element on a page as effectively a cursor.
+		-- This is an important option for scripts that need to paginate without
+		-- duplicating or missing data.
+		WHEN $3 :: uuid != '00000000-0000-0000-0000-000000000000'::uuid THEN (
+			-- The pagination cursor is the last ID of the previous page.
+			-- The query is ordered by the build_number field, so select all
+			-- rows after the cursor.
+			build_number > (
+				SELECT
+					build_number
+				FROM
+					workspace_builds
+				WHERE
+					id = $3
+			)
+		)
+		ELSE true
+END
+ORDER BY
+    build_number desc OFFSET $4
+LIMIT
+    -- A null limit means "no limit", so 0 means return all
+    NULLIF($5 :: int, 0)
+`
+
+type GetWorkspaceBuildsByWorkspaceIDParams struct {
+	WorkspaceID uuid.UUID `db:"workspace_id" json:"workspace_id"`
+	Since       time.Time `db:"since" json:"since"`
+	AfterID     uuid.UUID `db:"after_id" json:"after_id"`
+	OffsetOpt   int32     `db:"offset_opt" json:"offset_opt"`
+	LimitOpt    int32     `db:"limit_opt" json:"limit_opt"`
+}
+
+func (q *sqlQuerier) GetWorkspaceBuildsByWorkspaceID(ctx context.Context, arg GetWorkspaceBuildsByWorkspaceIDParams) ([]WorkspaceBuild, error) {
+	rows, err := q.db.QueryContext(ctx, getWorkspaceBuildsByWorkspaceID,
+		arg.WorkspaceID,
+		arg.Since,
+		arg.AfterID,
+		arg.OffsetOpt,
+		arg.LimitOpt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WorkspaceBuild
+	for rows.Next() {
+		var i WorkspaceBuild
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.WorkspaceID,
+			&i.TemplateVersionID,
+			&i.BuildNumber,
+			&i.Transition,
+			&i.InitiatorID,
 			&i.ProvisionerState,
 			&i.JobID,
 			&i.Deadline,
 			&i.Reason,
 			&i.DailyCost,
 			&i.MaxDeadline,
+			&i.RollbackOf,
+			&i.ParametersFromBuildID,
+			&i.StructuredReason,
+			&i.IdempotencyKey,
+			&i.TemplateFileHash,
+			&i.ScheduleName,
 			&i.InitiatorByAvatarUrl,
 			&i.InitiatorByUsername,
 		); err != nil {
@@ -7905,7 +9004,7 @@ func (q *sqlQuerier) GetWorkspaceBuildsByWorkspaceID(ctx context.Context, arg Ge
 }
 
 const getWorkspaceBuildsCreatedAfter = `-- name: GetWorkspaceBuildsCreatedAfter :many
-SELECT id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, initiator_by_avatar_url, initiator_by_username FROM workspace_build_with_user WHERE created_at > $1
+SELECT id, created_at, updated_at, workspace_id, template_version_id, build_number, transition, initiator_id, provisioner_state, job_id, deadline, reason, daily_cost, max_deadline, rollback_of, parameters_from_build_id, structured_reason, idempotency_key, template_file_hash, schedule_name, initiator_by_avatar_url, initiator_by_username FROM workspace_build_with_user WHERE created_at > $1
 `
 
 func (q *sqlQuerier) GetWorkspaceBuildsCreatedAfter(ctx context.Context, createdAt time.Time) ([]WorkspaceBuild, error) {
@@ -7932,6 +9031,12 @@ func (q *sqlQuerier) GetWorkspaceBuildsCreatedAfter(ctx context.Context, created
 			&i.Reason,
 			&i.DailyCost,
 			&i.MaxDeadline,
+			&i.RollbackOf,
+			&i.ParametersFromBuildID,
+			&i.StructuredReason,
+			&i.IdempotencyKey,
+			&i.TemplateFileHash,
+			&i.ScheduleName,
 			&i.InitiatorByAvatarUrl,
 			&i.InitiatorByUsername,
 		); err != nil {
@@ -7948,6 +9053,85 @@ func (q *sqlQuerier) GetWorkspaceBuildsCreatedAfter(ctx context.Context, created
 	return items, nil
 }
 
+const getWorkspaceBuildsWithParametersJSON = `-- name: GetWorkspaceBuildsWithParametersJSON :many
+SELECT
+	workspace_builds.id, workspace_builds.created_at, workspace_builds.updated_at, workspace_builds.workspace_id, workspace_builds.template_version_id, workspace_builds.build_number, workspace_builds.transition, workspace_builds.initiator_id, workspace_builds.provisioner_state, workspace_builds.job_id, workspace_builds.deadline, workspace_builds.reason, workspace_builds.daily_cost, workspace_builds.max_deadline, workspace_builds.rollback_of, workspace_builds.parameters_from_build_id, workspace_builds.structured_reason, workspace_builds.idempotency_key, workspace_builds.template_file_hash, workspace_builds.schedule_name, workspace_builds.initiator_by_avatar_url, workspace_builds.initiator_by_username,
+	COALESCE(
+		(
+			SELECT
+				json_agg(workspace_build_parameters ORDER BY workspace_build_parameters.name)
+			FROM
+				workspace_build_parameters
+			WHERE
+				workspace_build_parameters.workspace_build_id = COALESCE(workspace_builds.parameters_from_build_id, workspace_builds.id)
+		),
+		'[]'
+	) :: jsonb AS parameters
+FROM
+	workspace_build_with_user AS workspace_builds
+WHERE
+	workspace_builds.workspace_id = $1
+ORDER BY
+	workspace_builds.build_number ASC
+`
+
+type GetWorkspaceBuildsWithParametersJSONRow struct {
+	WorkspaceBuild WorkspaceBuild  `db:"workspace_build" json:"workspace_build"`
+	Parameters     json.RawMessage `db:"parameters" json:"parameters"`
+}
+
+// Returns all builds of the workspace, oldest first, each with its parameters
+// as a JSON array ordered by name. A build that shares the parameters of a
+// prior build gets that build's parameters. Fetching both in a single query
+// means exporting a workspace doesn't take a query per build.
+// GetWorkspaceBuildsWithParameters decodes the parameters.
+func (q *sqlQuerier) GetWorkspaceBuildsWithParametersJSON(ctx context.Context, workspaceID uuid.UUID) ([]GetWorkspaceBuildsWithParametersJSONRow, error) {
+	rows, err := q.db.QueryContext(ctx, getWorkspaceBuildsWithParametersJSON, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWorkspaceBuildsWithParametersJSONRow
+	for rows.Next() {
+		var i GetWorkspaceBuildsWithParametersJSONRow
+		if err := rows.Scan(
+			&i.WorkspaceBuild.ID,
+			&i.WorkspaceBuild.CreatedAt,
+			&i.WorkspaceBuild.UpdatedAt,
+			&i.WorkspaceBuild.WorkspaceID,
+			&i.WorkspaceBuild.TemplateVersionID,
+			&i.WorkspaceBuild.BuildNumber,
+			&i.WorkspaceBuild.Transition,
+			&i.WorkspaceBuild.InitiatorID,
+			&i.WorkspaceBuild.ProvisionerState,
+			&i.WorkspaceBuild.JobID,
+			&i.WorkspaceBuild.Deadline,
+			&i.WorkspaceBuild.Reason,
+			&i.WorkspaceBuild.DailyCost,
+			&i.WorkspaceBuild.MaxDeadline,
+			&i.WorkspaceBuild.RollbackOf,
+			&i.WorkspaceBuild.ParametersFromBuildID,
+			&i.WorkspaceBuild.StructuredReason,
+			&i.WorkspaceBuild.IdempotencyKey,
+			&i.WorkspaceBuild.TemplateFileHash,
+			&i.WorkspaceBuild.ScheduleName,
+			&i.WorkspaceBuild.InitiatorByAvatarUrl,
+			&i.WorkspaceBuild.InitiatorByUsername,
+			&i.Parameters,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const insertWorkspaceBuild = `-- name: InsertWorkspaceBuild :exec
 INSERT INTO
 	workspace_builds (
@@ -7963,26 +9147,59 @@ INSERT INTO
 		provisioner_state,
 		deadline,
 		max_deadline,
-		reason
+		reason,
+		rollback_of,
+		parameters_from_build_id,
+		structured_reason,
+		idempotency_key,
+		template_file_hash,
+		schedule_name
 	)
 VALUES
-	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	(
+		$1,
+		$2,
+		$3,
+		$4,
+		$5,
+		$6,
+		$7,
+		$8,
+		$9,
+		$10,
+		$11,
+		$12,
+		$13,
+		$14,
+		$15,
+		$16,
+		$17,
+		-- Record the hash of the template version's source, if it is still stored.
+		(SELECT hash FROM files WHERE id = $18 :: uuid),
+		$19
+	)
 `
 
 type InsertWorkspaceBuildParams struct {
-	ID                uuid.UUID           `db:"id" json:"id"`
-	CreatedAt         time.Time           `db:"created_at" json:"created_at"`
-	UpdatedAt         time.Time           `db:"updated_at" json:"updated_at"`
-	WorkspaceID       uuid.UUID           `db:"workspace_id" json:"workspace_id"`
-	TemplateVersionID uuid.UUID           `db:"template_version_id" json:"template_version_id"`
-	BuildNumber       int32               `db:"build_number" json:"build_number"`
-	Transition        WorkspaceTransition `db:"transition" json:"transition"`
-	InitiatorID       uuid.UUID           `db:"initiator_id" json:"initiator_id"`
-	JobID             uuid.UUID           `db:"job_id" json:"job_id"`
-	ProvisionerState  []byte              `db:"provisioner_state" json:"provisioner_state"`
-	Deadline          time.Time           `db:"deadline" json:"deadline"`
-	MaxDeadline       time.Time           `db:"max_deadline" json:"max_deadline"`
-	Reason            BuildReason         `db:"reason" json:"reason"`
+	ID                    uuid.UUID             `db:"id" json:"id"`
+	CreatedAt             time.Time             `db:"created_at" json:"created_at"`
+	UpdatedAt             time.Time             `db:"updated_at" json:"updated_at"`
+	WorkspaceID           uuid.UUID             `db:"workspace_id" json:"workspace_id"`
+	TemplateVersionID     uuid.UUID             `db:"template_version_id" json:"template_version_id"`
+	BuildNumber           int32                 `db:"build_number" json:"build_number"`
+	Transition            WorkspaceTransition   `db:"transition" json:"transition"`
+	InitiatorID           uuid.UUID             `db:"initiator_id" json:"initiator_id"`
+	JobID                 uuid.UUID             `db:"job_id" json:"job_id"`
+	ProvisionerState      []byte                `db:"provisioner_state" json:"provisioner_state"`
+	Deadline              time.Time             `db:"deadline" json:"deadline"`
+	MaxDeadline           time.Time             `db:"max_deadline" json:"max_deadline"`
+	Reason                BuildReason           `db:"reason" json:"reason"`
+	RollbackOf            uuid.NullUUID         `db:"rollback_of" json:"rollback_of"`
+	ParametersFromBuildID uuid.NullUUID         `db:"parameters_from_build_id" json:"parameters_from_build_id"`
+	StructuredReason      StructuredBuildReason `db:"structured_reason" json:"structured_reason"`
+	IdempotencyKey        sql.NullString        `db:"idempotency_key" json:"idempotency_key"`
+	TemplateFileID        uuid.UUID             `db:"template_file_id" json:"template_file_id"`
+	ScheduleName          sql.NullString        `db:"schedule_name" json:"schedule_name"`
 }
 
 func (q *sqlQuerier) InsertWorkspaceBuild(ctx context.Context, arg InsertWorkspaceBuildParams) error {
@@ -8000,6 +9217,12 @@ func (q *sqlQuerier) InsertWorkspaceBuild(ctx context.Context, arg InsertWorkspa
 		arg.Deadline,
 		arg.MaxDeadline,
 		arg.Reason,
+		arg.RollbackOf,
+		arg.ParametersFromBuildID,
+		arg.StructuredReason,
+		arg.IdempotencyKey,
+		arg.TemplateFileID,
+		arg.ScheduleName,
 	)
 	return err
 }
@@ -8642,6 +9865,30 @@ func (q *sqlQuerier) GetWorkspaceByWorkspaceAppID(ctx context.Context, workspace
 	return i, err
 }
 
+const getWorkspaceCountByOwner = `-- name: GetWorkspaceCountByOwner :one
+SELECT
+	COUNT(*)
+FROM
+	workspaces
+WHERE
+	owner_id = $1
+	AND ($2 :: boolean OR deleted = false)
+`
+
+type GetWorkspaceCountByOwnerParams struct {
+	OwnerID        uuid.UUID `db:"owner_id" json:"owner_id"`
+	IncludeDeleted bool      `db:"include_deleted" json:"include_deleted"`
+}
+
+// Counts the workspaces owned by a user, e.g. for quota enforcement. Deleted
+// workspaces are only counted if include_deleted is true.
+func (q *sqlQuerier) GetWorkspaceCountByOwner(ctx context.Context, arg GetWorkspaceCountByOwnerParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getWorkspaceCountByOwner, arg.OwnerID, arg.IncludeDeleted)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const getWorkspaces = `-- name: GetWorkspaces :many
 SELECT
 	workspaces.id, workspaces.created_at, workspaces.updated_at, workspaces.owner_id, workspaces.organization_id, workspaces.template_id, workspaces.deleted, workspaces.name, workspaces.autostart_schedule, workspaces.ttl, workspaces.last_used_at, workspaces.locked_at, workspaces.deleting_at,
@@ -8791,10 +10038,17 @@ WHERE
 			workspaces.name ILIKE '%' || $7 || '%'
 		ELSE true
 	END
+	-- Filter by search query, matching on substring of either the workspace name
+	-- or the owner's username
+	AND CASE
+		WHEN $8 :: text != '' THEN
+			(workspaces.name ILIKE concat('%', $8, '%') OR users.username ILIKE concat('%', $8, '%'))
+		ELSE true
+	END
 	-- Filter by agent status
 	-- has-agent: is only applicable for workspaces in "start" transition. Stopped and deleted workspaces don't have agents.
 	AND CASE
-		WHEN $8 :: text != '' THEN
+		WHEN $9 :: text != '' THEN
 			(
 				SELECT COUNT(*)
 				FROM
@@ -8806,7 +10060,7 @@ WHERE
 				WHERE
 					workspace_resources.job_id = latest_build.provisioner_job_id AND
 					latest_build.transition = 'start'::workspace_transition AND
-					$8 = (
+					$9 = (
 						CASE
 							WHEN workspace_agents.first_connected_at IS NULL THEN
 								CASE
@@ -8817,7 +10071,7 @@ WHERE
 								END
 							WHEN workspace_agents.disconnected_at > workspace_agents.last_connected_at THEN
 								'disconnected'
-							WHEN NOW() - workspace_agents.last_connected_at > INTERVAL '1 second' * $9 :: bigint THEN
+							WHEN NOW() - workspace_agents.last_connected_at > INTERVAL '1 second' * $10 :: bigint THEN
 								'disconnected'
 							WHEN workspace_agents.last_connected_at IS NOT NULL THEN
 								'connected'
@@ -8828,9 +10082,33 @@ WHERE
 			) > 0
 		ELSE true
 	END
+	-- Filter by locked workspaces
+	AND CASE
+		WHEN $11 :: boolean THEN
+			workspaces.locked_at IS NOT NULL
+		ELSE true
+	END
+	-- Filter by running workspaces, matching the 'running' status
+	AND CASE
+		WHEN $12 :: boolean THEN
+			latest_build.completed_at IS NOT NULL AND
+			latest_build.canceled_at IS NULL AND
+			latest_build.error IS NULL AND
+			latest_build.transition = 'start'::workspace_transition
+		ELSE true
+	END
 	-- Authorize Filter clause will be injected below in GetAuthorizedWorkspaces
 	-- @authorize_filter
 ORDER BY
+	-- An explicit sort column, if requested, takes precedence over the default
+	-- ordering below. Only whitelisted values are accepted, see
+	-- ValidateWorkspacesSort.
+	CASE WHEN $13 :: text = 'last_used_at' AND $14 :: text != 'desc' THEN workspaces.last_used_at END ASC,
+	CASE WHEN $13 :: text = 'last_used_at' AND $14 :: text = 'desc' THEN workspaces.last_used_at END DESC,
+	CASE WHEN $13 :: text = 'name' AND $14 :: text != 'desc' THEN LOWER(workspaces.name) END ASC,
+	CASE WHEN $13 :: text = 'name' AND $14 :: text = 'desc' THEN LOWER(workspaces.name) END DESC,
+	CASE WHEN $13 :: text = 'created_at' AND $14 :: text != 'desc' THEN workspaces.created_at END ASC,
+	CASE WHEN $13 :: text = 'created_at' AND $14 :: text = 'desc' THEN workspaces.created_at END DESC,
 	(latest_build.completed_at IS NOT NULL AND
 		latest_build.canceled_at IS NULL AND
 		latest_build.error IS NULL AND
@@ -8839,11 +10117,11 @@ ORDER BY
 	LOWER(workspaces.name) ASC
 LIMIT
 	CASE
-		WHEN $11 :: integer > 0 THEN
-			$11
+		WHEN $16 :: integer > 0 THEN
+			$16
 	END
 OFFSET
-	$10
+	$15
 `
 
 type GetWorkspacesParams struct {
@@ -8854,8 +10132,13 @@ type GetWorkspacesParams struct {
 	TemplateName                          string      `db:"template_name" json:"template_name"`
 	TemplateIDs                           []uuid.UUID `db:"template_ids" json:"template_ids"`
 	Name                                  string      `db:"name" json:"name"`
+	SearchQuery                           string      `db:"search_query" json:"search_query"`
 	HasAgent                              string      `db:"has_agent" json:"has_agent"`
 	AgentInactiveDisconnectTimeoutSeconds int64       `db:"agent_inactive_disconnect_timeout_seconds" json:"agent_inactive_disconnect_timeout_seconds"`
+	LockedOnly                            bool        `db:"locked_only" json:"locked_only"`
+	RunningOnly                           bool        `db:"running_only" json:"running_only"`
+	SortBy                                string      `db:"sort_by" json:"sort_by"`
+	SortOrder                             string      `db:"sort_order" json:"sort_order"`
 	Offset                                int32       `db:"offset_" json:"offset_"`
 	Limit                                 int32       `db:"limit_" json:"limit_"`
 }
@@ -8889,8 +10172,13 @@ func (q *sqlQuerier) GetWorkspaces(ctx context.Context, arg GetWorkspacesParams)
 		arg.TemplateName,
 		pq.Array(arg.TemplateIDs),
 		arg.Name,
+		arg.SearchQuery,
 		arg.HasAgent,
 		arg.AgentInactiveDisconnectTimeoutSeconds,
+		arg.LockedOnly,
+		arg.RunningOnly,
+		arg.SortBy,
+		arg.SortOrder,
 		arg.Offset,
 		arg.Limit,
 	)
@@ -8933,6 +10221,111 @@ func (q *sqlQuerier) GetWorkspaces(ctx context.Context, arg GetWorkspacesParams)
 	return items, nil
 }
 
+const getWorkspacesDueForAutostop = `-- name: GetWorkspacesDueForAutostop :many
+-- Returns the running workspaces whose latest build has passed its deadline,
+-- along with that build, so that the autostop loop needn't look each build up.
+-- A zero deadline means the build has none.
+SELECT
+	workspaces.id, workspaces.created_at, workspaces.updated_at, workspaces.owner_id, workspaces.organization_id, workspaces.template_id, workspaces.deleted, workspaces.name, workspaces.autostart_schedule, workspaces.ttl, workspaces.last_used_at, workspaces.locked_at, workspaces.deleting_at,
+	latest_build.id AS latest_build_id,
+	latest_build.deadline AS latest_build_deadline
+FROM
+	workspaces
+JOIN LATERAL (
+	SELECT
+		workspace_builds.id,
+		workspace_builds.transition,
+		workspace_builds.deadline,
+		provisioner_jobs.canceled_at,
+		provisioner_jobs.completed_at,
+		provisioner_jobs.error
+	FROM
+		workspace_builds
+	JOIN
+		provisioner_jobs
+	ON
+		provisioner_jobs.id = workspace_builds.job_id
+	WHERE
+		workspace_builds.workspace_id = workspaces.id
+	ORDER BY
+		build_number DESC
+	LIMIT
+		1
+) latest_build ON TRUE
+WHERE
+	workspaces.deleted = false
+	-- This matches the "running" status filter of GetWorkspaces.
+	AND latest_build.transition = 'start'::workspace_transition
+	AND latest_build.completed_at IS NOT NULL
+	AND latest_build.canceled_at IS NULL
+	AND (latest_build.error IS NULL OR latest_build.error = '')
+	AND latest_build.deadline != '0001-01-01 00:00:00+00'::timestamptz
+	AND latest_build.deadline < $1 :: timestamptz
+ORDER BY
+	latest_build.deadline ASC,
+	workspaces.id ASC
+`
+
+type GetWorkspacesDueForAutostopRow struct {
+	ID                  uuid.UUID      `db:"id" json:"id"`
+	CreatedAt           time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt           time.Time      `db:"updated_at" json:"updated_at"`
+	OwnerID             uuid.UUID      `db:"owner_id" json:"owner_id"`
+	OrganizationID      uuid.UUID      `db:"organization_id" json:"organization_id"`
+	TemplateID          uuid.UUID      `db:"template_id" json:"template_id"`
+	Deleted             bool           `db:"deleted" json:"deleted"`
+	Name                string         `db:"name" json:"name"`
+	AutostartSchedule   sql.NullString `db:"autostart_schedule" json:"autostart_schedule"`
+	Ttl                 sql.NullInt64  `db:"ttl" json:"ttl"`
+	LastUsedAt          time.Time      `db:"last_used_at" json:"last_used_at"`
+	LockedAt            sql.NullTime   `db:"locked_at" json:"locked_at"`
+	DeletingAt          sql.NullTime   `db:"deleting_at" json:"deleting_at"`
+	LatestBuildID       uuid.UUID      `db:"latest_build_id" json:"latest_build_id"`
+	LatestBuildDeadline time.Time      `db:"latest_build_deadline" json:"latest_build_deadline"`
+}
+
+// Returns the running workspaces whose latest build has passed its deadline,
+// along with that build, so that the autostop loop needn't look each build up.
+// A zero deadline means the build has none.
+func (q *sqlQuerier) GetWorkspacesDueForAutostop(ctx context.Context, now time.Time) ([]GetWorkspacesDueForAutostopRow, error) {
+	rows, err := q.db.QueryContext(ctx, getWorkspacesDueForAutostop, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWorkspacesDueForAutostopRow
+	for rows.Next() {
+		var i GetWorkspacesDueForAutostopRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.OrganizationID,
+			&i.TemplateID,
+			&i.Deleted,
+			&i.Name,
+			&i.AutostartSchedule,
+			&i.Ttl,
+			&i.LastUsedAt,
+			&i.LockedAt,
+			&i.DeletingAt,
+			&i.LatestBuildID,
+			&i.LatestBuildDeadline,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getWorkspacesEligibleForTransition = `-- name: GetWorkspacesEligibleForTransition :many
 SELECT
 	workspaces.id, workspaces.created_at, workspaces.updated_at, workspaces.owner_id, workspaces.organization_id, workspaces.template_id, workspaces.deleted, workspaces.name, workspaces.autostart_schedule, workspaces.ttl, workspaces.last_used_at, workspaces.locked_at, workspaces.deleting_at
@@ -9036,6 +10429,135 @@ func (q *sqlQuerier) GetWorkspacesEligibleForTransition(ctx context.Context, now
 	return items, nil
 }
 
+const getWorkspacesWithFailedLatestBuild = `-- name: GetWorkspacesWithFailedLatestBuild :many
+-- Returns the workspaces whose latest build failed, most recently failed first,
+-- along with the error reported by the build's provisioner job.
+SELECT
+	workspaces.id, workspaces.created_at, workspaces.updated_at, workspaces.owner_id, workspaces.organization_id, workspaces.template_id, workspaces.deleted, workspaces.name, workspaces.autostart_schedule, workspaces.ttl, workspaces.last_used_at, workspaces.locked_at, workspaces.deleting_at,
+	latest_build.id AS latest_build_id,
+	latest_build.transition AS latest_build_transition,
+	latest_build.error :: text AS latest_build_error,
+	latest_build.completed_at AS latest_build_completed_at,
+	COUNT(*) OVER () AS count
+FROM
+	workspaces
+JOIN LATERAL (
+	SELECT
+		workspace_builds.id,
+		workspace_builds.transition,
+		provisioner_jobs.canceled_at,
+		provisioner_jobs.completed_at,
+		provisioner_jobs.error
+	FROM
+		workspace_builds
+	LEFT JOIN
+		provisioner_jobs
+	ON
+		provisioner_jobs.id = workspace_builds.job_id
+	WHERE
+		workspace_builds.workspace_id = workspaces.id
+	ORDER BY
+		build_number DESC
+	LIMIT
+		1
+) latest_build ON TRUE
+WHERE
+	workspaces.deleted = false
+	-- This matches the "failed" status filter of GetWorkspaces.
+	AND (
+		(latest_build.canceled_at IS NOT NULL AND
+			latest_build.error IS NOT NULL) OR
+		(latest_build.completed_at IS NOT NULL AND
+			latest_build.error IS NOT NULL)
+	)
+	-- Filter by organization_id
+	AND CASE
+		WHEN $1 :: uuid != '00000000-0000-0000-0000-000000000000'::uuid THEN
+			workspaces.organization_id = $1
+		ELSE true
+	END
+	-- Authorize Filter clause will be injected below in GetAuthorizedWorkspacesWithFailedLatestBuild
+	-- @authorize_filter
+ORDER BY
+	latest_build.completed_at DESC NULLS LAST,
+	workspaces.id ASC
+OFFSET $2
+LIMIT
+	-- A null limit means "no limit", so 0 means return all
+	NULLIF($3 :: int, 0)
+`
+
+type GetWorkspacesWithFailedLatestBuildParams struct {
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+	OffsetOpt      int32     `db:"offset_opt" json:"offset_opt"`
+	LimitOpt       int32     `db:"limit_opt" json:"limit_opt"`
+}
+
+type GetWorkspacesWithFailedLatestBuildRow struct {
+	ID                     uuid.UUID           `db:"id" json:"id"`
+	CreatedAt              time.Time           `db:"created_at" json:"created_at"`
+	UpdatedAt              time.Time           `db:"updated_at" json:"updated_at"`
+	OwnerID                uuid.UUID           `db:"owner_id" json:"owner_id"`
+	OrganizationID         uuid.UUID           `db:"organization_id" json:"organization_id"`
+	TemplateID             uuid.UUID           `db:"template_id" json:"template_id"`
+	Deleted                bool                `db:"deleted" json:"deleted"`
+	Name                   string              `db:"name" json:"name"`
+	AutostartSchedule      sql.NullString      `db:"autostart_schedule" json:"autostart_schedule"`
+	Ttl                    sql.NullInt64       `db:"ttl" json:"ttl"`
+	LastUsedAt             time.Time           `db:"last_used_at" json:"last_used_at"`
+	LockedAt               sql.NullTime        `db:"locked_at" json:"locked_at"`
+	DeletingAt             sql.NullTime        `db:"deleting_at" json:"deleting_at"`
+	LatestBuildID          uuid.UUID           `db:"latest_build_id" json:"latest_build_id"`
+	LatestBuildTransition  WorkspaceTransition `db:"latest_build_transition" json:"latest_build_transition"`
+	LatestBuildError       string              `db:"latest_build_error" json:"latest_build_error"`
+	LatestBuildCompletedAt sql.NullTime        `db:"latest_build_completed_at" json:"latest_build_completed_at"`
+	Count                  int64               `db:"count" json:"count"`
+}
+
+// Returns the workspaces whose latest build failed, most recently failed first,
+// along with the error reported by the build's provisioner job.
+func (q *sqlQuerier) GetWorkspacesWithFailedLatestBuild(ctx context.Context, arg GetWorkspacesWithFailedLatestBuildParams) ([]GetWorkspacesWithFailedLatestBuildRow, error) {
+	rows, err := q.db.QueryContext(ctx, getWorkspacesWithFailedLatestBuild, arg.OrganizationID, arg.OffsetOpt, arg.LimitOpt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWorkspacesWithFailedLatestBuildRow
+	for rows.Next() {
+		var i GetWorkspacesWithFailedLatestBuildRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.OrganizationID,
+			&i.TemplateID,
+			&i.Deleted,
+			&i.Name,
+			&i.AutostartSchedule,
+			&i.Ttl,
+			&i.LastUsedAt,
+			&i.LockedAt,
+			&i.DeletingAt,
+			&i.LatestBuildID,
+			&i.LatestBuildTransition,
+			&i.LatestBuildError,
+			&i.LatestBuildCompletedAt,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const insertWorkspace = `-- name: InsertWorkspace :one
 INSERT INTO
 	workspaces (
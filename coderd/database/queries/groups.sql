@@ -30,6 +30,21 @@ WHERE
 AND
 	id != $1;
 
+-- name: GetUserGroups :many
+-- Returns the groups the user is an explicit member of, across all
+-- organizations. The "Everyone" group of an organization has no explicit
+-- members, so it is not included.
+SELECT
+	groups.*
+FROM
+	groups
+JOIN
+	group_members ON group_members.group_id = groups.id
+WHERE
+	group_members.user_id = $1
+ORDER BY
+	groups.name ASC;
+
 -- name: InsertGroup :one
 INSERT INTO groups (
 	id,
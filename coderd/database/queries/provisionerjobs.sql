@@ -24,6 +24,7 @@ WHERE
 			-- Ensure the caller satisfies all job tags.
 			AND nested.tags <@ @tags :: jsonb
 		ORDER BY
+			nested.priority DESC,
 			nested.created_at
 		FOR UPDATE
 		SKIP LOCKED
@@ -31,6 +32,24 @@ WHERE
 			1
 	) RETURNING *;
 
+-- Returns the number of pending or running workspace build jobs for each
+-- template.
+-- name: GetActiveJobCountsByTemplate :many
+SELECT
+	workspaces.template_id,
+	COUNT(*) AS count
+FROM
+	provisioner_jobs
+INNER JOIN
+	workspace_builds ON workspace_builds.job_id = provisioner_jobs.id
+INNER JOIN
+	workspaces ON workspaces.id = workspace_builds.workspace_id
+WHERE
+	provisioner_jobs.completed_at IS NULL
+	AND provisioner_jobs.canceled_at IS NULL
+GROUP BY
+	workspaces.template_id;
+
 -- name: GetProvisionerJobByID :one
 SELECT
 	*
@@ -50,7 +69,7 @@ WHERE
 -- name: GetProvisionerJobsByIDsWithQueuePosition :many
 WITH unstarted_jobs AS (
     SELECT
-        id, created_at
+        id, created_at, priority
     FROM
         provisioner_jobs
     WHERE
@@ -59,7 +78,7 @@ WITH unstarted_jobs AS (
 queue_position AS (
     SELECT
         id,
-        ROW_NUMBER() OVER (ORDER BY created_at ASC) AS queue_position
+        ROW_NUMBER() OVER (ORDER BY priority DESC, created_at ASC) AS queue_position
     FROM
         unstarted_jobs
 ),
@@ -96,10 +115,12 @@ INSERT INTO
 		"type",
 		"input",
 		tags,
-		trace_metadata
+		trace_metadata,
+		priority,
+		pending_deadline
 	)
 VALUES
-	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING *;
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING *;
 
 -- name: UpdateProvisionerJobByID :exec
 UPDATE
@@ -138,3 +159,21 @@ WHERE
 	updated_at < $1
 	AND started_at IS NOT NULL
 	AND completed_at IS NULL;
+
+-- name: FailStalePendingJobs :many
+-- Fails the jobs that have not been acquired by a provisioner daemon by their
+-- pending deadline. The jobs are marked as started too, so that they read as
+-- failed rather than pending.
+UPDATE
+	provisioner_jobs
+SET
+	updated_at = @now :: timestamptz,
+	started_at = COALESCE(started_at, @now :: timestamptz),
+	completed_at = @now :: timestamptz,
+	error = 'Coder: Build was not picked up by a provisioner daemon before its pending timeout.'
+WHERE
+	started_at IS NULL
+	AND canceled_at IS NULL
+	AND completed_at IS NULL
+	AND pending_deadline <= @now :: timestamptz
+RETURNING *;
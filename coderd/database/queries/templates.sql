@@ -34,6 +34,28 @@ WHERE
 			id = ANY(@ids)
 		ELSE true
 	END
+	-- Filter out templates with any workspace builds since the cutoff
+	AND CASE
+		WHEN @no_builds_since :: timestamp with time zone != '0001-01-01 00:00:00Z' THEN
+			NOT EXISTS (
+				SELECT
+					1
+				FROM
+					workspace_builds
+				INNER JOIN
+					workspaces ON workspaces.id = workspace_builds.workspace_id
+				WHERE
+					workspaces.template_id = templates.id
+					AND workspace_builds.created_at > @no_builds_since
+			)
+		ELSE true
+	END
+	-- Filter by created_by
+	AND CASE
+		WHEN @created_by :: uuid != '00000000-0000-0000-0000-000000000000'::uuid THEN
+			created_by = @created_by
+		ELSE true
+	END
   -- Authorize Filter clause will be injected below in GetAuthorizedTemplates
   -- @authorize_filter
 ORDER BY (name, id) ASC
@@ -137,6 +159,35 @@ WHERE
 	id = $3
 ;
 
+-- name: GetMostUsedTemplates :many
+-- GetMostUsedTemplates returns the templates ranked by the number of workspace
+-- builds created between start and end time, most used first, along with the
+-- number of builds. Templates without builds in the period and deleted
+-- templates are omitted.
+SELECT
+	templates.id AS template_id,
+	templates.name AS template_name,
+	templates.display_name AS template_display_name,
+	templates.icon AS template_icon,
+	COUNT(*) AS build_count
+FROM
+	workspace_builds
+JOIN workspaces ON
+	workspace_builds.workspace_id = workspaces.id
+JOIN templates ON
+	workspaces.template_id = templates.id
+WHERE
+	templates.deleted = false AND
+	workspace_builds.created_at >= @start_time::timestamptz AND
+	workspace_builds.created_at < @end_time::timestamptz
+GROUP BY
+	templates.id
+ORDER BY
+	build_count DESC, templates.name ASC
+LIMIT
+	-- A null limit means "no limit", so 0 means return all
+	NULLIF(@limit_opt :: int, 0);
+
 -- name: GetTemplateAverageBuildTime :one
 WITH build_times AS (
 SELECT
@@ -168,3 +219,27 @@ SELECT
 	coalesce((PERCENTILE_DISC(0.95) WITHIN GROUP(ORDER BY exec_time_sec) FILTER (WHERE transition = 'delete')), -1)::FLOAT AS delete_95
 FROM build_times
 ;
+
+-- name: GetTemplateBuildsPerDay :many
+-- GetTemplateBuildsPerDay returns the number of workspace builds created per
+-- day for workspaces of the given template between start and end time, along
+-- with a breakdown per transition. Days without any builds are omitted.
+SELECT
+	date_trunc('day', workspace_builds.created_at)::timestamptz AS day,
+	COUNT(*) AS count,
+	COUNT(*) FILTER (WHERE workspace_builds.transition = 'start') AS start_count,
+	COUNT(*) FILTER (WHERE workspace_builds.transition = 'stop') AS stop_count,
+	COUNT(*) FILTER (WHERE workspace_builds.transition = 'delete') AS delete_count
+FROM
+	workspace_builds
+JOIN workspaces ON
+	workspace_builds.workspace_id = workspaces.id
+WHERE
+	workspaces.template_id = @template_id AND
+	workspace_builds.created_at >= @start_time::timestamptz AND
+	workspace_builds.created_at < @end_time::timestamptz
+GROUP BY
+	day
+ORDER BY
+	day ASC
+;
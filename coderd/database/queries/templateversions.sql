@@ -80,10 +80,11 @@ INSERT INTO
 		message,
 		readme,
 		job_id,
-		created_by
+		created_by,
+		throwaway
 	)
 VALUES
-	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);
 
 -- name: UpdateTemplateVersionByID :exec
 UPDATE
@@ -129,3 +130,34 @@ WHERE
 	AND template_id = $3
 ORDER BY created_at DESC
 LIMIT 1;
+
+-- name: GetUnusedTemplateVersions :many
+-- Returns the versions of the template that no workspace has been built with
+-- successfully, i.e. without a build whose provisioner job completed without
+-- error. These are candidates for cleanup.
+SELECT
+	*
+FROM
+	template_version_with_user AS template_versions
+WHERE
+	template_versions.template_id = @template_id :: uuid
+	AND NOT EXISTS (
+		SELECT
+			1
+		FROM
+			workspace_builds
+		JOIN
+			provisioner_jobs ON provisioner_jobs.id = workspace_builds.job_id
+		WHERE
+			workspace_builds.template_version_id = template_versions.id
+			AND provisioner_jobs.completed_at IS NOT NULL
+			AND provisioner_jobs.canceled_at IS NULL
+			AND COALESCE(provisioner_jobs.error, '') = ''
+	)
+ORDER BY
+	template_versions.created_at ASC, template_versions.id ASC;
+
+-- Throwaway versions are only needed while they are inspected, so they are
+-- purged a day after they are created.
+-- name: DeleteOldThrowawayTemplateVersions :exec
+DELETE FROM template_versions WHERE throwaway AND created_at < NOW() - INTERVAL '1 day';
@@ -0,0 +1,32 @@
+-- name: InsertWorkspaceBuildIntent :exec
+INSERT INTO
+	workspace_build_intents (
+		id,
+		created_at,
+		workspace_id,
+		initiator_id,
+		transition,
+		reason,
+		template_version_id
+	)
+VALUES
+	($1, $2, $3, $4, $5, $6, $7);
+
+-- name: UpdateWorkspaceBuildIntentBuildByID :exec
+UPDATE
+	workspace_build_intents
+SET
+	workspace_build_id = $3
+WHERE
+	id = $1
+	AND workspace_id = $2;
+
+-- name: GetWorkspaceBuildIntentsByWorkspaceID :many
+SELECT
+	*
+FROM
+	workspace_build_intents
+WHERE
+	workspace_id = $1
+ORDER BY
+	created_at DESC;
@@ -8,9 +8,14 @@ SELECT
 RETURNING *;
 
 -- name: GetWorkspaceBuildParameters :many
+-- Builds that share the parameters of a prior build resolve to that build's
+-- parameters.
 SELECT
     *
 FROM
     workspace_build_parameters
 WHERE
-    workspace_build_id = $1;
+    workspace_build_id = COALESCE(
+        (SELECT parameters_from_build_id FROM workspace_builds WHERE id = @workspace_build_id :: uuid),
+        @workspace_build_id :: uuid
+    );
@@ -30,6 +30,96 @@ WHERE
 	workspace_id = $1
 	AND build_number = $2;
 
+-- name: GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey :one
+SELECT
+	*
+FROM
+	workspace_build_with_user AS workspace_builds
+WHERE
+	workspace_id = $1
+	AND idempotency_key = $2;
+
+-- name: GetWorkspaceBuildVersionLineage :many
+-- Returns the builds of a workspace with the name of the template version each
+-- one used, oldest first, e.g. to draw how the workspace moved between
+-- versions over time.
+SELECT
+	workspace_builds.id,
+	workspace_builds.build_number,
+	workspace_builds.transition,
+	workspace_builds.created_at,
+	workspace_builds.template_version_id,
+	template_versions.name AS template_version_name
+FROM
+	workspace_builds
+JOIN
+	template_versions ON template_versions.id = workspace_builds.template_version_id
+WHERE
+	workspace_builds.workspace_id = $1
+ORDER BY
+	workspace_builds.build_number ASC;
+
+-- name: GetWorkspaceBuilds :many
+SELECT
+	workspace_builds.*,
+	workspaces.name AS workspace_name,
+	workspaces.owner_id AS workspace_owner_id,
+	workspaces.organization_id AS workspace_organization_id,
+	workspaces.template_id AS workspace_template_id,
+	COUNT(*) OVER () AS count
+FROM
+	workspace_build_with_user AS workspace_builds
+INNER JOIN
+	workspaces ON workspaces.id = workspace_builds.workspace_id
+WHERE
+	workspace_builds.workspace_id IN (
+		SELECT
+			id
+		FROM
+			workspaces
+		WHERE
+			-- Filter by organization_id
+			CASE
+				WHEN @organization_id :: uuid != '00000000-0000-0000-0000-000000000000'::uuid THEN
+					organization_id = @organization_id
+				ELSE true
+			END
+			-- Authorize Filter clause will be injected below in GetAuthorizedWorkspaceBuilds
+			-- @authorize_filter
+	)
+ORDER BY
+	workspace_builds.created_at DESC,
+	workspace_builds.id DESC
+OFFSET @offset_opt
+LIMIT
+	-- A null limit means "no limit", so 0 means return all
+	NULLIF(@limit_opt :: int, 0);
+
+-- name: GetWorkspaceBuildsByInitiator :many
+-- Returns the builds started by the initiator across all workspaces, newest
+-- first, along with the names of the workspace and template they belong to.
+SELECT
+	workspace_builds.*,
+	workspaces.name AS workspace_name,
+	workspaces.owner_id AS workspace_owner_id,
+	workspaces.organization_id AS workspace_organization_id,
+	templates.name AS template_name
+FROM
+	workspace_build_with_user AS workspace_builds
+JOIN
+	workspaces ON workspaces.id = workspace_builds.workspace_id
+JOIN
+	templates ON templates.id = workspaces.template_id
+WHERE
+	workspace_builds.initiator_id = @initiator_id
+ORDER BY
+	workspace_builds.created_at DESC,
+	workspace_builds.id DESC
+OFFSET @offset_opt
+LIMIT
+	-- A null limit means "no limit", so 0 means return all
+	NULLIF(@limit_opt :: int, 0);
+
 -- name: GetWorkspaceBuildsByWorkspaceID :many
 SELECT
 	*
@@ -63,6 +153,65 @@ LIMIT
     -- A null limit means "no limit", so 0 means return all
     NULLIF(@limit_opt :: int, 0);
 
+-- name: GetWorkspaceBuildsWithParametersJSON :many
+-- Returns all builds of the workspace, oldest first, each with its parameters
+-- as a JSON array ordered by name. A build that shares the parameters of a
+-- prior build gets that build's parameters. Fetching both in a single query
+-- means exporting a workspace doesn't take a query per build.
+-- GetWorkspaceBuildsWithParameters decodes the parameters.
+SELECT
+	sqlc.embed(workspace_builds),
+	COALESCE(
+		(
+			SELECT
+				json_agg(workspace_build_parameters ORDER BY workspace_build_parameters.name)
+			FROM
+				workspace_build_parameters
+			WHERE
+				workspace_build_parameters.workspace_build_id = COALESCE(workspace_builds.parameters_from_build_id, workspace_builds.id)
+		),
+		'[]'
+	) :: jsonb AS parameters
+FROM
+	workspace_build_with_user AS workspace_builds
+WHERE
+	workspace_builds.workspace_id = @workspace_id
+ORDER BY
+	workspace_builds.build_number ASC;
+
+-- name: GetLatestBuildByTemplateVersionID :one
+-- Returns the most recently created build using the template version, across
+-- all workspaces.
+SELECT
+	*
+FROM
+	workspace_build_with_user AS workspace_builds
+WHERE
+	template_version_id = $1
+ORDER BY
+	created_at desc
+LIMIT
+	1;
+
+-- name: GetLatestSuccessfulWorkspaceBuildByWorkspaceID :one
+-- Returns the most recent build of the workspace whose provisioner job
+-- completed without error, regardless of transition.
+SELECT
+	workspace_builds.*
+FROM
+	workspace_build_with_user AS workspace_builds
+JOIN
+	provisioner_jobs ON provisioner_jobs.id = workspace_builds.job_id
+WHERE
+	workspace_builds.workspace_id = $1
+	AND provisioner_jobs.completed_at IS NOT NULL
+	AND provisioner_jobs.canceled_at IS NULL
+	AND COALESCE(provisioner_jobs.error, '') = ''
+ORDER BY
+	workspace_builds.build_number desc
+LIMIT
+	1;
+
 -- name: GetLatestWorkspaceBuildByWorkspaceID :one
 SELECT
 	*
@@ -105,6 +254,26 @@ JOIN
 	 workspace_build_with_user AS wb
 ON m.workspace_id = wb.workspace_id AND m.max_build_number = wb.build_number;
 
+-- name: GetStuckRunningBuilds :many
+-- Returns the builds whose job is still running and started before
+-- started_before, longest running first, for alerting on builds that are
+-- stuck. Jobs that are being canceled are not considered running.
+SELECT
+	workspace_builds.*
+FROM
+	workspace_build_with_user AS workspace_builds
+JOIN
+	provisioner_jobs
+ON
+	provisioner_jobs.id = workspace_builds.job_id
+WHERE
+	provisioner_jobs.started_at IS NOT NULL
+	AND provisioner_jobs.completed_at IS NULL
+	AND provisioner_jobs.canceled_at IS NULL
+	AND provisioner_jobs.started_at < @started_before :: timestamptz
+ORDER BY
+	provisioner_jobs.started_at ASC;
+
 -- name: InsertWorkspaceBuild :exec
 INSERT INTO
 	workspace_builds (
@@ -120,10 +289,37 @@ INSERT INTO
 		provisioner_state,
 		deadline,
 		max_deadline,
-		reason
+		reason,
+		rollback_of,
+		parameters_from_build_id,
+		structured_reason,
+		idempotency_key,
+		template_file_hash,
+		schedule_name
 	)
 VALUES
-	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);
+	(
+		@id,
+		@created_at,
+		@updated_at,
+		@workspace_id,
+		@template_version_id,
+		@build_number,
+		@transition,
+		@initiator_id,
+		@job_id,
+		@provisioner_state,
+		@deadline,
+		@max_deadline,
+		@reason,
+		@rollback_of,
+		@parameters_from_build_id,
+		@structured_reason,
+		@idempotency_key,
+		-- Record the hash of the template version's source, if it is still stored.
+		(SELECT hash FROM files WHERE id = @template_file_id :: uuid),
+		@schedule_name
+	);
 
 -- name: UpdateWorkspaceBuildByID :exec
 UPDATE
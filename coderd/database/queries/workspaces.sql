@@ -222,6 +222,13 @@ WHERE
 			workspaces.name ILIKE '%' || @name || '%'
 		ELSE true
 	END
+	-- Filter by search query, matching on substring of either the workspace name
+	-- or the owner's username
+	AND CASE
+		WHEN @search_query :: text != '' THEN
+			(workspaces.name ILIKE concat('%', @search_query, '%') OR users.username ILIKE concat('%', @search_query, '%'))
+		ELSE true
+	END
 	-- Filter by agent status
 	-- has-agent: is only applicable for workspaces in "start" transition. Stopped and deleted workspaces don't have agents.
 	AND CASE
@@ -259,9 +266,33 @@ WHERE
 			) > 0
 		ELSE true
 	END
+	-- Filter by locked workspaces
+	AND CASE
+		WHEN @locked_only :: boolean THEN
+			workspaces.locked_at IS NOT NULL
+		ELSE true
+	END
+	-- Filter by running workspaces, matching the 'running' status
+	AND CASE
+		WHEN @running_only :: boolean THEN
+			latest_build.completed_at IS NOT NULL AND
+			latest_build.canceled_at IS NULL AND
+			latest_build.error IS NULL AND
+			latest_build.transition = 'start'::workspace_transition
+		ELSE true
+	END
 	-- Authorize Filter clause will be injected below in GetAuthorizedWorkspaces
 	-- @authorize_filter
 ORDER BY
+	-- An explicit sort column, if requested, takes precedence over the default
+	-- ordering below. Only whitelisted values are accepted, see
+	-- ValidateWorkspacesSort.
+	CASE WHEN @sort_by :: text = 'last_used_at' AND @sort_order :: text != 'desc' THEN workspaces.last_used_at END ASC,
+	CASE WHEN @sort_by :: text = 'last_used_at' AND @sort_order :: text = 'desc' THEN workspaces.last_used_at END DESC,
+	CASE WHEN @sort_by :: text = 'name' AND @sort_order :: text != 'desc' THEN LOWER(workspaces.name) END ASC,
+	CASE WHEN @sort_by :: text = 'name' AND @sort_order :: text = 'desc' THEN LOWER(workspaces.name) END DESC,
+	CASE WHEN @sort_by :: text = 'created_at' AND @sort_order :: text != 'desc' THEN workspaces.created_at END ASC,
+	CASE WHEN @sort_by :: text = 'created_at' AND @sort_order :: text = 'desc' THEN workspaces.created_at END DESC,
 	(latest_build.completed_at IS NOT NULL AND
 		latest_build.canceled_at IS NULL AND
 		latest_build.error IS NULL AND
@@ -288,6 +319,17 @@ WHERE
 	AND LOWER("name") = LOWER(@name)
 ORDER BY created_at DESC;
 
+-- name: GetWorkspaceCountByOwner :one
+-- Counts the workspaces owned by a user, e.g. for quota enforcement. Deleted
+-- workspaces are only counted if include_deleted is true.
+SELECT
+	COUNT(*)
+FROM
+	workspaces
+WHERE
+	owner_id = @owner_id
+	AND (@include_deleted :: boolean OR deleted = false);
+
 -- name: InsertWorkspace :one
 INSERT INTO
 	workspaces (
@@ -409,6 +451,50 @@ SELECT
 	stopped_workspaces.count AS stopped_workspaces
 FROM pending_workspaces, building_workspaces, running_workspaces, failed_workspaces, stopped_workspaces;
 
+-- name: GetWorkspacesDueForAutostop :many
+-- Returns the running workspaces whose latest build has passed its deadline,
+-- along with that build, so that the autostop loop needn't look each build up.
+-- A zero deadline means the build has none.
+SELECT
+	workspaces.*,
+	latest_build.id AS latest_build_id,
+	latest_build.deadline AS latest_build_deadline
+FROM
+	workspaces
+JOIN LATERAL (
+	SELECT
+		workspace_builds.id,
+		workspace_builds.transition,
+		workspace_builds.deadline,
+		provisioner_jobs.canceled_at,
+		provisioner_jobs.completed_at,
+		provisioner_jobs.error
+	FROM
+		workspace_builds
+	JOIN
+		provisioner_jobs
+	ON
+		provisioner_jobs.id = workspace_builds.job_id
+	WHERE
+		workspace_builds.workspace_id = workspaces.id
+	ORDER BY
+		build_number DESC
+	LIMIT
+		1
+) latest_build ON TRUE
+WHERE
+	workspaces.deleted = false
+	-- This matches the "running" status filter of GetWorkspaces.
+	AND latest_build.transition = 'start'::workspace_transition
+	AND latest_build.completed_at IS NOT NULL
+	AND latest_build.canceled_at IS NULL
+	AND (latest_build.error IS NULL OR latest_build.error = '')
+	AND latest_build.deadline != '0001-01-01 00:00:00+00'::timestamptz
+	AND latest_build.deadline < @now :: timestamptz
+ORDER BY
+	latest_build.deadline ASC,
+	workspaces.id ASC;
+
 -- name: GetWorkspacesEligibleForTransition :many
 SELECT
 	workspaces.*
@@ -474,6 +560,63 @@ WHERE
 		)
 	) AND workspaces.deleted = 'false';
 
+-- name: GetWorkspacesWithFailedLatestBuild :many
+-- Returns the workspaces whose latest build failed, most recently failed first,
+-- along with the error reported by the build's provisioner job.
+SELECT
+	workspaces.*,
+	latest_build.id AS latest_build_id,
+	latest_build.transition AS latest_build_transition,
+	latest_build.error :: text AS latest_build_error,
+	latest_build.completed_at AS latest_build_completed_at,
+	COUNT(*) OVER () AS count
+FROM
+	workspaces
+JOIN LATERAL (
+	SELECT
+		workspace_builds.id,
+		workspace_builds.transition,
+		provisioner_jobs.canceled_at,
+		provisioner_jobs.completed_at,
+		provisioner_jobs.error
+	FROM
+		workspace_builds
+	LEFT JOIN
+		provisioner_jobs
+	ON
+		provisioner_jobs.id = workspace_builds.job_id
+	WHERE
+		workspace_builds.workspace_id = workspaces.id
+	ORDER BY
+		build_number DESC
+	LIMIT
+		1
+) latest_build ON TRUE
+WHERE
+	workspaces.deleted = false
+	-- This matches the "failed" status filter of GetWorkspaces.
+	AND (
+		(latest_build.canceled_at IS NOT NULL AND
+			latest_build.error IS NOT NULL) OR
+		(latest_build.completed_at IS NOT NULL AND
+			latest_build.error IS NOT NULL)
+	)
+	-- Filter by organization_id
+	AND CASE
+		WHEN @organization_id :: uuid != '00000000-0000-0000-0000-000000000000'::uuid THEN
+			workspaces.organization_id = @organization_id
+		ELSE true
+	END
+	-- Authorize Filter clause will be injected below in GetAuthorizedWorkspacesWithFailedLatestBuild
+	-- @authorize_filter
+ORDER BY
+	latest_build.completed_at DESC NULLS LAST,
+	workspaces.id ASC
+OFFSET @offset_opt
+LIMIT
+	-- A null limit means "no limit", so 0 means return all
+	NULLIF(@limit_opt :: int, 0);
+
 -- name: UpdateWorkspaceLockedDeletingAt :exec
 UPDATE
 	workspaces
@@ -80,6 +80,36 @@ func (m StringMap) Value() (driver.Value, error) {
 	return json.Marshal(m)
 }
 
+// StructuredBuildReason is a finer grained reason for a workspace build than
+// BuildReason. The zero value is stored as NULL.
+type StructuredBuildReason struct {
+	Category    string `json:"category"`
+	Subcategory string `json:"subcategory"`
+	// Source is the system that requested the build.
+	Source string `json:"source"`
+}
+
+func (r *StructuredBuildReason) Scan(src interface{}) error {
+	if src == nil {
+		*r = StructuredBuildReason{}
+		return nil
+	}
+	switch src := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(src), r)
+	case []byte:
+		return json.Unmarshal(src, r)
+	}
+	return xerrors.Errorf("unsupported Scan, storing driver.Value type %T into type %T", src, r)
+}
+
+func (r StructuredBuildReason) Value() (driver.Value, error) {
+	if r == (StructuredBuildReason{}) {
+		return nil, nil
+	}
+	return json.Marshal(r)
+}
+
 // Now returns a standardized timezone used for database resources.
 func Now() time.Time {
 	return Time(time.Now().UTC())
@@ -139,6 +139,7 @@ func (api *API) fileByID(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	rw.Header().Set("Content-Type", file.Mimetype)
+	rw.Header().Set(codersdk.FileHashHeader, file.Hash)
 	rw.WriteHeader(http.StatusOK)
 	_, _ = rw.Write(file.Data)
 }
@@ -3,6 +3,8 @@ package coderd_test
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 	"testing"
 
@@ -82,9 +84,11 @@ func TestDownload(t *testing.T) {
 
 		resp, err := client.Upload(ctx, codersdk.ContentTypeTar, bytes.NewReader(make([]byte, 1024)))
 		require.NoError(t, err)
-		data, contentType, err := client.Download(ctx, resp.ID)
+		data, contentType, hash, err := client.DownloadWithHash(ctx, resp.ID)
 		require.NoError(t, err)
 		require.Len(t, data, 1024)
 		require.Equal(t, codersdk.ContentTypeTar, contentType)
+		sum := sha256.Sum256(data)
+		require.Equal(t, hex.EncodeToString(sum[:]), hash)
 	})
 }
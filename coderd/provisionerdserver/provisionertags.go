@@ -31,3 +31,16 @@ func MutateTags(userID uuid.UUID, tags map[string]string) map[string]string {
 	}
 	return tags
 }
+
+// TagsMatch returns whether a provisioner daemon with daemonTags may acquire a
+// job with jobTags, i.e. whether the daemon satisfies all of the job's tags.
+// This mirrors the tag check of the AcquireProvisionerJob query.
+func TagsMatch(jobTags, daemonTags map[string]string) bool {
+	for key, value := range jobTags {
+		provided, ok := daemonTags[key]
+		if !ok || provided != value {
+			return false
+		}
+	}
+	return true
+}
@@ -131,6 +131,7 @@ func (api *API) postFirstUser(rw http.ResponseWriter, r *http.Request) {
 		},
 		CreateOrganization: true,
 		LoginType:          database.LoginTypePassword,
+		Name:               createUser.Name,
 	})
 	if err != nil {
 		httpapi.Write(ctx, rw, http.StatusInternalServerError, codersdk.Response{
@@ -1034,6 +1035,9 @@ type CreateUserRequest struct {
 	codersdk.CreateUserRequest
 	CreateOrganization bool
 	LoginType          database.LoginType
+	// Name is the full name of the user, which isn't part of
+	// codersdk.CreateUserRequest.
+	Name string
 }
 
 func (api *API) CreateUser(ctx context.Context, store database.Store, req CreateUserRequest) (database.User, uuid.UUID, error) {
@@ -1083,6 +1087,7 @@ func (api *API) CreateUser(ctx context.Context, store database.Store, req Create
 			// All new users are defaulted to members of the site.
 			RBACRoles: []string{},
 			LoginType: req.LoginType,
+			Name:      req.Name,
 		}
 		// If a user signs up with OAuth, they can have no password!
 		if req.Password != "" {
@@ -361,8 +361,9 @@ func (api *API) postWorkspaceBuilds(rw http.ResponseWriter, r *http.Request) {
 		}
 
 		httpapi.Write(ctx, rw, buildErr.Status, codersdk.Response{
-			Message: buildErr.Message,
-			Detail:  buildErr.Error(),
+			Message:     buildErr.Message,
+			Detail:      buildErr.Error(),
+			Validations: buildErr.Validations(),
 		})
 		return
 	}
@@ -457,8 +457,9 @@ func (api *API) postWorkspacesByOrganization(rw http.ResponseWriter, r *http.Req
 	var bldErr wsbuilder.BuildError
 	if xerrors.As(err, &bldErr) {
 		httpapi.Write(ctx, rw, bldErr.Status, codersdk.Response{
-			Message: bldErr.Message,
-			Detail:  bldErr.Error(),
+			Message:     bldErr.Message,
+			Detail:      bldErr.Error(),
+			Validations: bldErr.Validations(),
 		})
 		return
 	}
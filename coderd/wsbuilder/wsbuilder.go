@@ -3,17 +3,22 @@
 package wsbuilder
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/sqlc-dev/pqtype"
+	"golang.org/x/exp/slices"
 	"golang.org/x/xerrors"
 
+	"cdr.dev/slog"
 	"github.com/coder/coder/coderd/database"
 	"github.com/coder/coder/coderd/database/db2sdk"
 	"github.com/coder/coder/coderd/httpapi"
@@ -39,16 +44,42 @@ type Builder struct {
 	version          versionTarget
 	state            stateTarget
 	logLevel         string
+	pendingTimeout   time.Duration
 	deploymentValues *codersdk.DeploymentValues
+	logger           slog.Logger
 
 	richParameterValues []codersdk.WorkspaceBuildParameter
+	parameterMergeMode  ParameterMergeMode
 	initiator           uuid.UUID
 	reason              database.BuildReason
+	structuredReason    database.StructuredBuildReason
+	scheduleName        string
+	priority            int32
+	maintenanceCheck    func() bool
+	onLegacyParameters  func(names []string)
+	rollbackOf          uuid.NullUUID
+	idempotencyKey      string
+	retryPolicy         *retryPolicy
+	expectedVersionID   *uuid.UUID
+	expectedBuildNumber *int32
+
+	skipUnchangedParameters bool
+	allowUnknownPriorStatus bool
+	allowImmutableChanges   bool
+	strictParameterNames    bool
+	dryRun                  bool
+	deferParameterInsert    bool
+	recordIntent            bool
 
 	// used during build, makes function arguments less verbose
 	ctx   context.Context
 	store database.Store
 
+	// set by authorize if the caller may change immutable parameters
+	immutableChangesAuthorized bool
+	// the intent recorded for the build, if recordIntent and recording it succeeded
+	intentID uuid.UUID
+
 	// cache of objects, so we only fetch once
 	template                  *database.Template
 	templateVersion           *database.TemplateVersion
@@ -57,28 +88,92 @@ type Builder struct {
 	lastBuild                 *database.WorkspaceBuild
 	lastBuildErr              *error
 	lastBuildParameters       *[]database.WorkspaceBuildParameter
+	lastBuildVersionParams    *[]database.TemplateVersionParameter
 	lastBuildJob              *database.ProvisionerJob
+	lastSuccessfulBuild       *database.WorkspaceBuild
 
 	verifyNoLegacyParametersOnce bool
+
+	// how the inserted build differs from the last build
+	diff BuildDiff
+	// the parameters of the inserted build
+	parameterNames, parameterValues []string
+	// the parameter rows left for the caller to insert, if deferParameterInsert
+	deferredParameters []database.WorkspaceBuildParameter
+
+	// results of a dry run
+	dryRunNames, dryRunValues []string
+	dryRunDaemons             []database.ProvisionerDaemon
 }
 
 type Option func(Builder) Builder
 
 // versionTarget expresses how to determine the template version for the build.
 //
-// The zero value of this struct means to use the version from the last build.  If there is no last build,
-// the build will fail.
+// The zero value of this struct means to use the version from the last build.  If there is no last build, i.e. the
+// first build of a newly created workspace, the active version of the template is used.
 //
 // setting active: true means to use the active version from the template.
 //
 // setting specific to a non-nil value means to use the provided template version ID.
 //
-// active and specific are mutually exclusive and setting them both results in undefined behavior.
+// setting lastSuccessful: true means to use the version from the most recent successful build.  If there is no
+// successful build, the build will fail.
+//
+// setting name to a non-nil value means to use the version of the workspace's template with the provided name.
+//
+// active, specific, lastSuccessful and name are mutually exclusive and setting more than one results in undefined
+// behavior.
 type versionTarget struct {
-	active   bool
-	specific *uuid.UUID
+	active         bool
+	specific       *uuid.UUID
+	lastSuccessful bool
+	name           *string
 }
 
+// source describes where the template version is taken from, for diagnostics.
+func (v versionTarget) source() string {
+	switch {
+	case v.specific != nil:
+		return "specific"
+	case v.active:
+		return "active"
+	case v.lastSuccessful:
+		return "last successful build"
+	case v.name != nil:
+		return "name"
+	default:
+		return "last build"
+	}
+}
+
+// ParameterMergeMode controls how the RichParameterValues of a build are combined with the parameter values of the
+// last build.
+type ParameterMergeMode int
+
+const (
+	// ParameterMergeModeMerge keeps the last build's value of any parameter that isn't in RichParameterValues, unless
+	// the parameter is ephemeral.
+	ParameterMergeModeMerge ParameterMergeMode = iota
+	// ParameterMergeModeReplace resets any parameter that isn't in RichParameterValues to its template default.
+	// Immutable parameters are the exception: they keep the last build's value, since they can't be changed.  A
+	// required parameter has no default, so it must be included in RichParameterValues, even if the last build had a
+	// value for it.
+	ParameterMergeModeReplace
+)
+
+// retryPolicy controls how Build retries transactions that fail with a serialization error.
+type retryPolicy struct {
+	// maxAttempts is the number of times the transaction is attempted before giving up.
+	maxAttempts int
+	// backoff returns how long to wait after the given failed attempt, counting from 1.  A nil backoff retries
+	// immediately.
+	backoff func(attempt int) time.Duration
+}
+
+// defaultRetryPolicy attempts the build transaction up to 5 times, without waiting in between.
+var defaultRetryPolicy = retryPolicy{maxAttempts: 5}
+
 // stateTarget expresses how to determine the provisioner state for the build.
 //
 // The zero value of this struct means to use state from the last build.  If there is no last build, no state is
@@ -112,6 +207,22 @@ func (b Builder) ActiveVersion() Builder {
 	return b
 }
 
+// LastSuccessfulVersion builds with the template version of the most recent successful build, rather than that of the
+// last build, e.g. to recover from a build that failed on a broken version.
+func (b Builder) LastSuccessfulVersion() Builder {
+	// nolint: revive
+	b.version = versionTarget{lastSuccessful: true}
+	return b
+}
+
+// TemplateVersionByName builds with the version of the workspace's template that has the given name.  The name is
+// resolved when the build is computed; the build fails with http.StatusNotFound if the template has no such version.
+func (b Builder) TemplateVersionByName(name string) Builder {
+	// nolint: revive
+	b.version = versionTarget{name: &name}
+	return b
+}
+
 func (b Builder) State(state []byte) Builder {
 	// nolint: revive
 	b.state = stateTarget{explicit: &state}
@@ -130,12 +241,29 @@ func (b Builder) LogLevel(l string) Builder {
 	return b
 }
 
+// PendingTimeout sets how long the build's job may wait for a provisioner daemon to acquire it.  Once the timeout has
+// passed, the store's FailStalePendingJobs fails the job if it is still pending.  By default, the job waits
+// indefinitely.  Negative timeouts are rejected by Build.
+func (b Builder) PendingTimeout(d time.Duration) Builder {
+	// nolint: revive
+	b.pendingTimeout = d
+	return b
+}
+
 func (b Builder) DeploymentValues(dv *codersdk.DeploymentValues) Builder {
 	// nolint: revive
 	b.deploymentValues = dv
 	return b
 }
 
+// Logger sets the logger used to emit debug diagnostics about the decisions
+// made while computing the build. By default, nothing is logged.
+func (b Builder) Logger(l slog.Logger) Builder {
+	// nolint: revive
+	b.logger = l
+	return b
+}
+
 func (b Builder) Initiator(u uuid.UUID) Builder {
 	// nolint: revive
 	b.initiator = u
@@ -148,12 +276,172 @@ func (b Builder) Reason(r database.BuildReason) Builder {
 	return b
 }
 
+// StructuredReason records a finer grained reason for the build alongside the BuildReason, for analytics.  If no
+// Reason is set, the BuildReason is derived from the category when it names one, and is "initiator" otherwise.
+func (b Builder) StructuredReason(category, subcategory, source string) Builder {
+	// nolint: revive
+	b.structuredReason = database.StructuredBuildReason{
+		Category:    category,
+		Subcategory: subcategory,
+		Source:      source,
+	}
+	return b
+}
+
+// ScheduleName records the name of the schedule that triggered the build, such as an autostart or autostop schedule,
+// alongside the Reason, so that analytics can attribute builds to specific schedules.
+func (b Builder) ScheduleName(name string) Builder {
+	// nolint: revive
+	b.scheduleName = name
+	return b
+}
+
+// Priority sets the priority of the provisioner job for the build. Provisioner
+// daemons acquire jobs with a higher priority first; jobs with the same priority
+// are acquired in the order they were created. The default priority is 0.
+func (b Builder) Priority(p int32) Builder {
+	// nolint: revive
+	b.priority = p
+	return b
+}
+
+// MaintenanceCheck sets a hook that is consulted before computing the build.  If it returns true, the deployment is
+// considered to be in maintenance mode and the build is rejected.  Delete transitions are always allowed, so that
+// workspaces can still be torn down during maintenance.
+func (b Builder) MaintenanceCheck(fn func() bool) Builder {
+	// nolint: revive
+	b.maintenanceCheck = fn
+	return b
+}
+
+// RollbackOf marks the build as a rollback to the given prior build of the same workspace.  The reference is recorded
+// on the new build for auditing; the build fails if the referenced build belongs to a different workspace.
+func (b Builder) RollbackOf(buildID uuid.UUID) Builder {
+	// nolint: revive
+	b.rollbackOf = uuid.NullUUID{UUID: buildID, Valid: true}
+	return b
+}
+
+// OnLegacyParametersUsed registers a callback that is called with the names of the legacy parameters (parameter
+// schemas) of the template version, if it has any, so that the caller can log a deprecation notice and warn the user.
+// It doesn't change how the build treats legacy parameters.
+func (b Builder) OnLegacyParametersUsed(fn func(names []string)) Builder {
+	// nolint: revive
+	b.onLegacyParameters = fn
+	return b
+}
+
+// RetryPolicy sets how many times the build transaction is attempted when it fails with a serialization error under
+// contention, and how long to wait after each failed attempt, counting from 1.  A nil backoff retries immediately.
+// The default is 5 attempts without waiting.  The transaction is always attempted at least once.
+func (b Builder) RetryPolicy(maxAttempts int, backoff func(attempt int) time.Duration) Builder {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	// nolint: revive
+	b.retryPolicy = &retryPolicy{maxAttempts: maxAttempts, backoff: backoff}
+	return b
+}
+
+// IdempotencyKey identifies the request for the build, so that retries of the same request don't create duplicate
+// builds.  If the workspace already has a build with the key, Build returns that build and its job instead of
+// inserting a new one, or fails with http.StatusConflict if the existing build was requested with a different
+// transition, template version or parameter values.
+func (b Builder) IdempotencyKey(key string) Builder {
+	// nolint: revive
+	b.idempotencyKey = key
+	return b
+}
+
+// SkipUnchangedParameters avoids storing a copy of the build parameters when they are identical to those of the last
+// build, which is typical for stop transitions.  Instead, the new build refers to the build that stored them, and
+// reads of the new build's parameters resolve to that build's.
+func (b Builder) SkipUnchangedParameters() Builder {
+	// nolint: revive
+	b.skipUnchangedParameters = true
+	return b
+}
+
+// AllowUnknownPriorStatus lets the build proceed when the status of the last build's provisioner job can't be
+// determined.  By default, such a job is conservatively treated as still active, and the build is rejected.
+func (b Builder) AllowUnknownPriorStatus() Builder {
+	// nolint: revive
+	b.allowUnknownPriorStatus = true
+	return b
+}
+
+// AllowImmutableParameterChanges permits template managers to change the values of immutable parameters, e.g. to
+// recover a workspace in an emergency.  It only takes effect if Build is given an authFunc that permits updating the
+// template; for anyone else, changing an immutable parameter still fails validation.
+func (b Builder) AllowImmutableParameterChanges() Builder {
+	// nolint: revive
+	b.allowImmutableChanges = true
+	return b
+}
+
+// StrictParameterNames rejects the build if any of the RichParameterValues don't name a parameter of the template
+// version.  By default, such values are ignored.
+func (b Builder) StrictParameterNames() Builder {
+	// nolint: revive
+	b.strictParameterNames = true
+	return b
+}
+
+// DryRun validates the build without inserting anything into the database.  Build performs the same checks and
+// parameter resolution as a real build, and returns the same errors, but returns a nil build and job on success.  The
+// resolved parameters are then available from DryRunParameters, and the provisioner daemons that could run the build
+// from DryRunProvisionerDaemons.
+func (b Builder) DryRun() Builder {
+	// nolint: revive
+	b.dryRun = true
+	return b
+}
+
+// DeferParameterInsert leaves inserting the parameters of the build to the caller, e.g. so that a bulk operation can
+// insert the parameters of many builds at once.  Build inserts the build without its parameters, which are then
+// available from DeferredParameters.  The caller must insert them before the build is picked up by a provisioner, i.e.
+// in the same transaction.
+func (b Builder) DeferParameterInsert() Builder {
+	// nolint: revive
+	b.deferParameterInsert = true
+	return b
+}
+
+// RecordIntent records the request for the build as a workspace build intent before the build is computed, and links
+// it to the build once the build is inserted.  This makes requests that are rejected before a build or job is created,
+// e.g. by failing validation, visible.  The intent is inserted with the store given to Build, outside of the build's
+// transaction, so that it is kept when the build fails; if that store is itself a transaction, the intent is only kept
+// if the caller commits it.  Failing to record the intent doesn't fail the build.  Dry runs record nothing.
+func (b Builder) RecordIntent() Builder {
+	// nolint: revive
+	b.recordIntent = true
+	return b
+}
+
+// Expect makes the build fail with http.StatusConflict unless it uses the given template version and build number,
+// e.g. because a concurrent build changed them since the caller last looked at the workspace.  A nil expectation is
+// not checked.  The expectations are checked after the build is computed, but before anything is inserted.
+func (b Builder) Expect(versionID *uuid.UUID, buildNumber *int32) Builder {
+	// nolint: revive
+	b.expectedVersionID = versionID
+	b.expectedBuildNumber = buildNumber
+	return b
+}
+
 func (b Builder) RichParameterValues(p []codersdk.WorkspaceBuildParameter) Builder {
 	// nolint: revive
 	b.richParameterValues = p
 	return b
 }
 
+// ParameterMergeMode sets how RichParameterValues are combined with the parameter values of the last build.  The
+// default is ParameterMergeModeMerge.
+func (b Builder) ParameterMergeMode(mode ParameterMergeMode) Builder {
+	// nolint: revive
+	b.parameterMergeMode = mode
+	return b
+}
+
 // SetLastWorkspaceBuildInTx prepopulates the Builder's cache with the last workspace build.  This allows us
 // to avoid a repeated database query when the Builder's caller also needs the workspace build, e.g. auto-start &
 // auto-stop.
@@ -193,8 +481,37 @@ func (e BuildError) Unwrap() error {
 	return e.Wrapped
 }
 
+// Validations returns a validation error for each invalid parameter of the build, if the build failed because of
+// them.
+func (e BuildError) Validations() []codersdk.ValidationError {
+	var paramErrs ParameterValidationErrors
+	if !xerrors.As(e.Wrapped, &paramErrs) {
+		return nil
+	}
+	validations := make([]codersdk.ValidationError, 0, len(paramErrs))
+	for _, paramErr := range paramErrs {
+		validations = append(validations, codersdk.ValidationError{
+			Field:  paramErr.Name,
+			Detail: paramErr.Reason,
+		})
+	}
+	return validations
+}
+
+// ParameterValidationErrors are the reasons the parameters of a build are invalid, one per parameter.
+type ParameterValidationErrors []*codersdk.ParameterValidationError
+
+func (e ParameterValidationErrors) Error() string {
+	reasons := make([]string, 0, len(e))
+	for _, err := range e {
+		reasons = append(reasons, err.Reason)
+	}
+	return strings.Join(reasons, "; ")
+}
+
 // Build computes and inserts a new workspace build into the database.  If authFunc is provided, it also performs
-// authorization preflight checks.
+// authorization preflight checks.  If the Builder is a DryRun, nothing is inserted and the returned build and job are
+// nil.
 func (b *Builder) Build(
 	ctx context.Context,
 	store database.Store,
@@ -204,15 +521,23 @@ func (b *Builder) Build(
 ) {
 	b.ctx = ctx
 
+	if b.recordIntent && !b.dryRun {
+		b.insertIntent(store)
+	}
+
 	// Run the build in a transaction with RepeatableRead isolation, and retries.
 	// RepeatableRead isolation ensures that we get a consistent view of the database while
 	// computing the new build.  This simplifies the logic so that we do not need to worry if
 	// later reads are consistent with earlier ones.
+	policy := defaultRetryPolicy
+	if b.retryPolicy != nil {
+		policy = *b.retryPolicy
+	}
 	var err error
-	for retries := 0; retries < 5; retries++ {
+	for retries := 0; retries < policy.maxAttempts; retries++ {
 		var workspaceBuild *database.WorkspaceBuild
 		var provisionerJob *database.ProvisionerJob
-		err := store.InTx(func(store database.Store) error {
+		err = store.InTx(func(store database.Store) error {
 			b.store = store
 			workspaceBuild, provisionerJob, err = b.buildTx(authFunc)
 			return err
@@ -221,9 +546,30 @@ func (b *Builder) Build(
 		if xerrors.As(err, &pqe) {
 			if pqe.Code == "40001" {
 				// serialization error, retry
+				b.logger.Debug(ctx, "serialization error building workspace, retrying",
+					slog.F("workspace_id", b.workspace.ID),
+					slog.F("attempt", retries+1),
+					slog.Error(err),
+				)
+				if policy.backoff != nil && retries+1 < policy.maxAttempts {
+					select {
+					case <-ctx.Done():
+						return nil, nil, ctx.Err()
+					case <-time.After(policy.backoff(retries + 1)):
+					}
+				}
 				continue
 			}
 		}
+		if database.IsUniqueViolation(err, database.UniqueWorkspaceBuildsWorkspaceIDIdempotencyKeyIndex) {
+			// a concurrent request with the same idempotency key inserted its build first; retry so that we
+			// return it
+			b.logger.Debug(ctx, "idempotency key conflict building workspace, retrying",
+				slog.F("workspace_id", b.workspace.ID),
+				slog.F("attempt", retries+1),
+			)
+			continue
+		}
 		if err != nil {
 			// Other (hard) error
 			return nil, nil, err
@@ -233,6 +579,189 @@ func (b *Builder) Build(
 	return nil, nil, xerrors.Errorf("too many errors; last error: %w", err)
 }
 
+// VersionValidation is the outcome of validating a workspace's current parameters against a template version.
+type VersionValidation struct {
+	TemplateVersionID uuid.UUID
+	// Err is the reason the parameters don't validate against the version, or nil if they do.
+	Err error
+}
+
+// ValidateAgainstVersions resolves the parameters of the workspace's last build against each of the candidate template
+// versions, as a start build to that version without new parameter values would, and returns the outcome for each
+// version in order.  Nothing is inserted into the database.  An error is only returned if the data needed to validate
+// could not be fetched; a version the parameters don't validate against is reported in its VersionValidation.
+func ValidateAgainstVersions(
+	ctx context.Context,
+	store database.Store,
+	workspace database.Workspace,
+	candidateVersionIDs []uuid.UUID,
+) ([]VersionValidation, error) {
+	b := New(workspace, database.WorkspaceTransitionStart)
+	b.ctx = ctx
+	b.store = store
+	results := make([]VersionValidation, 0, len(candidateVersionIDs))
+	for _, versionID := range candidateVersionIDs {
+		versionID := versionID
+		// The last build and its parameters stay cached across versions; only the version-specific objects are
+		// fetched again.
+		b.version = versionTarget{specific: &versionID}
+		b.templateVersion = nil
+		b.templateVersionJob = nil
+		b.templateVersionParameters = nil
+		b.verifyNoLegacyParametersOnce = false
+
+		// As in BatchPreflight, a version of another template is reported rather than validated against.
+		err := b.checkTemplateVersionMatchesTemplate()
+		if err == nil {
+			_, _, err = b.getParameters()
+		}
+		var buildErr BuildError
+		if xerrors.As(err, &buildErr) && buildErr.Status == http.StatusInternalServerError {
+			return nil, xerrors.Errorf("validate against template version %s: %w", versionID, err)
+		}
+		results = append(results, VersionValidation{TemplateVersionID: versionID, Err: err})
+	}
+	return results, nil
+}
+
+// WorkspacePreflight is the outcome of validating a workspace's current parameters against a template version with
+// BatchPreflight.
+type WorkspacePreflight struct {
+	WorkspaceID uuid.UUID
+	// Err is the reason the workspace would fail to build on the version, or nil if it wouldn't.
+	Err error
+}
+
+// BatchPreflight resolves the parameters of the last build of each workspace against the template version, as a start
+// build to that version without new parameter values would, and returns the outcome for each workspace in order, e.g.
+// to report which workspaces would fail to build before promoting the version.  Nothing is inserted into the database.
+// If authFunc is provided, it is checked as for Build, so a workspace the caller may not build is reported as not
+// found.  An error is only returned if the data needed to validate could not be fetched.
+func BatchPreflight(
+	ctx context.Context,
+	store database.Store,
+	workspaceIDs []uuid.UUID,
+	versionID uuid.UUID,
+	authFunc func(action rbac.Action, object rbac.Objecter) bool,
+) ([]WorkspacePreflight, error) {
+	notFound := BuildError{http.StatusNotFound, httpapi.ResourceNotFoundResponse.Message, xerrors.New(httpapi.ResourceNotFoundResponse.Message)}
+	results := make([]WorkspacePreflight, 0, len(workspaceIDs))
+	for _, workspaceID := range workspaceIDs {
+		workspace, err := store.GetWorkspaceByID(ctx, workspaceID)
+		if xerrors.Is(err, sql.ErrNoRows) {
+			results = append(results, WorkspacePreflight{WorkspaceID: workspaceID, Err: notFound})
+			continue
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("get workspace %s: %w", workspaceID, err)
+		}
+
+		versionID := versionID
+		b := New(workspace, database.WorkspaceTransitionStart)
+		b.ctx = ctx
+		b.store = store
+		b.version = versionTarget{specific: &versionID}
+		err = b.preflight(authFunc)
+		var buildErr BuildError
+		if xerrors.As(err, &buildErr) && buildErr.Status == http.StatusInternalServerError {
+			return nil, xerrors.Errorf("preflight workspace %s: %w", workspaceID, err)
+		}
+		results = append(results, WorkspacePreflight{WorkspaceID: workspaceID, Err: err})
+	}
+	return results, nil
+}
+
+// preflight runs the checks of a build that don't depend on the state of the last build's job, without inserting
+// anything.
+func (b *Builder) preflight(authFunc func(action rbac.Action, object rbac.Objecter) bool) error {
+	if authFunc != nil {
+		err := b.authorize(authFunc)
+		if err != nil {
+			return err
+		}
+	}
+	err := b.checkTemplateVersionMatchesTemplate()
+	if err != nil {
+		return err
+	}
+	_, _, err = b.getParameters()
+	return err
+}
+
+// DraftValidation is the outcome of validating a partial set of parameters with DraftValidate.
+type DraftValidation struct {
+	// Errors are the parameters whose value fails validation, e.g. because of its type, format or options.
+	Errors ParameterValidationErrors
+	// Warnings are the required parameters that have no value yet.  They don't prevent the draft from being valid,
+	// but must be supplied before building.
+	Warnings ParameterValidationErrors
+}
+
+// Valid returns whether the draft parameters have no validation errors.
+func (d DraftValidation) Valid() bool {
+	return len(d.Errors) == 0
+}
+
+// DraftValidate resolves and validates the given parameters as the Builder would for a build, but without requiring
+// every required parameter to have a value: missing required parameters are reported as warnings rather than errors.
+// It is meant for forms that validate parameters as they are filled in.  Nothing is inserted into the database.  An
+// error is only returned if the parameters could not be validated at all.
+func (b *Builder) DraftValidate(
+	ctx context.Context,
+	store database.Store,
+	params []codersdk.WorkspaceBuildParameter,
+) (DraftValidation, error) {
+	b.ctx = ctx
+	b.store = store
+	b.richParameterValues = params
+
+	templateVersionParameters, err := b.getTemplateVersionParameters()
+	if err != nil {
+		return DraftValidation{}, BuildError{http.StatusInternalServerError, "failed to fetch template version parameters", err}
+	}
+	lastBuildParameters, err := b.getLastBuildParameters()
+	if err != nil {
+		return DraftValidation{}, BuildError{http.StatusInternalServerError, "failed to fetch last build parameters", err}
+	}
+	err = b.verifyNoLegacyParameters()
+	if err != nil {
+		return DraftValidation{}, BuildError{http.StatusBadRequest, "Unable to build workspace with unsupported parameters", err}
+	}
+	if b.strictParameterNames {
+		err = checkParameterNames(templateVersionParameters, b.richParameterValues)
+		if err != nil {
+			return DraftValidation{}, BuildError{http.StatusBadRequest, err.Error(), err}
+		}
+	}
+	if b.parameterMergeMode == ParameterMergeModeReplace {
+		lastBuildParameters = b.replacedLastBuildParameters(templateVersionParameters, lastBuildParameters)
+	}
+	resolver := codersdk.ParameterResolver{
+		Rich:                  db2sdk.WorkspaceBuildParameters(lastBuildParameters),
+		AllowImmutableChanges: b.immutableChangesAuthorized,
+	}
+	var result DraftValidation
+	for _, templateVersionParameter := range templateVersionParameters {
+		tvp, err := db2sdk.TemplateVersionParameter(templateVersionParameter)
+		if err != nil {
+			return DraftValidation{}, BuildError{http.StatusInternalServerError, "failed to convert template version parameter", err}
+		}
+		value := b.findNewBuildParameterValue(tvp.Name)
+		if tvp.Required && value == nil && !slices.ContainsFunc(resolver.Rich, func(p codersdk.WorkspaceBuildParameter) bool {
+			return p.Name == tvp.Name
+		}) {
+			err = xerrors.Errorf("Parameter %q is required but not provided", tvp.Name)
+			result.Warnings = append(result.Warnings, parameterValidationError(tvp, err))
+			continue
+		}
+		_, err = resolver.ValidateResolve(tvp, value)
+		if err != nil {
+			result.Errors = append(result.Errors, parameterValidationError(tvp, err))
+		}
+	}
+	return result, nil
+}
+
 // buildTx contains the business logic of computing a new build.  Attributes of the new database objects are computed
 // in a functional style, rather than imperative, to emphasize the logic of how they are defined.  A simple cache
 // of database-fetched objects is stored on the struct to ensure we only fetch things once, even if they are used in
@@ -242,13 +771,61 @@ func (b *Builder) Build(
 func (b *Builder) buildTx(authFunc func(action rbac.Action, object rbac.Objecter) bool) (
 	*database.WorkspaceBuild, *database.ProvisionerJob, error,
 ) {
+	b.logger.Debug(b.ctx, "computing workspace build",
+		slog.F("workspace_id", b.workspace.ID),
+		slog.F("transition", b.trans),
+	)
+	err := b.checkCanceled()
+	if err != nil {
+		return nil, nil, err
+	}
+	err = b.checkMaintenance()
+	if err != nil {
+		return nil, nil, err
+	}
+	err = b.checkTimeout()
+	if err != nil {
+		return nil, nil, err
+	}
+	// the structured reason maps to the coarse reason if its category names one, so that it is subject to the same
+	// checks as an explicit reason
+	if b.reason == "" {
+		if r := database.BuildReason(b.structuredReason.Category); r.Valid() {
+			b.reason = r
+		}
+	}
+	err = b.checkReasonMatchesTransition()
+	if err != nil {
+		return nil, nil, err
+	}
 	if authFunc != nil {
 		err := b.authorize(authFunc)
 		if err != nil {
 			return nil, nil, err
 		}
 	}
-	err := b.checkTemplateVersionMatchesTemplate()
+	if b.idempotencyKey != "" {
+		existing, job, err := b.getIdempotentBuild()
+		if err != nil {
+			return nil, nil, err
+		}
+		if existing != nil {
+			err = b.linkIntent(b.store, existing.ID)
+			if err != nil {
+				return nil, nil, err
+			}
+			return existing, job, nil
+		}
+	}
+	err = b.checkRollbackTarget()
+	if err != nil {
+		return nil, nil, err
+	}
+	err = b.checkTemplateVersionName()
+	if err != nil {
+		return nil, nil, err
+	}
+	err = b.checkTemplateVersionMatchesTemplate()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -260,6 +837,10 @@ func (b *Builder) buildTx(authFunc func(action rbac.Action, object rbac.Objecter
 	if err != nil {
 		return nil, nil, err
 	}
+	err = b.checkCanceled()
+	if err != nil {
+		return nil, nil, err
+	}
 
 	template, err := b.getTemplate()
 	if err != nil {
@@ -283,10 +864,7 @@ func (b *Builder) buildTx(authFunc func(action rbac.Action, object rbac.Objecter
 	}
 
 	workspaceBuildID := uuid.New()
-	input, err := json.Marshal(provisionerdserver.WorkspaceProvisionJob{
-		WorkspaceBuildID: workspaceBuildID,
-		LogLevel:         b.logLevel,
-	})
+	input, err := b.jobInput(workspaceBuildID)
 	if err != nil {
 		return nil, nil, BuildError{
 			http.StatusInternalServerError,
@@ -300,6 +878,18 @@ func (b *Builder) buildTx(authFunc func(action rbac.Action, object rbac.Objecter
 	}
 	tags := provisionerdserver.MutateTags(b.workspace.OwnerID, templateVersionJob.Tags)
 
+	err = b.checkExpectations()
+	if err != nil {
+		return nil, nil, err
+	}
+	err = b.checkCanceled()
+	if err != nil {
+		return nil, nil, err
+	}
+	if b.dryRun {
+		return nil, nil, b.dryRunTx(template.Provisioner, tags)
+	}
+
 	now := database.Now()
 	provisionerJob, err := b.store.InsertProvisionerJob(b.ctx, database.InsertProvisionerJobParams{
 		ID:             uuid.New(),
@@ -313,6 +903,11 @@ func (b *Builder) buildTx(authFunc func(action rbac.Action, object rbac.Objecter
 		FileID:         templateVersionJob.FileID,
 		Input:          input,
 		Tags:           tags,
+		Priority:       b.priority,
+		PendingDeadline: sql.NullTime{
+			Time:  now.Add(b.pendingTimeout),
+			Valid: b.pendingTimeout > 0,
+		},
 		TraceMetadata: pqtype.NullRawMessage{
 			Valid:      true,
 			RawMessage: traceMetadataRaw,
@@ -326,6 +921,10 @@ func (b *Builder) buildTx(authFunc func(action rbac.Action, object rbac.Objecter
 	if err != nil {
 		return nil, nil, BuildError{http.StatusInternalServerError, "compute template version ID", err}
 	}
+	b.logger.Debug(b.ctx, "resolved template version",
+		slog.F("template_version_id", templateVersionID),
+		slog.F("source", b.version.source()),
+	)
 	buildNum, err := b.getBuildNumber()
 	if err != nil {
 		return nil, nil, BuildError{http.StatusInternalServerError, "compute build number", err}
@@ -334,52 +933,287 @@ func (b *Builder) buildTx(authFunc func(action rbac.Action, object rbac.Objecter
 	if err != nil {
 		return nil, nil, BuildError{http.StatusInternalServerError, "compute build state", err}
 	}
+	err = b.checkStatePreserved(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = b.checkCanceled()
+	if err != nil {
+		return nil, nil, err
+	}
 
-	var workspaceBuild database.WorkspaceBuild
+	var (
+		workspaceBuild database.WorkspaceBuild
+		resolvedNames  []string
+		resolvedValues []string
+	)
 	err = b.store.InTx(func(store database.Store) error {
+		// Whether the parameters are shared has to be known before inserting the build, so resolve them up front
+		// if we might skip storing them.
+		var (
+			names, values  []string
+			parametersFrom uuid.NullUUID
+		)
+		if b.skipUnchangedParameters {
+			names, values, err = b.getParameters()
+			if err != nil {
+				// getParameters already wraps errors in BuildError
+				return err
+			}
+			parametersFrom, err = b.getUnchangedParametersSource(names, values)
+			if err != nil {
+				return BuildError{http.StatusInternalServerError, "compare parameters with last build", err}
+			}
+		}
+
 		err = store.InsertWorkspaceBuild(b.ctx, database.InsertWorkspaceBuildParams{
-			ID:                workspaceBuildID,
-			CreatedAt:         now,
-			UpdatedAt:         now,
-			WorkspaceID:       b.workspace.ID,
-			TemplateVersionID: templateVersionID,
-			BuildNumber:       buildNum,
-			ProvisionerState:  state,
-			InitiatorID:       b.initiator,
-			Transition:        b.trans,
-			JobID:             provisionerJob.ID,
-			Reason:            b.reason,
+			ID:                    workspaceBuildID,
+			CreatedAt:             now,
+			UpdatedAt:             now,
+			WorkspaceID:           b.workspace.ID,
+			TemplateVersionID:     templateVersionID,
+			BuildNumber:           buildNum,
+			ProvisionerState:      state,
+			InitiatorID:           b.initiator,
+			Transition:            b.trans,
+			JobID:                 provisionerJob.ID,
+			Reason:                b.reason,
+			RollbackOf:            b.rollbackOf,
+			ParametersFromBuildID: parametersFrom,
+			StructuredReason:      b.structuredReason,
+			IdempotencyKey:        sql.NullString{String: b.idempotencyKey, Valid: b.idempotencyKey != ""},
+			// The hash of the file is looked up when inserting, so that auditors can verify the source of the build.
+			TemplateFileID: templateVersionJob.FileID,
+			ScheduleName:   sql.NullString{String: b.scheduleName, Valid: b.scheduleName != ""},
 		})
 		if err != nil {
 			return BuildError{http.StatusInternalServerError, "insert workspace build", err}
 		}
+		err = b.linkIntent(store, workspaceBuildID)
+		if err != nil {
+			return err
+		}
 
-		names, values, err := b.getParameters()
+		if !b.skipUnchangedParameters {
+			names, values, err = b.getParameters()
+			if err != nil {
+				// getParameters already wraps errors in BuildError
+				return err
+			}
+		}
+		// resolving the parameters may take a while, so check that the caller is still waiting before storing them
+		err = b.checkCanceled()
 		if err != nil {
-			// getParameters already wraps errors in BuildError
 			return err
 		}
-		err = store.InsertWorkspaceBuildParameters(b.ctx, database.InsertWorkspaceBuildParametersParams{
-			WorkspaceBuildID: workspaceBuildID,
-			Name:             names,
-			Value:            values,
-		})
+		b.diff, err = b.computeDiff(templateVersionID, state, names, values)
+		if err != nil {
+			return BuildError{http.StatusInternalServerError, "compare build with last build", err}
+		}
+		switch {
+		case parametersFrom.Valid:
+			b.logger.Debug(b.ctx, "parameters unchanged, sharing them with a prior build",
+				slog.F("parameters_from_build_id", parametersFrom.UUID),
+			)
+		case b.deferParameterInsert:
+			b.deferredParameters = make([]database.WorkspaceBuildParameter, 0, len(names))
+			for i, name := range names {
+				b.deferredParameters = append(b.deferredParameters, database.WorkspaceBuildParameter{
+					WorkspaceBuildID: workspaceBuildID,
+					Name:             name,
+					Value:            values[i],
+				})
+			}
+		default:
+			err = b.insertBuildParameters(store, workspaceBuildID, names, values)
+			if err != nil {
+				// insertBuildParameters already wraps errors in BuildError
+				return err
+			}
+		}
+
+		workspaceBuild, err = store.GetWorkspaceBuildByID(b.ctx, workspaceBuildID)
+		if err != nil {
+			return BuildError{http.StatusInternalServerError, "get workspace build", err}
+		}
+
+		// returning an error rolls back the transaction, so nothing is committed if the caller gave up meanwhile
+		err = b.checkCanceled()
 		if err != nil {
-			return BuildError{http.StatusInternalServerError, "insert workspace build parameters: %w", err}
+			return err
 		}
+		resolvedNames, resolvedValues = names, values
+		return nil
+	}, nil)
+	if err != nil {
+		// the transaction was rolled back, so there is no build to insert the parameters of
+		b.deferredParameters = nil
+		return nil, nil, err
+	}
+	// Copy, so that callers holding the result aren't affected if the Builder is used again.
+	b.parameterNames, b.parameterValues = slices.Clone(resolvedNames), slices.Clone(resolvedValues)
+
+	return &workspaceBuild, &provisionerJob, nil
+}
+
+// Parameters returns the names and values of the parameters stored for the build inserted by Build, i.e. the values
+// the build was resolved with.  They are nil until Build inserts a build, including when Build returns an existing
+// build for the IdempotencyKey.
+func (b *Builder) Parameters() (names, values []string) {
+	return b.parameterNames, b.parameterValues
+}
+
+// DeferredParameters returns the parameter rows of the build inserted by a DeferParameterInsert Build, which the
+// caller is responsible for inserting.  It is empty if the build shares the parameters of a prior build, as with
+// SkipUnchangedParameters.
+func (b *Builder) DeferredParameters() []database.WorkspaceBuildParameter {
+	return b.deferredParameters
+}
+
+// BuildDiff describes how a build differs from the last build of the workspace, e.g. for auditing.
+type BuildDiff struct {
+	// FirstBuild is set if the workspace had no prior build, in which case nothing is reported as changed.
+	FirstBuild     bool
+	VersionChanged bool
+	// ChangedParameters are the names of the parameters whose values differ from the last build's, including
+	// parameters that were added or dropped.
+	ChangedParameters []string
+	// StateReplaced is set if the build doesn't carry the provisioner state of the last build forward.
+	StateReplaced bool
+}
+
+// Diff returns how the build inserted by Build differs from the workspace's last build.  It is the zero value until
+// Build inserts a build.
+func (b *Builder) Diff() BuildDiff {
+	return b.diff
+}
+
+func (b *Builder) computeDiff(versionID uuid.UUID, state []byte, names, values []string) (BuildDiff, error) {
+	bld, err := b.getLastBuild()
+	if xerrors.Is(err, sql.ErrNoRows) {
+		return BuildDiff{FirstBuild: true}, nil
+	}
+	if err != nil {
+		return BuildDiff{}, xerrors.Errorf("get last build: %w", err)
+	}
+	lastParameters, err := b.getLastBuildParameters()
+	if err != nil {
+		return BuildDiff{}, xerrors.Errorf("get last build parameters: %w", err)
+	}
+	diff := BuildDiff{
+		VersionChanged: bld.TemplateVersionID != versionID,
+		StateReplaced:  !bytes.Equal(bld.ProvisionerState, state),
+	}
+	lastValues := database.WorkspaceBuildParametersMap(lastParameters)
+	for i, name := range names {
+		if v, ok := lastValues[name]; !ok || v != values[i] {
+			diff.ChangedParameters = append(diff.ChangedParameters, name)
+		}
+	}
+	for _, p := range lastParameters {
+		if !slices.Contains(names, p.Name) {
+			diff.ChangedParameters = append(diff.ChangedParameters, p.Name)
+		}
+	}
+	return diff, nil
+}
+
+// dryRunTx performs the checks that buildTx makes after inserting the provisioner job, without inserting anything,
+// and records the resolved parameters and the provisioner daemons that could acquire a job with the given provisioner
+// type and tags.
+func (b *Builder) dryRunTx(provisioner database.ProvisionerType, tags map[string]string) error {
+	state, err := b.getState()
+	if err != nil {
+		return BuildError{http.StatusInternalServerError, "compute build state", err}
+	}
+	err = b.checkStatePreserved(state)
+	if err != nil {
+		return err
+	}
+	names, values, err := b.getParameters()
+	if err != nil {
+		// getParameters already wraps errors in BuildError
+		return err
+	}
+	daemons, err := b.store.GetProvisionerDaemons(b.ctx)
+	if err != nil && !xerrors.Is(err, sql.ErrNoRows) {
+		return BuildError{http.StatusInternalServerError, "failed to fetch provisioner daemons", err}
+	}
+	var candidates []database.ProvisionerDaemon
+	for _, daemon := range daemons {
+		if slices.Contains(daemon.Provisioners, provisioner) && provisionerdserver.TagsMatch(tags, daemon.Tags) {
+			candidates = append(candidates, daemon)
+		}
+	}
+	b.dryRunNames, b.dryRunValues = names, values
+	b.dryRunDaemons = candidates
+	b.logger.Debug(b.ctx, "dry run, not inserting workspace build",
+		slog.F("workspace_id", b.workspace.ID),
+		slog.F("provisioner_daemons", len(candidates)),
+	)
+	return nil
+}
+
+// DryRunParameters returns the names and values of the parameters resolved by a successful DryRun build.
+func (b *Builder) DryRunParameters() (names, values []string) {
+	return b.dryRunNames, b.dryRunValues
+}
+
+// DryRunProvisionerDaemons returns the registered provisioner daemons that could acquire the job of a successful
+// DryRun build.  If it is empty, no compatible provisioner daemon is available.
+func (b *Builder) DryRunProvisionerDaemons() []database.ProvisionerDaemon {
+	return b.dryRunDaemons
+}
 
-		workspaceBuild, err = store.GetWorkspaceBuildByID(b.ctx, workspaceBuildID)
-		if err != nil {
-			return BuildError{http.StatusInternalServerError, "get workspace build", err}
+// insertBuildParameters inserts the parameters of the build.  The parameters are inserted in bulk, so if the insert
+// fails we attempt to find the offending parameter in the database error and report it, to ease debugging bad data.
+func (b *Builder) insertBuildParameters(store database.Store, workspaceBuildID uuid.UUID, names, values []string) error {
+	err := store.InsertWorkspaceBuildParameters(b.ctx, database.InsertWorkspaceBuildParametersParams{
+		WorkspaceBuildID: workspaceBuildID,
+		Name:             names,
+		Value:            values,
+	})
+	if err == nil {
+		return nil
+	}
+	if i, ok := failedParameter(err, names); ok {
+		msg := fmt.Sprintf("insert workspace build parameter %d (%q)", i, names[i])
+		return BuildError{http.StatusInternalServerError, msg, err}
+	}
+	return BuildError{http.StatusInternalServerError, "insert workspace build parameters", err}
+}
+
+// failedParameter returns the index of the parameter that caused err, if the database reported it.  Postgres includes
+// the offending row in the error detail, e.g. "Key (workspace_build_id, name)=(<id>, <name>) already exists." for
+// unique violations and "Failing row contains (<id>, <name>, <value>)." for check violations.
+func failedParameter(err error, names []string) (int, bool) {
+	var pqe *pq.Error
+	if !xerrors.As(err, &pqe) || pqe.Detail == "" {
+		return 0, false
+	}
+	for i, name := range names {
+		if strings.Contains(pqe.Detail, ", "+name+")") || strings.Contains(pqe.Detail, ", "+name+",") {
+			return i, true
 		}
+	}
+	return 0, false
+}
 
-		return nil
-	}, nil)
+// PreviewJobInput returns the input of the provisioner job that Build would create, without creating anything.  The
+// workspace build ID is only assigned by Build, so it is the zero UUID in the preview.
+func (b *Builder) PreviewJobInput() (json.RawMessage, error) {
+	input, err := b.jobInput(uuid.Nil)
 	if err != nil {
-		return nil, nil, err
+		return nil, xerrors.Errorf("marshal provision job: %w", err)
 	}
+	return input, nil
+}
 
-	return &workspaceBuild, &provisionerJob, nil
+func (b *Builder) jobInput(workspaceBuildID uuid.UUID) ([]byte, error) {
+	return json.Marshal(provisionerdserver.WorkspaceProvisionJob{
+		WorkspaceBuildID: workspaceBuildID,
+		LogLevel:         b.logLevel,
+	})
 }
 
 func (b *Builder) getTemplate() (*database.Template, error) {
@@ -431,20 +1265,50 @@ func (b *Builder) getTemplateVersionID() (uuid.UUID, error) {
 		return *b.version.specific, nil
 	}
 	if b.version.active {
-		t, err := b.getTemplate()
+		return b.getActiveVersionID()
+	}
+	if b.version.lastSuccessful {
+		bld, err := b.getLastSuccessfulBuild()
+		if err != nil {
+			return uuid.Nil, xerrors.Errorf("get last successful build so we can get version: %w", err)
+		}
+		return bld.TemplateVersionID, nil
+	}
+	if b.version.name != nil {
+		// the version is fetched to look it up by name anyway, so cache it
+		if b.templateVersion != nil {
+			return b.templateVersion.ID, nil
+		}
+		v, err := b.store.GetTemplateVersionByTemplateIDAndName(b.ctx, database.GetTemplateVersionByTemplateIDAndNameParams{
+			TemplateID: uuid.NullUUID{UUID: b.workspace.TemplateID, Valid: true},
+			Name:       *b.version.name,
+		})
 		if err != nil {
-			return uuid.Nil, xerrors.Errorf("get template so we can get active version: %w", err)
+			return uuid.Nil, xerrors.Errorf("get template version %q: %w", *b.version.name, err)
 		}
-		return t.ActiveVersionID, nil
+		b.templateVersion = &v
+		return v.ID, nil
 	}
 	// default is prior version
 	bld, err := b.getLastBuild()
+	if xerrors.Is(err, sql.ErrNoRows) {
+		// first build, so there is no prior version; use the active version
+		return b.getActiveVersionID()
+	}
 	if err != nil {
 		return uuid.Nil, xerrors.Errorf("get last build so we can get version: %w", err)
 	}
 	return bld.TemplateVersionID, nil
 }
 
+func (b *Builder) getActiveVersionID() (uuid.UUID, error) {
+	t, err := b.getTemplate()
+	if err != nil {
+		return uuid.Nil, xerrors.Errorf("get template so we can get active version: %w", err)
+	}
+	return t.ActiveVersionID, nil
+}
+
 func (b *Builder) getLastBuild() (*database.WorkspaceBuild, error) {
 	if b.lastBuild != nil {
 		return b.lastBuild, nil
@@ -464,6 +1328,18 @@ func (b *Builder) getLastBuild() (*database.WorkspaceBuild, error) {
 	return b.lastBuild, nil
 }
 
+func (b *Builder) getLastSuccessfulBuild() (*database.WorkspaceBuild, error) {
+	if b.lastSuccessfulBuild != nil {
+		return b.lastSuccessfulBuild, nil
+	}
+	bld, err := b.store.GetLatestSuccessfulWorkspaceBuildByWorkspaceID(b.ctx, b.workspace.ID)
+	if err != nil {
+		return nil, xerrors.Errorf("get workspace %s last successful build: %w", b.workspace.ID, err)
+	}
+	b.lastSuccessfulBuild = &bld
+	return b.lastSuccessfulBuild, nil
+}
+
 func (b *Builder) getBuildNumber() (int32, error) {
 	bld, err := b.getLastBuild()
 	if xerrors.Is(err, sql.ErrNoRows) {
@@ -496,6 +1372,9 @@ func (b *Builder) getState() ([]byte, error) {
 	return bld.ProvisionerState, nil
 }
 
+// getParameters resolves the rich parameter values for the build.  Only the parameters of the target template version
+// are resolved, so values from the last build for parameters that no longer exist are dropped.  Each parameter takes
+// the value from the request, then the last build (unless it is ephemeral), and finally the parameter's default.
 func (b *Builder) getParameters() (names, values []string, err error) {
 	templateVersionParameters, err := b.getTemplateVersionParameters()
 	if err != nil {
@@ -509,8 +1388,25 @@ func (b *Builder) getParameters() (names, values []string, err error) {
 	if err != nil {
 		return nil, nil, BuildError{http.StatusBadRequest, "Unable to build workspace with unsupported parameters", err}
 	}
+	if b.strictParameterNames {
+		err = checkParameterNames(templateVersionParameters, b.richParameterValues)
+		if err != nil {
+			return nil, nil, BuildError{http.StatusBadRequest, err.Error(), err}
+		}
+	}
+	if b.parameterMergeMode == ParameterMergeModeReplace {
+		lastBuildParameters = b.replacedLastBuildParameters(templateVersionParameters, lastBuildParameters)
+	}
 	resolver := codersdk.ParameterResolver{
-		Rich: db2sdk.WorkspaceBuildParameters(lastBuildParameters),
+		Rich:                  db2sdk.WorkspaceBuildParameters(lastBuildParameters),
+		AllowImmutableChanges: b.immutableChangesAuthorized,
+	}
+	validationErrs, err := b.checkMutabilityTransitions(templateVersionParameters, resolver.Rich)
+	if err != nil {
+		return nil, nil, BuildError{http.StatusInternalServerError, "failed to check parameter mutability", err}
+	}
+	if len(validationErrs) > 0 {
+		return nil, nil, BuildError{http.StatusBadRequest, validationErrs.Error(), validationErrs}
 	}
 	for _, templateVersionParameter := range templateVersionParameters {
 		tvp, err := db2sdk.TemplateVersionParameter(templateVersionParameter)
@@ -524,15 +1420,141 @@ func (b *Builder) getParameters() (names, values []string, err error) {
 		if err != nil {
 			// At this point, we've queried all the data we need from the database,
 			// so the only errors are problems with the request (missing data, failed
-			// validation, immutable parameters, etc.)
-			return nil, nil, BuildError{http.StatusBadRequest, err.Error(), err}
+			// validation, immutable parameters, etc.)  Keep going so that all the
+			// invalid parameters are reported at once.
+			validationErrs = append(validationErrs, parameterValidationError(tvp, err))
+			continue
 		}
+		b.logger.Debug(b.ctx, "resolved parameter",
+			slog.F("name", templateVersionParameter.Name),
+			slog.F("source", parameterSource(tvp, b.findNewBuildParameterValue(templateVersionParameter.Name), resolver.Rich)),
+		)
 		names = append(names, templateVersionParameter.Name)
 		values = append(values, value)
 	}
+	if len(validationErrs) > 0 {
+		return nil, nil, BuildError{http.StatusBadRequest, validationErrs.Error(), validationErrs}
+	}
 	return names, values, nil
 }
 
+// parameterValidationError converts an error resolving the parameter into a validation error for it.
+func parameterValidationError(tvp codersdk.TemplateVersionParameter, err error) *codersdk.ParameterValidationError {
+	var validationErr *codersdk.ParameterValidationError
+	if !xerrors.As(err, &validationErr) {
+		validationErr = &codersdk.ParameterValidationError{Name: tvp.Name, Reason: err.Error(), Parameter: tvp, Err: err}
+	}
+	var regexErr *codersdk.ParameterRegexError
+	if xerrors.As(err, &regexErr) {
+		// Lead with the parameter name, value and pattern rather than the display name.
+		validationErr.Reason = regexErr.Error()
+	}
+	return validationErr
+}
+
+// checkMutabilityTransitions reports the parameters that were mutable in the template version of the last build but
+// are immutable in the version being built, when the value carried forward from the last build differs from the new
+// default.  Carrying such a value forward would lock the workspace into a value that was chosen while it could still
+// be changed, so the conflict has to be resolved first: either by setting the parameter to the new default before
+// updating, or by having a template administrator update the workspace, since they may change immutable parameters.
+func (b *Builder) checkMutabilityTransitions(params []database.TemplateVersionParameter, last []codersdk.WorkspaceBuildParameter) (ParameterValidationErrors, error) {
+	if b.immutableChangesAuthorized {
+		return nil, nil
+	}
+	bld, err := b.getLastBuild()
+	if xerrors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("get last build: %w", err)
+	}
+	tvID, err := b.getTemplateVersionID()
+	if err != nil {
+		return nil, xerrors.Errorf("get template version ID: %w", err)
+	}
+	if bld.TemplateVersionID == tvID {
+		return nil, nil
+	}
+	var conflicts ParameterValidationErrors
+	for _, p := range params {
+		// ephemeral parameters and parameters given a new value don't carry forward the last build's value
+		if p.Mutable || p.Ephemeral || b.findNewBuildParameterValue(p.Name) != nil {
+			continue
+		}
+		i := slices.IndexFunc(last, func(l codersdk.WorkspaceBuildParameter) bool { return l.Name == p.Name })
+		if i < 0 || last[i].Value == p.DefaultValue {
+			continue
+		}
+		// the previous version's parameters are only needed once there's a candidate conflict
+		lastVersionParams, err := b.getLastBuildVersionParameters()
+		if err != nil {
+			return nil, xerrors.Errorf("get last build version parameters: %w", err)
+		}
+		wasMutable := slices.ContainsFunc(lastVersionParams, func(lp database.TemplateVersionParameter) bool {
+			return lp.Name == p.Name && lp.Mutable
+		})
+		if !wasMutable {
+			continue
+		}
+		tvp, err := db2sdk.TemplateVersionParameter(p)
+		if err != nil {
+			return nil, xerrors.Errorf("convert template version parameter: %w", err)
+		}
+		err = xerrors.Errorf(
+			"Parameter %q is immutable in the new template version, but the workspace's value %q differs from the new default %q. "+
+				"Change it to %q before updating, or ask a template administrator to update the workspace.",
+			p.Name, last[i].Value, p.DefaultValue, p.DefaultValue,
+		)
+		conflicts = append(conflicts, parameterValidationError(tvp, err))
+	}
+	return conflicts, nil
+}
+
+// replacedLastBuildParameters returns the last build parameters that still apply when parameters are replaced: those
+// given a new value, which are needed to validate it, and those of immutable parameters.
+func (b *Builder) replacedLastBuildParameters(params []database.TemplateVersionParameter, last []database.WorkspaceBuildParameter) []database.WorkspaceBuildParameter {
+	var kept []database.WorkspaceBuildParameter
+	for _, l := range last {
+		immutable := slices.ContainsFunc(params, func(p database.TemplateVersionParameter) bool {
+			return p.Name == l.Name && !p.Mutable
+		})
+		if immutable || b.findNewBuildParameterValue(l.Name) != nil {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+// checkParameterNames returns an error listing the names of any values that don't match a template version parameter.
+func checkParameterNames(params []database.TemplateVersionParameter, values []codersdk.WorkspaceBuildParameter) error {
+	var unknown []string
+	for _, v := range values {
+		if !slices.ContainsFunc(params, func(p database.TemplateVersionParameter) bool { return p.Name == v.Name }) {
+			unknown = append(unknown, fmt.Sprintf("%q", v.Name))
+		}
+	}
+	if len(unknown) > 0 {
+		return xerrors.Errorf("Unknown parameters for template version: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// parameterSource describes where the resolved value of a parameter comes from, for diagnostics.  It mirrors the
+// precedence of codersdk.ParameterResolver.
+func parameterSource(p codersdk.TemplateVersionParameter, v *codersdk.WorkspaceBuildParameter, last []codersdk.WorkspaceBuildParameter) string {
+	if v != nil {
+		return "request"
+	}
+	if !p.Ephemeral {
+		for _, l := range last {
+			if l.Name == p.Name {
+				return "last build"
+			}
+		}
+	}
+	return "default"
+}
+
 func (b *Builder) findNewBuildParameterValue(name string) *codersdk.WorkspaceBuildParameter {
 	for _, v := range b.richParameterValues {
 		if v.Name == name {
@@ -563,6 +1585,41 @@ func (b *Builder) getLastBuildParameters() ([]database.WorkspaceBuildParameter,
 	return values, nil
 }
 
+// getUnchangedParametersSource returns the build that stores the parameters of the last build, if the given
+// parameters are identical to them.  Otherwise, or if there is no last build, it returns an invalid uuid.NullUUID.
+func (b *Builder) getUnchangedParametersSource(names, values []string) (uuid.NullUUID, error) {
+	bld, err := b.getLastBuild()
+	if xerrors.Is(err, sql.ErrNoRows) {
+		return uuid.NullUUID{}, nil
+	}
+	if err != nil {
+		return uuid.NullUUID{}, xerrors.Errorf("get last build: %w", err)
+	}
+	lastParameters, err := b.getLastBuildParameters()
+	if err != nil {
+		return uuid.NullUUID{}, xerrors.Errorf("get last build parameters: %w", err)
+	}
+	if len(lastParameters) != len(names) {
+		return uuid.NullUUID{}, nil
+	}
+	lastValues := make(map[string]string, len(lastParameters))
+	for _, p := range lastParameters {
+		lastValues[p.Name] = p.Value
+	}
+	for i, name := range names {
+		v, ok := lastValues[name]
+		if !ok || v != values[i] {
+			return uuid.NullUUID{}, nil
+		}
+	}
+	// The last build may itself be sharing the parameters of an earlier build; refer to the build that stored them
+	// so that reads only need to follow one reference.
+	if bld.ParametersFromBuildID.Valid {
+		return bld.ParametersFromBuildID, nil
+	}
+	return uuid.NullUUID{UUID: bld.ID, Valid: true}, nil
+}
+
 func (b *Builder) getTemplateVersionParameters() ([]database.TemplateVersionParameter, error) {
 	if b.templateVersionParameters != nil {
 		return *b.templateVersionParameters, nil
@@ -579,6 +1636,24 @@ func (b *Builder) getTemplateVersionParameters() ([]database.TemplateVersionPara
 	return tvp, nil
 }
 
+// getLastBuildVersionParameters returns the parameters of the template version of the last build, which may differ
+// from the version being built.
+func (b *Builder) getLastBuildVersionParameters() ([]database.TemplateVersionParameter, error) {
+	if b.lastBuildVersionParams != nil {
+		return *b.lastBuildVersionParams, nil
+	}
+	bld, err := b.getLastBuild()
+	if err != nil {
+		return nil, xerrors.Errorf("get last build to get version parameters: %w", err)
+	}
+	tvp, err := b.store.GetTemplateVersionParameters(b.ctx, bld.TemplateVersionID)
+	if err != nil && !xerrors.Is(err, sql.ErrNoRows) {
+		return nil, xerrors.Errorf("get template version %s parameters: %w", bld.TemplateVersionID, err)
+	}
+	b.lastBuildVersionParams = &tvp
+	return tvp, nil
+}
+
 // verifyNoLegacyParameters verifies that initiator can't start the workspace build
 // if it uses legacy parameters (database.ParameterSchemas).
 func (b *Builder) verifyNoLegacyParameters() error {
@@ -606,6 +1681,13 @@ func (b *Builder) verifyNoLegacyParameters() error {
 	}
 
 	if len(parameterSchemas) > 0 {
+		if b.onLegacyParameters != nil {
+			names := make([]string, 0, len(parameterSchemas))
+			for _, schema := range parameterSchemas {
+				names = append(names, schema.Name)
+			}
+			b.onLegacyParameters(names)
+		}
 		return xerrors.Errorf("Legacy parameters in use on this version are not supported anymore. Contact your administrator for assistance.")
 	}
 	return nil
@@ -660,6 +1742,12 @@ func (b *Builder) authorize(authFunc func(action rbac.Action, object rbac.Object
 		}
 	}
 
+	// Likewise, only template managers may override parameter immutability.
+	// Anyone else falls through to the usual validation error.
+	if b.allowImmutableChanges {
+		b.immutableChangesAuthorized = authFunc(rbac.ActionUpdate, template.RBACObject())
+	}
+
 	if b.logLevel != "" && !authFunc(rbac.ActionRead, rbac.ResourceDeploymentValues) {
 		return BuildError{
 			http.StatusBadRequest,
@@ -678,6 +1766,26 @@ func (b *Builder) authorize(authFunc func(action rbac.Action, object rbac.Object
 	return nil
 }
 
+// checkTemplateVersionName checks that the template has a version with the requested name, if the version is
+// targeted by name.
+func (b *Builder) checkTemplateVersionName() error {
+	if b.version.name == nil {
+		return nil
+	}
+	if *b.version.name == "" {
+		return BuildError{http.StatusBadRequest, "Template version name must not be empty.", xerrors.New("empty template version name")}
+	}
+	_, err := b.getTemplateVersionID()
+	if xerrors.Is(err, sql.ErrNoRows) {
+		msg := fmt.Sprintf("Template version %q does not exist on the workspace's template.", *b.version.name)
+		return BuildError{http.StatusNotFound, msg, err}
+	}
+	if err != nil {
+		return BuildError{http.StatusInternalServerError, "failed to fetch template version by name", err}
+	}
+	return nil
+}
+
 func (b *Builder) checkTemplateVersionMatchesTemplate() error {
 	template, err := b.getTemplate()
 	if err != nil {
@@ -698,6 +1806,13 @@ func (b *Builder) checkTemplateVersionMatchesTemplate() error {
 				templateVersion.TemplateID, template.ID),
 		}
 	}
+	if templateVersion.Throwaway {
+		return BuildError{
+			http.StatusBadRequest,
+			"template version is a throwaway and can't be built",
+			xerrors.Errorf("template version %s is a throwaway", templateVersion.ID),
+		}
+	}
 	return nil
 }
 
@@ -742,6 +1857,65 @@ func (b *Builder) checkTemplateJobStatus() error {
 	return nil
 }
 
+// checkCanceled returns an error if the context of the build is done, e.g. because the caller gave up on the request.
+// buildTx checks it between phases, so that it returns promptly rather than completing a build nobody is waiting for.
+func (b *Builder) checkCanceled() error {
+	if err := b.ctx.Err(); err != nil {
+		return BuildError{http.StatusRequestTimeout, "workspace build canceled", err}
+	}
+	return nil
+}
+
+func (b *Builder) checkMaintenance() error {
+	if b.maintenanceCheck == nil || b.trans == database.WorkspaceTransitionDelete {
+		return nil
+	}
+	if b.maintenanceCheck() {
+		msg := "deployment is in maintenance mode"
+		return BuildError{
+			http.StatusServiceUnavailable,
+			msg,
+			xerrors.New(msg),
+		}
+	}
+	return nil
+}
+
+func (b *Builder) checkTimeout() error {
+	if b.pendingTimeout < 0 {
+		msg := fmt.Sprintf("Pending timeout %s must not be negative.", b.pendingTimeout)
+		return BuildError{
+			http.StatusBadRequest,
+			msg,
+			xerrors.New(msg),
+		}
+	}
+	return nil
+}
+
+// checkReasonMatchesTransition guards against schedulers issuing the wrong transition: autostart builds must start the
+// workspace and autostop builds must stop it.
+func (b *Builder) checkReasonMatchesTransition() error {
+	var want database.WorkspaceTransition
+	switch b.reason {
+	case database.BuildReasonAutostart:
+		want = database.WorkspaceTransitionStart
+	case database.BuildReasonAutostop:
+		want = database.WorkspaceTransitionStop
+	default:
+		return nil
+	}
+	if b.trans != want {
+		msg := fmt.Sprintf("Build reason %q requires transition %q, not %q.", b.reason, want, b.trans)
+		return BuildError{
+			http.StatusBadRequest,
+			msg,
+			xerrors.New(msg),
+		}
+	}
+	return nil
+}
+
 func (b *Builder) checkRunningBuild() error {
 	job, err := b.getLastBuildJob()
 	if xerrors.Is(err, sql.ErrNoRows) {
@@ -751,7 +1925,33 @@ func (b *Builder) checkRunningBuild() error {
 	if err != nil {
 		return BuildError{http.StatusInternalServerError, "failed to fetch prior build", err}
 	}
-	if db2sdk.ProvisionerJobStatus(*job).Active() {
+	return b.checkPriorJobStatus(*job, db2sdk.ProvisionerJobStatus(*job))
+}
+
+// checkPriorJobStatus rejects the build if the prior build's job, whose status is given, is still active.  A status
+// we don't know how to handle is conservatively treated as active, unless AllowUnknownPriorStatus is set.
+func (b *Builder) checkPriorJobStatus(job database.ProvisionerJob, status codersdk.ProvisionerJobStatus) error {
+	switch status {
+	case codersdk.ProvisionerJobPending, codersdk.ProvisionerJobRunning, codersdk.ProvisionerJobCanceling,
+		codersdk.ProvisionerJobSucceeded, codersdk.ProvisionerJobCanceled, codersdk.ProvisionerJobFailed:
+		// Known statuses are checked for being active below.
+	default:
+		b.logger.Warn(b.ctx, "prior build job has an unknown status",
+			slog.F("job_id", job.ID),
+			slog.F("status", status),
+			slog.F("allowed", b.allowUnknownPriorStatus),
+		)
+		if b.allowUnknownPriorStatus {
+			return nil
+		}
+		msg := "The status of the prior workspace build is unknown."
+		return BuildError{
+			http.StatusConflict,
+			msg,
+			xerrors.New(msg),
+		}
+	}
+	if status.Active() {
 		msg := "A workspace build is already active."
 		return BuildError{
 			http.StatusConflict,
@@ -761,3 +1961,186 @@ func (b *Builder) checkRunningBuild() error {
 	}
 	return nil
 }
+
+// insertIntent records the request for the build.  It is called before the build's transaction, so it can't rely on
+// any cached objects, and it uses the same defaults for the initiator and reason as buildTx.
+func (b *Builder) insertIntent(store database.Store) {
+	initiator := b.initiator
+	if initiator == uuid.Nil {
+		initiator = b.workspace.OwnerID
+	}
+	reason := b.reason
+	if reason == "" {
+		reason = database.BuildReasonInitiator
+		if r := database.BuildReason(b.structuredReason.Category); r.Valid() {
+			reason = r
+		}
+	}
+	var templateVersionID uuid.NullUUID
+	if b.version.specific != nil {
+		templateVersionID = uuid.NullUUID{UUID: *b.version.specific, Valid: true}
+	}
+
+	intentID := uuid.New()
+	err := store.InsertWorkspaceBuildIntent(b.ctx, database.InsertWorkspaceBuildIntentParams{
+		ID:                intentID,
+		CreatedAt:         database.Now(),
+		WorkspaceID:       b.workspace.ID,
+		InitiatorID:       initiator,
+		Transition:        b.trans,
+		Reason:            reason,
+		TemplateVersionID: templateVersionID,
+	})
+	if err != nil {
+		// The intent is only for observability, so failing to record it doesn't fail the build.
+		b.logger.Warn(b.ctx, "failed to record workspace build intent",
+			slog.F("workspace_id", b.workspace.ID),
+			slog.Error(err),
+		)
+		return
+	}
+	b.intentID = intentID
+}
+
+// linkIntent references the build from the recorded intent, if there is one.
+func (b *Builder) linkIntent(store database.Store, buildID uuid.UUID) error {
+	if b.intentID == uuid.Nil {
+		return nil
+	}
+	err := store.UpdateWorkspaceBuildIntentBuildByID(b.ctx, database.UpdateWorkspaceBuildIntentBuildByIDParams{
+		ID:               b.intentID,
+		WorkspaceID:      b.workspace.ID,
+		WorkspaceBuildID: uuid.NullUUID{UUID: buildID, Valid: true},
+	})
+	if err != nil {
+		return BuildError{http.StatusInternalServerError, "link build intent", err}
+	}
+	return nil
+}
+
+// getIdempotentBuild returns the workspace's existing build with the Builder's idempotency key and its job, or nil if
+// there is none.  It fails if the existing build doesn't match what was requested of the Builder, since the key is
+// being reused for a different request.
+func (b *Builder) getIdempotentBuild() (*database.WorkspaceBuild, *database.ProvisionerJob, error) {
+	existing, err := b.store.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(b.ctx, database.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams{
+		WorkspaceID:    b.workspace.ID,
+		IdempotencyKey: sql.NullString{String: b.idempotencyKey, Valid: true},
+	})
+	if xerrors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, BuildError{http.StatusInternalServerError, "failed to fetch build by idempotency key", err}
+	}
+	mismatch, err := b.idempotentBuildMismatch(existing)
+	if err != nil {
+		return nil, nil, BuildError{http.StatusInternalServerError, "failed to compare build with idempotency key", err}
+	}
+	if mismatch != "" {
+		msg := fmt.Sprintf("Idempotency key %q was used for a build with a different %s.", b.idempotencyKey, mismatch)
+		return nil, nil, BuildError{http.StatusConflict, msg, xerrors.New(msg)}
+	}
+	job, err := b.store.GetProvisionerJobByID(b.ctx, existing.JobID)
+	if err != nil {
+		return nil, nil, BuildError{http.StatusInternalServerError, "failed to fetch provisioner job of build with idempotency key", err}
+	}
+	b.logger.Debug(b.ctx, "returning existing build with idempotency key",
+		slog.F("workspace_build_id", existing.ID),
+	)
+	return &existing, &job, nil
+}
+
+// idempotentBuildMismatch returns what differs between the existing build and the request, or "" if they match.  Only
+// what was explicitly requested is compared: parameters not given in the request may have been resolved from the
+// build that preceded the existing one.
+func (b *Builder) idempotentBuildMismatch(existing database.WorkspaceBuild) (string, error) {
+	if existing.Transition != b.trans {
+		return "transition", nil
+	}
+	if b.version.specific != nil && *b.version.specific != existing.TemplateVersionID {
+		return "template version", nil
+	}
+	if len(b.richParameterValues) == 0 {
+		return "", nil
+	}
+	params, err := b.store.GetWorkspaceBuildParameters(b.ctx, existing.ID)
+	if err != nil && !xerrors.Is(err, sql.ErrNoRows) {
+		return "", xerrors.Errorf("get build %s parameters: %w", existing.ID, err)
+	}
+	values := make(map[string]string, len(params))
+	for _, p := range params {
+		values[p.Name] = p.Value
+	}
+	for _, p := range b.richParameterValues {
+		if v, ok := values[p.Name]; !ok || v != p.Value {
+			return fmt.Sprintf("value for parameter %q", p.Name), nil
+		}
+	}
+	return "", nil
+}
+
+// checkRollbackTarget verifies that the build being rolled back to, if any, belongs to the workspace being built.
+func (b *Builder) checkRollbackTarget() error {
+	if !b.rollbackOf.Valid {
+		return nil
+	}
+	target, err := b.store.GetWorkspaceBuildByID(b.ctx, b.rollbackOf.UUID)
+	if xerrors.Is(err, sql.ErrNoRows) {
+		msg := fmt.Sprintf("Rollback target build %s does not exist.", b.rollbackOf.UUID)
+		return BuildError{http.StatusBadRequest, msg, xerrors.New(msg)}
+	}
+	if err != nil {
+		return BuildError{http.StatusInternalServerError, "failed to fetch rollback target build", err}
+	}
+	if target.WorkspaceID != b.workspace.ID {
+		msg := fmt.Sprintf("Rollback target build %s does not belong to this workspace.", b.rollbackOf.UUID)
+		return BuildError{http.StatusBadRequest, msg, xerrors.New(msg)}
+	}
+	return nil
+}
+
+// checkExpectations compares the computed build with the expectations of the caller, if any.
+func (b *Builder) checkExpectations() error {
+	if b.expectedVersionID != nil {
+		versionID, err := b.getTemplateVersionID()
+		if err != nil {
+			return BuildError{http.StatusInternalServerError, "compute template version ID", err}
+		}
+		if versionID != *b.expectedVersionID {
+			msg := fmt.Sprintf("Build would use template version %s, but %s was expected.", versionID, *b.expectedVersionID)
+			return BuildError{http.StatusConflict, msg, xerrors.New(msg)}
+		}
+	}
+	if b.expectedBuildNumber != nil {
+		buildNum, err := b.getBuildNumber()
+		if err != nil {
+			return BuildError{http.StatusInternalServerError, "compute build number", err}
+		}
+		if buildNum != *b.expectedBuildNumber {
+			msg := fmt.Sprintf("Build would be number %d, but %d was expected.", buildNum, *b.expectedBuildNumber)
+			return BuildError{http.StatusConflict, msg, xerrors.New(msg)}
+		}
+	}
+	return nil
+}
+
+// checkStatePreserved guards against a stop build silently dropping the provisioner state of the prior build, which
+// would leave the workspace's resources running with nothing to track them.  Only Orphan() may discard the state.
+func (b *Builder) checkStatePreserved(state []byte) error {
+	if b.trans != database.WorkspaceTransitionStop || b.state.orphan || len(state) > 0 {
+		return nil
+	}
+	bld, err := b.getLastBuild()
+	if xerrors.Is(err, sql.ErrNoRows) {
+		// no prior build, so there is no state to lose
+		return nil
+	}
+	if err != nil {
+		return BuildError{http.StatusInternalServerError, "failed to fetch prior build", err}
+	}
+	if len(bld.ProvisionerState) > 0 {
+		msg := "Stop build would discard the provisioner state of the prior build."
+		return BuildError{http.StatusInternalServerError, msg, xerrors.New(msg)}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package wsbuilder
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"cdr.dev/slog/sloggers/slogtest"
+	"github.com/coder/coder/coderd/database"
+	"github.com/coder/coder/codersdk"
+)
+
+func TestCheckPriorJobStatus(t *testing.T) {
+	t.Parallel()
+
+	job := database.ProvisionerJob{ID: uuid.New()}
+	// A status that db2sdk doesn't produce today, e.g. one added to codersdk later.
+	unmapped := codersdk.ProvisionerJobStatus("unmapped")
+
+	newBuilder := func(t *testing.T) Builder {
+		b := New(database.Workspace{}, database.WorkspaceTransitionStart).Logger(slogtest.Make(t, nil))
+		b.ctx = context.Background()
+		return b
+	}
+
+	t.Run("UnknownBlocked", func(t *testing.T) {
+		t.Parallel()
+		b := newBuilder(t)
+		err := b.checkPriorJobStatus(job, unmapped)
+		var bldErr BuildError
+		require.ErrorAs(t, err, &bldErr)
+		require.Equal(t, http.StatusConflict, bldErr.Status)
+		require.Equal(t, "The status of the prior workspace build is unknown.", bldErr.Message)
+	})
+
+	t.Run("UnknownAllowed", func(t *testing.T) {
+		t.Parallel()
+		b := newBuilder(t).AllowUnknownPriorStatus()
+		require.NoError(t, b.checkPriorJobStatus(job, unmapped))
+	})
+
+	t.Run("Known", func(t *testing.T) {
+		t.Parallel()
+		for _, status := range []codersdk.ProvisionerJobStatus{
+			codersdk.ProvisionerJobPending,
+			codersdk.ProvisionerJobRunning,
+			codersdk.ProvisionerJobCanceling,
+		} {
+			b := newBuilder(t)
+			var bldErr BuildError
+			require.ErrorAs(t, b.checkPriorJobStatus(job, status), &bldErr, status)
+			require.Equal(t, "A workspace build is already active.", bldErr.Message, status)
+		}
+		for _, status := range []codersdk.ProvisionerJobStatus{
+			codersdk.ProvisionerJobSucceeded,
+			codersdk.ProvisionerJobCanceled,
+			codersdk.ProvisionerJobFailed,
+		} {
+			b := newBuilder(t)
+			require.NoError(t, b.checkPriorJobStatus(job, status), status)
+		}
+	})
+}
@@ -4,18 +4,25 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
 
+	"cdr.dev/slog"
 	"github.com/coder/coder/coderd/database"
+	"github.com/coder/coder/coderd/database/dbfake"
+	"github.com/coder/coder/coderd/database/dbgen"
 	"github.com/coder/coder/coderd/database/dbmock"
 	"github.com/coder/coder/coderd/provisionerdserver"
+	"github.com/coder/coder/coderd/rbac"
 	"github.com/coder/coder/coderd/wsbuilder"
 	"github.com/coder/coder/codersdk"
 )
@@ -35,6 +42,7 @@ var (
 	lastBuildID       = uuid.MustParse("12341234-0000-0000-000b-000000000000")
 	lastBuildJobID    = uuid.MustParse("12341234-0000-0000-000c-000000000000")
 	otherUserID       = uuid.MustParse("12341234-0000-0000-000d-000000000000")
+	priorBuildID      = uuid.MustParse("12341234-0000-0000-000e-000000000000")
 )
 
 func TestBuilder_NoOptions(t *testing.T) {
@@ -58,6 +66,7 @@ func TestBuilder_NoOptions(t *testing.T) {
 		// Outputs
 		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {
 			asrt.Equal(userID, job.InitiatorID)
+			asrt.False(job.PendingDeadline.Valid)
 			asrt.Equal(inactiveFileID, job.FileID)
 			input := provisionerdserver.WorkspaceProvisionJob{}
 			err := json.Unmarshal(job.Input, &input)
@@ -76,6 +85,8 @@ func TestBuilder_NoOptions(t *testing.T) {
 			asrt.Equal(database.WorkspaceTransitionStart, bld.Transition)
 			asrt.Equal(database.BuildReasonInitiator, bld.Reason)
 			asrt.Equal(buildID, bld.ID)
+			// The hash of the version's source file is recorded with the build.
+			asrt.Equal(inactiveFileID, bld.TemplateFileID)
 		}),
 		withBuild,
 		expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
@@ -91,6 +102,43 @@ func TestBuilder_NoOptions(t *testing.T) {
 	req.NoError(err)
 }
 
+func TestBuilder_FirstBuild(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mDB := expectDB(t,
+		// Inputs
+		withTemplate,
+		withActiveVersion(nil),
+		withLastBuildNotFound,
+		withParameterSchemas(activeJobID, nil),
+		// previous rich parameters are not queried because there is no previous build.
+
+		// Outputs
+		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {
+			asrt.Equal(activeFileID, job.FileID)
+		}),
+		withInTx,
+		expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+			// without a prior version to build, the active version is used
+			asrt.Equal(activeVersionID, bld.TemplateVersionID)
+			asrt.Equal(int32(1), bld.BuildNumber)
+			asrt.Empty(bld.ProvisionerState)
+		}),
+		withBuild,
+		expectBuildParameters(func(_ database.InsertWorkspaceBuildParametersParams) {}),
+	)
+
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart)
+	_, _, err := uut.Build(ctx, mDB, nil)
+	req.NoError(err)
+}
+
 func TestBuilder_Initiator(t *testing.T) {
 	t.Parallel()
 	req := require.New(t)
@@ -160,7 +208,60 @@ func TestBuilder_Reason(t *testing.T) {
 	req.NoError(err)
 }
 
-func TestBuilder_ActiveVersion(t *testing.T) {
+func TestBuilder_StructuredReason(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name       string
+		category   string
+		wantReason database.BuildReason
+	}{
+		{"CoarseCategory", string(database.BuildReasonAutostart), database.BuildReasonAutostart},
+		{"CustomCategory", "capacity", database.BuildReasonInitiator},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			req := require.New(t)
+			asrt := assert.New(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mDB := expectDB(t,
+				// Inputs
+				withTemplate,
+				withInactiveVersion(nil),
+				withLastBuildFound,
+				withRichParameters(nil),
+				withParameterSchemas(inactiveJobID, nil),
+
+				// Outputs
+				expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+				withInTx,
+				expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+					asrt.Equal(tc.wantReason, bld.Reason)
+					asrt.Equal(database.StructuredBuildReason{
+						Category:    tc.category,
+						Subcategory: "rebalance",
+						Source:      "scheduler",
+					}, bld.StructuredReason)
+				}),
+				expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+				}),
+				withBuild,
+			)
+
+			ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+			uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+				StructuredReason(tc.category, "rebalance", "scheduler")
+			_, _, err := uut.Build(ctx, mDB, nil)
+			req.NoError(err)
+		})
+	}
+}
+
+func TestBuilder_ScheduleName(t *testing.T) {
 	t.Parallel()
 	req := require.New(t)
 	asrt := assert.New(t)
@@ -171,22 +272,17 @@ func TestBuilder_ActiveVersion(t *testing.T) {
 	mDB := expectDB(t,
 		// Inputs
 		withTemplate,
-		withActiveVersion(nil),
-		withLastBuildNotFound,
-		withParameterSchemas(activeJobID, nil),
-		// previous rich parameters are not queried because there is no previous build.
+		withInactiveVersion(nil),
+		withLastBuildFound,
+		withRichParameters(nil),
+		withParameterSchemas(inactiveJobID, nil),
 
 		// Outputs
-		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {
-			asrt.Equal(activeFileID, job.FileID)
-		}),
-
+		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
 		withInTx,
 		expectBuild(func(bld database.InsertWorkspaceBuildParams) {
-			asrt.Equal(activeVersionID, bld.TemplateVersionID)
-			// no previous build...
-			asrt.Equal(int32(1), bld.BuildNumber)
-			asrt.Len(bld.ProvisionerState, 0)
+			asrt.Equal(database.BuildReasonAutostart, bld.Reason)
+			asrt.Equal(sql.NullString{String: "weekday-mornings", Valid: true}, bld.ScheduleName)
 		}),
 		expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
 		}),
@@ -194,67 +290,2296 @@ func TestBuilder_ActiveVersion(t *testing.T) {
 	)
 
 	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
-	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).ActiveVersion()
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+		Reason(database.BuildReasonAutostart).
+		ScheduleName("weekday-mornings")
 	_, _, err := uut.Build(ctx, mDB, nil)
 	req.NoError(err)
 }
 
-func TestWorkspaceBuildWithRichParameters(t *testing.T) {
+func TestBuilder_ReasonTransitionMismatch(t *testing.T) {
 	t.Parallel()
 
-	const (
-		firstParameterName        = "first_parameter"
-		firstParameterDescription = "This is first parameter"
-		firstParameterValue       = "1"
+	for _, tc := range []struct {
+		reason database.BuildReason
+		trans  database.WorkspaceTransition
+	}{
+		{database.BuildReasonAutostart, database.WorkspaceTransitionStop},
+		{database.BuildReasonAutostart, database.WorkspaceTransitionDelete},
+		{database.BuildReasonAutostop, database.WorkspaceTransitionStart},
+		{database.BuildReasonAutostop, database.WorkspaceTransitionDelete},
+	} {
+		tc := tc
+		t.Run(fmt.Sprintf("%s_%s", tc.reason, tc.trans), func(t *testing.T) {
+			t.Parallel()
+			req := require.New(t)
+			asrt := assert.New(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// No queries are expected, since the build is rejected up front.
+			mDB := expectDB(t)
+
+			ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+			uut := wsbuilder.New(ws, tc.trans).Reason(tc.reason)
+			_, _, err := uut.Build(ctx, mDB, nil)
+			bldErr := wsbuilder.BuildError{}
+			req.ErrorAs(err, &bldErr)
+			asrt.Equal(http.StatusBadRequest, bldErr.Status)
+		})
+	}
+}
 
-		secondParameterName        = "second_parameter"
-		secondParameterDescription = "This is second parameter"
-		secondParameterValue       = "2"
+func TestBuilder_ReasonTransitionMatch(t *testing.T) {
+	t.Parallel()
 
-		immutableParameterName        = "immutable_parameter"
-		immutableParameterDescription = "This is immutable parameter"
-		immutableParameterValue       = "3"
-	)
+	for _, tc := range []struct {
+		reason database.BuildReason
+		trans  database.WorkspaceTransition
+	}{
+		{database.BuildReasonAutostart, database.WorkspaceTransitionStart},
+		{database.BuildReasonAutostop, database.WorkspaceTransitionStop},
+	} {
+		tc := tc
+		t.Run(fmt.Sprintf("%s_%s", tc.reason, tc.trans), func(t *testing.T) {
+			t.Parallel()
+			req := require.New(t)
+			asrt := assert.New(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			expectations := []txExpect{
+				// Inputs
+				withTemplate,
+				withInactiveVersion(nil),
+				withLastBuildFound,
+				withRichParameters(nil),
+			}
+			if tc.trans == database.WorkspaceTransitionStart {
+				// Legacy parameters are only verified when starting.
+				expectations = append(expectations, withParameterSchemas(inactiveJobID, nil))
+			}
+			expectations = append(expectations,
+				// Outputs
+				expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+				withInTx,
+				expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+					asrt.Equal(tc.reason, bld.Reason)
+					asrt.Equal(tc.trans, bld.Transition)
+				}),
+				expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+				}),
+				withBuild,
+			)
+			mDB := expectDB(t, expectations...)
 
-	initialBuildParameters := []database.WorkspaceBuildParameter{
-		{Name: firstParameterName, Value: firstParameterValue},
-		{Name: secondParameterName, Value: secondParameterValue},
-		{Name: immutableParameterName, Value: immutableParameterValue},
+			ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+			uut := wsbuilder.New(ws, tc.trans).Reason(tc.reason)
+			_, _, err := uut.Build(ctx, mDB, nil)
+			req.NoError(err)
+		})
 	}
+}
 
-	richParameters := []database.TemplateVersionParameter{
-		{Name: firstParameterName, Description: firstParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
-		{Name: secondParameterName, Description: secondParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
-		{Name: immutableParameterName, Description: immutableParameterDescription, Mutable: false, Options: json.RawMessage("[]")},
-	}
+func TestBuilder_Priority(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
 
-	t.Run("UpdateParameterValues", func(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mDB := expectDB(t,
+		// Inputs
+		withTemplate,
+		withInactiveVersion(nil),
+		withLastBuildFound,
+		withRichParameters(nil),
+		withParameterSchemas(inactiveJobID, nil),
+
+		// Outputs
+		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {
+			asrt.EqualValues(10, job.Priority)
+		}),
+		withInTx,
+		expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+		expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+		}),
+		withBuild,
+	)
+
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).Priority(10)
+	_, _, err := uut.Build(ctx, mDB, nil)
+	req.NoError(err)
+}
+
+func TestBuilder_PendingTimeout(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Set", func(t *testing.T) {
 		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(nil),
+			withLastBuildFound,
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {
+				asrt.True(job.PendingDeadline.Valid)
+				asrt.Equal(job.CreatedAt.Add(10*time.Minute), job.PendingDeadline.Time)
+			}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+			}),
+			withBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).PendingTimeout(10 * time.Minute)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
 
+	t.Run("Negative", func(t *testing.T) {
+		t.Parallel()
 		req := require.New(t)
 		asrt := assert.New(t)
 
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		const updatedParameterValue = "3"
-		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
-			{Name: firstParameterName, Value: firstParameterValue},
-			{Name: secondParameterName, Value: updatedParameterValue},
-		}
-		expectedParams := map[string]string{
-			firstParameterName:     firstParameterValue,
-			secondParameterName:    updatedParameterValue,
-			immutableParameterName: immutableParameterValue,
-		}
+		// No queries are expected, since the build is rejected up front.
+		mDB := expectDB(t)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).PendingTimeout(-time.Minute)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+	})
+
+	t.Run("Reaped", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The last build's job was failed by the reaper before a daemon acquired it.
+		fakeDB := dbfake.New()
+		_ = dbgen.ProvisionerJob(t, fakeDB, database.ProvisionerJob{
+			ID:              lastBuildJobID,
+			OrganizationID:  orgID,
+			InitiatorID:     userID,
+			Type:            database.ProvisionerJobTypeWorkspaceBuild,
+			PendingDeadline: sql.NullTime{Time: database.Now().Add(-time.Minute), Valid: true},
+		})
+		reaped, err := fakeDB.FailStalePendingJobs(ctx, database.Now())
+		req.NoError(err)
+		req.Len(reaped, 1)
 
 		mDB := expectDB(t,
 			// Inputs
 			withTemplate,
-			withInactiveVersion(richParameters),
+			withInactiveVersion(nil),
+			func(mTx *dbmock.MockStore) {
+				mTx.EXPECT().GetLatestWorkspaceBuildByWorkspaceID(gomock.Any(), workspaceID).
+					Times(1).
+					Return(database.WorkspaceBuild{
+						ID:                lastBuildID,
+						WorkspaceID:       workspaceID,
+						TemplateVersionID: inactiveVersionID,
+						BuildNumber:       1,
+						Transition:        database.WorkspaceTransitionStart,
+						InitiatorID:       userID,
+						JobID:             lastBuildJobID,
+						Reason:            database.BuildReasonInitiator,
+					}, nil)
+				mTx.EXPECT().GetProvisionerJobByID(gomock.Any(), lastBuildJobID).
+					Times(1).
+					Return(reaped[0], nil)
+			},
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {}),
+			withBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart)
+		_, _, err = uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+}
+
+func TestBuilder_RetryPolicy(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Every attempt fails with a serialization error, before reaching the builder's queries.
+	ctrl := gomock.NewController(t)
+	mDB := dbmock.NewMockStore(ctrl)
+	mDB.EXPECT().InTx(gomock.Any(), gomock.Any()).
+		Times(3).
+		Return(&pq.Error{Code: "40001", Message: "could not serialize access due to concurrent update"})
+
+	var attempts []int
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+		RetryPolicy(3, func(attempt int) time.Duration {
+			attempts = append(attempts, attempt)
+			return time.Millisecond
+		})
+	_, _, err := uut.Build(ctx, mDB, nil)
+	req.Error(err)
+	var pqErr *pq.Error
+	req.ErrorAs(err, &pqErr)
+	asrt.EqualValues("40001", pqErr.Code)
+	// No backoff after the last attempt.
+	asrt.Equal([]int{1, 2}, attempts)
+}
+
+func TestBuilder_RollbackOf(t *testing.T) {
+	t.Parallel()
+
+	t.Run("SameWorkspace", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var insertedBuildID uuid.UUID
+		mDB := expectDB(t,
+			// Inputs
+			withRollbackTarget(priorBuildID, workspaceID),
+			withTemplate,
+			withInactiveVersion(nil),
+			withLastBuildFound,
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+				insertedBuildID = bld.ID
+				asrt.Equal(uuid.NullUUID{UUID: priorBuildID, Valid: true}, bld.RollbackOf)
+			}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+			}),
+			withBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RollbackOf(priorBuildID)
+		bld, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+		asrt.Equal(insertedBuildID, bld.ID)
+	})
+
+	t.Run("OtherWorkspace", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		otherWorkspaceID := uuid.MustParse("12341234-0000-0000-000f-000000000000")
+		mDB := expectDB(t,
+			withRollbackTarget(priorBuildID, otherWorkspaceID),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RollbackOf(priorBuildID)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+		asrt.Contains(bldErr.Message, "does not belong to this workspace")
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t, func(mTx *dbmock.MockStore) {
+			mTx.EXPECT().GetWorkspaceBuildByID(gomock.Any(), priorBuildID).
+				Times(1).
+				Return(database.WorkspaceBuild{}, sql.ErrNoRows)
+		})
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RollbackOf(priorBuildID)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+		asrt.Contains(bldErr.Message, "does not exist")
+	})
+}
+
+func TestBuilder_TemplateVersionByName(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Found", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersionByName(nil),
+			withLastBuildFound,
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {
+				asrt.Equal(inactiveFileID, job.FileID)
+			}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+				asrt.Equal(inactiveVersionID, bld.TemplateVersionID)
+			}),
+			withBuild,
+			expectBuildParameters(func(_ database.InsertWorkspaceBuildParametersParams) {}),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).TemplateVersionByName("inactive")
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t, func(mTx *dbmock.MockStore) {
+			mTx.EXPECT().GetTemplateVersionByTemplateIDAndName(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(database.TemplateVersion{}, sql.ErrNoRows)
+		})
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).TemplateVersionByName("missing")
+		_, _, err := uut.Build(ctx, mDB, nil)
+		var buildErr wsbuilder.BuildError
+		req.ErrorAs(err, &buildErr)
+		req.Equal(http.StatusNotFound, buildErr.Status)
+		req.Contains(buildErr.Message, `"missing"`)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).TemplateVersionByName("")
+		_, _, err := uut.Build(ctx, mDB, nil)
+		var buildErr wsbuilder.BuildError
+		req.ErrorAs(err, &buildErr)
+		req.Equal(http.StatusBadRequest, buildErr.Status)
+	})
+}
+
+func TestBuilder_SkipUnchangedParameters(t *testing.T) {
+	t.Parallel()
+
+	richParameters := []database.TemplateVersionParameter{
+		{Name: "region", Description: "Region", Mutable: true, Options: json.RawMessage("[]")},
+		{Name: "size", Description: "Size", Mutable: true, Options: json.RawMessage("[]")},
+	}
+	lastBuildParameters := []database.WorkspaceBuildParameter{
+		{WorkspaceBuildID: lastBuildID, Name: "region", Value: "eu"},
+		{WorkspaceBuildID: lastBuildID, Name: "size", Value: "small"},
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// No call to InsertWorkspaceBuildParameters is expected.
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(lastBuildParameters),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+				asrt.Equal(uuid.NullUUID{UUID: lastBuildID, Valid: true}, bld.ParametersFromBuildID)
+			}),
+			withBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStop).SkipUnchangedParameters()
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(lastBuildParameters),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+				asrt.False(bld.ParametersFromBuildID.Valid)
+			}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+				asrt.ElementsMatch([]string{"region", "size"}, params.Name)
+				asrt.ElementsMatch([]string{"us", "small"}, params.Value)
+			}),
+			withBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues([]codersdk.WorkspaceBuildParameter{{Name: "region", Value: "us"}}).
+			SkipUnchangedParameters()
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+}
+
+func TestBuilder_MaintenanceCheck(t *testing.T) {
+	t.Parallel()
+
+	t.Run("On", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// No queries are expected, since the build is rejected up front.
+		mDB := expectDB(t)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			MaintenanceCheck(func() bool { return true })
+		_, _, err := uut.Build(ctx, mDB, nil)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusServiceUnavailable, bldErr.Status)
+		asrt.Equal("deployment is in maintenance mode", bldErr.Message)
+	})
+
+	t.Run("Off", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(nil),
+			withLastBuildFound,
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+			}),
+			withBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			MaintenanceCheck(func() bool { return false })
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+}
+
+func TestBuilder_StopPreservesState(t *testing.T) {
+	t.Parallel()
+
+	t.Run("CarriedForward", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(nil),
+			withLastBuildFound,
+			withRichParameters(nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+				asrt.Equal(database.WorkspaceTransitionStop, bld.Transition)
+				asrt.Equal("last build state", string(bld.ProvisionerState))
+			}),
+			withBuild,
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {}),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStop)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+
+	t.Run("Orphan", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(nil),
+			withLastBuildFound,
+			withRichParameters(nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+				asrt.Len(bld.ProvisionerState, 0)
+			}),
+			withBuild,
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {}),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStop).Orphan()
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+}
+
+func TestBuilder_InsertParametersFailure(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	richParameters := []database.TemplateVersionParameter{
+		{Name: "first", Mutable: true, DefaultValue: "1", Options: json.RawMessage("[]")},
+		{Name: "second", Mutable: true, DefaultValue: "2", Options: json.RawMessage("[]")},
+	}
+
+	mDB := expectDB(t,
+		// Inputs
+		withTemplate,
+		withInactiveVersion(richParameters),
+		withLastBuildFound,
+		withRichParameters(nil),
+		withParameterSchemas(inactiveJobID, nil),
+
+		// Outputs
+		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+		withInTx,
+		expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+		func(mTx *dbmock.MockStore) {
+			mTx.EXPECT().InsertWorkspaceBuildParameters(gomock.Any(), gomock.Any()).
+				Times(1).
+				DoAndReturn(func(_ context.Context, params database.InsertWorkspaceBuildParametersParams) error {
+					// Fail on the second parameter, as the database would.
+					return &pq.Error{
+						Code:    "23514",
+						Message: "new row violates check constraint",
+						Detail:  fmt.Sprintf("Failing row contains (%s, %s, %s).", params.WorkspaceBuildID, params.Name[1], params.Value[1]),
+					}
+				})
+		},
+	)
+
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart)
+	_, _, err := uut.Build(ctx, mDB, nil)
+	bldErr := wsbuilder.BuildError{}
+	req.ErrorAs(err, &bldErr)
+	asrt.Equal(http.StatusInternalServerError, bldErr.Status)
+	asrt.Contains(bldErr.Message, `1 ("second")`)
+}
+
+func TestBuilder_Canceled(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	richParameters := []database.TemplateVersionParameter{
+		{Name: "first", Mutable: true, DefaultValue: "1", Options: json.RawMessage("[]")},
+	}
+
+	mDB := expectDB(t,
+		// Inputs
+		withTemplate,
+		withInactiveVersion(richParameters),
+		withLastBuildFound,
+		withRichParameters(nil),
+		withParameterSchemas(inactiveJobID, nil),
+
+		// Outputs
+		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+		withInTx,
+		// The caller gives up while the build is being inserted, e.g. because the database is slow.
+		expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+			cancel()
+		}),
+		// no build parameters and no read of the build, since the build returns as soon as it notices.
+	)
+
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart)
+	bld, job, err := uut.Build(ctx, mDB, nil)
+	// The error is returned from the transaction, so it is rolled back and nothing is committed.
+	bldErr := wsbuilder.BuildError{}
+	req.ErrorAs(err, &bldErr)
+	asrt.Equal(http.StatusRequestTimeout, bldErr.Status)
+	asrt.ErrorIs(err, context.Canceled)
+	asrt.Nil(bld)
+	asrt.Nil(job)
+	names, values := uut.Parameters()
+	asrt.Nil(names)
+	asrt.Nil(values)
+}
+
+func TestBuilder_DeferParameterInsert(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	richParameters := []database.TemplateVersionParameter{
+		{Name: "region", Description: "Region", Mutable: true, Options: json.RawMessage("[]")},
+		{Name: "size", Description: "Size", Mutable: true, Options: json.RawMessage("[]")},
+	}
+	lastBuildParameters := []database.WorkspaceBuildParameter{
+		{WorkspaceBuildID: lastBuildID, Name: "region", Value: "eu"},
+		{WorkspaceBuildID: lastBuildID, Name: "size", Value: "small"},
+	}
+
+	var buildID uuid.UUID
+	// No call to InsertWorkspaceBuildParameters is expected.
+	mDB := expectDB(t,
+		// Inputs
+		withTemplate,
+		withInactiveVersion(richParameters),
+		withLastBuildFound,
+		withRichParameters(lastBuildParameters),
+		withParameterSchemas(inactiveJobID, nil),
+
+		// Outputs
+		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+		withInTx,
+		expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+			buildID = bld.ID
+		}),
+		withBuild,
+	)
+
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+		RichParameterValues([]codersdk.WorkspaceBuildParameter{{Name: "region", Value: "us"}}).
+		DeferParameterInsert()
+	_, _, err := uut.Build(ctx, mDB, nil)
+	req.NoError(err)
+
+	asrt.ElementsMatch([]database.WorkspaceBuildParameter{
+		{WorkspaceBuildID: buildID, Name: "region", Value: "us"},
+		{WorkspaceBuildID: buildID, Name: "size", Value: "small"},
+	}, uut.DeferredParameters())
+}
+
+func TestBuilder_RecordIntent(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Rejected", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The build is rejected before any build or job is inserted, so the intent is never linked to a build.
+		mDB := expectDB(t)
+		mDB.EXPECT().InsertWorkspaceBuildIntent(gomock.Any(), gomock.Any()).
+			Times(1).
+			DoAndReturn(func(_ context.Context, intent database.InsertWorkspaceBuildIntentParams) error {
+				asrt.NotEqual(uuid.Nil, intent.ID)
+				asrt.Equal(workspaceID, intent.WorkspaceID)
+				asrt.Equal(otherUserID, intent.InitiatorID)
+				asrt.Equal(database.WorkspaceTransitionStart, intent.Transition)
+				asrt.Equal(database.BuildReasonInitiator, intent.Reason)
+				asrt.Equal(uuid.NullUUID{UUID: inactiveVersionID, Valid: true}, intent.TemplateVersionID)
+				return nil
+			})
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			VersionID(inactiveVersionID).
+			Initiator(otherUserID).
+			MaintenanceCheck(func() bool { return true }).
+			RecordIntent()
+		_, _, err := uut.Build(ctx, mDB, nil)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusServiceUnavailable, bldErr.Status)
+	})
+
+	t.Run("Linked", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var intentID, buildID uuid.UUID
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(nil),
+			withLastBuildFound,
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+				buildID = bld.ID
+			}),
+			func(mTx *dbmock.MockStore) {
+				mTx.EXPECT().UpdateWorkspaceBuildIntentBuildByID(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, link database.UpdateWorkspaceBuildIntentBuildByIDParams) error {
+						asrt.Equal(intentID, link.ID)
+						asrt.Equal(workspaceID, link.WorkspaceID)
+						asrt.Equal(uuid.NullUUID{UUID: buildID, Valid: true}, link.WorkspaceBuildID)
+						return nil
+					})
+			},
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {}),
+			withBuild,
+		)
+		mDB.EXPECT().InsertWorkspaceBuildIntent(gomock.Any(), gomock.Any()).
+			Times(1).
+			DoAndReturn(func(_ context.Context, intent database.InsertWorkspaceBuildIntentParams) error {
+				intentID = intent.ID
+				// No version was requested explicitly.
+				asrt.False(intent.TemplateVersionID.Valid)
+				asrt.Equal(userID, intent.InitiatorID)
+				return nil
+			})
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RecordIntent()
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+
+	t.Run("InsertFails", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Failing to record the intent doesn't fail the build, and there's nothing to link.
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(nil),
+			withLastBuildFound,
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {}),
+			withBuild,
+		)
+		mDB.EXPECT().InsertWorkspaceBuildIntent(gomock.Any(), gomock.Any()).
+			Times(1).
+			Return(xerrors.New("boom"))
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RecordIntent()
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+}
+
+func TestBuilder_Expect(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Met", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(nil),
+			withLastBuildFound,
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+				asrt.Equal(inactiveVersionID, bld.TemplateVersionID)
+				asrt.Equal(int32(2), bld.BuildNumber)
+			}),
+			withBuild,
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {}),
+		)
+
+		versionID := inactiveVersionID
+		buildNumber := int32(2)
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).Expect(&versionID, &buildNumber)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+
+	t.Run("BuildNumberChanged", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The caller saw the workspace before its first build, but a concurrent build was inserted meanwhile, so
+		// this build would be number 2.  Nothing is inserted.
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			func(mTx *dbmock.MockStore) {
+				mTx.EXPECT().GetTemplateVersionByID(gomock.Any(), inactiveVersionID).
+					Times(1).
+					Return(inactiveVersion, nil)
+			},
+			withInactiveVersionJobOnly,
+			withLastBuildFound,
+		)
+
+		buildNumber := int32(1)
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).Expect(nil, &buildNumber)
+		bld, job, err := uut.Build(ctx, mDB, nil)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusConflict, bldErr.Status)
+		asrt.Contains(bldErr.Message, "number 2")
+		asrt.Nil(bld)
+		asrt.Nil(job)
+	})
+
+	t.Run("VersionChanged", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			func(mTx *dbmock.MockStore) {
+				mTx.EXPECT().GetTemplateVersionByID(gomock.Any(), inactiveVersionID).
+					Times(1).
+					Return(inactiveVersion, nil)
+			},
+			withInactiveVersionJobOnly,
+			withLastBuildFound,
+		)
+
+		versionID := activeVersionID
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).Expect(&versionID, nil)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusConflict, bldErr.Status)
+		asrt.Contains(bldErr.Message, activeVersionID.String())
+	})
+}
+
+func TestBuilder_DryRun(t *testing.T) {
+	t.Parallel()
+
+	richParameters := []database.TemplateVersionParameter{
+		{Name: "region", Mutable: true, Options: json.RawMessage("[]")},
+		{Name: "size", Mutable: false, Options: json.RawMessage("[]")},
+	}
+	lastBuildParameters := []database.WorkspaceBuildParameter{
+		{Name: "region", Value: "eu"},
+		{Name: "size", Value: "small"},
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// No outputs are expected, since nothing is inserted.
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(lastBuildParameters),
+			withParameterSchemas(inactiveJobID, nil),
+			withProvisionerDaemons(nil),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues([]codersdk.WorkspaceBuildParameter{{Name: "region", Value: "us"}}).
+			DryRun()
+		bld, job, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+		asrt.Nil(bld)
+		asrt.Nil(job)
+		names, values := uut.DryRunParameters()
+		asrt.Equal([]string{"region", "size"}, names)
+		asrt.Equal([]string{"us", "small"}, values)
+		asrt.Empty(uut.DryRunProvisionerDaemons())
+	})
+
+	t.Run("ProvisionerDaemons", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The inactive version's job is user scoped, so the build's job is owned by the workspace owner.
+		jobTags := database.StringMap{
+			"version":                   "inactive",
+			provisionerdserver.TagScope: provisionerdserver.ScopeUser,
+			provisionerdserver.TagOwner: userID.String(),
+		}
+		matching := database.ProvisionerDaemon{
+			ID:           uuid.New(),
+			Name:         "matching",
+			Provisioners: []database.ProvisionerType{database.ProvisionerTypeTerraform},
+			Tags:         jobTags,
+		}
+		extraTags := database.ProvisionerDaemon{
+			ID:           uuid.New(),
+			Name:         "extra-tags",
+			Provisioners: []database.ProvisionerType{database.ProvisionerTypeEcho, database.ProvisionerTypeTerraform},
+			Tags: database.StringMap{
+				"version":                   "inactive",
+				"region":                    "eu",
+				provisionerdserver.TagScope: provisionerdserver.ScopeUser,
+				provisionerdserver.TagOwner: userID.String(),
+			},
+		}
+		otherOwner := database.ProvisionerDaemon{
+			ID:           uuid.New(),
+			Name:         "other-owner",
+			Provisioners: []database.ProvisionerType{database.ProvisionerTypeTerraform},
+			Tags: database.StringMap{
+				"version":                   "inactive",
+				provisionerdserver.TagScope: provisionerdserver.ScopeUser,
+				provisionerdserver.TagOwner: otherUserID.String(),
+			},
+		}
+		organizationScoped := database.ProvisionerDaemon{
+			ID:           uuid.New(),
+			Name:         "organization-scoped",
+			Provisioners: []database.ProvisionerType{database.ProvisionerTypeTerraform},
+			Tags:         database.StringMap{provisionerdserver.TagScope: provisionerdserver.ScopeOrganization},
+		}
+		otherProvisioner := database.ProvisionerDaemon{
+			ID:           uuid.New(),
+			Name:         "other-provisioner",
+			Provisioners: []database.ProvisionerType{database.ProvisionerTypeEcho},
+			Tags:         jobTags,
+		}
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(nil),
+			withLastBuildFound,
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, nil),
+			withProvisionerDaemons([]database.ProvisionerDaemon{
+				matching, extraTags, otherOwner, organizationScoped, otherProvisioner,
+			}),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).DryRun()
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+		asrt.Equal([]database.ProvisionerDaemon{matching, extraTags}, uut.DryRunProvisionerDaemons())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(lastBuildParameters),
+			withParameterSchemas(inactiveJobID, nil),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues([]codersdk.WorkspaceBuildParameter{{Name: "size", Value: "large"}}).
+			DryRun()
+		_, _, err := uut.Build(ctx, mDB, nil)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+		names, values := uut.DryRunParameters()
+		asrt.Empty(names)
+		asrt.Empty(values)
+	})
+}
+
+func TestBuilder_IdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	key := sql.NullString{String: "retry-1", Valid: true}
+	withIdempotentBuild := func(mTx *dbmock.MockStore) {
+		mTx.EXPECT().GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(gomock.Any(), database.GetWorkspaceBuildByWorkspaceIDAndIdempotencyKeyParams{
+			WorkspaceID:    workspaceID,
+			IdempotencyKey: key,
+		}).
+			Times(1).
+			Return(database.WorkspaceBuild{
+				ID:                lastBuildID,
+				WorkspaceID:       workspaceID,
+				TemplateVersionID: inactiveVersionID,
+				BuildNumber:       1,
+				Transition:        database.WorkspaceTransitionStart,
+				InitiatorID:       userID,
+				JobID:             lastBuildJobID,
+				IdempotencyKey:    key,
+			}, nil)
+	}
+	withIdempotentBuildParameters := func(mTx *dbmock.MockStore) {
+		mTx.EXPECT().GetWorkspaceBuildParameters(gomock.Any(), lastBuildID).
+			Times(1).
+			Return([]database.WorkspaceBuildParameter{
+				{WorkspaceBuildID: lastBuildID, Name: "region", Value: "eu"},
+			}, nil)
+	}
+
+	t.Run("Existing", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Nothing is inserted; the existing build and its job are returned.
+		mDB := expectDB(t,
+			withIdempotentBuild,
+			withIdempotentBuildParameters,
+			func(mTx *dbmock.MockStore) {
+				mTx.EXPECT().GetProvisionerJobByID(gomock.Any(), lastBuildJobID).
+					Times(1).
+					Return(database.ProvisionerJob{ID: lastBuildJobID}, nil)
+			},
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues([]codersdk.WorkspaceBuildParameter{{Name: "region", Value: "eu"}}).
+			IdempotencyKey(key.String)
+		bld, job, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+		asrt.Equal(lastBuildID, bld.ID)
+		asrt.Equal(lastBuildJobID, job.ID)
+	})
+
+	t.Run("ParameterMismatch", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			withIdempotentBuild,
+			withIdempotentBuildParameters,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues([]codersdk.WorkspaceBuildParameter{{Name: "region", Value: "us"}}).
+			IdempotencyKey(key.String)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		var buildErr wsbuilder.BuildError
+		req.ErrorAs(err, &buildErr)
+		req.Equal(http.StatusConflict, buildErr.Status)
+		req.Contains(buildErr.Message, `parameter "region"`)
+	})
+
+	t.Run("TransitionMismatch", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			withIdempotentBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStop).
+			IdempotencyKey(key.String)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		var buildErr wsbuilder.BuildError
+		req.ErrorAs(err, &buildErr)
+		req.Equal(http.StatusConflict, buildErr.Status)
+		req.Contains(buildErr.Message, "transition")
+	})
+
+	t.Run("New", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			func(mTx *dbmock.MockStore) {
+				mTx.EXPECT().GetWorkspaceBuildByWorkspaceIDAndIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(database.WorkspaceBuild{}, sql.ErrNoRows)
+			},
+			withTemplate,
+			withInactiveVersion(nil),
+			withLastBuildFound,
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+				asrt.Equal(key, bld.IdempotencyKey)
+			}),
+			withBuild,
+			expectBuildParameters(func(_ database.InsertWorkspaceBuildParametersParams) {}),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			IdempotencyKey(key.String)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+}
+
+func TestBuilder_Diff(t *testing.T) {
+	t.Parallel()
+
+	richParameters := []database.TemplateVersionParameter{
+		{Name: "region", Mutable: true, Options: json.RawMessage("[]")},
+		{Name: "size", Mutable: false, Options: json.RawMessage("[]")},
+	}
+
+	t.Run("Changed", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withActiveVersion(richParameters),
+			withLastBuildFound,
+			withLastBuildVersionParameters(richParameters),
+			withRichParameters([]database.WorkspaceBuildParameter{
+				{Name: "region", Value: "eu"},
+				{Name: "size", Value: "small"},
+				{Name: "dropped", Value: "x"},
+			}),
+			withParameterSchemas(activeJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			withBuild,
+			expectBuildParameters(func(_ database.InsertWorkspaceBuildParametersParams) {}),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			ActiveVersion().
+			State([]byte("new state")).
+			RichParameterValues([]codersdk.WorkspaceBuildParameter{{Name: "region", Value: "us"}})
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+		asrt.Equal(wsbuilder.BuildDiff{
+			VersionChanged:    true,
+			ChangedParameters: []string{"region", "dropped"},
+			StateReplaced:     true,
+		}, uut.Diff())
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters([]database.WorkspaceBuildParameter{
+				{Name: "region", Value: "eu"},
+				{Name: "size", Value: "small"},
+			}),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			withBuild,
+			expectBuildParameters(func(_ database.InsertWorkspaceBuildParametersParams) {}),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+		asrt.Equal(wsbuilder.BuildDiff{}, uut.Diff())
+	})
+
+	t.Run("FirstBuild", func(t *testing.T) {
+		t.Parallel()
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withActiveVersion(nil),
+			withLastBuildNotFound,
+			withParameterSchemas(activeJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			withBuild,
+			expectBuildParameters(func(_ database.InsertWorkspaceBuildParametersParams) {}),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+		asrt.Equal(wsbuilder.BuildDiff{FirstBuild: true}, uut.Diff())
+	})
+}
+
+func TestBuilder_PreviewJobInput(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var inserted json.RawMessage
+	mDB := expectDB(t,
+		// Inputs
+		withTemplate,
+		withInactiveVersion(nil),
+		withLastBuildFound,
+		withRichParameters(nil),
+		withParameterSchemas(inactiveJobID, nil),
+
+		// Outputs
+		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {
+			inserted = job.Input
+		}),
+		withInTx,
+		expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+		withBuild,
+		expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {}),
+	)
+
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).LogLevel("debug")
+	preview, err := uut.PreviewJobInput()
+	req.NoError(err)
+	bld, _, err := uut.Build(ctx, mDB, nil)
+	req.NoError(err)
+
+	// The preview matches the inserted input, apart from the build ID that is only assigned by Build.
+	var want provisionerdserver.WorkspaceProvisionJob
+	req.NoError(json.Unmarshal(inserted, &want))
+	asrt.Equal(bld.ID, want.WorkspaceBuildID)
+	asrt.Equal("debug", want.LogLevel)
+	want.WorkspaceBuildID = uuid.Nil
+	wantJSON, err := json.Marshal(want)
+	req.NoError(err)
+	asrt.JSONEq(string(wantJSON), string(preview))
+}
+
+func TestValidateAgainstVersions(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lastBuildParameters := []database.WorkspaceBuildParameter{
+		{Name: "region", Value: "us"},
+	}
+	compatibleParameters := []database.TemplateVersionParameter{
+		{Name: "region", Mutable: true, Options: json.RawMessage("[]")},
+	}
+	incompatibleParameters := []database.TemplateVersionParameter{
+		{Name: "region", Mutable: true, Options: json.RawMessage("[]")},
+		{Name: "size", Mutable: true, Required: true, Options: json.RawMessage("[]")},
+	}
+
+	mDB := dbmock.NewMockStore(gomock.NewController(t))
+	// The last build is only fetched once for all the versions, and no build is inserted.
+	mDB.EXPECT().GetLatestWorkspaceBuildByWorkspaceID(gomock.Any(), workspaceID).
+		Times(1).
+		Return(database.WorkspaceBuild{
+			ID:                lastBuildID,
+			WorkspaceID:       workspaceID,
+			TemplateVersionID: inactiveVersionID,
+			BuildNumber:       1,
+			Transition:        database.WorkspaceTransitionStart,
+		}, nil)
+	withRichParameters(lastBuildParameters)(mDB)
+	withTemplate(mDB)
+	withActiveVersion(compatibleParameters)(mDB)
+	withParameterSchemas(activeJobID, nil)(mDB)
+	withInactiveVersion(incompatibleParameters)(mDB)
+	withParameterSchemas(inactiveJobID, nil)(mDB)
+	// A version of another template is rejected without fetching its job or parameters.
+	foreignVersionID := uuid.MustParse("f0a2e7c4-5b1d-4d8e-9c3a-7e6b5d4c3b21")
+	mDB.EXPECT().GetTemplateVersionByID(gomock.Any(), foreignVersionID).
+		Times(1).
+		Return(database.TemplateVersion{
+			ID:             foreignVersionID,
+			TemplateID:     uuid.NullUUID{UUID: uuid.MustParse("f0a2e7c4-5b1d-4d8e-9c3a-7e6b5d4c3b22"), Valid: true},
+			OrganizationID: orgID,
+			Name:           "foreign",
+		}, nil)
+
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	results, err := wsbuilder.ValidateAgainstVersions(ctx, mDB, ws, []uuid.UUID{activeVersionID, inactiveVersionID, foreignVersionID})
+	req.NoError(err)
+	req.Len(results, 3)
+
+	asrt.Equal(activeVersionID, results[0].TemplateVersionID)
+	asrt.NoError(results[0].Err)
+
+	asrt.Equal(inactiveVersionID, results[1].TemplateVersionID)
+	var buildErr wsbuilder.BuildError
+	req.ErrorAs(results[1].Err, &buildErr)
+	asrt.Equal(http.StatusBadRequest, buildErr.Status)
+	asrt.Contains(buildErr.Message, `"size"`)
+
+	asrt.Equal(foreignVersionID, results[2].TemplateVersionID)
+	req.ErrorAs(results[2].Err, &buildErr)
+	asrt.Equal(http.StatusBadRequest, buildErr.Status)
+	asrt.Equal("template version doesn't match template", buildErr.Message)
+}
+
+func TestBatchPreflight(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		compatibleWorkspaceID   = uuid.MustParse("e6a9a1b4-1ac3-4c3e-8a55-4b1f6d6b6e01")
+		incompatibleWorkspaceID = uuid.MustParse("e6a9a1b4-1ac3-4c3e-8a55-4b1f6d6b6e02")
+		forbiddenWorkspaceID    = uuid.MustParse("e6a9a1b4-1ac3-4c3e-8a55-4b1f6d6b6e03")
+		missingWorkspaceID      = uuid.MustParse("e6a9a1b4-1ac3-4c3e-8a55-4b1f6d6b6e04")
+		compatibleBuildID       = uuid.MustParse("e6a9a1b4-1ac3-4c3e-8a55-4b1f6d6b6e11")
+		incompatibleBuildID     = uuid.MustParse("e6a9a1b4-1ac3-4c3e-8a55-4b1f6d6b6e12")
+	)
+	versionParameters := []database.TemplateVersionParameter{
+		{Name: "region", Mutable: true, Options: json.RawMessage("[]")},
+		{Name: "size", Mutable: true, Required: true, Options: json.RawMessage("[]")},
+	}
+
+	mDB := dbmock.NewMockStore(gomock.NewController(t))
+	// Nothing is inserted, and no transaction is needed.
+	for _, w := range []struct {
+		workspaceID uuid.UUID
+		buildID     uuid.UUID
+		params      []database.WorkspaceBuildParameter
+	}{
+		{
+			workspaceID: compatibleWorkspaceID,
+			buildID:     compatibleBuildID,
+			params:      []database.WorkspaceBuildParameter{{Name: "region", Value: "us"}, {Name: "size", Value: "large"}},
+		},
+		{
+			// The workspace has no value for the newly required "size".
+			workspaceID: incompatibleWorkspaceID,
+			buildID:     incompatibleBuildID,
+			params:      []database.WorkspaceBuildParameter{{Name: "region", Value: "us"}},
+		},
+	} {
+		mDB.EXPECT().GetWorkspaceByID(gomock.Any(), w.workspaceID).
+			Times(1).
+			Return(database.Workspace{ID: w.workspaceID, TemplateID: templateID, OwnerID: userID}, nil)
+		withTemplate(mDB)
+		withActiveVersion(versionParameters)(mDB)
+		withParameterSchemas(activeJobID, nil)(mDB)
+		mDB.EXPECT().GetLatestWorkspaceBuildByWorkspaceID(gomock.Any(), w.workspaceID).
+			Times(1).
+			Return(database.WorkspaceBuild{
+				ID:                w.buildID,
+				WorkspaceID:       w.workspaceID,
+				TemplateVersionID: inactiveVersionID,
+				BuildNumber:       1,
+				Transition:        database.WorkspaceTransitionStart,
+			}, nil)
+		mDB.EXPECT().GetWorkspaceBuildParameters(gomock.Any(), w.buildID).
+			Times(1).
+			Return(w.params, nil)
+	}
+	// The caller may not build this workspace, so nothing else is fetched for it.
+	mDB.EXPECT().GetWorkspaceByID(gomock.Any(), forbiddenWorkspaceID).
+		Times(1).
+		Return(database.Workspace{ID: forbiddenWorkspaceID, TemplateID: templateID, OwnerID: userID}, nil)
+	mDB.EXPECT().GetWorkspaceByID(gomock.Any(), missingWorkspaceID).
+		Times(1).
+		Return(database.Workspace{}, sql.ErrNoRows)
+
+	authFunc := func(_ rbac.Action, object rbac.Objecter) bool {
+		return object.RBACObject().ID != forbiddenWorkspaceID.String()
+	}
+	results, err := wsbuilder.BatchPreflight(ctx, mDB,
+		[]uuid.UUID{compatibleWorkspaceID, incompatibleWorkspaceID, forbiddenWorkspaceID, missingWorkspaceID},
+		activeVersionID, authFunc)
+	req.NoError(err)
+	req.Len(results, 4)
+
+	asrt.Equal(compatibleWorkspaceID, results[0].WorkspaceID)
+	asrt.NoError(results[0].Err)
+
+	asrt.Equal(incompatibleWorkspaceID, results[1].WorkspaceID)
+	var buildErr wsbuilder.BuildError
+	req.ErrorAs(results[1].Err, &buildErr)
+	asrt.Equal(http.StatusBadRequest, buildErr.Status)
+	asrt.Contains(buildErr.Message, `"size"`)
+
+	for _, r := range results[2:] {
+		req.ErrorAs(r.Err, &buildErr)
+		asrt.Equal(http.StatusNotFound, buildErr.Status)
+	}
+	asrt.Equal(forbiddenWorkspaceID, results[2].WorkspaceID)
+	asrt.Equal(missingWorkspaceID, results[3].WorkspaceID)
+}
+
+func TestBuilder_DraftValidate(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	draftParameters := []database.TemplateVersionParameter{
+		{Name: "region", Type: "string", Mutable: true, Options: json.RawMessage("[]"), ValidationRegex: "^[a-z]+-[0-9]$"},
+		{Name: "size", Type: "string", Mutable: true, Required: true, Options: json.RawMessage("[]")},
+		{Name: "zone", Type: "string", Mutable: true, Required: true, Options: json.RawMessage("[]")},
+	}
+	// Only some of the parameters have been filled in so far.
+	draftValues := []codersdk.WorkspaceBuildParameter{
+		{Name: "region", Value: "Europe"},
+		{Name: "zone", Value: "a"},
+	}
+
+	mDB := dbmock.NewMockStore(gomock.NewController(t))
+	// Nothing is inserted, and no transaction is needed.
+	mDB.EXPECT().GetLatestWorkspaceBuildByWorkspaceID(gomock.Any(), workspaceID).
+		Times(1).
+		Return(database.WorkspaceBuild{
+			ID:                lastBuildID,
+			WorkspaceID:       workspaceID,
+			TemplateVersionID: inactiveVersionID,
+			BuildNumber:       1,
+			Transition:        database.WorkspaceTransitionStart,
+		}, nil)
+	withRichParameters(nil)(mDB)
+	withInactiveVersion(draftParameters)(mDB)
+	withParameterSchemas(inactiveJobID, nil)(mDB)
+
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart)
+	result, err := uut.DraftValidate(ctx, mDB, draftValues)
+	req.NoError(err)
+	asrt.False(result.Valid())
+
+	// The malformed value fails, while the missing required parameter is only a warning.
+	req.Len(result.Errors, 1)
+	asrt.Equal("region", result.Errors[0].Name)
+	asrt.Contains(result.Errors[0].Reason, `"^[a-z]+-[0-9]$"`)
+	req.Len(result.Warnings, 1)
+	asrt.Equal("size", result.Warnings[0].Name)
+	asrt.Contains(result.Warnings[0].Reason, "required")
+}
+
+func TestBuilder_Logger(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	richParameters := []database.TemplateVersionParameter{
+		{Name: "from_request", Mutable: true, Options: json.RawMessage("[]")},
+		{Name: "from_last_build", Mutable: true, Options: json.RawMessage("[]")},
+		{Name: "from_default", Mutable: true, DefaultValue: "default", Options: json.RawMessage("[]")},
+	}
+	lastBuildParameters := []database.WorkspaceBuildParameter{
+		{Name: "from_request", Value: "old"},
+		{Name: "from_last_build", Value: "old"},
+	}
+
+	mDB := expectDB(t,
+		// Inputs
+		withTemplate,
+		withInactiveVersion(richParameters),
+		withLastBuildFound,
+		withRichParameters(lastBuildParameters),
+		withParameterSchemas(inactiveJobID, nil),
+
+		// Outputs
+		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+		withInTx,
+		expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+		expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {}),
+		withBuild,
+	)
+
+	sink := &fakeSink{}
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+		Logger(slog.Make(sink).Leveled(slog.LevelDebug)).
+		RichParameterValues([]codersdk.WorkspaceBuildParameter{{Name: "from_request", Value: "new"}})
+	_, _, err := uut.Build(ctx, mDB, nil)
+	req.NoError(err)
+
+	asrt.Equal("last build", sink.field("resolved template version", "", "source"))
+	asrt.Equal("request", sink.field("resolved parameter", "from_request", "source"))
+	asrt.Equal("last build", sink.field("resolved parameter", "from_last_build", "source"))
+	asrt.Equal("default", sink.field("resolved parameter", "from_default", "source"))
+}
+
+func TestBuilder_ActiveVersion(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mDB := expectDB(t,
+		// Inputs
+		withTemplate,
+		withActiveVersion(nil),
+		withLastBuildNotFound,
+		withParameterSchemas(activeJobID, nil),
+		// previous rich parameters are not queried because there is no previous build.
+
+		// Outputs
+		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {
+			asrt.Equal(activeFileID, job.FileID)
+		}),
+
+		withInTx,
+		expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+			asrt.Equal(activeVersionID, bld.TemplateVersionID)
+			// no previous build...
+			asrt.Equal(int32(1), bld.BuildNumber)
+			asrt.Len(bld.ProvisionerState, 0)
+		}),
+		expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+		}),
+		withBuild,
+	)
+
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).ActiveVersion()
+	_, _, err := uut.Build(ctx, mDB, nil)
+	req.NoError(err)
+}
+
+func TestBuilder_LastSuccessfulVersion(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	asrt := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The last build used the inactive version and failed, while the build before it succeeded on the active one.
+	mDB := expectDB(t,
+		// Inputs
+		withTemplate,
+		withLastSuccessfulBuild(activeVersionID),
+		withActiveVersion(nil),
+		withLastBuildFound,
+		withRichParameters(nil),
+		withParameterSchemas(activeJobID, nil),
+
+		// Outputs
+		expectProvisionerJob(func(job database.InsertProvisionerJobParams) {
+			asrt.Equal(activeFileID, job.FileID)
+		}),
+
+		withInTx,
+		expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+			asrt.Equal(activeVersionID, bld.TemplateVersionID)
+			asrt.Equal(int32(2), bld.BuildNumber)
+		}),
+		expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+		}),
+		withBuild,
+	)
+
+	ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+	uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).LastSuccessfulVersion()
+	_, _, err := uut.Build(ctx, mDB, nil)
+	req.NoError(err)
+}
+
+func TestWorkspaceBuildWithRichParameters(t *testing.T) {
+	t.Parallel()
+
+	const (
+		firstParameterName        = "first_parameter"
+		firstParameterDescription = "This is first parameter"
+		firstParameterValue       = "1"
+
+		secondParameterName        = "second_parameter"
+		secondParameterDescription = "This is second parameter"
+		secondParameterValue       = "2"
+
+		immutableParameterName        = "immutable_parameter"
+		immutableParameterDescription = "This is immutable parameter"
+		immutableParameterValue       = "3"
+	)
+
+	initialBuildParameters := []database.WorkspaceBuildParameter{
+		{Name: firstParameterName, Value: firstParameterValue},
+		{Name: secondParameterName, Value: secondParameterValue},
+		{Name: immutableParameterName, Value: immutableParameterValue},
+	}
+
+	richParameters := []database.TemplateVersionParameter{
+		{Name: firstParameterName, Description: firstParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
+		{Name: secondParameterName, Description: secondParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
+		{Name: immutableParameterName, Description: immutableParameterDescription, Mutable: false, Options: json.RawMessage("[]")},
+	}
+
+	t.Run("UpdateParameterValues", func(t *testing.T) {
+		t.Parallel()
+
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		const updatedParameterValue = "3"
+		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
+			{Name: firstParameterName, Value: firstParameterValue},
+			{Name: secondParameterName, Value: updatedParameterValue},
+		}
+		expectedParams := map[string]string{
+			firstParameterName:     firstParameterValue,
+			secondParameterName:    updatedParameterValue,
+			immutableParameterName: immutableParameterValue,
+		}
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(initialBuildParameters),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+				asrt.Len(params.Name, len(expectedParams))
+				for i := range params.Name {
+					value, ok := expectedParams[params.Name[i]]
+					asrt.True(ok, "unexpected name %s", params.Name[i])
+					asrt.Equal(value, params.Value[i])
+				}
+			}),
+			withBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RichParameterValues(nextBuildParameters)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+
+		// The stored parameters are available without querying for them.
+		names, values := uut.Parameters()
+		req.Len(names, len(expectedParams))
+		req.Len(values, len(names))
+		for i := range names {
+			asrt.Equal(expectedParams[names[i]], values[i])
+		}
+	})
+	t.Run("ReplaceParameterValues", func(t *testing.T) {
+		t.Parallel()
+
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		const updatedParameterValue = "4"
+		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
+			{Name: firstParameterName, Value: updatedParameterValue},
+		}
+		// The second parameter is reset to its (empty) default, but the immutable
+		// parameter keeps its value.
+		expectedParams := map[string]string{
+			firstParameterName:     updatedParameterValue,
+			secondParameterName:    "",
+			immutableParameterName: immutableParameterValue,
+		}
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(initialBuildParameters),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+				asrt.Len(params.Name, len(expectedParams))
+				for i := range params.Name {
+					value, ok := expectedParams[params.Name[i]]
+					asrt.True(ok, "unexpected name %s", params.Name[i])
+					asrt.Equal(value, params.Value[i])
+				}
+			}),
+			withBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues(nextBuildParameters).
+			ParameterMergeMode(wsbuilder.ParameterMergeModeReplace)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+
+	t.Run("UsePreviousParameterValues", func(t *testing.T) {
+		t.Parallel()
+
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		nextBuildParameters := []codersdk.WorkspaceBuildParameter{}
+		expectedParams := map[string]string{}
+		for _, p := range initialBuildParameters {
+			expectedParams[p.Name] = p.Value
+		}
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(initialBuildParameters),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+				asrt.Len(params.Name, len(expectedParams))
+				for i := range params.Name {
+					value, ok := expectedParams[params.Name[i]]
+					asrt.True(ok, "unexpected name %s", params.Name[i])
+					asrt.Equal(value, params.Value[i])
+				}
+			}),
+			withBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RichParameterValues(nextBuildParameters)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+
+	t.Run("StartWorkspaceWithLegacyParameterValues", func(t *testing.T) {
+		t.Parallel()
+
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		schemas := []database.ParameterSchema{
+			{
+				Name:                     "not-replaced",
+				DefaultDestinationScheme: database.ParameterDestinationSchemeEnvironmentVariable,
+			},
+			{
+				Name:                     "replaced",
+				DefaultDestinationScheme: database.ParameterDestinationSchemeEnvironmentVariable,
+			},
+		}
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, schemas),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+		)
+
+		var legacyNames []string
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			OnLegacyParametersUsed(func(names []string) {
+				legacyNames = names
+			})
+		_, _, err := uut.Build(ctx, mDB, nil)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+		asrt.Equal([]string{"not-replaced", "replaced"}, legacyNames)
+	})
+
+	t.Run("DoNotModifyImmutables", func(t *testing.T) {
+		t.Parallel()
+
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
+			{Name: immutableParameterName, Value: "BAD"},
+		}
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(initialBuildParameters),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			// no build parameters, since we hit an error validating.
+			// expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {}),
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RichParameterValues(nextBuildParameters)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+	})
+
+	t.Run("TemplateAdminOverridesImmutables", func(t *testing.T) {
+		t.Parallel()
+
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
+			{Name: immutableParameterName, Value: "4"},
+		}
+		expectedParams := map[string]string{
+			firstParameterName:     firstParameterValue,
+			secondParameterName:    secondParameterValue,
+			immutableParameterName: "4",
+		}
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(initialBuildParameters),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+				asrt.Len(params.Name, len(expectedParams))
+				for i := range params.Name {
+					value, ok := expectedParams[params.Name[i]]
+					asrt.True(ok, "unexpected name %s", params.Name[i])
+					asrt.Equal(value, params.Value[i])
+				}
+			}),
+			withBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues(nextBuildParameters).
+			AllowImmutableParameterChanges()
+		authFunc := func(rbac.Action, rbac.Objecter) bool { return true }
+		_, _, err := uut.Build(ctx, mDB, authFunc)
+		req.NoError(err)
+	})
+
+	t.Run("NonAdminCannotOverrideImmutables", func(t *testing.T) {
+		t.Parallel()
+
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
+			{Name: immutableParameterName, Value: "4"},
+		}
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(initialBuildParameters),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			// no build parameters, since we hit an error validating.
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues(nextBuildParameters).
+			AllowImmutableParameterChanges()
+		// The caller may update the workspace, but not the template.
+		authFunc := func(_ rbac.Action, object rbac.Objecter) bool {
+			return object.RBACObject().Type != rbac.ResourceTemplate.Type
+		}
+		_, _, err := uut.Build(ctx, mDB, authFunc)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+		asrt.Contains(bldErr.Message, "not mutable")
+	})
+
+	t.Run("StrictParameterNames", func(t *testing.T) {
+		t.Parallel()
+
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
+			{Name: firstParameterName, Value: firstParameterValue},
+			{Name: "frist_parameter", Value: "typo"},
+		}
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withInactiveVersion(richParameters),
+			withLastBuildFound,
+			withRichParameters(initialBuildParameters),
+			withParameterSchemas(inactiveJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			// no build parameters, since we hit an error validating.
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues(nextBuildParameters).
+			StrictParameterNames()
+		_, _, err := uut.Build(ctx, mDB, nil)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+		asrt.Contains(bldErr.Message, `"frist_parameter"`)
+		asrt.NotContains(bldErr.Message, firstParameterName)
+	})
+
+	t.Run("NewImmutableRequiredParameterAdded", func(t *testing.T) {
+		t.Parallel()
+
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// new template revision
+		const newImmutableParameterName = "new_immutable_parameter"
+		const newImmutableParameterDescription = "This is also an immutable parameter"
+		version2params := []database.TemplateVersionParameter{
+			{Name: firstParameterName, Description: firstParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
+			{Name: secondParameterName, Description: secondParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
+			{Name: immutableParameterName, Description: immutableParameterDescription, Mutable: false, Options: json.RawMessage("[]")},
+			{Name: newImmutableParameterName, Description: newImmutableParameterDescription, Mutable: false, Required: true, Options: json.RawMessage("[]")},
+		}
+
+		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
+			{Name: newImmutableParameterName, Value: "good"},
+		}
+		expectedParams := map[string]string{
+			firstParameterName:        firstParameterValue,
+			secondParameterName:       secondParameterValue,
+			immutableParameterName:    immutableParameterValue,
+			newImmutableParameterName: "good",
+		}
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withActiveVersion(version2params),
+			withLastBuildFound,
+			withLastBuildVersionParameters(richParameters),
+			withRichParameters(initialBuildParameters),
+			withParameterSchemas(activeJobID, nil),
+
+			// Outputs
+			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
+			withInTx,
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+				asrt.Len(params.Name, len(expectedParams))
+				for i := range params.Name {
+					value, ok := expectedParams[params.Name[i]]
+					asrt.True(ok, "unexpected name %s", params.Name[i])
+					asrt.Equal(value, params.Value[i])
+				}
+			}),
+			withBuild,
+		)
+
+		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues(nextBuildParameters).
+			VersionID(activeVersionID)
+		_, _, err := uut.Build(ctx, mDB, nil)
+		req.NoError(err)
+	})
+
+	t.Run("NewImmutableOptionalParameterAdded", func(t *testing.T) {
+		t.Parallel()
+
+		req := require.New(t)
+		asrt := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// new template revision
+		const newImmutableParameterName = "new_immutable_parameter"
+		const newImmutableParameterDescription = "This is also an immutable parameter"
+		version2params := []database.TemplateVersionParameter{
+			{Name: firstParameterName, Description: firstParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
+			{Name: secondParameterName, Description: secondParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
+			{Name: immutableParameterName, Description: immutableParameterDescription, Mutable: false, Options: json.RawMessage("[]")},
+			{Name: newImmutableParameterName, Description: newImmutableParameterDescription, Mutable: false, DefaultValue: "12345", Options: json.RawMessage("[]")},
+		}
+
+		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
+			{Name: newImmutableParameterName, Value: "good"},
+		}
+		expectedParams := map[string]string{
+			firstParameterName:        firstParameterValue,
+			secondParameterName:       secondParameterValue,
+			immutableParameterName:    immutableParameterValue,
+			newImmutableParameterName: "good",
+		}
+
+		mDB := expectDB(t,
+			// Inputs
+			withTemplate,
+			withActiveVersion(version2params),
 			withLastBuildFound,
+			withLastBuildVersionParameters(richParameters),
 			withRichParameters(initialBuildParameters),
-			withParameterSchemas(inactiveJobID, nil),
+			withParameterSchemas(activeJobID, nil),
 
 			// Outputs
 			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
@@ -272,11 +2597,14 @@ func TestWorkspaceBuildWithRichParameters(t *testing.T) {
 		)
 
 		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
-		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RichParameterValues(nextBuildParameters)
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues(nextBuildParameters).
+			VersionID(activeVersionID)
 		_, _, err := uut.Build(ctx, mDB, nil)
 		req.NoError(err)
 	})
-	t.Run("UsePreviousParameterValues", func(t *testing.T) {
+
+	t.Run("NewImmutableOptionalParameterUsesDefault", func(t *testing.T) {
 		t.Parallel()
 
 		req := require.New(t)
@@ -285,19 +2613,32 @@ func TestWorkspaceBuildWithRichParameters(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
+		// new template revision
+		const newImmutableParameterName = "new_immutable_parameter"
+		const newImmutableParameterDescription = "This is also an immutable parameter"
+		version2params := []database.TemplateVersionParameter{
+			{Name: firstParameterName, Description: firstParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
+			{Name: secondParameterName, Description: secondParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
+			{Name: immutableParameterName, Description: immutableParameterDescription, Mutable: false, Options: json.RawMessage("[]")},
+			{Name: newImmutableParameterName, Description: newImmutableParameterDescription, Mutable: false, DefaultValue: "12345", Options: json.RawMessage("[]")},
+		}
+
 		nextBuildParameters := []codersdk.WorkspaceBuildParameter{}
-		expectedParams := map[string]string{}
-		for _, p := range initialBuildParameters {
-			expectedParams[p.Name] = p.Value
+		expectedParams := map[string]string{
+			firstParameterName:        firstParameterValue,
+			secondParameterName:       secondParameterValue,
+			immutableParameterName:    immutableParameterValue,
+			newImmutableParameterName: "12345",
 		}
 
 		mDB := expectDB(t,
 			// Inputs
 			withTemplate,
-			withInactiveVersion(richParameters),
+			withActiveVersion(version2params),
 			withLastBuildFound,
+			withLastBuildVersionParameters(richParameters),
 			withRichParameters(initialBuildParameters),
-			withParameterSchemas(inactiveJobID, nil),
+			withParameterSchemas(activeJobID, nil),
 
 			// Outputs
 			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
@@ -315,12 +2656,14 @@ func TestWorkspaceBuildWithRichParameters(t *testing.T) {
 		)
 
 		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
-		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RichParameterValues(nextBuildParameters)
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
+			RichParameterValues(nextBuildParameters).
+			VersionID(activeVersionID)
 		_, _, err := uut.Build(ctx, mDB, nil)
 		req.NoError(err)
 	})
 
-	t.Run("StartWorkspaceWithLegacyParameterValues", func(t *testing.T) {
+	t.Run("NewVersionCarriesForwardParameterValues", func(t *testing.T) {
 		t.Parallel()
 
 		req := require.New(t)
@@ -329,40 +2672,55 @@ func TestWorkspaceBuildWithRichParameters(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		schemas := []database.ParameterSchema{
-			{
-				Name:                     "not-replaced",
-				DefaultDestinationScheme: database.ParameterDestinationSchemeEnvironmentVariable,
-			},
-			{
-				Name:                     "replaced",
-				DefaultDestinationScheme: database.ParameterDestinationSchemeEnvironmentVariable,
-			},
+		// new template revision removes the second parameter and adds a new one
+		const newParameterName = "new_parameter"
+		const newParameterDescription = "This is a new parameter"
+		version2params := []database.TemplateVersionParameter{
+			{Name: firstParameterName, Description: firstParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
+			{Name: immutableParameterName, Description: immutableParameterDescription, Mutable: false, Options: json.RawMessage("[]")},
+			{Name: newParameterName, Description: newParameterDescription, Mutable: true, DefaultValue: "abc", Options: json.RawMessage("[]")},
+		}
+
+		expectedParams := map[string]string{
+			firstParameterName:     firstParameterValue,
+			immutableParameterName: immutableParameterValue,
+			newParameterName:       "abc",
 		}
 
 		mDB := expectDB(t,
 			// Inputs
 			withTemplate,
-			withInactiveVersion(richParameters),
+			withActiveVersion(version2params),
 			withLastBuildFound,
-			withRichParameters(nil),
-			withParameterSchemas(inactiveJobID, schemas),
+			withLastBuildVersionParameters(richParameters),
+			withRichParameters(initialBuildParameters),
+			withParameterSchemas(activeJobID, nil),
 
 			// Outputs
 			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
 			withInTx,
-			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
+			expectBuild(func(bld database.InsertWorkspaceBuildParams) {
+				asrt.Equal(activeVersionID, bld.TemplateVersionID)
+			}),
+			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
+				asrt.Len(params.Name, len(expectedParams))
+				asrt.NotContains(params.Name, secondParameterName)
+				for i := range params.Name {
+					value, ok := expectedParams[params.Name[i]]
+					asrt.True(ok, "unexpected name %s", params.Name[i])
+					asrt.Equal(value, params.Value[i])
+				}
+			}),
+			withBuild,
 		)
 
 		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
-		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart)
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).VersionID(activeVersionID)
 		_, _, err := uut.Build(ctx, mDB, nil)
-		bldErr := wsbuilder.BuildError{}
-		req.ErrorAs(err, &bldErr)
-		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+		req.NoError(err)
 	})
 
-	t.Run("DoNotModifyImmutables", func(t *testing.T) {
+	t.Run("ParameterBecameImmutable", func(t *testing.T) {
 		t.Parallel()
 
 		req := require.New(t)
@@ -371,35 +2729,47 @@ func TestWorkspaceBuildWithRichParameters(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
-			{Name: immutableParameterName, Value: "BAD"},
+		// new template revision locks the second parameter, with a default other than the workspace's value
+		version2params := []database.TemplateVersionParameter{
+			{Name: firstParameterName, Description: firstParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
+			{Name: secondParameterName, Description: secondParameterDescription, Mutable: false, DefaultValue: "5", Options: json.RawMessage("[]")},
+			{Name: immutableParameterName, Description: immutableParameterDescription, Mutable: false, Options: json.RawMessage("[]")},
 		}
 
 		mDB := expectDB(t,
 			// Inputs
 			withTemplate,
-			withInactiveVersion(richParameters),
+			withActiveVersion(version2params),
 			withLastBuildFound,
+			withLastBuildVersionParameters(richParameters),
 			withRichParameters(initialBuildParameters),
-			withParameterSchemas(inactiveJobID, nil),
+			withParameterSchemas(activeJobID, nil),
 
 			// Outputs
 			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
 			withInTx,
 			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
 			// no build parameters, since we hit an error validating.
-			// expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {}),
 		)
 
 		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
-		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RichParameterValues(nextBuildParameters)
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).VersionID(activeVersionID)
 		_, _, err := uut.Build(ctx, mDB, nil)
 		bldErr := wsbuilder.BuildError{}
 		req.ErrorAs(err, &bldErr)
 		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+		// The immutable parameter that stayed immutable is not a conflict.
+		asrt.NotContains(bldErr.Message, immutableParameterName)
+		asrt.Contains(bldErr.Message, fmt.Sprintf("Parameter %q is immutable in the new template version", secondParameterName))
+		asrt.Contains(bldErr.Message, fmt.Sprintf("value %q differs from the new default %q", secondParameterValue, "5"))
+		asrt.Contains(bldErr.Message, "template administrator")
+		var paramErrs wsbuilder.ParameterValidationErrors
+		req.ErrorAs(err, &paramErrs)
+		req.Len(paramErrs, 1)
+		asrt.Equal(secondParameterName, paramErrs[0].Name)
 	})
 
-	t.Run("NewImmutableRequiredParameterAdded", func(t *testing.T) {
+	t.Run("ParameterBecameMutable", func(t *testing.T) {
 		t.Parallel()
 
 		req := require.New(t)
@@ -408,24 +2778,17 @@ func TestWorkspaceBuildWithRichParameters(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		// new template revision
-		const newImmutableParameterName = "new_immutable_parameter"
-		const newImmutableParameterDescription = "This is also an immutable parameter"
+		// new template revision unlocks the immutable parameter, so its value is carried forward as is
 		version2params := []database.TemplateVersionParameter{
 			{Name: firstParameterName, Description: firstParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
 			{Name: secondParameterName, Description: secondParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
-			{Name: immutableParameterName, Description: immutableParameterDescription, Mutable: false, Options: json.RawMessage("[]")},
-			{Name: newImmutableParameterName, Description: newImmutableParameterDescription, Mutable: false, Required: true, Options: json.RawMessage("[]")},
+			{Name: immutableParameterName, Description: immutableParameterDescription, Mutable: true, DefaultValue: "5", Options: json.RawMessage("[]")},
 		}
 
-		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
-			{Name: newImmutableParameterName, Value: "good"},
-		}
 		expectedParams := map[string]string{
-			firstParameterName:        firstParameterValue,
-			secondParameterName:       secondParameterValue,
-			immutableParameterName:    immutableParameterValue,
-			newImmutableParameterName: "good",
+			firstParameterName:     firstParameterValue,
+			secondParameterName:    secondParameterValue,
+			immutableParameterName: immutableParameterValue,
 		}
 
 		mDB := expectDB(t,
@@ -452,14 +2815,12 @@ func TestWorkspaceBuildWithRichParameters(t *testing.T) {
 		)
 
 		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
-		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
-			RichParameterValues(nextBuildParameters).
-			VersionID(activeVersionID)
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).VersionID(activeVersionID)
 		_, _, err := uut.Build(ctx, mDB, nil)
 		req.NoError(err)
 	})
 
-	t.Run("NewImmutableOptionalParameterAdded", func(t *testing.T) {
+	t.Run("RegexMismatch", func(t *testing.T) {
 		t.Parallel()
 
 		req := require.New(t)
@@ -468,58 +2829,49 @@ func TestWorkspaceBuildWithRichParameters(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		// new template revision
-		const newImmutableParameterName = "new_immutable_parameter"
-		const newImmutableParameterDescription = "This is also an immutable parameter"
-		version2params := []database.TemplateVersionParameter{
-			{Name: firstParameterName, Description: firstParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
-			{Name: secondParameterName, Description: secondParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
-			{Name: immutableParameterName, Description: immutableParameterDescription, Mutable: false, Options: json.RawMessage("[]")},
-			{Name: newImmutableParameterName, Description: newImmutableParameterDescription, Mutable: false, DefaultValue: "12345", Options: json.RawMessage("[]")},
+		regexParameters := []database.TemplateVersionParameter{
+			{
+				Name:            "region",
+				DisplayName:     "Region",
+				Type:            "string",
+				Mutable:         true,
+				Options:         json.RawMessage("[]"),
+				ValidationRegex: "^[a-z]+-[0-9]$",
+				ValidationError: "Region must look like eu-1.",
+			},
 		}
-
 		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
-			{Name: newImmutableParameterName, Value: "good"},
-		}
-		expectedParams := map[string]string{
-			firstParameterName:        firstParameterValue,
-			secondParameterName:       secondParameterValue,
-			immutableParameterName:    immutableParameterValue,
-			newImmutableParameterName: "good",
+			{Name: "region", Value: "Europe"},
 		}
 
 		mDB := expectDB(t,
 			// Inputs
 			withTemplate,
-			withActiveVersion(version2params),
+			withInactiveVersion(regexParameters),
 			withLastBuildFound,
-			withRichParameters(initialBuildParameters),
-			withParameterSchemas(activeJobID, nil),
+			withRichParameters(nil),
+			withParameterSchemas(inactiveJobID, nil),
 
 			// Outputs
 			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
 			withInTx,
 			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
-			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
-				asrt.Len(params.Name, len(expectedParams))
-				for i := range params.Name {
-					value, ok := expectedParams[params.Name[i]]
-					asrt.True(ok, "unexpected name %s", params.Name[i])
-					asrt.Equal(value, params.Value[i])
-				}
-			}),
-			withBuild,
+			// no build parameters, since we hit an error validating.
 		)
 
 		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
-		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
-			RichParameterValues(nextBuildParameters).
-			VersionID(activeVersionID)
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RichParameterValues(nextBuildParameters)
 		_, _, err := uut.Build(ctx, mDB, nil)
-		req.NoError(err)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+		asrt.Contains(bldErr.Message, `"region"`)
+		asrt.Contains(bldErr.Message, `"Europe"`)
+		asrt.Contains(bldErr.Message, `"^[a-z]+-[0-9]$"`)
+		asrt.Contains(bldErr.Message, "Region must look like eu-1.")
 	})
 
-	t.Run("NewImmutableOptionalParameterUsesDefault", func(t *testing.T) {
+	t.Run("MultipleInvalid", func(t *testing.T) {
 		t.Parallel()
 
 		req := require.New(t)
@@ -528,53 +2880,58 @@ func TestWorkspaceBuildWithRichParameters(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		// new template revision
-		const newImmutableParameterName = "new_immutable_parameter"
-		const newImmutableParameterDescription = "This is also an immutable parameter"
-		version2params := []database.TemplateVersionParameter{
-			{Name: firstParameterName, Description: firstParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
-			{Name: secondParameterName, Description: secondParameterDescription, Mutable: true, Options: json.RawMessage("[]")},
-			{Name: immutableParameterName, Description: immutableParameterDescription, Mutable: false, Options: json.RawMessage("[]")},
-			{Name: newImmutableParameterName, Description: newImmutableParameterDescription, Mutable: false, DefaultValue: "12345", Options: json.RawMessage("[]")},
-		}
-
-		nextBuildParameters := []codersdk.WorkspaceBuildParameter{}
-		expectedParams := map[string]string{
-			firstParameterName:        firstParameterValue,
-			secondParameterName:       secondParameterValue,
-			immutableParameterName:    immutableParameterValue,
-			newImmutableParameterName: "12345",
+		invalidParameters := append([]database.TemplateVersionParameter{
+			{
+				Name:            "region",
+				Type:            "string",
+				Mutable:         true,
+				Options:         json.RawMessage("[]"),
+				ValidationRegex: "^[a-z]+-[0-9]$",
+			},
+		}, richParameters...)
+		nextBuildParameters := []codersdk.WorkspaceBuildParameter{
+			{Name: "region", Value: "Europe"},
+			{Name: immutableParameterName, Value: "BAD"},
 		}
 
 		mDB := expectDB(t,
 			// Inputs
 			withTemplate,
-			withActiveVersion(version2params),
+			withInactiveVersion(invalidParameters),
 			withLastBuildFound,
 			withRichParameters(initialBuildParameters),
-			withParameterSchemas(activeJobID, nil),
+			withParameterSchemas(inactiveJobID, nil),
 
 			// Outputs
 			expectProvisionerJob(func(job database.InsertProvisionerJobParams) {}),
 			withInTx,
 			expectBuild(func(bld database.InsertWorkspaceBuildParams) {}),
-			expectBuildParameters(func(params database.InsertWorkspaceBuildParametersParams) {
-				asrt.Len(params.Name, len(expectedParams))
-				for i := range params.Name {
-					value, ok := expectedParams[params.Name[i]]
-					asrt.True(ok, "unexpected name %s", params.Name[i])
-					asrt.Equal(value, params.Value[i])
-				}
-			}),
-			withBuild,
+			// no build parameters, since we hit an error validating.
 		)
 
 		ws := database.Workspace{ID: workspaceID, TemplateID: templateID, OwnerID: userID}
-		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).
-			RichParameterValues(nextBuildParameters).
-			VersionID(activeVersionID)
+		uut := wsbuilder.New(ws, database.WorkspaceTransitionStart).RichParameterValues(nextBuildParameters)
 		_, _, err := uut.Build(ctx, mDB, nil)
-		req.NoError(err)
+		bldErr := wsbuilder.BuildError{}
+		req.ErrorAs(err, &bldErr)
+		asrt.Equal(http.StatusBadRequest, bldErr.Status)
+
+		// Both invalid parameters are reported, in the template's order.
+		var paramErrs wsbuilder.ParameterValidationErrors
+		req.ErrorAs(err, &paramErrs)
+		req.Len(paramErrs, 2)
+		asrt.Equal("region", paramErrs[0].Name)
+		asrt.Equal("^[a-z]+-[0-9]$", paramErrs[0].Parameter.ValidationRegex)
+		asrt.Equal(immutableParameterName, paramErrs[1].Name)
+		asrt.Contains(paramErrs[1].Reason, "not mutable")
+
+		validations := bldErr.Validations()
+		req.Len(validations, 2)
+		asrt.Equal("region", validations[0].Field)
+		asrt.Equal(paramErrs[0].Reason, validations[0].Detail)
+		asrt.Equal(immutableParameterName, validations[1].Field)
+		asrt.Contains(bldErr.Message, `"Europe"`)
+		asrt.Contains(bldErr.Message, "not mutable")
 	})
 }
 
@@ -667,32 +3024,28 @@ func withInactiveVersion(params []database.TemplateVersionParameter) func(mTx *d
 	return func(mTx *dbmock.MockStore) {
 		mTx.EXPECT().GetTemplateVersionByID(gomock.Any(), inactiveVersionID).
 			Times(1).
-			Return(database.TemplateVersion{
-				ID:             inactiveVersionID,
-				TemplateID:     uuid.NullUUID{UUID: templateID, Valid: true},
-				OrganizationID: orgID,
-				Name:           "inactive",
-				JobID:          inactiveJobID,
-			}, nil)
+			Return(inactiveVersion, nil)
+		withInactiveVersionJob(params)(mTx)
+	}
+}
 
-		mTx.EXPECT().GetProvisionerJobByID(gomock.Any(), inactiveJobID).
-			Times(1).Return(database.ProvisionerJob{
-			ID:             inactiveJobID,
-			OrganizationID: orgID,
-			InitiatorID:    userID,
-			Provisioner:    database.ProvisionerTypeTerraform,
-			StorageMethod:  database.ProvisionerStorageMethodFile,
-			Type:           database.ProvisionerJobTypeTemplateVersionImport,
-			Input:          nil,
-			Tags: database.StringMap{
-				"version":                   "inactive",
-				provisionerdserver.TagScope: provisionerdserver.ScopeUser,
-			},
-			FileID:      inactiveFileID,
-			StartedAt:   sql.NullTime{Time: database.Now(), Valid: true},
-			UpdatedAt:   time.Now(),
-			CompletedAt: sql.NullTime{Time: database.Now(), Valid: true},
-		}, nil)
+// withInactiveVersionByName is like withInactiveVersion, but the version is looked up by its name.
+func withInactiveVersionByName(params []database.TemplateVersionParameter) func(mTx *dbmock.MockStore) {
+	return func(mTx *dbmock.MockStore) {
+		mTx.EXPECT().GetTemplateVersionByTemplateIDAndName(gomock.Any(), database.GetTemplateVersionByTemplateIDAndNameParams{
+			TemplateID: uuid.NullUUID{UUID: templateID, Valid: true},
+			Name:       inactiveVersion.Name,
+		}).
+			Times(1).
+			Return(inactiveVersion, nil)
+		withInactiveVersionJob(params)(mTx)
+	}
+}
+
+// withInactiveVersionJob expects the job and parameters of the inactive version to be fetched.
+func withInactiveVersionJob(params []database.TemplateVersionParameter) func(mTx *dbmock.MockStore) {
+	return func(mTx *dbmock.MockStore) {
+		withInactiveVersionJobOnly(mTx)
 		paramsCall := mTx.EXPECT().GetTemplateVersionParameters(gomock.Any(), inactiveVersionID).
 			Times(1)
 		if len(params) > 0 {
@@ -703,6 +3056,47 @@ func withInactiveVersion(params []database.TemplateVersionParameter) func(mTx *d
 	}
 }
 
+// withInactiveVersionJobOnly expects the job of the inactive version to be fetched, but not its parameters, e.g.
+// because the build is rejected before they are resolved.
+func withInactiveVersionJobOnly(mTx *dbmock.MockStore) {
+	mTx.EXPECT().GetProvisionerJobByID(gomock.Any(), inactiveJobID).
+		Times(1).Return(database.ProvisionerJob{
+		ID:             inactiveJobID,
+		OrganizationID: orgID,
+		InitiatorID:    userID,
+		Provisioner:    database.ProvisionerTypeTerraform,
+		StorageMethod:  database.ProvisionerStorageMethodFile,
+		Type:           database.ProvisionerJobTypeTemplateVersionImport,
+		Input:          nil,
+		Tags: database.StringMap{
+			"version":                   "inactive",
+			provisionerdserver.TagScope: provisionerdserver.ScopeUser,
+		},
+		FileID:      inactiveFileID,
+		StartedAt:   sql.NullTime{Time: database.Now(), Valid: true},
+		UpdatedAt:   time.Now(),
+		CompletedAt: sql.NullTime{Time: database.Now(), Valid: true},
+	}, nil)
+}
+
+// withLastBuildVersionParameters expects the parameters of the last build's version to be fetched, when the build
+// moves to another version.
+func withLastBuildVersionParameters(params []database.TemplateVersionParameter) func(mTx *dbmock.MockStore) {
+	return func(mTx *dbmock.MockStore) {
+		mTx.EXPECT().GetTemplateVersionParameters(gomock.Any(), inactiveVersionID).
+			Times(1).
+			Return(params, nil)
+	}
+}
+
+var inactiveVersion = database.TemplateVersion{
+	ID:             inactiveVersionID,
+	TemplateID:     uuid.NullUUID{UUID: templateID, Valid: true},
+	OrganizationID: orgID,
+	Name:           "inactive",
+	JobID:          inactiveJobID,
+}
+
 func withLastBuildFound(mTx *dbmock.MockStore) {
 	mTx.EXPECT().GetLatestWorkspaceBuildByWorkspaceID(gomock.Any(), workspaceID).
 		Times(1).
@@ -734,12 +3128,35 @@ func withLastBuildFound(mTx *dbmock.MockStore) {
 		}, nil)
 }
 
+func withProvisionerDaemons(daemons []database.ProvisionerDaemon) func(mTx *dbmock.MockStore) {
+	return func(mTx *dbmock.MockStore) {
+		mTx.EXPECT().GetProvisionerDaemons(gomock.Any()).
+			Times(1).
+			Return(daemons, nil)
+	}
+}
+
 func withLastBuildNotFound(mTx *dbmock.MockStore) {
 	mTx.EXPECT().GetLatestWorkspaceBuildByWorkspaceID(gomock.Any(), workspaceID).
 		Times(1).
 		Return(database.WorkspaceBuild{}, sql.ErrNoRows)
 }
 
+func withLastSuccessfulBuild(versionID uuid.UUID) func(mTx *dbmock.MockStore) {
+	return func(mTx *dbmock.MockStore) {
+		mTx.EXPECT().GetLatestSuccessfulWorkspaceBuildByWorkspaceID(gomock.Any(), workspaceID).
+			Times(1).
+			Return(database.WorkspaceBuild{
+				ID:                priorBuildID,
+				WorkspaceID:       workspaceID,
+				TemplateVersionID: versionID,
+				Transition:        database.WorkspaceTransitionStart,
+				InitiatorID:       userID,
+				Reason:            database.BuildReasonInitiator,
+			}, nil)
+	}
+}
+
 func withParameterSchemas(jobID uuid.UUID, schemas []database.ParameterSchema) func(mTx *dbmock.MockStore) {
 	return func(mTx *dbmock.MockStore) {
 		c := mTx.EXPECT().GetParameterSchemasByJobID(
@@ -796,6 +3213,23 @@ func withBuild(mTx *dbmock.MockStore) {
 		})
 }
 
+// withRollbackTarget returns the build being rolled back to, belonging to the given workspace.  It must be listed
+// before withBuild so that the more specific expectation is matched first.
+func withRollbackTarget(buildID, workspaceID uuid.UUID) func(mTx *dbmock.MockStore) {
+	return func(mTx *dbmock.MockStore) {
+		mTx.EXPECT().GetWorkspaceBuildByID(gomock.Any(), buildID).
+			Times(1).
+			Return(database.WorkspaceBuild{
+				ID:                buildID,
+				WorkspaceID:       workspaceID,
+				TemplateVersionID: inactiveVersionID,
+				BuildNumber:       1,
+				Transition:        database.WorkspaceTransitionStart,
+				InitiatorID:       userID,
+			}, nil)
+	}
+}
+
 // expectBuild captures a call to InsertWorkspaceBuild and runs the provided assertions
 // against it.
 func expectBuild(
@@ -829,3 +3263,33 @@ func expectBuildParameters(
 			)
 	}
 }
+
+// fakeSink captures log entries so that tests can assert on the builder's diagnostics.
+type fakeSink struct {
+	entries []slog.SinkEntry
+}
+
+func (s *fakeSink) LogEntry(_ context.Context, e slog.SinkEntry) {
+	s.entries = append(s.entries, e)
+}
+
+func (*fakeSink) Sync() {}
+
+// field returns the value of the named field on the first entry with the given message.  If name is non-empty,
+// only entries whose "name" field matches are considered.
+func (s *fakeSink) field(msg, name, field string) interface{} {
+	for _, e := range s.entries {
+		if e.Message != msg {
+			continue
+		}
+		fields := map[string]interface{}{}
+		for _, f := range e.Fields {
+			fields[f.Name] = f.Value
+		}
+		if name != "" && fields["name"] != name {
+			continue
+		}
+		return fields[field]
+	}
+	return nil
+}
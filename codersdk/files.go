@@ -12,6 +12,10 @@ import (
 
 const (
 	ContentTypeTar = "application/x-tar"
+
+	// FileHashHeader is set on file downloads to the hex-encoded SHA-256
+	// of the file contents.
+	FileHashHeader = "X-Coder-File-Sha256"
 )
 
 // UploadResponse contains the hash to reference the uploaded file.
@@ -38,17 +42,25 @@ func (c *Client) Upload(ctx context.Context, contentType string, rd io.Reader) (
 
 // Download fetches a file by uploaded hash.
 func (c *Client) Download(ctx context.Context, id uuid.UUID) ([]byte, string, error) {
+	data, contentType, _, err := c.DownloadWithHash(ctx, id)
+	return data, contentType, err
+}
+
+// DownloadWithHash is like Download, but also returns the hex-encoded SHA-256
+// the server reported for the file. The hash is empty if the server did not
+// report one.
+func (c *Client) DownloadWithHash(ctx context.Context, id uuid.UUID) ([]byte, string, string, error) {
 	res, err := c.Request(ctx, http.MethodGet, fmt.Sprintf("/api/v2/files/%s", id.String()), nil)
 	if err != nil {
-		return nil, "", err
+		return nil, "", "", err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return nil, "", ReadBodyAsError(res)
+		return nil, "", "", ReadBodyAsError(res)
 	}
 	data, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, "", err
+		return nil, "", "", err
 	}
-	return data, res.Header.Get("Content-Type"), nil
+	return data, res.Header.Get("Content-Type"), res.Header.Get(FileHashHeader), nil
 }
@@ -53,6 +53,10 @@ type CreateTemplateVersionRequest struct {
 	ProvisionerTags map[string]string        `json:"tags"`
 
 	UserVariableValues []VariableValue `json:"user_variable_values,omitempty"`
+	// Throwaway marks a version that is only imported to inspect its resources
+	// and parameters. It can't be used to create a template, be promoted or be
+	// built, and is deleted after a day.
+	Throwaway bool `json:"throwaway,omitempty"`
 }
 
 type VariableValue struct {
@@ -1,6 +1,8 @@
 package codersdk
 
 import (
+	"fmt"
+	"regexp"
 	"strconv"
 
 	"golang.org/x/xerrors"
@@ -102,6 +104,19 @@ func validateBuildParameter(richParameter TemplateVersionParameter, buildParamet
 		return nil
 	}
 
+	if richParameter.Type == "string" && richParameter.ValidationRegex != "" {
+		// An invalid regex is reported by the provider validation below.
+		regex, err := regexp.Compile(richParameter.ValidationRegex)
+		if err == nil && !regex.MatchString(value) {
+			return &ParameterRegexError{
+				Name:        richParameter.Name,
+				Value:       value,
+				Regex:       richParameter.ValidationRegex,
+				Description: richParameter.ValidationError,
+			}
+		}
+	}
+
 	var min, max int
 	if richParameter.ValidationMin != nil {
 		min = int(*richParameter.ValidationMin)
@@ -122,6 +137,54 @@ func validateBuildParameter(richParameter TemplateVersionParameter, buildParamet
 	return validation.Valid(richParameter.Type, value)
 }
 
+// ParameterRegexError is returned when a parameter value does not match the
+// validation regex of the parameter.
+// @typescript-ignore ParameterRegexError
+type ParameterRegexError struct {
+	Name  string
+	Value string
+	Regex string
+	// Description is the validation error provided by the template, if any.
+	Description string
+}
+
+func (e *ParameterRegexError) Error() string {
+	msg := fmt.Sprintf("parameter %q value %q does not match pattern %q", e.Name, e.Value, e.Regex)
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// ParameterValidationError is returned by ParameterResolver.ValidateResolve
+// when a parameter can't be resolved to a valid value.  It wraps the
+// underlying error, e.g. a ParameterRegexError.
+// @typescript-ignore ParameterValidationError
+type ParameterValidationError struct {
+	Name string
+	// Reason describes why the parameter is invalid.
+	Reason    string
+	Parameter TemplateVersionParameter
+	Err       error
+}
+
+func newParameterValidationError(p TemplateVersionParameter, err error) *ParameterValidationError {
+	return &ParameterValidationError{
+		Name:      p.Name,
+		Reason:    err.Error(),
+		Parameter: p,
+		Err:       err,
+	}
+}
+
+func (e *ParameterValidationError) Error() string {
+	return e.Reason
+}
+
+func (e *ParameterValidationError) Unwrap() error {
+	return e.Err
+}
+
 func findBuildParameter(params []WorkspaceBuildParameter, parameterName string) (*WorkspaceBuildParameter, bool) {
 	if params == nil {
 		return nil, false
@@ -158,17 +221,20 @@ func validationEnabled(param TemplateVersionParameter) bool {
 // @typescript-ignore ParameterResolver
 type ParameterResolver struct {
 	Rich []WorkspaceBuildParameter
+	// AllowImmutableChanges permits new values for immutable parameters that already have a value from the previous
+	// build.  The new values are still validated.
+	AllowImmutableChanges bool
 }
 
 // ValidateResolve checks the provided value, v, against the parameter, p, and the previous build.  If v is nil, it also
 // resolves the correct value.  It returns the value of the parameter, if valid, and an error if invalid.
 func (r *ParameterResolver) ValidateResolve(p TemplateVersionParameter, v *WorkspaceBuildParameter) (value string, err error) {
 	prevV := r.findLastValue(p)
-	if !p.Mutable && v != nil && prevV != nil {
-		return "", xerrors.Errorf("Parameter %q is not mutable, so it can't be updated after creating a workspace.", p.Name)
+	if !p.Mutable && !r.AllowImmutableChanges && v != nil && prevV != nil {
+		return "", newParameterValidationError(p, xerrors.Errorf("Parameter %q is not mutable, so it can't be updated after creating a workspace.", p.Name))
 	}
 	if p.Required && v == nil && prevV == nil {
-		return "", xerrors.Errorf("Parameter %q is required but not provided", p.Name)
+		return "", newParameterValidationError(p, xerrors.Errorf("Parameter %q is required but not provided", p.Name))
 	}
 	// First, the provided value
 	resolvedValue := v
@@ -185,7 +251,7 @@ func (r *ParameterResolver) ValidateResolve(p TemplateVersionParameter, v *Works
 	}
 	err = ValidateWorkspaceBuildParameter(p, resolvedValue, prevV)
 	if err != nil {
-		return "", err
+		return "", newParameterValidationError(p, err)
 	}
 	return resolvedValue.Value, nil
 }
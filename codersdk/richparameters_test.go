@@ -135,6 +135,59 @@ func TestParameterResolver_ValidateResolve_Immutable(t *testing.T) {
 	})
 	require.Error(t, err)
 	require.Equal(t, "", v)
+	var validationErr *codersdk.ParameterValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, "n", validationErr.Name)
+	require.Equal(t, p, validationErr.Parameter)
+	require.Equal(t, `Parameter "n" is not mutable, so it can't be updated after creating a workspace.`, validationErr.Reason)
+}
+
+func TestParameterResolver_ValidateResolve_AllowImmutableChanges(t *testing.T) {
+	t.Parallel()
+	uut := codersdk.ParameterResolver{
+		Rich:                  []codersdk.WorkspaceBuildParameter{{Name: "n", Value: "5"}},
+		AllowImmutableChanges: true,
+	}
+	p := codersdk.TemplateVersionParameter{
+		Name:     "n",
+		Type:     "number",
+		Required: true,
+		Mutable:  false,
+	}
+	v, err := uut.ValidateResolve(p, &codersdk.WorkspaceBuildParameter{
+		Name:  "n",
+		Value: "6",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "6", v)
+}
+
+func TestParameterResolver_ValidateResolve_RegexMismatch(t *testing.T) {
+	t.Parallel()
+	uut := codersdk.ParameterResolver{}
+	p := codersdk.TemplateVersionParameter{
+		Name:            "region",
+		DisplayName:     "Region",
+		Type:            "string",
+		ValidationRegex: "^[a-z]+$",
+		ValidationError: "lowercase letters only",
+	}
+	_, err := uut.ValidateResolve(p, &codersdk.WorkspaceBuildParameter{
+		Name:  "region",
+		Value: "EU",
+	})
+	var validationErr *codersdk.ParameterValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, "region", validationErr.Name)
+	var regexErr *codersdk.ParameterRegexError
+	require.ErrorAs(t, err, &regexErr)
+	require.Equal(t, codersdk.ParameterRegexError{
+		Name:        "region",
+		Value:       "EU",
+		Regex:       "^[a-z]+$",
+		Description: "lowercase letters only",
+	}, *regexErr)
+	require.Equal(t, `parameter "region" value "EU" does not match pattern "^[a-z]+$": lowercase letters only`, regexErr.Error())
 }
 
 func TestRichParameterValidation(t *testing.T) {
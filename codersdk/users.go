@@ -45,6 +45,7 @@ type MinimalUser struct {
 type User struct {
 	ID         uuid.UUID `json:"id" validate:"required" table:"id" format:"uuid"`
 	Username   string    `json:"username" validate:"required" table:"username,default_sort"`
+	Name       string    `json:"name"`
 	Email      string    `json:"email" validate:"required" table:"email" format:"email"`
 	CreatedAt  time.Time `json:"created_at" validate:"required" table:"created at" format:"date-time"`
 	LastSeenAt time.Time `json:"last_seen_at" format:"date-time"`
@@ -64,6 +65,8 @@ type GetUsersResponse struct {
 type CreateFirstUserRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Username string `json:"username" validate:"required,username"`
+	// Name is the full name of the user, for display. It is optional.
+	Name     string `json:"name"`
 	Password string `json:"password" validate:"required"`
 	Trial    bool   `json:"trial"`
 }
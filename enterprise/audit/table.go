@@ -97,6 +97,7 @@ var auditableResourcesTypes = map[any]map[string]Action{
 		"job_id":                ActionIgnore, // Not helpful in a diff because jobs aren't tracked in audit logs.
 		"created_by":            ActionTrack,
 		"git_auth_providers":    ActionIgnore, // Not helpful because this can only change when new versions are added.
+		"throwaway":             ActionIgnore, // Never changes after creation.
 		"created_by_avatar_url": ActionIgnore,
 		"created_by_username":   ActionIgnore,
 	},
@@ -114,6 +115,7 @@ var auditableResourcesTypes = map[any]map[string]Action{
 		"last_seen_at":         ActionIgnore,
 		"deleted":              ActionTrack,
 		"quiet_hours_schedule": ActionTrack,
+		"name":                 ActionTrack,
 	},
 	&database.Workspace{}: {
 		"id":                 ActionTrack,
@@ -131,22 +133,28 @@ var auditableResourcesTypes = map[any]map[string]Action{
 		"deleting_at":        ActionTrack,
 	},
 	&database.WorkspaceBuild{}: {
-		"id":                      ActionIgnore,
-		"created_at":              ActionIgnore,
-		"updated_at":              ActionIgnore,
-		"workspace_id":            ActionIgnore,
-		"template_version_id":     ActionTrack,
-		"build_number":            ActionIgnore,
-		"transition":              ActionIgnore,
-		"initiator_id":            ActionIgnore,
-		"provisioner_state":       ActionIgnore,
-		"job_id":                  ActionIgnore,
-		"deadline":                ActionIgnore,
-		"reason":                  ActionIgnore,
-		"daily_cost":              ActionIgnore,
-		"max_deadline":            ActionIgnore,
-		"initiator_by_avatar_url": ActionIgnore,
-		"initiator_by_username":   ActionIgnore,
+		"id":                       ActionIgnore,
+		"created_at":               ActionIgnore,
+		"updated_at":               ActionIgnore,
+		"workspace_id":             ActionIgnore,
+		"template_version_id":      ActionTrack,
+		"build_number":             ActionIgnore,
+		"transition":               ActionIgnore,
+		"initiator_id":             ActionIgnore,
+		"provisioner_state":        ActionIgnore,
+		"job_id":                   ActionIgnore,
+		"deadline":                 ActionIgnore,
+		"reason":                   ActionIgnore,
+		"daily_cost":               ActionIgnore,
+		"max_deadline":             ActionIgnore,
+		"rollback_of":              ActionTrack,
+		"parameters_from_build_id": ActionIgnore,
+		"structured_reason":        ActionIgnore,
+		"idempotency_key":          ActionIgnore,
+		"template_file_hash":       ActionIgnore,
+		"schedule_name":            ActionIgnore,
+		"initiator_by_avatar_url":  ActionIgnore,
+		"initiator_by_username":    ActionIgnore,
 	},
 	&database.AuditableGroup{}: {
 		"id":              ActionTrack,
@@ -412,6 +412,7 @@ func convertUser(user database.User, organizationIDs []uuid.UUID) codersdk.User
 		CreatedAt:       user.CreatedAt,
 		LastSeenAt:      user.LastSeenAt,
 		Username:        user.Username,
+		Name:            user.Name,
 		Status:          codersdk.UserStatus(user.Status),
 		OrganizationIDs: organizationIDs,
 		Roles:           make([]codersdk.Role, 0, len(user.RBACRoles)),
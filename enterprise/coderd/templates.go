@@ -91,17 +91,12 @@ func (api *API) templateACL(rw http.ResponseWriter, r *http.Request) {
 		template = httpmw.TemplateParam(r)
 	)
 
-	users, err := api.Database.GetTemplateUserRoles(ctx, template.ID)
-	if err != nil {
-		httpapi.InternalServerError(rw, err)
-		return
-	}
-
-	dbGroups, err := api.Database.GetTemplateGroupRoles(ctx, template.ID)
+	acl, err := api.Database.GetTemplateACL(ctx, template.ID)
 	if err != nil {
 		httpapi.InternalServerError(rw, err)
 		return
 	}
+	users, dbGroups := acl.Users, acl.Groups
 
 	userIDs := make([]uuid.UUID, 0, len(users))
 	for _, user := range users {
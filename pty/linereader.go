@@ -0,0 +1,179 @@
+package pty
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LineReader reads terminal output, such as from PTYCmd.OutputReader(), and
+// returns it one line at a time as it would appear on a terminal. Carriage
+// returns, backspaces and cursor movement within the line overwrite earlier
+// output, so e.g. a progress indicator that redraws itself produces only its
+// final state. Escape sequences that don't affect the line, such as colors,
+// are dropped.
+//
+// Movement between lines is not modeled: each line feed completes the current
+// line.
+type LineReader struct {
+	r    *bufio.Reader
+	line []rune
+	col  int
+}
+
+// NewLineReader returns a LineReader that reads terminal output from r.
+func NewLineReader(r io.Reader) *LineReader {
+	return &LineReader{r: bufio.NewReader(r)}
+}
+
+// ReadLine returns the next complete line, without the line ending. When the
+// output ends without a final line feed, the partial line is returned before
+// the error from the underlying reader, generally io.EOF.
+func (l *LineReader) ReadLine() (string, error) {
+	for {
+		r, _, err := l.r.ReadRune()
+		if err != nil {
+			if len(l.line) > 0 {
+				return l.flush(), nil
+			}
+			return "", err
+		}
+		switch r {
+		case '\n':
+			return l.flush(), nil
+		case '\r':
+			l.col = 0
+		case '\b':
+			if l.col > 0 {
+				l.col--
+			}
+		case '\t':
+			l.moveTo((l.col/8 + 1) * 8)
+		case '\x1b':
+			err = l.escape()
+			if err != nil {
+				if len(l.line) > 0 {
+					return l.flush(), nil
+				}
+				return "", err
+			}
+		default:
+			if r < ' ' || r == '\x7f' {
+				// Other control characters don't print anything.
+				continue
+			}
+			l.put(r)
+		}
+	}
+}
+
+// flush returns the current line and starts a new one.
+func (l *LineReader) flush() string {
+	line := strings.TrimRight(string(l.line), " ")
+	l.line = l.line[:0]
+	l.col = 0
+	return line
+}
+
+// put writes r at the cursor and advances it.
+func (l *LineReader) put(r rune) {
+	l.moveTo(l.col)
+	if l.col < len(l.line) {
+		l.line[l.col] = r
+	} else {
+		l.line = append(l.line, r)
+	}
+	l.col++
+}
+
+// moveTo moves the cursor to col, padding the line with spaces if the cursor
+// would be past its end.
+func (l *LineReader) moveTo(col int) {
+	if col < 0 {
+		col = 0
+	}
+	for len(l.line) < col {
+		l.line = append(l.line, ' ')
+	}
+	l.col = col
+}
+
+// escape handles the escape sequence following an ESC.
+func (l *LineReader) escape() error {
+	r, _, err := l.r.ReadRune()
+	if err != nil {
+		return err
+	}
+	switch r {
+	case '[':
+		return l.csi()
+	case ']':
+		// Operating system commands, e.g. setting the window title, are
+		// terminated by BEL or ESC \.
+		for {
+			r, _, err := l.r.ReadRune()
+			if err != nil {
+				return err
+			}
+			if r == '\a' {
+				return nil
+			}
+			if r == '\x1b' {
+				_, _, err := l.r.ReadRune()
+				return err
+			}
+		}
+	default:
+		// Two character sequences don't affect the line.
+		return nil
+	}
+}
+
+// csi handles a control sequence, i.e. ESC [ followed by parameters and a
+// final character.
+func (l *LineReader) csi() error {
+	var params strings.Builder
+	for {
+		r, _, err := l.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		if r < '@' || r > '~' {
+			_, _ = params.WriteRune(r)
+			continue
+		}
+		n, err := strconv.Atoi(params.String())
+		if err != nil {
+			// Missing, or not a single numeric parameter.
+			n = 0
+		}
+		// Cursor movement treats a missing count as 1.
+		count := n
+		if count < 1 {
+			count = 1
+		}
+		switch r {
+		case 'C': // Cursor forward
+			l.moveTo(l.col + count)
+		case 'D': // Cursor back
+			l.moveTo(l.col - count)
+		case 'G': // Cursor horizontal absolute, 1-based
+			l.moveTo(count - 1)
+		case 'K': // Erase in line
+			switch n {
+			case 0:
+				if l.col < len(l.line) {
+					l.line = l.line[:l.col]
+				}
+			case 1:
+				for i := 0; i < l.col && i < len(l.line); i++ {
+					l.line[i] = ' '
+				}
+			case 2:
+				l.line = l.line[:0]
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package pty_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/coder/pty"
+)
+
+func TestLineReader(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			name:   "Lines",
+			output: "first\r\nsecond\r\n",
+			want:   []string{"first", "second"},
+		},
+		{
+			name:   "CarriageReturnOverwrites",
+			output: "progress 10%\rprogress 55%\rprogress 100%\r\ndone\r\n",
+			want:   []string{"progress 100%", "done"},
+		},
+		{
+			name:   "PartialOverwrite",
+			output: "abcdef\rXY\r\n",
+			want:   []string{"XYcdef"},
+		},
+		{
+			name:   "EraseLine",
+			output: "downloading...\r\x1b[Kdownloaded\r\n",
+			want:   []string{"downloaded"},
+		},
+		{
+			name:   "EraseToEnd",
+			output: "abcdef\x1b[3D\x1b[K\r\n",
+			want:   []string{"abc"},
+		},
+		{
+			name:   "CursorMovement",
+			output: "abc\x1b[2DX\x1b[5G!\r\n",
+			want:   []string{"aXc !"},
+		},
+		{
+			name:   "Backspace",
+			output: "ab\bc\r\n",
+			want:   []string{"ac"},
+		},
+		{
+			name:   "Colors",
+			output: "\x1b[1;32mok\x1b[0m\r\n",
+			want:   []string{"ok"},
+		},
+		{
+			name:   "Title",
+			output: "\x1b]0;window title\aprompt\r\n",
+			want:   []string{"prompt"},
+		},
+		{
+			name:   "NoFinalNewline",
+			output: "first\r\nstill going\r100%",
+			want:   []string{"first", "100%l going"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			lr := pty.NewLineReader(strings.NewReader(tc.output))
+			var got []string
+			for {
+				line, err := lr.ReadLine()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				got = append(got, line)
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
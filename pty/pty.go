@@ -1,23 +1,36 @@
 package pty
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gliderlabs/ssh"
+	"github.com/hinshun/vt10x"
 	"golang.org/x/xerrors"
 )
 
 // ErrClosed is returned when a PTY is used after it has been closed.
 var ErrClosed = xerrors.New("pty: closed")
 
+// ErrUnsupported is returned when an operation isn't supported on the current
+// platform.
+var ErrUnsupported = xerrors.New("pty: unsupported")
+
 // PTYCmd is an interface for interacting with a pseudo-TTY where we control
 // only one end, and the other end has been passed to a running os.Process.
 // nolint:revive
 type PTYCmd interface {
 	io.Closer
 
-	// Resize sets the size of the PTY.
+	// Resize sets the size of the PTY, e.g. when the window of the client
+	// changes, and signals the process with SIGWINCH on Unix.  It returns an
+	// error if the PTY has been closed.
 	Resize(height uint16, width uint16) error
 
 	// OutputReader returns an io.Reader for reading the output from the process
@@ -25,8 +38,41 @@ type PTYCmd interface {
 	OutputReader() io.Reader
 
 	// InputWriter returns an io.Writer for writing into to the process
-	// controlled by the pseudo-TTY
+	// controlled by the pseudo-TTY.  Each call to Write delivers the whole
+	// buffer, retrying if the pseudo-TTY accepts only part of it, or returns
+	// an error.
 	InputWriter() io.Writer
+
+	// Pause stops delivering output to readers of OutputReader until Resume
+	// is called. Output is not dropped: it is left in the pseudo-TTY buffer,
+	// so the OS applies backpressure to the process once the buffer fills.
+	Pause()
+
+	// Resume undoes Pause, delivering any buffered output in order.
+	Resume()
+
+	// Snapshot returns the current screen contents, as rendered from the
+	// output read so far from OutputReader, e.g. to bring a reconnecting
+	// client up to date. Rows are separated by CRLF, with trailing blanks
+	// removed. It returns nil unless the PTY was created WithSnapshot.
+	Snapshot() []byte
+
+	// WriteOutput injects p into the output of the process, as if the process
+	// wrote it, e.g. to broadcast a notice to the session. It doesn't reach
+	// the input of the process. Concurrent calls don't interleave with each
+	// other, but a long write may interleave with the output of the process.
+	WriteOutput(p []byte) error
+
+	// ForegroundProcessGroup returns the ID of the foreground process group
+	// of the pseudo-TTY, as for tcgetpgrp(3). It returns ErrUnsupported on
+	// Windows.
+	ForegroundProcessGroup() (int, error)
+
+	// SetForegroundProcessGroup makes pgid the foreground process group of
+	// the pseudo-TTY, as for tcsetpgrp(3), e.g. to implement job control.
+	// The process group must be in the session of the process. It returns
+	// ErrUnsupported on Windows.
+	SetForegroundProcessGroup(pgid int) error
 }
 
 // PTY is a minimal interface for interacting with pseudo-TTY where this
@@ -69,6 +115,27 @@ type Process interface {
 
 	// Kill the command process.  Returned error is as for os.Process.Kill()
 	Kill() error
+
+	// Done returns a channel that is closed when the command exits.
+	Done() <-chan struct{}
+
+	// ExitError returns the error as for Wait(), without blocking.  It is only valid once the channel returned by
+	// Done() is closed, and returns nil before then.
+	ExitError() error
+
+	// Usage returns the resources used by the command, e.g. for per-session accounting.  It is only valid once the
+	// channel returned by Done() is closed, and returns nil before then.  It returns ErrUnsupported on Windows.
+	Usage() (*Usage, error)
+}
+
+// Usage is the resource usage of a process that has exited.
+type Usage struct {
+	// UserTime is the CPU time spent in user mode.
+	UserTime time.Duration
+	// SystemTime is the CPU time spent in kernel mode.
+	SystemTime time.Duration
+	// MaxRSS is the maximum resident set size, in bytes.
+	MaxRSS int64
 }
 
 // WithFlags represents a PTY whose flags can be inspected, in particular
@@ -87,6 +154,20 @@ type ptyOptions struct {
 	logger    *log.Logger
 	sshReq    *ssh.Pty
 	setGPGTTY bool
+
+	rows, cols uint16
+
+	handleBell  bool
+	bellHandler func()
+
+	titleHandler func(title string)
+
+	snapshot bool
+
+	inputRecorder io.Writer
+
+	normalizeNewlines bool
+	newlines          NewlineDirection
 }
 
 // WithSSHRequest applies the ssh.Pty request to the PTY.
@@ -98,6 +179,28 @@ func WithSSHRequest(req ssh.Pty) Option {
 	}
 }
 
+// WithSize sets the initial size of the PTY, so that a command started in it
+// sees the size from the start, e.g. for programs that render once at launch.
+// It takes precedence over the window size of WithSSHRequest.  Sizes of zero
+// are ignored.
+func WithSize(rows, cols uint16) Option {
+	return func(opts *ptyOptions) {
+		opts.rows = rows
+		opts.cols = cols
+	}
+}
+
+// windowSize returns the initial size of the PTY, and whether one was set.
+func (o ptyOptions) windowSize() (rows, cols uint16, ok bool) {
+	if o.rows > 0 && o.cols > 0 {
+		return o.rows, o.cols, true
+	}
+	if o.sshReq != nil && o.sshReq.Window.Height > 0 && o.sshReq.Window.Width > 0 {
+		return uint16(o.sshReq.Window.Height), uint16(o.sshReq.Window.Width), true
+	}
+	return 0, 0, false
+}
+
 // WithLogger sets a logger for logging errors.
 func WithLogger(logger *log.Logger) Option {
 	return func(opts *ptyOptions) {
@@ -113,6 +216,75 @@ func WithGPGTTY() Option {
 	}
 }
 
+// WithBellHandler removes BEL characters from the output returned by
+// OutputReader, calling fn once for each of them instead. This allows e.g. web
+// clients to show a visual bell. If fn is nil, bells are silently dropped.
+//
+// BEL characters that terminate operating system commands, such as setting the
+// window title, are left in place.
+func WithBellHandler(fn func()) Option {
+	return func(opts *ptyOptions) {
+		opts.handleBell = true
+		opts.bellHandler = fn
+	}
+}
+
+// WithTitleHandler calls fn with the new window title whenever the output
+// read from OutputReader sets it with an OSC 0 or OSC 2 sequence, e.g. so that
+// web terminals can update the name of their tab. The output itself is left
+// unchanged.
+func WithTitleHandler(fn func(title string)) Option {
+	return func(opts *ptyOptions) {
+		opts.titleHandler = fn
+	}
+}
+
+// WithSnapshot maintains a model of the terminal screen from the output read
+// from OutputReader, so that PTYCmd.Snapshot can return it.
+func WithSnapshot() Option {
+	return func(opts *ptyOptions) {
+		opts.snapshot = true
+	}
+}
+
+// WithInputRecorder records all input written to the PTY to w, separately
+// from the output, e.g. to audit what a user typed. Each write is recorded on
+// its own line as an asciinema input event, [time, "i", data], where time is
+// the number of seconds since the PTY was created.
+//
+// Writes to w are serialized along with the input, so w need not be safe for
+// concurrent use, but a slow w delays the input.
+func WithInputRecorder(w io.Writer) Option {
+	return func(opts *ptyOptions) {
+		opts.inputRecorder = w
+	}
+}
+
+// NewlineDirection is the conversion applied by WithNormalizeNewlines.
+type NewlineDirection int
+
+const (
+	// NewlinesCRLFToLF converts CRLF line endings to LF, e.g. for Windows
+	// programs whose output is consumed by Unix-oriented tools.  Bare CRs,
+	// which return the cursor to the start of the line, are left in place.
+	NewlinesCRLFToLF NewlineDirection = iota
+	// NewlinesLFToCRLF converts bare LF line endings to CRLF.
+	NewlinesLFToCRLF
+)
+
+// WithNormalizeNewlines converts the line endings in the output returned by
+// OutputReader in the given direction.  The raw output remains available from
+// PTY.Output.
+//
+// When converting CRLF to LF, a CR at the end of a read is held back until the
+// next read shows whether it is followed by LF.
+func WithNormalizeNewlines(dir NewlineDirection) Option {
+	return func(opts *ptyOptions) {
+		opts.normalizeNewlines = true
+		opts.newlines = dir
+	}
+}
+
 // New constructs a new Pty.
 func New(opts ...Option) (PTY, error) {
 	return newPty(opts...)
@@ -133,3 +305,440 @@ func (rw ReadWriter) Read(p []byte) (int, error) {
 func (rw ReadWriter) Write(p []byte) (int, error) {
 	return rw.Writer.Write(p)
 }
+
+// syncWriter serializes writes to the underlying writer, so that each call to
+// Write is delivered intact even when multiple goroutines write concurrently,
+// e.g. user keystrokes and injected control sequences.  Partial writes to the
+// underlying writer are retried until all of p is written, so callers never
+// silently lose input.
+type syncWriter struct {
+	mutex *sync.Mutex
+	w     io.Writer
+}
+
+func (s syncWriter) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var written int
+	for written < len(p) {
+		n, err := s.w.Write(p[written:])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if n == 0 {
+			// The writer is making no progress, give up rather than spin.
+			return written, io.ErrShortWrite
+		}
+	}
+	return written, nil
+}
+
+// outputPauser gates reads of the PTY output, so that they can be paused and
+// resumed for flow control. The zero value is not paused.
+type outputPauser struct {
+	mutex   sync.Mutex
+	resumed chan struct{}
+}
+
+// Pause causes subsequent reads to block until Resume is called.
+func (p *outputPauser) Pause() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.resumed == nil {
+		p.resumed = make(chan struct{})
+	}
+}
+
+// Resume unblocks reads that are waiting because of Pause.
+func (p *outputPauser) Resume() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.resumed != nil {
+		close(p.resumed)
+		p.resumed = nil
+	}
+}
+
+// wait blocks while the output is paused.
+func (p *outputPauser) wait() {
+	p.mutex.Lock()
+	resumed := p.resumed
+	p.mutex.Unlock()
+	if resumed != nil {
+		<-resumed
+	}
+}
+
+// reader returns an io.Reader that reads from r, waiting while paused.
+func (p *outputPauser) reader(r io.Reader) io.Reader {
+	return pausableReader{pauser: p, r: r}
+}
+
+type pausableReader struct {
+	pauser *outputPauser
+	r      io.Reader
+}
+
+func (r pausableReader) Read(b []byte) (int, error) {
+	r.pauser.wait()
+	return r.r.Read(b)
+}
+
+// outputReader wraps r, the PTY output, according to the options.
+func (o ptyOptions) outputReader(r io.Reader) io.Reader {
+	if o.titleHandler != nil {
+		r = &titleReader{r: r, handler: o.titleHandler}
+	}
+	if o.handleBell {
+		r = &bellReader{r: r, handler: o.bellHandler}
+	}
+	if o.normalizeNewlines {
+		r = &newlineReader{r: r, dir: o.newlines}
+	}
+	return r
+}
+
+// newlineReader converts the line endings of the output read from r.
+type newlineReader struct {
+	r   io.Reader
+	dir NewlineDirection
+	// cr is set if the last byte read was a CR.  When converting CRLF to LF,
+	// that CR has not been returned yet.
+	cr bool
+	// out holds converted output that has not been returned yet, since
+	// converting LF to CRLF may not fit in the caller's buffer.
+	out []byte
+	err error
+}
+
+func (r *newlineReader) Read(b []byte) (int, error) {
+	for len(r.out) == 0 && r.err == nil {
+		n, err := r.r.Read(b)
+		r.out = r.convert(r.out[:0], b[:n])
+		if err != nil {
+			if r.cr && r.dir == NewlinesCRLFToLF {
+				// There is no LF to come, so return the held CR.
+				r.out = append(r.out, '\r')
+				r.cr = false
+			}
+			r.err = err
+		}
+		if n == 0 && err == nil {
+			return 0, nil
+		}
+	}
+	if len(r.out) == 0 {
+		return 0, r.err
+	}
+	n := copy(b, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// convert appends the conversion of p to out.
+func (r *newlineReader) convert(out, p []byte) []byte {
+	for _, c := range p {
+		switch r.dir {
+		case NewlinesLFToCRLF:
+			if c == '\n' && !r.cr {
+				out = append(out, '\r')
+			}
+			out = append(out, c)
+			r.cr = c == '\r'
+		default:
+			if r.cr {
+				r.cr = false
+				if c == '\n' {
+					out = append(out, c)
+					continue
+				}
+				out = append(out, '\r')
+			}
+			if c == '\r' {
+				r.cr = true
+				continue
+			}
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+type bellState int
+
+const (
+	bellStateText bellState = iota
+	// bellStateEscape follows an ESC.
+	bellStateEscape
+	// bellStateOSC is within an operating system command, i.e. ESC ], which
+	// is terminated by BEL or ESC \.
+	bellStateOSC
+	// bellStateOSCEscape follows an ESC within an operating system command.
+	bellStateOSCEscape
+)
+
+// bellReader removes BEL characters from the output read from r and calls
+// handler for each of them.
+type bellReader struct {
+	r       io.Reader
+	handler func()
+	state   bellState
+}
+
+func (r *bellReader) Read(b []byte) (int, error) {
+	for {
+		n, err := r.r.Read(b)
+		kept := 0
+		for _, c := range b[:n] {
+			if r.filter(c) {
+				b[kept] = c
+				kept++
+			} else if r.handler != nil {
+				r.handler()
+			}
+		}
+		// Don't return zero bytes without an error if the read only
+		// contained bells.
+		if kept > 0 || n == 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// filter advances the state with c, and returns whether c should be kept.
+func (r *bellReader) filter(c byte) bool {
+	switch r.state {
+	case bellStateEscape:
+		if c == ']' {
+			r.state = bellStateOSC
+		} else {
+			r.state = bellStateText
+		}
+	case bellStateOSC:
+		switch c {
+		case '\a':
+			r.state = bellStateText
+		case '\x1b':
+			r.state = bellStateOSCEscape
+		}
+	case bellStateOSCEscape:
+		r.state = bellStateText
+	default:
+		switch c {
+		case '\a':
+			return false
+		case '\x1b':
+			r.state = bellStateEscape
+		}
+	}
+	return true
+}
+
+// maxTitleLength bounds the operating system command buffered by titleReader,
+// so that an unterminated command doesn't grow the buffer without limit.
+const maxTitleLength = 4096
+
+type titleState int
+
+const (
+	titleStateText titleState = iota
+	// titleStateEscape follows an ESC.
+	titleStateEscape
+	// titleStateOSC is within an operating system command, i.e. ESC ], which
+	// is terminated by BEL or ESC \.
+	titleStateOSC
+	// titleStateOSCEscape follows an ESC within an operating system command.
+	titleStateOSCEscape
+)
+
+// titleReader calls handler with the window title set by the OSC 0 and OSC 2
+// sequences in the output read from r, which it returns unchanged.
+type titleReader struct {
+	r       io.Reader
+	handler func(title string)
+	state   titleState
+	// osc holds the operating system command read so far.
+	osc []byte
+	// truncated is set if the command exceeded maxTitleLength, in which case
+	// it is ignored.
+	truncated bool
+}
+
+func (r *titleReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	for _, c := range b[:n] {
+		r.advance(c)
+	}
+	return n, err
+}
+
+// advance advances the state with c.
+func (r *titleReader) advance(c byte) {
+	switch r.state {
+	case titleStateEscape:
+		if c == ']' {
+			r.state = titleStateOSC
+			r.osc = r.osc[:0]
+			r.truncated = false
+		} else {
+			r.state = titleStateText
+		}
+	case titleStateOSC:
+		switch c {
+		case '\a':
+			r.state = titleStateText
+			r.dispatch()
+		case '\x1b':
+			r.state = titleStateOSCEscape
+		default:
+			if len(r.osc) < maxTitleLength {
+				r.osc = append(r.osc, c)
+			} else {
+				r.truncated = true
+			}
+		}
+	case titleStateOSCEscape:
+		if c == '\\' {
+			r.state = titleStateText
+			r.dispatch()
+			return
+		}
+		// Any other escape sequence aborts the command.
+		r.state = titleStateEscape
+		r.advance(c)
+	default:
+		if c == '\x1b' {
+			r.state = titleStateEscape
+		}
+	}
+}
+
+// dispatch calls the handler if the completed operating system command sets
+// the window title.
+func (r *titleReader) dispatch() {
+	if r.truncated {
+		return
+	}
+	ps, title, ok := bytes.Cut(r.osc, []byte{';'})
+	if !ok {
+		return
+	}
+	switch string(ps) {
+	case "0", "2":
+		r.handler(string(title))
+	}
+}
+
+// screen models the terminal screen that the PTY output is rendered to.  A nil
+// *screen does no modeling, so that it can be used unconditionally.
+type screen struct {
+	term vt10x.Terminal
+}
+
+// newScreen returns a screen if the options ask for snapshots, and nil
+// otherwise.
+func newScreen(opts ptyOptions) *screen {
+	if !opts.snapshot {
+		return nil
+	}
+	cols, rows := 80, 24
+	if r, c, ok := opts.windowSize(); ok {
+		cols, rows = int(c), int(r)
+	}
+	return &screen{term: vt10x.New(vt10x.WithSize(cols, rows))}
+}
+
+// reader returns an io.Reader that reads from r, rendering the output to the
+// screen.
+func (s *screen) reader(r io.Reader) io.Reader {
+	if s == nil {
+		return r
+	}
+	return io.TeeReader(r, s.term)
+}
+
+func (s *screen) resize(height uint16, width uint16) {
+	if s == nil {
+		return
+	}
+	s.term.Resize(int(width), int(height))
+}
+
+func (s *screen) snapshot() []byte {
+	if s == nil {
+		return nil
+	}
+	s.term.Lock()
+	defer s.term.Unlock()
+
+	cols, rows := s.term.Size()
+	lines := make([]string, rows)
+	var line []rune
+	for y := 0; y < rows; y++ {
+		line = line[:0]
+		for x := 0; x < cols; x++ {
+			c := s.term.Cell(x, y).Char
+			if c == 0 {
+				c = ' '
+			}
+			line = append(line, c)
+		}
+		lines[y] = strings.TrimRight(string(line), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return []byte(strings.Join(lines, "\r\n"))
+}
+
+// inputRecorder records the input written to the PTY.  A nil *inputRecorder
+// records nothing, so that it can be used unconditionally.
+type inputRecorder struct {
+	w     io.Writer
+	start time.Time
+}
+
+// newInputRecorder returns an inputRecorder if the options ask for input to be
+// recorded, and nil otherwise.
+func newInputRecorder(opts ptyOptions) *inputRecorder {
+	if opts.inputRecorder == nil {
+		return nil
+	}
+	return &inputRecorder{w: opts.inputRecorder, start: time.Now()}
+}
+
+// writer returns an io.Writer that writes to w, recording what was written.
+func (r *inputRecorder) writer(w io.Writer) io.Writer {
+	if r == nil {
+		return w
+	}
+	return recordingWriter{recorder: r, w: w}
+}
+
+// record writes an asciinema input event for p.  Bytes that aren't valid
+// UTF-8 are recorded as U+FFFD, as asciinema events hold strings.
+func (r *inputRecorder) record(p []byte) error {
+	data, err := json.Marshal(string(p))
+	if err != nil {
+		return err
+	}
+	elapsed := time.Since(r.start).Seconds()
+	_, err = fmt.Fprintf(r.w, "[%.6f, \"i\", %s]\n", elapsed, data)
+	return err
+}
+
+type recordingWriter struct {
+	recorder *inputRecorder
+	w        io.Writer
+}
+
+func (w recordingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		// Failing to record doesn't fail the input, which has already been
+		// written.
+		_ = w.recorder.record(p[:n])
+	}
+	return n, err
+}
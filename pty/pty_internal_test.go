@@ -0,0 +1,335 @@
+package pty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// byteWriter writes one byte at a time, yielding in between, so that
+// concurrent unsynchronized writers are very likely to interleave.
+type byteWriter struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (w *byteWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		w.mutex.Lock()
+		_ = w.buf.WriteByte(b)
+		w.mutex.Unlock()
+		runtime.Gosched()
+	}
+	return len(p), nil
+}
+
+func TestSyncWriter(t *testing.T) {
+	t.Parallel()
+
+	const (
+		writers = 20
+		writes  = 50
+	)
+
+	var (
+		mutex sync.Mutex
+		bw    byteWriter
+		wg    sync.WaitGroup
+	)
+	w := syncWriter{mutex: &mutex, w: &bw}
+	for i := 0; i < writers; i++ {
+		seq := []byte(fmt.Sprintf("\x1b[%d;%dR", i, i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				n, err := w.Write(seq)
+				assert.NoError(t, err)
+				assert.Equal(t, len(seq), n)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every escape sequence must have been written intact.
+	got := map[string]int{}
+	for _, seq := range bytes.SplitAfter(bw.buf.Bytes(), []byte("R")) {
+		if len(seq) == 0 {
+			continue
+		}
+		var a, b int
+		_, err := fmt.Sscanf(string(seq), "\x1b[%d;%dR", &a, &b)
+		require.NoError(t, err, "corrupted sequence %q", seq)
+		require.Equal(t, a, b, "corrupted sequence %q", seq)
+		got[string(seq)]++
+	}
+	require.Len(t, got, writers)
+	for seq, count := range got {
+		require.Equal(t, writes, count, "sequence %q", seq)
+	}
+}
+
+// shortWriter accepts at most max bytes per call to Write, like a file
+// descriptor that does partial writes.
+type shortWriter struct {
+	max int
+	buf bytes.Buffer
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.max {
+		p = p[:w.max]
+	}
+	return w.buf.Write(p)
+}
+
+func TestSyncWriterPartialWrites(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Complete", func(t *testing.T) {
+		t.Parallel()
+
+		data := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+		sw := &shortWriter{max: 1000}
+		w := syncWriter{mutex: &sync.Mutex{}, w: sw}
+		n, err := w.Write(data)
+		require.NoError(t, err)
+		require.Equal(t, len(data), n)
+		require.Equal(t, data, sw.buf.Bytes())
+	})
+
+	t.Run("NoProgress", func(t *testing.T) {
+		t.Parallel()
+
+		sw := &shortWriter{max: 0}
+		w := syncWriter{mutex: &sync.Mutex{}, w: sw}
+		n, err := w.Write([]byte("hello"))
+		require.ErrorIs(t, err, io.ErrShortWrite)
+		require.Equal(t, 0, n)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		w := syncWriter{mutex: &sync.Mutex{}, w: errWriter{w: &buf, max: 2, err: io.ErrClosedPipe}}
+		n, err := w.Write([]byte("hello"))
+		require.ErrorIs(t, err, io.ErrClosedPipe)
+		require.Equal(t, 2, n)
+		require.Equal(t, "he", buf.String())
+	})
+}
+
+// errWriter writes at most max bytes and then fails with err.
+type errWriter struct {
+	w   io.Writer
+	max int
+	err error
+}
+
+func (w errWriter) Write(p []byte) (int, error) {
+	if len(p) > w.max {
+		p = p[:w.max]
+	}
+	n, _ := w.w.Write(p)
+	return n, w.err
+}
+
+func TestBellReader(t *testing.T) {
+	t.Parallel()
+
+	const (
+		output = "one\a two\a\a \x1b]0;title\a \x1b]2;other\x1b\\ three\a"
+		want   = "one two \x1b]0;title\a \x1b]2;other\x1b\\ three"
+	)
+
+	t.Run("Handler", func(t *testing.T) {
+		t.Parallel()
+
+		bells := 0
+		opts := ptyOptions{}
+		WithBellHandler(func() { bells++ })(&opts)
+		got, err := io.ReadAll(opts.outputReader(strings.NewReader(output)))
+		require.NoError(t, err)
+		require.Equal(t, want, string(got))
+		require.Equal(t, 4, bells)
+	})
+
+	t.Run("OneByteReads", func(t *testing.T) {
+		t.Parallel()
+
+		bells := 0
+		opts := ptyOptions{}
+		WithBellHandler(func() { bells++ })(&opts)
+		got, err := io.ReadAll(opts.outputReader(iotest.OneByteReader(strings.NewReader(output))))
+		require.NoError(t, err)
+		require.Equal(t, want, string(got))
+		require.Equal(t, 4, bells)
+	})
+
+	t.Run("Suppress", func(t *testing.T) {
+		t.Parallel()
+
+		opts := ptyOptions{}
+		WithBellHandler(nil)(&opts)
+		got, err := io.ReadAll(opts.outputReader(strings.NewReader(output)))
+		require.NoError(t, err)
+		require.Equal(t, want, string(got))
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		t.Parallel()
+
+		got, err := io.ReadAll(ptyOptions{}.outputReader(strings.NewReader(output)))
+		require.NoError(t, err)
+		require.Equal(t, output, string(got))
+	})
+}
+
+func TestTitleReader(t *testing.T) {
+	t.Parallel()
+
+	const output = "one\x1b]2;vim main.go\a two \x1b]1;icon\a\x1b]0;~/src\x1b\\ \x1b]2;aborted\x1b[0m three"
+
+	t.Run("Handler", func(t *testing.T) {
+		t.Parallel()
+
+		var titles []string
+		opts := ptyOptions{}
+		WithTitleHandler(func(title string) { titles = append(titles, title) })(&opts)
+		got, err := io.ReadAll(opts.outputReader(strings.NewReader(output)))
+		require.NoError(t, err)
+		require.Equal(t, output, string(got))
+		require.Equal(t, []string{"vim main.go", "~/src"}, titles)
+	})
+
+	t.Run("OneByteReads", func(t *testing.T) {
+		t.Parallel()
+
+		var titles []string
+		opts := ptyOptions{}
+		WithTitleHandler(func(title string) { titles = append(titles, title) })(&opts)
+		got, err := io.ReadAll(opts.outputReader(iotest.OneByteReader(strings.NewReader(output))))
+		require.NoError(t, err)
+		require.Equal(t, output, string(got))
+		require.Equal(t, []string{"vim main.go", "~/src"}, titles)
+	})
+
+	t.Run("WithBells", func(t *testing.T) {
+		t.Parallel()
+
+		var titles []string
+		opts := ptyOptions{}
+		WithBellHandler(nil)(&opts)
+		WithTitleHandler(func(title string) { titles = append(titles, title) })(&opts)
+		got, err := io.ReadAll(opts.outputReader(strings.NewReader("one\a\x1b]2;title\a")))
+		require.NoError(t, err)
+		require.Equal(t, "one\x1b]2;title\a", string(got))
+		require.Equal(t, []string{"title"}, titles)
+	})
+
+	t.Run("TooLong", func(t *testing.T) {
+		t.Parallel()
+
+		var titles []string
+		opts := ptyOptions{}
+		WithTitleHandler(func(title string) { titles = append(titles, title) })(&opts)
+		long := "\x1b]2;" + strings.Repeat("x", maxTitleLength) + "\a\x1b]2;short\a"
+		_, err := io.ReadAll(opts.outputReader(strings.NewReader(long)))
+		require.NoError(t, err)
+		require.Equal(t, []string{"short"}, titles)
+	})
+}
+
+func TestNewlineReader(t *testing.T) {
+	t.Parallel()
+
+	const output = "one\r\ntwo\r\n50%\r100%\r\nthree\nfour\r"
+
+	t.Run("CRLFToLF", func(t *testing.T) {
+		t.Parallel()
+
+		opts := ptyOptions{}
+		WithNormalizeNewlines(NewlinesCRLFToLF)(&opts)
+		got, err := io.ReadAll(opts.outputReader(strings.NewReader(output)))
+		require.NoError(t, err)
+		require.Equal(t, "one\ntwo\n50%\r100%\nthree\nfour\r", string(got))
+	})
+
+	t.Run("CRLFToLFOneByteReads", func(t *testing.T) {
+		t.Parallel()
+
+		opts := ptyOptions{}
+		WithNormalizeNewlines(NewlinesCRLFToLF)(&opts)
+		got, err := io.ReadAll(opts.outputReader(iotest.OneByteReader(strings.NewReader(output))))
+		require.NoError(t, err)
+		require.Equal(t, "one\ntwo\n50%\r100%\nthree\nfour\r", string(got))
+	})
+
+	t.Run("LFToCRLF", func(t *testing.T) {
+		t.Parallel()
+
+		opts := ptyOptions{}
+		WithNormalizeNewlines(NewlinesLFToCRLF)(&opts)
+		// Read through a small buffer, so that the converted output doesn't
+		// fit in one read.
+		got, err := io.ReadAll(iotest.OneByteReader(opts.outputReader(strings.NewReader(output))))
+		require.NoError(t, err)
+		require.Equal(t, "one\r\ntwo\r\n50%\r100%\r\nthree\r\nfour\r", string(got))
+	})
+
+	t.Run("WithBells", func(t *testing.T) {
+		t.Parallel()
+
+		opts := ptyOptions{}
+		WithBellHandler(nil)(&opts)
+		WithNormalizeNewlines(NewlinesCRLFToLF)(&opts)
+		got, err := io.ReadAll(opts.outputReader(strings.NewReader("one\r\a\ntwo")))
+		require.NoError(t, err)
+		require.Equal(t, "one\ntwo", string(got))
+	})
+}
+
+func TestInputRecorder(t *testing.T) {
+	t.Parallel()
+
+	inputs := []string{"ls -la\r", "\x1b[A", "exit\r"}
+
+	var recorded, input bytes.Buffer
+	opts := ptyOptions{}
+	WithInputRecorder(&recorded)(&opts)
+	w := newInputRecorder(opts).writer(&input)
+	for _, in := range inputs {
+		n, err := w.Write([]byte(in))
+		require.NoError(t, err)
+		require.Equal(t, len(in), n)
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, strings.Join(inputs, ""), input.String())
+
+	lines := strings.Split(strings.TrimSuffix(recorded.String(), "\n"), "\n")
+	require.Len(t, lines, len(inputs))
+	last := 0.0
+	for i, line := range lines {
+		var event []interface{}
+		err := json.Unmarshal([]byte(line), &event)
+		require.NoError(t, err, "line %q", line)
+		require.Len(t, event, 3)
+		elapsed, ok := event[0].(float64)
+		require.True(t, ok, "line %q", line)
+		require.Greater(t, elapsed, last, "line %q", line)
+		last = elapsed
+		require.Equal(t, "i", event[1])
+		require.Equal(t, inputs[i], event[2])
+	}
+}
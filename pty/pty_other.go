@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"runtime"
 	"sync"
+	"syscall"
 
 	"github.com/creack/pty"
 	"github.com/u-root/u-root/pkg/termios"
@@ -27,10 +28,12 @@ func newPty(opt ...Option) (retPTY *otherPty, err error) {
 		return nil, err
 	}
 	opty := &otherPty{
-		pty:  ptyFile,
-		tty:  ttyFile,
-		opts: opts,
-		name: ttyFile.Name(),
+		pty:    ptyFile,
+		tty:    ttyFile,
+		opts:   opts,
+		name:   ttyFile.Name(),
+		screen: newScreen(opts),
+		input:  newInputRecorder(opts),
 	}
 	defer func() {
 		if err != nil {
@@ -46,6 +49,19 @@ func newPty(opt ...Option) (retPTY *otherPty, err error) {
 			return nil, err
 		}
 	}
+	if opts.rows > 0 && opts.cols > 0 {
+		err = opty.control(opty.tty, func(fd uintptr) error {
+			return termios.SetWinSize(fd, &termios.Winsize{
+				Winsize: unix.Winsize{
+					Row: opts.rows,
+					Col: opts.cols,
+				},
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return opty, nil
 }
@@ -57,6 +73,17 @@ type otherPty struct {
 	pty, tty *os.File
 	opts     ptyOptions
 	name     string
+
+	// inputMutex serializes writes to the PTY input.
+	inputMutex sync.Mutex
+	// outputMutex serializes WriteOutput.
+	outputMutex sync.Mutex
+	// output gates reads of OutputReader for Pause and Resume.
+	output outputPauser
+	// screen models the output read from OutputReader for Snapshot.
+	screen *screen
+	// input records the input for WithInputRecorder.
+	input *inputRecorder
 }
 
 func (p *otherPty) control(tty *os.File, fn func(fd uintptr) error) (err error) {
@@ -95,12 +122,12 @@ func (p *otherPty) Name() string {
 func (p *otherPty) Input() ReadWriter {
 	return ReadWriter{
 		Reader: p.tty,
-		Writer: p.pty,
+		Writer: syncWriter{mutex: &p.inputMutex, w: p.input.writer(p.pty)},
 	}
 }
 
 func (p *otherPty) InputWriter() io.Writer {
-	return p.pty
+	return syncWriter{mutex: &p.inputMutex, w: p.input.writer(p.pty)}
 }
 
 func (p *otherPty) Output() ReadWriter {
@@ -111,11 +138,62 @@ func (p *otherPty) Output() ReadWriter {
 }
 
 func (p *otherPty) OutputReader() io.Reader {
-	return &ptmReader{p.pty}
+	return &ptmReader{p.output.reader(p.screen.reader(p.opts.outputReader(p.pty)))}
 }
 
-func (p *otherPty) Resize(height uint16, width uint16) error {
+func (p *otherPty) Pause() {
+	p.output.Pause()
+}
+
+func (p *otherPty) Resume() {
+	p.output.Resume()
+}
+
+func (p *otherPty) WriteOutput(b []byte) error {
+	p.outputMutex.Lock()
+	defer p.outputMutex.Unlock()
+
+	p.mutex.Lock()
+	closed, closeErr, tty := p.closed, p.err, p.tty
+	p.mutex.Unlock()
+	if closed {
+		return closeErr
+	}
+	if tty == nil {
+		// We closed our TTY file when the process started, so that reads
+		// observe the process hanging up.  Writes to the TTY go through the
+		// same line discipline as the process output, so open it only for as
+		// long as it takes to write.
+		f, err := os.OpenFile(p.name, os.O_WRONLY|unix.O_NOCTTY, 0)
+		if err != nil {
+			return xerrors.Errorf("open tty: %w", err)
+		}
+		defer f.Close()
+		tty = f
+	}
+	_, err := tty.Write(b)
+	return err
+}
+
+func (p *otherPty) ForegroundProcessGroup() (pgid int, err error) {
+	err = p.control(p.pty, func(fd uintptr) error {
+		pgid, err = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return pgid, nil
+}
+
+func (p *otherPty) SetForegroundProcessGroup(pgid int) error {
 	return p.control(p.pty, func(fd uintptr) error {
+		return unix.IoctlSetPointerInt(int(fd), unix.TIOCSPGRP, pgid)
+	})
+}
+
+func (p *otherPty) Resize(height uint16, width uint16) error {
+	err := p.control(p.pty, func(fd uintptr) error {
 		return termios.SetWinSize(fd, &termios.Winsize{
 			Winsize: unix.Winsize{
 				Row: height,
@@ -123,9 +201,21 @@ func (p *otherPty) Resize(height uint16, width uint16) error {
 			},
 		})
 	})
+	if err != nil {
+		return err
+	}
+	p.screen.resize(height, width)
+	return nil
+}
+
+func (p *otherPty) Snapshot() []byte {
+	return p.screen.snapshot()
 }
 
 func (p *otherPty) Close() error {
+	// Unblock any paused readers so that they observe the close.
+	p.output.Resume()
+
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
@@ -157,7 +247,7 @@ type otherProcess struct {
 	cmd *exec.Cmd
 
 	// cmdDone protects access to cmdErr: anything reading cmdErr should read from cmdDone first.
-	cmdDone chan any
+	cmdDone chan struct{}
 	cmdErr  error
 }
 
@@ -170,6 +260,44 @@ func (p *otherProcess) Kill() error {
 	return p.cmd.Process.Kill()
 }
 
+func (p *otherProcess) Done() <-chan struct{} {
+	return p.cmdDone
+}
+
+func (p *otherProcess) ExitError() error {
+	select {
+	case <-p.cmdDone:
+		return p.cmdErr
+	default:
+		return nil
+	}
+}
+
+func (p *otherProcess) Usage() (*Usage, error) {
+	select {
+	case <-p.cmdDone:
+	default:
+		return nil, nil
+	}
+	state := p.cmd.ProcessState
+	if state == nil {
+		// The command failed to be waited on, so there's no usage to report.
+		return nil, nil
+	}
+	usage := &Usage{
+		UserTime:   state.UserTime(),
+		SystemTime: state.SystemTime(),
+	}
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		// ru_maxrss is in bytes on macOS, and kilobytes elsewhere.
+		usage.MaxRSS = int64(rusage.Maxrss)
+		if runtime.GOOS != "darwin" {
+			usage.MaxRSS *= 1024
+		}
+	}
+	return usage, nil
+}
+
 func (p *otherProcess) waitInternal() {
 	// The GC can garbage collect the TTY FD before the command
 	// has finished running. See:
@@ -39,7 +39,9 @@ func newPty(opt ...Option) (*ptyWindows, error) {
 	}
 
 	pty := &ptyWindows{
-		opts: opts,
+		opts:   opts,
+		screen: newScreen(opts),
+		input:  newInputRecorder(opts),
 	}
 
 	var err error
@@ -55,8 +57,8 @@ func newPty(opt ...Option) (*ptyWindows, error) {
 	}
 
 	consoleSize := uintptr(80) + (uintptr(80) << 16)
-	if opts.sshReq != nil {
-		consoleSize = uintptr(opts.sshReq.Window.Width) + (uintptr(opts.sshReq.Window.Height) << 16)
+	if rows, cols, ok := opts.windowSize(); ok {
+		consoleSize = uintptr(cols) + (uintptr(rows) << 16)
 	}
 	ret, _, err := procCreatePseudoConsole.Call(
 		consoleSize,
@@ -83,6 +85,22 @@ type ptyWindows struct {
 	outputRead  *os.File
 	inputWrite  *os.File
 	inputRead   *os.File
+	// inputMutex serializes writes to inputWrite.
+	inputMutex sync.Mutex
+	// outputMutex serializes writes to mergedWrite, so that WriteOutput
+	// doesn't interleave with the output of the process.
+	outputMutex sync.Mutex
+	// mergedRead and mergedWrite carry the output of the process, merged with
+	// WriteOutput, to OutputReader once the process is started.  Only the
+	// process holds a handle to write to the console output.
+	mergedRead  *io.PipeReader
+	mergedWrite *io.PipeWriter
+	// output gates reads of OutputReader for Pause and Resume.
+	output outputPauser
+	// screen models the output read from OutputReader for Snapshot.
+	screen *screen
+	// input records the input for WithInputRecorder.
+	input *inputRecorder
 
 	closeMutex sync.Mutex
 	closed     bool
@@ -90,7 +108,7 @@ type ptyWindows struct {
 
 type windowsProcess struct {
 	// cmdDone protects access to cmdErr: anything reading cmdErr should read from cmdDone first.
-	cmdDone chan any
+	cmdDone chan struct{}
 	cmdErr  error
 	proc    *os.Process
 	pw      *ptyWindows
@@ -111,18 +129,89 @@ func (p *ptyWindows) Output() ReadWriter {
 }
 
 func (p *ptyWindows) OutputReader() io.Reader {
-	return p.outputRead
+	var r io.Reader = p.outputRead
+	if p.mergedRead != nil {
+		r = p.mergedRead
+	}
+	return p.output.reader(p.screen.reader(p.opts.outputReader(r)))
+}
+
+func (p *ptyWindows) Pause() {
+	p.output.Pause()
+}
+
+func (p *ptyWindows) Resume() {
+	p.output.Resume()
+}
+
+// WriteOutput writes to the console output before a process is started, and
+// to the merged output afterwards.  In the latter case, it blocks until the
+// output is read from OutputReader.
+func (p *ptyWindows) WriteOutput(b []byte) error {
+	p.outputMutex.Lock()
+	defer p.outputMutex.Unlock()
+
+	p.closeMutex.Lock()
+	closed, outputWrite := p.closed, p.outputWrite
+	p.closeMutex.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	if p.mergedWrite != nil {
+		_, err := p.mergedWrite.Write(b)
+		return err
+	}
+	_, err := outputWrite.Write(b)
+	return err
+}
+
+// mergeOutput starts copying the console output to mergedWrite, so that
+// WriteOutput can inject output once the process is started.
+func (p *ptyWindows) mergeOutput() {
+	p.mergedRead, p.mergedWrite = io.Pipe()
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := p.outputRead.Read(buf)
+			if n > 0 {
+				p.outputMutex.Lock()
+				_, werr := p.mergedWrite.Write(buf[:n])
+				p.outputMutex.Unlock()
+				if werr != nil {
+					// OutputReader is closed, but the console must still be
+					// drained so that closing it doesn't block.
+					_, _ = io.Copy(io.Discard, p.outputRead)
+					return
+				}
+			}
+			if err != nil {
+				_ = p.mergedWrite.CloseWithError(err)
+				return
+			}
+		}
+	}()
+}
+
+// ForegroundProcessGroup is not supported on Windows, which doesn't have
+// process groups in the Unix sense.
+func (p *ptyWindows) ForegroundProcessGroup() (int, error) {
+	return 0, ErrUnsupported
+}
+
+// SetForegroundProcessGroup is not supported on Windows.
+func (p *ptyWindows) SetForegroundProcessGroup(_ int) error {
+	return ErrUnsupported
 }
 
 func (p *ptyWindows) Input() ReadWriter {
 	return ReadWriter{
 		Reader: p.inputRead,
-		Writer: p.inputWrite,
+		Writer: syncWriter{mutex: &p.inputMutex, w: p.input.writer(p.inputWrite)},
 	}
 }
 
 func (p *ptyWindows) InputWriter() io.Writer {
-	return p.inputWrite
+	return syncWriter{mutex: &p.inputMutex, w: p.input.writer(p.inputWrite)}
 }
 
 func (p *ptyWindows) Resize(height uint16, width uint16) error {
@@ -140,9 +229,14 @@ func (p *ptyWindows) Resize(height uint16, width uint16) error {
 	if windows.Handle(ret) != windows.S_OK {
 		return err
 	}
+	p.screen.resize(height, width)
 	return nil
 }
 
+func (p *ptyWindows) Snapshot() []byte {
+	return p.screen.snapshot()
+}
+
 // closeConsoleNoLock closes the console handle, and sets it to
 // windows.InvalidHandle. It must be called with p.closeMutex held.
 func (p *ptyWindows) closeConsoleNoLock() error {
@@ -171,12 +265,21 @@ func (p *ptyWindows) closeConsoleNoLock() error {
 }
 
 func (p *ptyWindows) Close() error {
+	// Unblock any paused readers so that they observe the close.
+	p.output.Resume()
+
 	p.closeMutex.Lock()
 	defer p.closeMutex.Unlock()
 	if p.closed {
 		return nil
 	}
 
+	// Close the merged output first, so that copying the console output to it
+	// doesn't block the console from closing.
+	if p.mergedRead != nil {
+		_ = p.mergedRead.Close()
+	}
+
 	// Close the pseudo console, this will also terminate the process attached
 	// to this pty. If it was created via Start(), this also unblocks close of
 	// the readers below.
@@ -243,6 +346,24 @@ func (p *windowsProcess) Kill() error {
 	return p.proc.Kill()
 }
 
+func (p *windowsProcess) Done() <-chan struct{} {
+	return p.cmdDone
+}
+
+func (p *windowsProcess) ExitError() error {
+	select {
+	case <-p.cmdDone:
+		return p.cmdErr
+	default:
+		return nil
+	}
+}
+
+// Usage is not implemented on Windows.
+func (p *windowsProcess) Usage() (*Usage, error) {
+	return nil, ErrUnsupported
+}
+
 // killOnContext waits for the context to be done and kills the process, unless it exits on its own first.
 func (p *windowsProcess) killOnContext(ctx context.Context) {
 	select {
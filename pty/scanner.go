@@ -0,0 +1,223 @@
+package pty
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hinshun/vt10x"
+)
+
+const (
+	// scannerCols is the width of the terminal modeled by a Scanner.  It's
+	// wide so that long lines aren't wrapped, as a wrapped line would be
+	// returned as several tokens.
+	scannerCols = 1024
+	scannerRows = 24
+
+	defaultPromptDelay = 100 * time.Millisecond
+)
+
+// Scanner reads the output of a process running in a PTY and splits it into
+// logical tokens, as they would appear on a terminal: complete lines, and
+// prompts, i.e. partial lines after which the process stops writing, e.g. to
+// wait for input.  The output is rendered to a vt10x terminal model, so
+// carriage returns, cursor movement and erasing within a line are interpreted
+// rather than returned, and escape sequences such as colors are dropped.
+//
+// Like bufio.Scanner, successive calls to Scan step through the tokens, which
+// are then available from Text.  A prompt that is followed by more output on
+// the same line, such as the echo of the input it asked for, is returned
+// again once the line is complete.
+type Scanner struct {
+	term        vt10x.Terminal
+	chunks      chan []byte
+	closed      chan struct{}
+	closeOnce   sync.Once
+	promptDelay time.Duration
+
+	// readErr is set before chunks is closed.
+	readErr error
+
+	pending []byte
+	done    bool
+	text    string
+	prompt  bool
+	// promptShown is set once the current partial line has been returned as a
+	// prompt, so that it isn't returned again until there is more output.
+	promptShown bool
+}
+
+// NewScanner returns a Scanner that reads from pc.OutputReader().  The
+// Scanner reads in the background until the output ends or Close is called.
+func NewScanner(pc PTYCmd) *Scanner {
+	s := &Scanner{
+		term:        vt10x.New(vt10x.WithSize(scannerCols, scannerRows)),
+		chunks:      make(chan []byte),
+		closed:      make(chan struct{}),
+		promptDelay: defaultPromptDelay,
+	}
+	go s.read(pc.OutputReader())
+	return s
+}
+
+// SetPromptDelay sets how long the output must pause after a partial line for
+// the line to be returned as a prompt.  The default is 100ms.
+func (s *Scanner) SetPromptDelay(d time.Duration) {
+	s.promptDelay = d
+}
+
+func (s *Scanner) read(r io.Reader) {
+	defer close(s.chunks)
+	for {
+		buf := make([]byte, 1024)
+		n, err := r.Read(buf)
+		if n > 0 {
+			select {
+			case s.chunks <- buf[:n]:
+			case <-s.closed:
+				return
+			}
+		}
+		if err != nil {
+			s.readErr = err
+			return
+		}
+	}
+}
+
+// Scan advances the Scanner to the next token, which will then be available
+// through Text.  It blocks until a line is complete, or a partial line has
+// been followed by no output for the prompt delay.  It returns false when the
+// output ends, after returning any final partial line, or when Close is
+// called.  Err then returns the error that ended the output, if any.
+func (s *Scanner) Scan() bool {
+	for {
+		if i := bytes.IndexByte(s.pending, '\n'); i >= 0 {
+			_, _ = s.term.Write(s.pending[:i])
+			s.setToken(s.line(), false)
+			// The line feed moves the cursor to the next line, possibly
+			// scrolling the screen, so write it only once the line is read.
+			_, _ = s.term.Write([]byte{'\n'})
+			s.pending = s.pending[i+1:]
+			s.promptShown = false
+			return true
+		}
+		// An incomplete UTF-8 sequence at the end of the output is kept until
+		// the rest of it is read.
+		if n := completeUTF8(s.pending); n > 0 {
+			_, _ = s.term.Write(s.pending[:n])
+			s.pending = s.pending[n:]
+			s.promptShown = false
+		}
+
+		line := s.line()
+		if s.done {
+			s.pending = nil
+			if line == "" || s.promptShown {
+				return false
+			}
+			s.setToken(line, false)
+			s.promptShown = true
+			return true
+		}
+
+		var (
+			timer   *time.Timer
+			timeout <-chan time.Time
+		)
+		if line != "" && !s.promptShown {
+			timer = time.NewTimer(s.promptDelay)
+			timeout = timer.C
+		}
+		select {
+		case chunk, ok := <-s.chunks:
+			if timer != nil {
+				timer.Stop()
+			}
+			if !ok {
+				s.done = true
+				continue
+			}
+			s.pending = append(s.pending, chunk...)
+		case <-timeout:
+			s.setToken(line, true)
+			s.promptShown = true
+			return true
+		case <-s.closed:
+			if timer != nil {
+				timer.Stop()
+			}
+			return false
+		}
+	}
+}
+
+// Text returns the most recent token generated by a call to Scan, without
+// trailing blanks.
+func (s *Scanner) Text() string {
+	return s.text
+}
+
+// Prompt reports whether the most recent token is a prompt, i.e. a partial
+// line after which the process paused, rather than a complete line.
+func (s *Scanner) Prompt() bool {
+	return s.prompt
+}
+
+// Err returns the error that ended the output, if it wasn't io.EOF.  It must
+// only be called after Scan returns false.
+func (s *Scanner) Err() error {
+	if !s.done || s.readErr == io.EOF {
+		return nil
+	}
+	return s.readErr
+}
+
+// Close stops the Scanner reading in the background.  It doesn't close the
+// PTY.
+func (s *Scanner) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+	return nil
+}
+
+func (s *Scanner) setToken(text string, prompt bool) {
+	s.text = text
+	s.prompt = prompt
+}
+
+// completeUTF8 returns the length of p without any incomplete UTF-8 sequence
+// at its end.
+func completeUTF8(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if utf8.FullRune(p[i:]) {
+				return len(p)
+			}
+			return i
+		}
+	}
+	return len(p)
+}
+
+// line returns the contents of the line the cursor is on.
+func (s *Scanner) line() string {
+	s.term.Lock()
+	defer s.term.Unlock()
+
+	y := s.term.Cursor().Y
+	line := make([]rune, 0, scannerCols)
+	for x := 0; x < scannerCols; x++ {
+		c := s.term.Cell(x, y).Char
+		if c == 0 {
+			c = ' '
+		}
+		line = append(line, c)
+	}
+	return strings.TrimRight(string(line), " ")
+}
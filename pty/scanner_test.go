@@ -0,0 +1,100 @@
+package pty_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/coder/coder/pty"
+	"github.com/coder/coder/testutil"
+)
+
+// outputPTY is a PTYCmd that only has output, read from r.
+type outputPTY struct {
+	pty.PTYCmd
+	r io.Reader
+}
+
+func (o outputPTY) OutputReader() io.Reader {
+	return o.r
+}
+
+func TestScanner(t *testing.T) {
+	t.Parallel()
+
+	t.Run("EscapeSequences", func(t *testing.T) {
+		t.Parallel()
+
+		output := "\x1b]0;window title\a\x1b[1;32mok\x1b[0m\r\n" +
+			"progress 10%\rprogress 100%\r\n" +
+			"downloading...\r\x1b[Kdownloaded\r\n" +
+			"abc\x1b[2DX\x1b[5G!\r\n" +
+			"h\xc3\xa9llo\r\n" +
+			"no final newline"
+		s := pty.NewScanner(outputPTY{r: strings.NewReader(output)})
+		defer s.Close()
+
+		var got []string
+		for s.Scan() {
+			require.False(t, s.Prompt())
+			got = append(got, s.Text())
+		}
+		require.NoError(t, s.Err())
+		require.Equal(t, []string{"ok", "progress 100%", "downloaded", "aXc !", "héllo", "no final newline"}, got)
+	})
+
+	t.Run("Prompt", func(t *testing.T) {
+		t.Parallel()
+
+		r, w := io.Pipe()
+		s := pty.NewScanner(outputPTY{r: r})
+		defer s.Close()
+		s.SetPromptDelay(10 * time.Millisecond)
+
+		go func() {
+			_, _ = w.Write([]byte("Welcome!\r\n\x1b[1mName:\x1b[0m "))
+		}()
+		require.True(t, s.Scan())
+		require.Equal(t, "Welcome!", s.Text())
+		require.False(t, s.Prompt())
+		require.True(t, s.Scan())
+		require.Equal(t, "Name:", s.Text())
+		require.True(t, s.Prompt())
+
+		// The echoed input completes the line.
+		go func() {
+			_, _ = w.Write([]byte("bob\r\nHello, bob\r\n"))
+			_ = w.Close()
+		}()
+		var got []string
+		for s.Scan() {
+			require.False(t, s.Prompt())
+			got = append(got, s.Text())
+		}
+		require.NoError(t, s.Err())
+		require.Equal(t, []string{"Name: bob", "Hello, bob"}, got)
+	})
+
+	t.Run("SplitRune", func(t *testing.T) {
+		t.Parallel()
+
+		r, w := io.Pipe()
+		s := pty.NewScanner(outputPTY{r: r})
+		defer s.Close()
+		// The partial line must not be taken for a prompt.
+		s.SetPromptDelay(testutil.WaitLong)
+
+		go func() {
+			_, _ = w.Write([]byte("caf\xc3"))
+			_, _ = w.Write([]byte("\xa9\r\n"))
+			_ = w.Close()
+		}()
+		require.True(t, s.Scan())
+		require.Equal(t, "café", s.Text())
+		require.False(t, s.Scan())
+		require.NoError(t, s.Err())
+	})
+}
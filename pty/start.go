@@ -2,14 +2,25 @@ package pty
 
 import (
 	"context"
+	"io"
 	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/xerrors"
 )
 
+// ErrMaxRuntimeExceeded is returned by Process.Wait when the command was
+// killed for running longer than allowed by WithMaxRuntime.
+var ErrMaxRuntimeExceeded = xerrors.New("pty: max runtime exceeded")
+
 // StartOption represents a configuration option passed to Start.
 type StartOption func(*startOptions)
 
 type startOptions struct {
-	ptyOpts []Option
+	ptyOpts    []Option
+	maxRuntime time.Duration
+	newSession bool
 }
 
 // WithPTYOption applies the given options to the underlying PTY.
@@ -19,6 +30,31 @@ func WithPTYOption(opts ...Option) StartOption {
 	}
 }
 
+// WithMaxRuntime kills the command if it is still running after d, in which
+// case Wait returns ErrMaxRuntimeExceeded. Unlike canceling the command's
+// context, this allows callers to tell a runaway command apart from one that
+// was stopped on purpose.
+func WithMaxRuntime(d time.Duration) StartOption {
+	return func(o *startOptions) {
+		o.maxRuntime = d
+	}
+}
+
+// WithNewSession detaches the command from the parent's session, e.g. for
+// daemon-like processes that should survive the parent.  On Unix, the command
+// becomes the leader of a new session (setsid), whose controlling terminal is
+// the PTY rather than the parent's terminal.  Commands started by Start always
+// get a new session on Unix, since that is required to make the PTY their
+// controlling terminal, so the option only documents the intent there.  On
+// Windows, which has no sessions in this sense, the command is started in a
+// new process group instead, so that console control events sent to the
+// parent's group don't reach it.
+func WithNewSession() StartOption {
+	return func(o *startOptions) {
+		o.newSession = true
+	}
+}
+
 // Cmd is a drop-in replacement for exec.Cmd with most of the same API, but
 // it exposes the context.Context to our PTY code so that we can still kill the
 // process when the Context expires.  This is required because on Windows, we don't
@@ -56,5 +92,78 @@ func (c *Cmd) AsExec() *exec.Cmd {
 // Start the command in a TTY.  The calling code must not use cmd after passing it to the PTY, and
 // instead rely on the returned Process to manage the command/process.
 func Start(cmd *Cmd, opt ...StartOption) (PTYCmd, Process, error) {
-	return startPty(cmd, opt...)
+	var opts startOptions
+	for _, o := range opt {
+		o(&opts)
+	}
+	ptty, process, err := startPty(cmd, opt...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.maxRuntime > 0 {
+		process = limitRuntime(process, opts.maxRuntime)
+	}
+	return ptty, process, nil
+}
+
+// StartRWC starts the command in a TTY like Start, but returns the pseudo-TTY as
+// a single io.ReadWriteCloser: reads come from the process output, writes go
+// to the process input, and Close tears down the pseudo-TTY.  This is
+// convenient for bridging the TTY to a stream, e.g. a websocket.
+func StartRWC(cmd *Cmd, opt ...StartOption) (io.ReadWriteCloser, Process, error) {
+	ptty, process, err := Start(cmd, opt...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &readWriteCloser{
+		Reader: ptty.OutputReader(),
+		Writer: ptty.InputWriter(),
+		Closer: ptty,
+	}, process, nil
+}
+
+type readWriteCloser struct {
+	io.Reader
+	io.Writer
+	io.Closer
+}
+
+// maxRuntimeProcess kills the wrapped Process once it exceeds its max runtime,
+// and reports ErrMaxRuntimeExceeded in that case.
+type maxRuntimeProcess struct {
+	Process
+	exceeded atomic.Bool
+}
+
+func limitRuntime(p Process, d time.Duration) Process {
+	mp := &maxRuntimeProcess{Process: p}
+	timer := time.AfterFunc(d, func() {
+		select {
+		case <-p.Done():
+			return
+		default:
+		}
+		mp.exceeded.Store(true)
+		_ = p.Kill()
+	})
+	go func() {
+		<-p.Done()
+		timer.Stop()
+	}()
+	return mp
+}
+
+func (p *maxRuntimeProcess) Wait() error {
+	return p.exitError(p.Process.Wait())
+}
+
+func (p *maxRuntimeProcess) ExitError() error {
+	return p.exitError(p.Process.ExitError())
+}
+
+func (p *maxRuntimeProcess) exitError(err error) error {
+	if err != nil && p.exceeded.Load() {
+		return ErrMaxRuntimeExceeded
+	}
+	return err
 }
@@ -35,6 +35,8 @@ func startPty(cmdPty *Cmd, opt ...StartOption) (retPTY *otherPty, proc Process,
 	}
 	cmdExec := cmdPty.AsExec()
 
+	// Setsid is required for Setctty, so the command is always the leader of
+	// a new session, regardless of WithNewSession.
 	cmdExec.SysProcAttr = &syscall.SysProcAttr{
 		Setsid:  true,
 		Setctty: true,
@@ -76,7 +78,7 @@ func startPty(cmdPty *Cmd, opt ...StartOption) (retPTY *otherPty, proc Process,
 	oProcess := &otherProcess{
 		pty:     opty.pty,
 		cmd:     cmdExec,
-		cmdDone: make(chan any),
+		cmdDone: make(chan struct{}),
 	}
 	go oProcess.waitInternal()
 	return opty, oProcess, nil
@@ -3,17 +3,23 @@
 package pty_test
 
 import (
+	"context"
+	"fmt"
 	"os/exec"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gliderlabs/ssh"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
+	"golang.org/x/sys/unix"
 	"golang.org/x/xerrors"
 
 	"github.com/coder/coder/pty"
 	"github.com/coder/coder/pty/ptytest"
+	"github.com/coder/coder/testutil"
 )
 
 func TestMain(m *testing.M) {
@@ -61,6 +67,215 @@ func TestStart(t *testing.T) {
 		err = pty.Close()
 		require.NoError(t, err)
 	})
+
+	t.Run("Size", func(t *testing.T) {
+		t.Parallel()
+		ptty, ps := ptytest.Start(t, pty.Command("stty", "size"), pty.WithPTYOption(pty.WithSize(40, 100)))
+		ptty.ExpectMatch("40 100")
+		err := ps.Wait()
+		require.NoError(t, err)
+		err = ptty.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("SizeOverridesSSHRequest", func(t *testing.T) {
+		t.Parallel()
+		opts := pty.WithPTYOption(
+			pty.WithSSHRequest(ssh.Pty{
+				Window: ssh.Window{
+					Width:  80,
+					Height: 24,
+				},
+			}),
+			pty.WithSize(50, 132),
+		)
+		ptty, ps := ptytest.Start(t, pty.Command("stty", "size"), opts)
+		ptty.ExpectMatch("50 132")
+		err := ps.Wait()
+		require.NoError(t, err)
+		err = ptty.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("Resize", func(t *testing.T) {
+		t.Parallel()
+		// Print a line wider than the resized PTY once the test has resized it.
+		script := `stty size; read -r _; stty size; echo abcdefghijklmnopqrstuvwxyz0123`
+		ptty, ps := ptytest.Start(t, pty.Command("sh", "-c", script),
+			pty.WithPTYOption(pty.WithSize(24, 80), pty.WithSnapshot()))
+		ptty.ExpectMatch("24 80")
+		err := ptty.Resize(10, 20)
+		require.NoError(t, err)
+		_, err = ptty.InputWriter().Write([]byte("\r"))
+		require.NoError(t, err)
+		ptty.ExpectMatch("10 20")
+		ptty.ExpectMatch("0123")
+		err = ps.Wait()
+		require.NoError(t, err)
+
+		// The screen model is resized along with the PTY, so the line wraps
+		// at the new width.
+		lines := strings.Split(string(ptty.Snapshot()), "\r\n")
+		require.Contains(t, lines, "10 20")
+		require.Contains(t, lines, "abcdefghijklmnopqrst")
+		require.Contains(t, lines, "uvwxyz0123")
+
+		err = ptty.Close()
+		require.NoError(t, err)
+		err = ptty.Resize(24, 80)
+		require.ErrorIs(t, err, pty.ErrClosed)
+	})
+
+	t.Run("ReadWriteCloser", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.WaitShort)
+		defer cancel()
+
+		rwc, ps, err := pty.StartRWC(pty.CommandContext(ctx, "cat"))
+		require.NoError(t, err)
+		_, err = rwc.Write([]byte("hello\n"))
+		require.NoError(t, err)
+		err = readUntil(ctx, t, "hello", rwc)
+		require.NoError(t, err)
+		err = rwc.Close()
+		require.NoError(t, err)
+		_ = ps.Wait()
+	})
+
+	t.Run("MaxRuntime", func(t *testing.T) {
+		t.Parallel()
+		ptty, ps := ptytest.Start(t, pty.Command("sleep", "30"), pty.WithMaxRuntime(100*time.Millisecond))
+		err := ps.Wait()
+		require.ErrorIs(t, err, pty.ErrMaxRuntimeExceeded)
+		require.ErrorIs(t, ps.ExitError(), pty.ErrMaxRuntimeExceeded)
+		err = ptty.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("WithinMaxRuntime", func(t *testing.T) {
+		t.Parallel()
+		ptty, ps := ptytest.Start(t, pty.Command("echo", "test"), pty.WithMaxRuntime(testutil.WaitLong))
+		ptty.ExpectMatch("test")
+		err := ps.Wait()
+		require.NoError(t, err)
+		err = ptty.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("WriteOutput", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.WaitShort)
+		defer cancel()
+
+		ptty, ps := ptytest.Start(t, pty.CommandContext(ctx, "cat"))
+		_, err := ptty.InputWriter().Write([]byte("before\n"))
+		require.NoError(t, err)
+		ptty.ExpectMatch("before")
+
+		// The notice is delivered between the output of the process, and
+		// doesn't reach its input, so cat doesn't repeat it.
+		err = ptty.WriteOutput([]byte("server restarting\n"))
+		require.NoError(t, err)
+		_, err = ptty.InputWriter().Write([]byte("after\n"))
+		require.NoError(t, err)
+		ptty.ExpectNoMatchBefore(ctx, "after", "server restarting")
+		ptty.ExpectNoMatchBefore(ctx, "server restarting", "after")
+
+		// Send EOF so cat exits before the PTY is closed.
+		_, err = ptty.InputWriter().Write([]byte{4})
+		require.NoError(t, err)
+		err = ps.Wait()
+		require.NoError(t, err)
+		err = ptty.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("ForegroundProcessGroup", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.WaitShort)
+		defer cancel()
+
+		ptty, ps := ptytest.Start(t, pty.CommandContext(ctx, "sh"))
+		// The shell leads a new session on the TTY, so it starts out as the
+		// foreground process group, whose ID is its PID.
+		pgid, err := ptty.ForegroundProcessGroup()
+		require.NoError(t, err)
+		require.Positive(t, pgid)
+		_, err = ptty.InputWriter().Write([]byte("echo pid=$$\n"))
+		require.NoError(t, err)
+		ptty.ExpectMatch(fmt.Sprintf("pid=%d", pgid))
+
+		_, err = ptty.InputWriter().Write([]byte("exit\n"))
+		require.NoError(t, err)
+		err = ps.Wait()
+		require.NoError(t, err)
+		err = ptty.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		t.Parallel()
+		// Spin for a while so that some user CPU time is accounted.
+		ptty, ps := ptytest.Start(t, pty.Command("sh", "-c", "i=0; while [ $i -lt 200000 ]; do i=$((i+1)); done"))
+		usage, err := ps.Usage()
+		require.NoError(t, err)
+		require.Nil(t, usage, "usage before exit")
+
+		err = ps.Wait()
+		require.NoError(t, err)
+		usage, err = ps.Usage()
+		require.NoError(t, err)
+		require.NotNil(t, usage)
+		require.Positive(t, usage.UserTime)
+		require.Positive(t, usage.MaxRSS)
+		err = ptty.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("NewSession", func(t *testing.T) {
+		t.Parallel()
+		ptty, ps := ptytest.Start(t, pty.Command("sh", "-c", `echo "session $(ps -o sid= -p $$ | tr -d ' ') pid $$"`), pty.WithNewSession())
+		ctx := testutil.Context(t, testutil.WaitShort)
+		line := ptty.ReadLine(ctx)
+		var sid, pid int
+		_, err := fmt.Sscanf(line, "session %d pid %d", &sid, &pid)
+		require.NoError(t, err, "parse %q", line)
+		parentSID, err := unix.Getsid(0)
+		require.NoError(t, err)
+		assert.NotEqual(t, parentSID, sid)
+		assert.Equal(t, pid, sid, "command is the session leader")
+		err = ps.Wait()
+		require.NoError(t, err)
+		err = ptty.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		t.Parallel()
+		opts := pty.WithPTYOption(pty.WithSnapshot(), pty.WithSSHRequest(ssh.Pty{
+			Window: ssh.Window{
+				Width:  20,
+				Height: 5,
+			},
+		}))
+		// Clear the screen, draw on every row out of order, then overwrite
+		// part of the first row.
+		draw := `printf '\033[2J\033[5;1Hbottom\033[3;8Hmiddle\033[1;1Hoverwritten top\033[1;1H\033[Ktop\033[2;20Hx\033[4;1Hend'`
+		pty, ps := ptytest.Start(t, pty.Command("sh", "-c", draw), opts)
+		pty.ExpectMatch("end")
+		err := ps.Wait()
+		require.NoError(t, err)
+		want := strings.Join([]string{
+			"top",
+			"                   x",
+			"       middle",
+			"end",
+			"bottom",
+		}, "\r\n")
+		require.Equal(t, want, string(pty.Snapshot()))
+		err = pty.Close()
+		require.NoError(t, err)
+	})
 }
 
 // these constants/vars are used by Test_Start_copy
@@ -90,3 +305,9 @@ done
 const cmdSleep = "sleep"
 
 var argSleep = []string{"30"}
+
+// these constants/vars are used by Test_Start_done
+
+const cmdExit = "sh"
+
+var argExit = []string{"-c", "exit 3"}
@@ -5,7 +5,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os/exec"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -42,16 +44,11 @@ func Test_Start_copy(t *testing.T) {
 	}
 	assert.Contains(t, b.String(), "test")
 
-	cmdDone := make(chan error, 1)
-	go func() {
-		cmdDone <- cmd.Wait()
-	}()
-
 	select {
-	case err := <-cmdDone:
-		require.NoError(t, err)
+	case <-cmd.Done():
+		require.NoError(t, cmd.ExitError())
 	case <-ctx.Done():
-		t.Error("cmd.Wait() timed out")
+		t.Error("cmd.Done() timed out")
 	}
 }
 
@@ -93,24 +90,80 @@ func Test_Start_truncation(t *testing.T) {
 		assert.NoError(t, err)
 	}()
 
-	cmdDone := make(chan error, 1)
-	go func() {
-		cmdDone <- cmd.Wait()
-	}()
+	select {
+	case <-cmd.Done():
+		require.NoError(t, cmd.ExitError())
+	case <-ctx.Done():
+		t.Fatal("cmd.Done() timed out")
+	}
 
 	select {
-	case err := <-cmdDone:
-		require.NoError(t, err)
+	case <-readDone:
+		// OK!
 	case <-ctx.Done():
-		t.Fatal("cmd.Wait() timed out")
+		t.Fatal("read timed out")
 	}
+}
+
+// Test_Start_pause tests that pausing the output delivers nothing to readers,
+// and that resuming delivers all buffered output in order.
+func Test_Start_pause(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testutil.WaitSuperLong)
+	defer cancel()
+
+	pc, cmd, err := pty.Start(pty.CommandContext(ctx, cmdCount, argCount...))
+	require.NoError(t, err)
+	pc.Pause()
+
+	var read atomic.Int64
+	r := &countingReader{r: pc.OutputReader(), n: &read}
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		for n := 1; n <= countEnd; n++ {
+			want := fmt.Sprintf("%d", n)
+			err := readUntil(ctx, t, want, r)
+			assert.NoError(t, err, "want: %s", want)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Give the process time to produce output; none of it may be delivered
+	// while paused.
+	time.Sleep(testutil.IntervalSlow)
+	require.Zero(t, read.Load(), "output delivered while paused")
 
+	pc.Resume()
 	select {
 	case <-readDone:
 		// OK!
 	case <-ctx.Done():
 		t.Fatal("read timed out")
 	}
+
+	select {
+	case <-cmd.Done():
+		require.NoError(t, cmd.ExitError())
+	case <-ctx.Done():
+		t.Fatal("cmd.Done() timed out")
+	}
+	require.NoError(t, pc.Close())
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
 }
 
 // Test_Start_cancel_context tests that we can cancel the command context and kill the process.
@@ -128,20 +181,68 @@ func Test_Start_cancel_context(t *testing.T) {
 	}()
 	cmdCancel()
 
-	cmdDone := make(chan struct{})
-	go func() {
-		defer close(cmdDone)
-		_ = cmd.Wait()
-	}()
-
 	select {
-	case <-cmdDone:
+	case <-cmd.Done():
 		// OK!
 	case <-ctx.Done():
-		t.Error("cmd.Wait() timed out")
+		t.Error("cmd.Done() timed out")
 	}
 }
 
+// Test_Start_done tests that Done() is closed when the command exits, and that
+// ExitError() then reports how it exited.
+func Test_Start_done(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Success", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.WaitShort)
+		defer cancel()
+
+		pc, cmd, err := pty.Start(pty.CommandContext(ctx, cmdEcho, argEcho...))
+		require.NoError(t, err)
+		defer func() {
+			_ = pc.Close()
+		}()
+		go func() {
+			_, _ = io.Copy(io.Discard, pc.OutputReader())
+		}()
+
+		select {
+		case <-cmd.Done():
+			require.NoError(t, cmd.ExitError())
+			require.NoError(t, cmd.Wait())
+		case <-ctx.Done():
+			t.Fatal("cmd.Done() timed out")
+		}
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), testutil.WaitShort)
+		defer cancel()
+
+		pc, cmd, err := pty.Start(pty.CommandContext(ctx, cmdExit, argExit...))
+		require.NoError(t, err)
+		defer func() {
+			_ = pc.Close()
+		}()
+		go func() {
+			_, _ = io.Copy(io.Discard, pc.OutputReader())
+		}()
+
+		select {
+		case <-cmd.Done():
+			var exitErr *exec.ExitError
+			require.ErrorAs(t, cmd.ExitError(), &exitErr)
+			require.Equal(t, 3, exitErr.ExitCode())
+			require.Equal(t, cmd.ExitError(), cmd.Wait())
+		case <-ctx.Done():
+			t.Fatal("cmd.Done() timed out")
+		}
+	})
+}
+
 // readUntil reads one byte at a time until we either see the string we want, or the context expires
 func readUntil(ctx context.Context, t *testing.T, want string, r io.Reader) error {
 	// output can contain virtual terminal sequences, so we need to parse these
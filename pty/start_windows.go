@@ -75,6 +75,11 @@ func startPty(cmd *Cmd, opt ...StartOption) (_ PTYCmd, _ Process, retErr error)
 	startupInfo.ProcThreadAttributeList = attrs.List()
 	startupInfo.StartupInfo.Flags = windows.STARTF_USESTDHANDLES
 	startupInfo.StartupInfo.Cb = uint32(unsafe.Sizeof(*startupInfo))
+	// https://docs.microsoft.com/en-us/windows/win32/procthread/process-creation-flags#create_unicode_environment
+	flags := uint32(windows.CREATE_UNICODE_ENVIRONMENT | windows.EXTENDED_STARTUPINFO_PRESENT)
+	if opts.newSession {
+		flags |= windows.CREATE_NEW_PROCESS_GROUP
+	}
 	var processInfo windows.ProcessInformation
 	err = windows.CreateProcess(
 		pathPtr,
@@ -82,8 +87,7 @@ func startPty(cmd *Cmd, opt ...StartOption) (_ PTYCmd, _ Process, retErr error)
 		nil,
 		nil,
 		false,
-		// https://docs.microsoft.com/en-us/windows/win32/procthread/process-creation-flags#create_unicode_environment
-		windows.CREATE_UNICODE_ENVIRONMENT|windows.EXTENDED_STARTUPINFO_PRESENT,
+		flags,
 		createEnvBlock(addCriticalEnv(dedupEnvCase(true, cmd.Env))),
 		dirPtr,
 		&startupInfo.StartupInfo,
@@ -100,7 +104,7 @@ func startPty(cmd *Cmd, opt ...StartOption) (_ PTYCmd, _ Process, retErr error)
 		return nil, nil, xerrors.Errorf("find process %d: %w", processInfo.ProcessId, err)
 	}
 	wp := &windowsProcess{
-		cmdDone: make(chan any),
+		cmdDone: make(chan struct{}),
 		proc:    process,
 		pw:      winPty,
 	}
@@ -128,6 +132,7 @@ func startPty(cmd *Cmd, opt ...StartOption) (_ PTYCmd, _ Process, retErr error)
 	if errI != nil {
 		return nil, nil, errI
 	}
+	winPty.mergeOutput()
 	go wp.waitInternal()
 	if cmd.Context != nil {
 		go wp.killOnContext(cmd.Context)
@@ -38,6 +38,8 @@ func TestStart(t *testing.T) {
 		require.NoError(t, err)
 		err = ptty.Close()
 		require.NoError(t, err)
+		err = ptty.Resize(100, 50)
+		require.ErrorIs(t, err, pty.ErrClosed)
 	})
 	t.Run("Kill", func(t *testing.T) {
 		t.Parallel()
@@ -51,6 +53,18 @@ func TestStart(t *testing.T) {
 		err = ptty.Close()
 		require.NoError(t, err)
 	})
+	t.Run("WriteOutput", func(t *testing.T) {
+		t.Parallel()
+		ptty, ps := ptytest.Start(t, pty.Command("cmd.exe"))
+		err := ptty.WriteOutput([]byte("server restarting\r\n"))
+		require.NoError(t, err)
+		ptty.ExpectMatch("server restarting")
+		err = ps.Kill()
+		assert.NoError(t, err)
+		_ = ps.Wait()
+		err = ptty.Close()
+		require.NoError(t, err)
+	})
 }
 
 // these constants/vars are used by Test_Start_copy
@@ -73,3 +87,9 @@ var argCount = []string{"/c", fmt.Sprintf("for /L %%n in (1,1,%d) do @echo %%n",
 const cmdSleep = "cmd.exe"
 
 var argSleep = []string{"/c", "timeout", "/t", "30"}
+
+// these constants/vars are used by Test_Start_done
+
+const cmdExit = "cmd.exe"
+
+var argExit = []string{"/c", "exit", "3"}
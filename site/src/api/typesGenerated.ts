@@ -162,6 +162,7 @@ export interface ConvertLoginRequest {
 export interface CreateFirstUserRequest {
   readonly email: string
   readonly username: string
+  readonly name: string
   readonly password: string
   readonly trial: boolean
 }
@@ -221,6 +222,7 @@ export interface CreateTemplateVersionRequest {
   readonly provisioner: ProvisionerType
   readonly tags: Record<string, string>
   readonly user_variable_values?: VariableValue[]
+  readonly throwaway?: boolean
 }
 
 // From codersdk/audit.go
@@ -1176,6 +1178,7 @@ export interface UploadResponse {
 export interface User {
   readonly id: string
   readonly username: string
+  readonly name: string
   readonly email: string
   readonly created_at: string
   readonly last_seen_at: string